@@ -47,7 +47,7 @@ type Engine struct {
 	mu  sync.RWMutex
 
 	bounds  image.Rectangle
-	sources []detector.Source
+	sources detector.Snapshotter
 	keys    *keystate.Tracker
 	mover   mouse.Mover
 
@@ -57,7 +57,7 @@ type Engine struct {
 	isActive  bool
 }
 
-func New(cfg Config, sources []detector.Source, bounds image.Rectangle, mover mouse.Mover) *Engine {
+func New(cfg Config, sources detector.Snapshotter, bounds image.Rectangle, mover mouse.Mover) *Engine {
 	if mover == nil {
 		mover = mouse.LocalMover{}
 	}
@@ -202,23 +202,9 @@ func (e *Engine) Tick() {
 		return
 	}
 
-	// 多来源策略：取全链路延迟最低且新鲜的来源。
-	// 本地延迟=推理耗时；远程延迟=帧发出到收到结果（网络+手机推理）。
-	var results []detector.Result
-	var bestLatency time.Duration
-	bestSet := false
-	for _, src := range e.sources {
-		srcResults, latency, fresh := src.Snapshot()
-		if !fresh || len(srcResults) == 0 {
-			continue
-		}
-		if !bestSet || latency < bestLatency {
-			results = srcResults
-			bestLatency = latency
-			bestSet = true
-		}
-	}
-	if !bestSet {
+	// 多来源策略见 detector.Sources.Snapshot：取全链路延迟最低且新鲜的来源。
+	results, _, fresh := e.sources.Snapshot()
+	if !fresh {
 		e.mu.Lock()
 		e.isActive = false
 		e.targetBox = image.Rectangle{}
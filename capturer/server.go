@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"image"
-	"image/color"
 	"runtime"
 	"sync"
 	"time"
@@ -21,7 +20,8 @@ type Config struct {
 	DisableOpenCV bool
 }
 
-var imageToMatWarnOnce sync.Once
+// ErrNoFrame 尚未采集到画面
+var ErrNoFrame = errors.New("no frame captured yet")
 
 type Stats struct {
 	FPS        float64
@@ -32,8 +32,11 @@ type Stats struct {
 
 type Frame struct {
 	rgba *image.RGBA
-	mat  gocv.Mat
-	id   uint64
+	// mat 采集源直接提供的 BGR 画面（如 OBS），其余采集源为空，按需从 rgba 转换
+	mat gocv.Mat
+	id  uint64
+	// matId mat 所属的帧号，与 id 不同时 mat 已过时，改从 rgba 转换
+	matId uint64
 }
 
 type Server struct {
@@ -46,7 +49,6 @@ type Server struct {
 
 	stats   Stats
 	onFrame func()
-	cvtCode gocv.ColorConversionCode
 	cfg     Config
 
 	targetFps    int
@@ -55,18 +57,13 @@ type Server struct {
 
 	noOpenCV bool
 
-	frameMatRGBAInter gocv.Mat
-
 	diagGetImage   *timing.Diag
-	diagImageToMat *timing.Diag
+	diagProvideMat *timing.Diag
 }
 
-func NewServer(src Source, cfg Config, mode gocv.IMReadFlag, onFrame func()) *Server {
+// NewServer 画面以 RGBA 保存，OpenCV 使用方通过 CropMat 只转换需要的区域。
+func NewServer(src Source, cfg Config, onFrame func()) *Server {
 	bounds := src.Bounds()
-	cvtCode := gocv.ColorRGBAToBGR
-	if mode == gocv.IMReadGrayScale {
-		cvtCode = gocv.ColorRGBAToGray
-	}
 	if cfg.MinFps <= 0 {
 		cfg.MinFps = 1
 	}
@@ -75,17 +72,15 @@ func NewServer(src Source, cfg Config, mode gocv.IMReadFlag, onFrame func()) *Se
 		fp:         fps.NewCounter(time.Second),
 		screenRGBA: image.NewRGBA(bounds),
 		onFrame:    onFrame,
-		cvtCode:    cvtCode,
 		cfg:        cfg,
 		targetFps:  cfg.MinFps,
 		noOpenCV:   cfg.DisableOpenCV,
 
 		diagGetImage:   timing.NewDiag("GetImage"),
-		diagImageToMat: timing.NewDiag("ImageToMat"),
+		diagProvideMat: timing.NewDiag("ProvideMat"),
 	}
 	if !cfg.DisableOpenCV {
 		s.frame.mat = gocv.NewMat()
-		s.frameMatRGBAInter = gocv.NewMatWithSize(bounds.Dy(), bounds.Dx(), gocv.MatTypeCV8UC4)
 	}
 	return s
 }
@@ -124,21 +119,74 @@ func (s *Server) CloneRgba() *image.RGBA {
 	return cp
 }
 
-func (s *Server) ReadMat() gocv.Mat {
+// CropRgba 复制最新一帧中 r 区域（与 Bounds 同一坐标系），r 超出画面的部分被裁掉；
+// 返回图像的 Bounds 从 (0, 0) 开始。
+func (s *Server) CropRgba(r image.Rectangle) (*image.RGBA, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.frame.mat
+	rgba := s.frame.rgba
+	if rgba == nil {
+		return nil, ErrNoFrame
+	}
+	if r = r.Intersect(rgba.Bounds()); r.Empty() {
+		return nil, fmt.Errorf("crop outside frame %v", rgba.Bounds())
+	}
+	dst := image.NewRGBA(image.Rectangle{Max: r.Size()})
+	for y := range r.Dy() {
+		off := rgba.PixOffset(r.Min.X, r.Min.Y+y)
+		copy(dst.Pix[y*dst.Stride:y*dst.Stride+r.Dx()*4], rgba.Pix[off:off+r.Dx()*4])
+	}
+	return dst, nil
 }
 
-// CloneMat 返回最新 OpenCV Mat 的深拷贝（供其他 goroutine 编码/发送用）。
-// 第二个返回值表示是否可用（noopencv 模式下为空）。
-func (s *Server) CloneMat() (gocv.Mat, bool) {
+// CropMat 把最新一帧中 r 区域（与 Bounds 同一坐标系）转换为 BGR Mat 写入 dst，
+// 只转换所需区域；r 超出画面的部分被裁掉。
+func (s *Server) CropMat(r image.Rectangle, dst *gocv.Mat) error {
+	if s.noOpenCV {
+		return errors.New("OpenCV disabled")
+	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	if s.frame.mat.Empty() {
+	rgba := s.frame.rgba
+	if rgba == nil {
+		return ErrNoFrame
+	}
+	bounds := rgba.Bounds()
+	if r = r.Intersect(bounds); r.Empty() {
+		return fmt.Errorf("crop outside frame %v", bounds)
+	}
+
+	if s.frame.matId == s.frame.id && !s.frame.mat.Empty() {
+		region := s.frame.mat.Region(r.Sub(bounds.Min))
+		defer region.Close()
+		region.CopyTo(dst)
+		return nil
+	}
+
+	// 逐行拷贝为连续内存，再整体转换
+	w, h := r.Dx(), r.Dy()
+	pix := make([]byte, w*h*4)
+	for y := range h {
+		off := rgba.PixOffset(r.Min.X, r.Min.Y+y)
+		copy(pix[y*w*4:(y+1)*w*4], rgba.Pix[off:off+w*4])
+	}
+	src, err := gocv.NewMatFromBytes(h, w, gocv.MatTypeCV8UC4, pix)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return gocv.CvtColor(src, dst, gocv.ColorRGBAToBGR)
+}
+
+// CloneMat 返回最新整帧的 BGR Mat（供其他 goroutine 编码/发送用）。
+// 第二个返回值表示是否可用（noopencv 模式或尚无画面时为空）。
+func (s *Server) CloneMat() (gocv.Mat, bool) {
+	mat := gocv.NewMat()
+	if err := s.CropMat(s.Bounds(), &mat); err != nil {
+		mat.Close()
 		return gocv.Mat{}, false
 	}
-	return s.frame.mat.Clone(), true
+	return mat, true
 }
 
 func (s *Server) ReadFrameId() uint64 {
@@ -223,20 +271,9 @@ func (s *Server) Run(ctx context.Context) {
 		if !s.noOpenCV {
 			tImg := time.Now()
 			if s.source.ProvideMat(&s.frame.mat) {
-				if s.cvtCode == gocv.ColorRGBAToGray {
-					tmp := s.frame.mat.Clone()
-					gocv.CvtColor(tmp, &s.frame.mat, gocv.ColorBGRToGray)
-					tmp.Close()
-				}
-			} else {
-				err = s.imageToMat(rawRGBA, &s.frame.mat)
-				if err != nil {
-					log.Error().Err(err).Msg("failed to convert image to mat")
-					s.mu.Unlock()
-					continue
-				}
+				s.frame.matId = s.frame.id
+				s.diagProvideMat.Observe(time.Since(tImg), log)
 			}
-			s.diagImageToMat.Observe(time.Since(tImg), log)
 		}
 
 		s.screenRGBA, rawRGBA = rawRGBA, s.screenRGBA
@@ -264,10 +301,6 @@ func (s *Server) reallocBuffers(rawRGBA **image.RGBA) {
 
 	*rawRGBA = image.NewRGBA(bounds)
 	s.screenRGBA = image.NewRGBA(bounds)
-	if !s.noOpenCV {
-		s.frameMatRGBAInter.Close()
-		s.frameMatRGBAInter = gocv.NewMatWithSize(bounds.Dy(), bounds.Dx(), gocv.MatTypeCV8UC4)
-	}
 	log.Info().
 		Int("width", bounds.Dx()).
 		Int("height", bounds.Dy()).
@@ -277,65 +310,6 @@ func (s *Server) reallocBuffers(rawRGBA **image.RGBA) {
 func (s *Server) Close() error {
 	if !s.noOpenCV {
 		s.frame.mat.Close()
-		s.frameMatRGBAInter.Close()
 	}
 	return s.source.Close()
 }
-
-func (s *Server) imageToMat(img image.Image, dst *gocv.Mat) (err error) {
-	var src gocv.Mat
-
-	bounds := img.Bounds()
-	x := bounds.Dx()
-	y := bounds.Dy()
-
-	switch img.ColorModel() {
-	case color.RGBAModel:
-		m, res := img.(*image.RGBA)
-		if true != res {
-			return fmt.Errorf("image color format error")
-		}
-		data, err := s.frameMatRGBAInter.DataPtrUint8()
-		if err != nil {
-			return err
-		}
-		copy(data, m.Pix)
-		return gocv.CvtColor(s.frameMatRGBAInter, dst, s.cvtCode)
-
-	default:
-		imageToMatWarnOnce.Do(func() {
-			log.Warn().Msg("unexpected image color model, conversion performance may be affected")
-		})
-		if s.cvtCode == gocv.ColorRGBAToGray {
-			data := make([]byte, 0, x*y)
-			for j := bounds.Min.Y; j < bounds.Max.Y; j++ {
-				for i := bounds.Min.X; i < bounds.Max.X; i++ {
-					r, g, b, _ := img.At(i, j).RGBA()
-					gray := byte((19595*uint32(r) + 38470*uint32(g) + 7471*uint32(b)) >> 16)
-					data = append(data, gray)
-				}
-			}
-			src, err = gocv.NewMatFromBytes(y, x, gocv.MatTypeCV8UC1, data)
-			if err != nil {
-				return err
-			}
-			defer src.Close()
-			src.CopyTo(dst)
-			return nil
-		}
-		data := make([]byte, 0, x*y*3)
-		for j := bounds.Min.Y; j < bounds.Max.Y; j++ {
-			for i := bounds.Min.X; i < bounds.Max.X; i++ {
-				r, g, b, _ := img.At(i, j).RGBA()
-				data = append(data, byte(b>>8), byte(g>>8), byte(r>>8))
-			}
-		}
-		src, err = gocv.NewMatFromBytes(y, x, gocv.MatTypeCV8UC3, data)
-		if err != nil {
-			return err
-		}
-		defer src.Close()
-		src.CopyTo(dst)
-		return nil
-	}
-}
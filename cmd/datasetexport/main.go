@@ -0,0 +1,46 @@
+// Command datasetexport 把 -record 采集的样本导出为 YOLO txt 或 COCO JSON 布局,
+// 按样本名称哈希稳定划分 train/val。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Miuzarte/GoCVStreamer/dataset"
+	"github.com/Miuzarte/GoCVStreamer/detector"
+)
+
+var (
+	dir      = flag.String("dir", "dataset", "dataset directory written by the streamer's -record")
+	out      = flag.String("out", "dataset_export", "output directory")
+	format   = flag.String("format", dataset.FORMAT_YOLO, "export format: yolo or coco")
+	valRatio = flag.Float64("val", 0.2, "validation split ratio (0-1)")
+	minScore = flag.Float64("minscore", 0, "drop auto-labelled boxes below this score (manual boxes are always kept)")
+)
+
+func main() {
+	flag.Parse()
+	if *valRatio < 0 || *valRatio > 1 {
+		fmt.Fprintf(os.Stderr, "invalid -val %v (want 0-1)\n", *valRatio)
+		os.Exit(2)
+	}
+
+	store, err := dataset.Open(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	stats, err := store.Export(dataset.ExportConfig{
+		Format:     *format,
+		Out:        *out,
+		ValRatio:   *valRatio,
+		ClassNames: detector.CocoClassNames,
+		MinScore:   float32(*minScore),
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("exported %d train / %d val frames, %d boxes to %s\n", stats.Train, stats.Val, stats.Boxes, *out)
+}
@@ -0,0 +1,143 @@
+// Command deteval 在标注集（YOLO / COCO）上离线评估检测后端,
+// 走与 detector.Run 相同的裁剪缩放流程, 输出各类别 P/R、mAP@0.5、
+// mAP@0.5:0.95、延迟分位与混淆矩阵（JSON + Markdown）。
+package main
+
+import (
+	"encoding/json/jsontext"
+	jsonv2 "encoding/json/v2"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Miuzarte/GoCVStreamer/cuda"
+	"github.com/Miuzarte/GoCVStreamer/dataset"
+	"github.com/Miuzarte/GoCVStreamer/detector"
+	"github.com/Miuzarte/GoCVStreamer/deteval"
+	"github.com/Miuzarte/GoCVStreamer/utils"
+)
+
+var (
+	data       = flag.String("data", "", "labelled dataset directory")
+	format     = flag.String("format", dataset.FORMAT_YOLO, "dataset format: yolo (images/<split>, labels/<split>) or coco (annotations/instances_<split>.json, images/<split>)")
+	split      = flag.String("split", "val", "dataset split")
+	limit      = flag.Int("limit", 0, "evaluate at most N images (0 = all)")
+	jsonOut    = flag.String("json", "", "write the JSON report to this file")
+	mdOut      = flag.String("md", "", "write the Markdown report to this file (default: stdout)")
+	backend    = flag.String("backend", detector.BACKEND_YOLO, "detector backend: yolo, http, mock")
+	model      = flag.String("model", "", "ONNX model path (yolo backend, default: detector.DefaultConfig)")
+	url        = flag.String("url", "", "endpoint (http backend)")
+	protocol   = flag.String("protocol", detector.HTTP_PROTOCOL_JPEG, "http protocol: jpeg, kserve, kserve-binary")
+	conf       = flag.Float64("conf", 0, "confidence threshold (0 = backend default)")
+	inputSize  = flag.Int("input", 0, "model input size (0 = default)")
+	cropSize   = flag.Int("crop", -2, "centre crop size as in detector.Config.CropSize (-2 = same as the streamer: 2×input)")
+	tileSize   = flag.Int("tile", 0, "tile size for SAHI-style tiled detection (0 = off)")
+	allClasses = flag.Bool("allclasses", true, "score every class instead of only person")
+)
+
+func main() {
+	flag.Parse()
+	if *data == "" {
+		fmt.Fprintln(os.Stderr, "-data is required")
+		os.Exit(2)
+	}
+
+	cfg := detector.DefaultConfig()
+	cfg.Backend = *backend
+	cfg.Http.Url = *url
+	cfg.Http.Protocol = *protocol
+	if *model != "" {
+		cfg.ModelPath = *model
+	}
+	if *conf > 0 {
+		cfg.ConfThresh = float32(*conf)
+		cfg.Http.ConfThresh = float32(*conf)
+	}
+	if *inputSize > 0 {
+		cfg.InputSize = *inputSize
+	}
+	cfg.CropSize = *cropSize
+	if cfg.CropSize == -2 {
+		cfg.CropSize = cfg.InputSize * 2
+	}
+	cfg.TileSize = *tileSize
+	if *allClasses {
+		ids := make([]int, len(detector.CocoClassNames))
+		for i := range ids {
+			ids[i] = i
+		}
+		cfg.ResultIds = utils.NewSet(ids...)
+	}
+
+	samples, err := loadSamples()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *limit > 0 && len(samples) > *limit {
+		samples = samples[:*limit]
+	}
+	if len(samples) == 0 {
+		fmt.Fprintln(os.Stderr, "no images found")
+		os.Exit(1)
+	}
+
+	if cfg.Backend == detector.BACKEND_YOLO {
+		if _, err := cuda.InitContextCiG(); err != nil {
+			fmt.Fprintln(os.Stderr, "CUDA context init failed, ORT will use default context:", err)
+		}
+	}
+	engine, err := detector.New(nil, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer engine.Close()
+
+	report, err := deteval.Run(engine, cfg, samples, detector.CocoClassNames, func(done, total int) {
+		if done%50 == 0 || done == total {
+			fmt.Fprintf(os.Stderr, "\r%d/%d", done, total)
+		}
+	})
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *jsonOut != "" {
+		out, err := jsonv2.Marshal(report, jsontext.WithIndent("  "))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(*jsonOut, append(out, '\n'), 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	md := report.Markdown()
+	if *mdOut == "" {
+		fmt.Print(md)
+	} else if err := os.WriteFile(*mdOut, []byte(md), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func loadSamples() ([]deteval.Sample, error) {
+	switch *format {
+	case dataset.FORMAT_YOLO:
+		return deteval.LoadYolo(*data, *split)
+	case dataset.FORMAT_COCO:
+		return deteval.LoadCoco(
+			filepath.Join(*data, "annotations", "instances_"+*split+".json"),
+			filepath.Join(*data, "images", *split),
+			detector.CocoClassNames,
+		)
+	default:
+		return nil, fmt.Errorf("unknown format %q", *format)
+	}
+}
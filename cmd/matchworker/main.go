@@ -0,0 +1,203 @@
+// Command matchworker 是远程匹配端（ws://<host>/stream?role=matcher）的参考实现：
+// 加载与 streamer 相同的模板目录，匹配收到的 ROI 裁剪并回传结果，断线后自动重连。
+//
+// 协议（每帧一条 WebSocket 消息）：
+//
+//	下行 binary：[8B frame_id LE][PNG]，ROI 裁剪已缩放到参考分辨率，模板按原尺寸匹配
+//	上行 text：sender.RemoteMatch JSON
+//	  {"frame_id":N,"found":true,"name":"...","confidence":0.93,"x":12,"y":4,"match_ms":1.8}
+//
+// frame_id 原样回传；x/y 为模板在裁剪内的左上角；found 为 false 时 name 等字段可省略。
+// streamer 只采用帧号不早于最近提交帧号减 REMOTE_FRAME_WINDOW 的结果。
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	jsonv2 "encoding/json/v2"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Miuzarte/GoCVStreamer/logger"
+	"github.com/Miuzarte/GoCVStreamer/matcher"
+	"github.com/Miuzarte/GoCVStreamer/sender"
+	"github.com/Miuzarte/GoCVStreamer/template"
+	"github.com/Miuzarte/GoCVStreamer/weapon"
+	"github.com/Miuzarte/GoCVStreamer/weapons"
+	"github.com/coder/websocket"
+	"gocv.io/x/gocv"
+)
+
+var log = logger.New("MatchWorker")
+
+var (
+	server     = flag.String("server", "ws://127.0.0.1:9090/stream?role=matcher", "streamer WebSocket URL (role=matcher)")
+	dir        = flag.String("dir", "templates", "template directory")
+	depth      = flag.Int("depth", 3, "directory depth to walk")
+	suffix     = flag.String("suffix", ".png", "template image suffix")
+	ignore     = flag.String("ignore", "__", "skip files with this prefix")
+	mask       = flag.Bool("mask", false, "create masks from the alpha channel (as CREATE_MASK)")
+	catalog    = flag.String("catalog", "", "weapon class catalogue file (default: built-in)")
+	strategy   = flag.String("strategy", "", "default matching strategy: ccoeff_normed, ccorr_normed, sqdiff_normed, edge, orb")
+	threshold  = flag.Float64("threshold", 0, "default match threshold (0 = strategy default)")
+	preprocess = flag.String("preprocess", matcher.DefaultPreprocess.String(), "preprocessing chain applied to templates and crops (as -matchpreprocess)")
+)
+
+// HEADER_SIZE 下行消息的帧号长度
+const HEADER_SIZE = 8
+
+func main() {
+	flag.Parse()
+
+	if *catalog != "" {
+		c, err := weapon.LoadCatalog(*catalog)
+		if err != nil {
+			fail(err)
+		}
+		weapon.SetActiveCatalog(c)
+	}
+	defaultStrategy, err := template.ParseStrategy(*strategy)
+	if err != nil {
+		fail(err)
+	}
+	pre, err := template.ParsePreprocess(*preprocess)
+	if err != nil {
+		fail(err)
+	}
+
+	reg := weapons.NewRegistry()
+	defer reg.Close()
+	if err := reg.SetPreprocess(pre); err != nil {
+		fail(err)
+	}
+	if err := reg.ReadFrom(*dir, *depth, *suffix, *ignore, *mask, gocv.IMReadColor); err != nil {
+		log.Warn().Err(err).Msg("some templates failed to load")
+	}
+	log.Info().
+		Str("dir", *dir).
+		Int("templates", reg.Len()).
+		Msg("templates loaded")
+
+	w := &worker{
+		reg:       reg,
+		pre:       pre,
+		strategy:  defaultStrategy,
+		threshold: float32(*threshold),
+		scratch:   template.NewScratch(),
+	}
+	defer w.scratch.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	for {
+		err := w.serve(ctx, *server)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn().Err(err).Msg("disconnected, reconnecting in 1s")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+// worker 逐帧匹配；消息按顺序处理，模板与缓冲不会被并发使用。
+type worker struct {
+	reg       *weapons.Registry
+	pre       template.Preprocess
+	strategy  template.Strategy
+	threshold float32
+	scratch   *template.Scratch
+}
+
+// serve 连接 streamer 并处理消息，直到连接断开或 ctx 结束。
+func (w *worker) serve(ctx context.Context, url string) error {
+	c, _, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+	defer c.CloseNow()
+	c.SetReadLimit(-1)
+	log.Info().Str("server", url).Msg("connected")
+
+	for {
+		mt, data, err := c.Read(ctx)
+		if err != nil {
+			return err
+		}
+		if mt != websocket.MessageBinary || len(data) < HEADER_SIZE {
+			continue
+		}
+		res, err := w.match(binary.LittleEndian.Uint64(data), data[HEADER_SIZE:])
+		if err != nil {
+			log.Debug().Err(err).Msg("match failed")
+			continue
+		}
+		payload, err := jsonv2.Marshal(res)
+		if err != nil {
+			return err
+		}
+		if err := c.Write(ctx, websocket.MessageText, payload); err != nil {
+			return err
+		}
+	}
+}
+
+// match 解码 PNG 裁剪并与全部模板匹配，取超过阈值的最高分。
+func (w *worker) match(frameID uint64, png []byte) (sender.RemoteMatch, error) {
+	tStart := time.Now()
+	res := sender.RemoteMatch{FrameID: frameID}
+
+	img, err := gocv.IMDecode(png, gocv.IMReadColor)
+	if err != nil {
+		return res, err
+	}
+	defer img.Close()
+	if img.Empty() {
+		return res, errors.New("empty ROI crop")
+	}
+	if err := w.pre.Apply(img, &img); err != nil {
+		return res, err
+	}
+	f := template.NewFrame(img)
+	defer f.Close()
+
+	var best float32
+	w.reg.Read(func(ws weapons.Weapons) {
+		for _, wp := range ws {
+			s := w.strategy
+			if wp.Strategy != nil {
+				s = wp.Strategy
+			}
+			th := s.DefaultThreshold()
+			if wp.Threshold > 0 {
+				th = wp.Threshold
+			} else if w.threshold > 0 {
+				th = w.threshold
+			}
+			r, err := wp.Template.Eval(s, f, 1, w.scratch)
+			if err != nil {
+				log.Debug().Err(err).Str("template", wp.Name).Msg("template match failed")
+				continue
+			}
+			if r.Score >= th && (!res.Found || r.Score > best) {
+				best = r.Score
+				res.Found, res.Name, res.Confidence = true, wp.Name, float64(r.Score)
+				res.X, res.Y = r.Box.Min.X, r.Box.Min.Y
+			}
+		}
+	})
+	res.MatchMs = float64(time.Since(tStart)) / float64(time.Millisecond)
+	return res, nil
+}
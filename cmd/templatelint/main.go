@@ -0,0 +1,110 @@
+// Command templatelint 检查模板库：解析失败、名称重复、超出 ROI 的尺寸与无效掩码，
+// 并把每个模板与其他模板（及可选的 ROI 裁剪图）互相匹配，输出混淆矩阵与超过阈值的组合。
+package main
+
+import (
+	"encoding/json/jsontext"
+	jsonv2 "encoding/json/v2"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/Miuzarte/GoCVStreamer/matcher"
+	"github.com/Miuzarte/GoCVStreamer/template"
+	"github.com/Miuzarte/GoCVStreamer/tmpllint"
+	"github.com/Miuzarte/GoCVStreamer/weapon"
+	"gocv.io/x/gocv"
+)
+
+var (
+	dir        = flag.String("dir", "templates", "template directory")
+	depth      = flag.Int("depth", 3, "directory depth to walk")
+	suffix     = flag.String("suffix", ".png", "template image suffix")
+	ignore     = flag.String("ignore", "__", "skip files with this prefix")
+	mask       = flag.Bool("mask", false, "create masks from the alpha channel (as CREATE_MASK)")
+	catalog    = flag.String("catalog", "", "weapon class catalogue file (default: built-in)")
+	crops      = flag.String("crops", "", "directory of recorded ROI crops to match against")
+	strategy   = flag.String("strategy", "", "default matching strategy: ccoeff_normed, ccorr_normed, sqdiff_normed, edge, orb")
+	threshold  = flag.Float64("threshold", 0, "default match threshold (0 = strategy default)")
+	preprocess = flag.String("preprocess", matcher.DefaultPreprocess.String(), "preprocessing chain applied to templates and crops (as -matchpreprocess)")
+	roi        = flag.String("roi", "", "ROI size WxH for the size check (default: reference ROI)")
+	format     = flag.String("format", "md", "output format: md or json")
+	out        = flag.String("o", "", "write the report to this file (default: stdout)")
+)
+
+func main() {
+	flag.Parse()
+
+	if *catalog != "" {
+		c, err := weapon.LoadCatalog(*catalog)
+		if err != nil {
+			fail(err)
+		}
+		weapon.SetActiveCatalog(c)
+	}
+
+	defaultStrategy, err := template.ParseStrategy(*strategy)
+	if err != nil {
+		fail(err)
+	}
+	pre, err := template.ParsePreprocess(*preprocess)
+	if err != nil {
+		fail(err)
+	}
+
+	roiSize := matcher.ReferenceRoi.Size()
+	if *roi != "" {
+		if _, err := fmt.Sscanf(*roi, "%dx%d", &roiSize.X, &roiSize.Y); err != nil {
+			fail(fmt.Errorf("invalid -roi %q: %w", *roi, err))
+		}
+	}
+
+	report, err := tmpllint.Run(tmpllint.Config{
+		Dir:          *dir,
+		Depth:        *depth,
+		Suffix:       *suffix,
+		IgnorePrefix: *ignore,
+		CreateMask:   *mask,
+		Flag:         gocv.IMReadColor,
+		Preprocess:   pre,
+		Strategy:     defaultStrategy,
+		Threshold:    float32(*threshold),
+		Roi:          roiSize,
+		MaxScale:     slices.Max(matcher.DefaultScales),
+		Crops:        *crops,
+	})
+	if err != nil {
+		fail(err)
+	}
+
+	var data []byte
+	switch *format {
+	case "md":
+		data = []byte(report.Markdown())
+	case "json":
+		data, err = jsonv2.Marshal(report, jsontext.WithIndent("  "))
+		if err != nil {
+			fail(err)
+		}
+		data = append(data, '\n')
+	default:
+		fail(fmt.Errorf("unknown format %q", *format))
+	}
+
+	if *out == "" {
+		os.Stdout.Write(data)
+	} else if err := os.WriteFile(*out, data, 0o644); err != nil {
+		fail(err)
+	}
+
+	// 有问题时以非零退出，便于在脚本中使用
+	if len(report.Issues) > 0 || len(report.Pairs) > 0 {
+		os.Exit(1)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
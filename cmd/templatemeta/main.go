@@ -0,0 +1,97 @@
+// Command templatemeta 一次性把模板文件名中的 {CLASS_SPEED_SPEED} 参数迁移为
+// 附属元数据（NAME.json），或每个目录一份 templates.json 清单；图片文件名保持不变。
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Miuzarte/GoCVStreamer/utils"
+	"github.com/Miuzarte/GoCVStreamer/weapon"
+)
+
+var (
+	dir      = flag.String("dir", "templates", "template directory")
+	depth    = flag.Int("depth", 1, "directory depth to walk")
+	suffix   = flag.String("suffix", ".png", "template image suffix")
+	ignore   = flag.String("ignore", "__", "skip files with this prefix")
+	manifest = flag.Bool("manifest", false, "write one "+weapon.MANIFEST_NAME+" per directory instead of a sidecar per template")
+	force    = flag.Bool("force", false, "overwrite existing metadata")
+	dryRun   = flag.Bool("n", false, "print what would be written without writing")
+)
+
+func main() {
+	flag.Parse()
+
+	paths, err := utils.WalkDir(*dir, max(*depth, 1), *suffix, *ignore)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	manifests := make(map[string]map[string]weapon.Meta)
+	var written, skipped, failed int
+	for _, path := range paths {
+		if !*force {
+			if _, err := weapon.LoadMeta(path); !errors.Is(err, weapon.ErrNoMeta) {
+				skipped++
+				continue
+			}
+		}
+		m, err := weapon.MetaFromFileName(path)
+		if err == nil {
+			err = m.Validate()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed++
+			continue
+		}
+
+		if *manifest {
+			d := filepath.Dir(path)
+			if manifests[d] == nil {
+				// 保留清单中已有的条目
+				existing, err := weapon.LoadManifest(d)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+				manifests[d] = make(map[string]weapon.Meta, len(existing))
+				for k, v := range existing {
+					manifests[d][k] = v
+				}
+			}
+			manifests[d][filepath.Base(path)] = m
+			written++
+			continue
+		}
+
+		fmt.Printf("%s -> %s\n", path, weapon.MetaPath(path))
+		if !*dryRun {
+			if err := weapon.WriteMeta(path, m); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		written++
+	}
+
+	for d, entries := range manifests {
+		fmt.Printf("%s (%d templates)\n", filepath.Join(d, weapon.MANIFEST_NAME), len(entries))
+		if !*dryRun {
+			if err := weapon.WriteManifest(d, entries); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	fmt.Printf("migrated %d, skipped %d with existing metadata, failed %d\n", written, skipped, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,237 @@
+// Package dataset 从实时画面采集训练样本（图像 + detector.Result 框）,
+// 并导出为 YOLO txt / COCO JSON 布局, 用于微调检测模型
+package dataset
+
+import (
+	jsonv2 "encoding/json/v2"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Miuzarte/GoCVStreamer/detector"
+	"github.com/Miuzarte/GoCVStreamer/logger"
+)
+
+var log = logger.New("Dataset")
+
+const (
+	FRAMES_DIR = "frames" // 原始样本：NAME.jpg + NAME.json
+
+	IMAGE_EXT  = ".jpg"
+	RECORD_EXT = ".json"
+)
+
+const (
+	SOURCE_LOCAL  = "local"
+	SOURCE_REMOTE = "remote"
+	SOURCE_MANUAL = "manual"
+)
+
+// ErrNotFound 样本不存在。
+var ErrNotFound = errors.New("dataset: record not found")
+
+// Box 一个标注框（帧像素坐标）。
+type Box struct {
+	Class  int     `json:"class"`
+	Score  float32 `json:"score,omitzero"` // 人工标注为 0
+	X1     int     `json:"x1"`
+	Y1     int     `json:"y1"`
+	X2     int     `json:"x2"`
+	Y2     int     `json:"y2"`
+	Source string  `json:"source,omitzero"`
+}
+
+func (b Box) Rect() image.Rectangle {
+	return image.Rect(b.X1, b.Y1, b.X2, b.Y2)
+}
+
+// BoxFromResult 把检测结果转为标注框。
+func BoxFromResult(r detector.Result) Box {
+	source := SOURCE_LOCAL
+	if r.Kind == detector.KindRemote {
+		source = SOURCE_REMOTE
+	}
+	return Box{
+		Class:  r.ClassID,
+		Score:  r.Score,
+		X1:     r.Box.Min.X,
+		Y1:     r.Box.Min.Y,
+		X2:     r.Box.Max.X,
+		Y2:     r.Box.Max.Y,
+		Source: source,
+	}
+}
+
+// Record 一帧样本的元数据, 保存为与图像同名的 .json 侧车文件。
+type Record struct {
+	Name    string    `json:"name"` // 不含扩展名
+	FrameId uint64    `json:"frame_id"`
+	Time    time.Time `json:"time"`
+	Trigger string    `json:"trigger"`
+	Width   int       `json:"width"`
+	Height  int       `json:"height"`
+	Hash    uint64    `json:"hash"` // DHash, 用于去重
+
+	Boxes      []Box `json:"boxes"`               // 导出使用的标注
+	Candidates []Box `json:"candidates,omitzero"` // 另一推理源的结果, 仅供人工复核
+	Reviewed   bool  `json:"reviewed,omitzero"`   // 已人工复核
+}
+
+// Store 数据集目录：dir/frames 下每个样本一张 jpg 与一个 json。
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, FRAMES_DIR), 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+func (s *Store) ImagePath(name string) string {
+	return filepath.Join(s.dir, FRAMES_DIR, name+IMAGE_EXT)
+}
+
+func (s *Store) RecordPath(name string) string {
+	return filepath.Join(s.dir, FRAMES_DIR, name+RECORD_EXT)
+}
+
+// validName 拒绝路径分隔符等, 防止通过名称逃逸出数据集目录。
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." &&
+		!strings.ContainsAny(name, `/\:`) && filepath.Base(name) == name
+}
+
+// Save 写入图像与元数据（元数据最后落盘, 只有完整的样本才会被 List 看到）。
+func (s *Store) Save(rec Record, img image.Image, quality int) error {
+	if !validName(rec.Name) {
+		return fmt.Errorf("invalid record name %q", rec.Name)
+	}
+	b := img.Bounds()
+	rec.Width, rec.Height = b.Dx(), b.Dy()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Create(s.ImagePath(rec.Name))
+	if err != nil {
+		return err
+	}
+	err = jpeg.Encode(f, img, &jpeg.Options{Quality: quality})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(s.ImagePath(rec.Name))
+		return err
+	}
+	return s.writeRecordLocked(rec)
+}
+
+// SaveRecord 只更新元数据（人工修正标注）。
+func (s *Store) SaveRecord(rec Record) error {
+	if !validName(rec.Name) {
+		return fmt.Errorf("invalid record name %q", rec.Name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := os.Stat(s.ImagePath(rec.Name)); err != nil {
+		return ErrNotFound
+	}
+	return s.writeRecordLocked(rec)
+}
+
+func (s *Store) writeRecordLocked(rec Record) error {
+	if rec.Boxes == nil {
+		rec.Boxes = []Box{}
+	}
+	data, err := jsonv2.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	tmp := s.RecordPath(rec.Name) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.RecordPath(rec.Name))
+}
+
+func (s *Store) Load(name string) (Record, error) {
+	if !validName(name) {
+		return Record{}, ErrNotFound
+	}
+	data, err := os.ReadFile(s.RecordPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return Record{}, ErrNotFound
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	var rec Record
+	if err := jsonv2.Unmarshal(data, &rec); err != nil {
+		return Record{}, fmt.Errorf("%s: %w", name, err)
+	}
+	rec.Name = name
+	return rec, nil
+}
+
+// List 按名称（即采集时间）排序返回所有样本；损坏的侧车文件跳过并记录日志。
+func (s *Store) List() ([]Record, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, FRAMES_DIR))
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), RECORD_EXT)
+		if e.IsDir() || !ok {
+			continue
+		}
+		rec, err := s.Load(name)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("name", name).
+				Msg("skip broken record")
+			continue
+		}
+		records = append(records, rec)
+	}
+	slices.SortFunc(records, func(a, b Record) int { return strings.Compare(a.Name, b.Name) })
+	return records, nil
+}
+
+// Delete 删除样本的图像与元数据。
+func (s *Store) Delete(name string) error {
+	if !validName(name) {
+		return ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.RecordPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return os.Remove(s.ImagePath(name))
+}
+
+// recordName 采集时间 + 帧号, 字典序即时间序。
+func recordName(t time.Time, frameId uint64) string {
+	return fmt.Sprintf("%s_%d", t.Format("20060102-150405.000"), frameId)
+}
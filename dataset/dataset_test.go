@@ -0,0 +1,269 @@
+package dataset
+
+import (
+	jsonv2 "encoding/json/v2"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Miuzarte/GoCVStreamer/detector"
+	"github.com/getcharzp/go-vision/yolo26"
+)
+
+type fakeFrames struct {
+	mu  sync.Mutex
+	id  uint64
+	img *image.RGBA
+}
+
+func (f *fakeFrames) set(img *image.RGBA) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.id++
+	f.img = img
+}
+
+func (f *fakeFrames) ReadFrameId() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.id
+}
+
+func (f *fakeFrames) CloneRgba() *image.RGBA {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cp := image.NewRGBA(f.img.Bounds())
+	copy(cp.Pix, f.img.Pix)
+	return cp
+}
+
+type fakeSource struct {
+	results []detector.Result
+}
+
+func (s *fakeSource) Snapshot() ([]detector.Result, time.Duration, bool) {
+	return s.results, 0, len(s.results) > 0
+}
+
+func (s *fakeSource) Close() error { return nil }
+
+func person(kind detector.Kind, score float32, box image.Rectangle) detector.Result {
+	return detector.Result{DetResult: yolo26.DetResult{ClassID: 0, Score: score, Box: box}, Kind: kind}
+}
+
+// gradient 生成带方向渐变的测试画面, dir 不同则哈希差异明显。
+func gradient(w, h int, dir int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			v := uint8(x * 255 / w)
+			if dir == 1 {
+				v = uint8(y * 255 / h)
+			} else if dir == 2 {
+				v = uint8(((x / 32) + (y / 32)) % 2 * 255)
+			}
+			img.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+func TestDHash(t *testing.T) {
+	a := gradient(320, 180, 0)
+	b := gradient(640, 360, 0) // 同一画面不同分辨率
+	c := gradient(320, 180, 2)
+
+	if d := HashDistance(DHash(a), DHash(b)); d > 4 {
+		t.Fatalf("resized frame distance = %d", d)
+	}
+	if d := HashDistance(DHash(a), DHash(c)); d < 10 {
+		t.Fatalf("different frame distance = %d", d)
+	}
+}
+
+func TestRecorderTriggers(t *testing.T) {
+	frames := &fakeFrames{}
+	frames.set(gradient(320, 180, 0))
+	local := &fakeSource{}
+	remote := &fakeSource{}
+
+	cfg := DefaultConfig()
+	cfg.Dir = t.TempDir()
+	cfg.MinGap = 0
+	r, err := New(frames, local, remote, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if trigger := r.check(now); trigger != "" {
+		t.Fatalf("empty sources triggered %q", trigger)
+	}
+
+	box := image.Rect(10, 10, 60, 120)
+	local.results = []detector.Result{person(detector.KindLocal, 0.9, box)}
+	remote.results = []detector.Result{person(detector.KindRemote, 0.8, box.Add(image.Pt(2, 2)))}
+	if trigger := r.check(now); trigger != "" {
+		t.Fatalf("agreeing sources triggered %q", trigger)
+	}
+
+	remote.results = nil
+	if trigger := r.check(now); trigger != TRIGGER_DISAGREE {
+		t.Fatalf("trigger = %q, want disagree", trigger)
+	}
+
+	local.results[0].Score = 0.5
+	if trigger := r.check(now); trigger != TRIGGER_LOW_CONF {
+		t.Fatalf("trigger = %q, want lowconf", trigger)
+	}
+}
+
+func TestRecorderCaptureDedupe(t *testing.T) {
+	frames := &fakeFrames{}
+	frames.set(gradient(320, 180, 0))
+	local := &fakeSource{results: []detector.Result{person(detector.KindLocal, 0.9, image.Rect(10, 10, 60, 120))}}
+	remote := &fakeSource{results: []detector.Result{person(detector.KindRemote, 0.7, image.Rect(200, 10, 260, 120))}}
+
+	cfg := DefaultConfig()
+	cfg.Dir = t.TempDir()
+	r, err := New(frames, local, remote, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, saved, err := r.Capture(TRIGGER_MANUAL)
+	if err != nil || !saved {
+		t.Fatalf("capture: saved=%v err=%v", saved, err)
+	}
+	if len(rec.Boxes) != 1 || rec.Boxes[0].Source != SOURCE_LOCAL || len(rec.Candidates) != 1 {
+		t.Fatalf("record = %+v", rec)
+	}
+
+	if _, saved, _ := r.Capture(TRIGGER_MANUAL); saved {
+		t.Fatal("duplicate frame saved")
+	}
+
+	frames.set(gradient(320, 180, 2))
+	if _, saved, _ := r.Capture(TRIGGER_MANUAL); !saved {
+		t.Fatal("different frame not saved")
+	}
+	if s := r.Stats(); s.Saved != 2 || s.Duplicates != 1 {
+		t.Fatalf("stats = %+v", s)
+	}
+
+	// 重新打开时载入已有哈希, 仍能去重
+	r2, err := New(frames, local, remote, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, saved, _ := r2.Capture(TRIGGER_MANUAL); saved {
+		t.Fatal("duplicate saved after reopen")
+	}
+
+	loaded, err := r.Store().Load(rec.Name)
+	if err != nil || loaded.Width != 320 || loaded.Hash != rec.Hash {
+		t.Fatalf("load = %+v, %v", loaded, err)
+	}
+}
+
+func newTestStore(t *testing.T, n int) *Store {
+	t.Helper()
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := gradient(200, 100, 0)
+	for i := range n {
+		rec := Record{
+			Name: recordName(time.Unix(int64(i), 0), uint64(i)),
+			Boxes: []Box{
+				{Class: 0, Score: 0.9, X1: 50, Y1: 25, X2: 150, Y2: 75},
+				{Class: 0, Score: 0.2, X1: 0, Y1: 0, X2: 10, Y2: 10}, // 低分, 过滤
+				{Class: 2, X1: -20, Y1: 50, X2: 20, Y2: 150},         // 人工框, 裁剪到图内
+			},
+		}
+		if err := store.Save(rec, img, 90); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestExportYolo(t *testing.T) {
+	store := newTestStore(t, 20)
+	out := t.TempDir()
+	stats, err := store.Export(ExportConfig{
+		Format:     FORMAT_YOLO,
+		Out:        out,
+		ValRatio:   0.3,
+		ClassNames: []string{"person", "bicycle", "car"},
+		MinScore:   0.45,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Train+stats.Val != 20 || stats.Val == 0 || stats.Train == 0 || stats.Boxes != 40 {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	records, _ := store.List()
+	name := records[0].Name
+	split := Split(name, 0.3)
+	data, err := os.ReadFile(filepath.Join(out, "labels", split, name+".txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "0 0.500000 0.500000 0.500000 0.500000\n2 0.050000 0.750000 0.100000 0.500000\n"
+	if string(data) != want {
+		t.Fatalf("labels =\n%s\nwant\n%s", data, want)
+	}
+	if _, err := os.Stat(filepath.Join(out, "images", split, name+IMAGE_EXT)); err != nil {
+		t.Fatal(err)
+	}
+	yaml, _ := os.ReadFile(filepath.Join(out, "data.yaml"))
+	if !strings.Contains(string(yaml), "  2: car\n") {
+		t.Fatalf("data.yaml =\n%s", yaml)
+	}
+}
+
+func TestExportCoco(t *testing.T) {
+	store := newTestStore(t, 10)
+	out := t.TempDir()
+	stats, err := store.Export(ExportConfig{
+		Format:     FORMAT_COCO,
+		Out:        out,
+		ValRatio:   0.5,
+		ClassNames: []string{"person", "bicycle", "car"},
+		MinScore:   0.45,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var total int
+	for _, split := range []string{SPLIT_TRAIN, SPLIT_VAL} {
+		data, err := os.ReadFile(filepath.Join(out, "annotations", "instances_"+split+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var f cocoFile
+		if err := jsonv2.Unmarshal(data, &f); err != nil {
+			t.Fatal(err)
+		}
+		if len(f.Categories) != 3 || len(f.Annotations) != 2*len(f.Images) {
+			t.Fatalf("%s: %d images, %d annotations", split, len(f.Images), len(f.Annotations))
+		}
+		if len(f.Annotations) > 0 && f.Annotations[0].Bbox != [4]float64{50, 25, 100, 50} {
+			t.Fatalf("bbox = %v", f.Annotations[0].Bbox)
+		}
+		total += len(f.Images)
+	}
+	if total != 10 || stats.Train+stats.Val != 10 {
+		t.Fatalf("total = %d, stats = %+v", total, stats)
+	}
+}
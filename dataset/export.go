@@ -0,0 +1,266 @@
+package dataset
+
+import (
+	"encoding/json/jsontext"
+	jsonv2 "encoding/json/v2"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	FORMAT_YOLO = "yolo"
+	FORMAT_COCO = "coco"
+
+	SPLIT_TRAIN = "train"
+	SPLIT_VAL   = "val"
+)
+
+type ExportConfig struct {
+	Format     string
+	Out        string
+	ValRatio   float64  // 验证集比例 0~1
+	ClassNames []string // 类别 id 即下标；写入 data.yaml / categories
+	MinScore   float32  // 低于该得分的自动标注框不导出（人工框 Score=0 不受影响）
+}
+
+type ExportStats struct {
+	Train int
+	Val   int
+	Boxes int
+}
+
+// Split 按名称哈希确定样本所属划分：同一样本多次导出始终落在同一侧, 新增样本不会打乱旧划分。
+func Split(name string, valRatio float64) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	if float64(h.Sum32()%10000) < valRatio*10000 {
+		return SPLIT_VAL
+	}
+	return SPLIT_TRAIN
+}
+
+// Export 把所有样本导出为 YOLO 或 COCO 布局：
+//
+//	yolo: Out/images/{train,val}/NAME.jpg, Out/labels/{train,val}/NAME.txt, Out/data.yaml
+//	coco: Out/images/{train,val}/NAME.jpg, Out/annotations/instances_{train,val}.json
+func (s *Store) Export(cfg ExportConfig) (ExportStats, error) {
+	if cfg.Out == "" {
+		return ExportStats{}, errors.New("export: empty output dir")
+	}
+	if cfg.Format != FORMAT_YOLO && cfg.Format != FORMAT_COCO {
+		return ExportStats{}, fmt.Errorf("export: unknown format %q", cfg.Format)
+	}
+
+	records, err := s.List()
+	if err != nil {
+		return ExportStats{}, err
+	}
+
+	var stats ExportStats
+	cocos := map[string]*cocoFile{
+		SPLIT_TRAIN: newCocoFile(cfg.ClassNames),
+		SPLIT_VAL:   newCocoFile(cfg.ClassNames),
+	}
+	for _, split := range []string{SPLIT_TRAIN, SPLIT_VAL} {
+		if err := os.MkdirAll(filepath.Join(cfg.Out, "images", split), 0o755); err != nil {
+			return stats, err
+		}
+		if cfg.Format == FORMAT_YOLO {
+			if err := os.MkdirAll(filepath.Join(cfg.Out, "labels", split), 0o755); err != nil {
+				return stats, err
+			}
+		}
+	}
+
+	for _, rec := range records {
+		split := Split(rec.Name, cfg.ValRatio)
+		if split == SPLIT_VAL {
+			stats.Val++
+		} else {
+			stats.Train++
+		}
+
+		if err := linkOrCopy(s.ImagePath(rec.Name), filepath.Join(cfg.Out, "images", split, rec.Name+IMAGE_EXT)); err != nil {
+			return stats, err
+		}
+
+		boxes := exportBoxes(rec, cfg.MinScore)
+		stats.Boxes += len(boxes)
+		switch cfg.Format {
+		case FORMAT_YOLO:
+			path := filepath.Join(cfg.Out, "labels", split, rec.Name+".txt")
+			if err := os.WriteFile(path, []byte(yoloLabels(rec, boxes)), 0o644); err != nil {
+				return stats, err
+			}
+		case FORMAT_COCO:
+			cocos[split].add(rec, boxes)
+		}
+	}
+
+	switch cfg.Format {
+	case FORMAT_YOLO:
+		err = os.WriteFile(filepath.Join(cfg.Out, "data.yaml"), []byte(yoloDataYaml(cfg.ClassNames)), 0o644)
+	case FORMAT_COCO:
+		err = writeCoco(cfg.Out, cocos)
+	}
+	if err != nil {
+		return stats, err
+	}
+
+	log.Info().
+		Str("format", cfg.Format).
+		Str("out", cfg.Out).
+		Int("train", stats.Train).
+		Int("val", stats.Val).
+		Int("boxes", stats.Boxes).
+		Msg("dataset exported")
+	return stats, nil
+}
+
+// exportBoxes 过滤低分自动标注并裁剪到图像范围内, 丢弃退化框。
+func exportBoxes(rec Record, minScore float32) []Box {
+	boxes := make([]Box, 0, len(rec.Boxes))
+	for _, b := range rec.Boxes {
+		if b.Score > 0 && b.Score < minScore {
+			continue
+		}
+		r := b.Rect().Canon()
+		r.Min.X, r.Min.Y = max(r.Min.X, 0), max(r.Min.Y, 0)
+		r.Max.X, r.Max.Y = min(r.Max.X, rec.Width), min(r.Max.Y, rec.Height)
+		if r.Empty() {
+			continue
+		}
+		b.X1, b.Y1, b.X2, b.Y2 = r.Min.X, r.Min.Y, r.Max.X, r.Max.Y
+		boxes = append(boxes, b)
+	}
+	return boxes
+}
+
+// yoloLabels 每行 "class cx cy w h", 坐标归一化到 0~1。
+func yoloLabels(rec Record, boxes []Box) string {
+	var sb strings.Builder
+	w, h := float64(rec.Width), float64(rec.Height)
+	for _, b := range boxes {
+		cx := float64(b.X1+b.X2) / 2 / w
+		cy := float64(b.Y1+b.Y2) / 2 / h
+		bw := float64(b.X2-b.X1) / w
+		bh := float64(b.Y2-b.Y1) / h
+		fmt.Fprintf(&sb, "%d %.6f %.6f %.6f %.6f\n", b.Class, cx, cy, bw, bh)
+	}
+	return sb.String()
+}
+
+func yoloDataYaml(classNames []string) string {
+	var sb strings.Builder
+	sb.WriteString("path: .\ntrain: images/train\nval: images/val\nnames:\n")
+	for i, name := range classNames {
+		fmt.Fprintf(&sb, "  %d: %s\n", i, name)
+	}
+	return sb.String()
+}
+
+type cocoImage struct {
+	Id       int    `json:"id"`
+	FileName string `json:"file_name"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+type cocoAnnotation struct {
+	Id         int        `json:"id"`
+	ImageId    int        `json:"image_id"`
+	CategoryId int        `json:"category_id"`
+	Bbox       [4]float64 `json:"bbox"` // x, y, w, h
+	Area       float64    `json:"area"`
+	IsCrowd    int        `json:"iscrowd"`
+	Score      float32    `json:"score,omitzero"`
+}
+
+type cocoCategory struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// cocoFile COCO instances JSON；category_id 直接使用类别下标（与 YOLO 一致）, 不做 COCO 原始 1~90 映射。
+type cocoFile struct {
+	Images      []cocoImage      `json:"images"`
+	Annotations []cocoAnnotation `json:"annotations"`
+	Categories  []cocoCategory   `json:"categories"`
+}
+
+func newCocoFile(classNames []string) *cocoFile {
+	f := &cocoFile{
+		Images:      []cocoImage{},
+		Annotations: []cocoAnnotation{},
+		Categories:  make([]cocoCategory, len(classNames)),
+	}
+	for i, name := range classNames {
+		f.Categories[i] = cocoCategory{Id: i, Name: name}
+	}
+	return f
+}
+
+func (f *cocoFile) add(rec Record, boxes []Box) {
+	imageId := len(f.Images) + 1
+	f.Images = append(f.Images, cocoImage{
+		Id:       imageId,
+		FileName: rec.Name + IMAGE_EXT,
+		Width:    rec.Width,
+		Height:   rec.Height,
+	})
+	for _, b := range boxes {
+		w, h := float64(b.X2-b.X1), float64(b.Y2-b.Y1)
+		f.Annotations = append(f.Annotations, cocoAnnotation{
+			Id:         len(f.Annotations) + 1,
+			ImageId:    imageId,
+			CategoryId: b.Class,
+			Bbox:       [4]float64{float64(b.X1), float64(b.Y1), w, h},
+			Area:       w * h,
+			Score:      b.Score,
+		})
+	}
+}
+
+func writeCoco(out string, files map[string]*cocoFile) error {
+	dir := filepath.Join(out, "annotations")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for split, f := range files {
+		data, err := jsonv2.Marshal(f, jsontext.WithIndent("  "))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, "instances_"+split+".json"), data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkOrCopy 优先硬链接（同盘导出不占额外空间）, 失败时复制。
+func linkOrCopy(src, dst string) error {
+	os.Remove(dst)
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,257 @@
+package dataset
+
+import (
+	_ "embed"
+	"encoding/json/jsontext"
+	jsonv2 "encoding/json/v2"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+//go:embed labeler.html
+var labelerHtml []byte
+
+const (
+	DEFAULT_PAGE_SIZE = 50
+	MAX_PAGE_SIZE     = 500
+)
+
+// Labeler 人工复核/标注 Web 工具：页面 + JSON API, 挂载到已有的 http.ServeMux。
+//
+//	GET    {prefix}/                         标注页面
+//	GET    {prefix}/api/classes              类别名
+//	GET    {prefix}/api/frames?page=&size=   分页列出样本摘要
+//	GET    {prefix}/api/frames/{name}        样本元数据
+//	GET    {prefix}/api/frames/{name}/image  样本图像
+//	PUT    {prefix}/api/frames/{name}        保存标注 {"boxes":[...],"reviewed":bool}
+//	DELETE {prefix}/api/frames/{name}        删除样本
+//	POST   {prefix}/api/export               导出 {"format","out","val_ratio","min_score"}
+type Labeler struct {
+	store      *Store
+	classNames []string
+}
+
+func NewLabeler(store *Store, classNames []string) *Labeler {
+	return &Labeler{store: store, classNames: classNames}
+}
+
+// FrameSummary 列表页的样本摘要。
+type FrameSummary struct {
+	Name     string    `json:"name"`
+	Time     time.Time `json:"time"`
+	Trigger  string    `json:"trigger"`
+	Boxes    int       `json:"boxes"`
+	Reviewed bool      `json:"reviewed"`
+}
+
+type FramePage struct {
+	Total  int            `json:"total"`
+	Page   int            `json:"page"`
+	Size   int            `json:"size"`
+	Frames []FrameSummary `json:"frames"`
+}
+
+// LabelUpdate PUT 请求体。
+type LabelUpdate struct {
+	Boxes    []Box `json:"boxes"`
+	Reviewed bool  `json:"reviewed"`
+}
+
+type ExportRequest struct {
+	Format   string  `json:"format"`
+	Out      string  `json:"out,omitzero"` // 默认 Dir/export_{format}
+	ValRatio float64 `json:"val_ratio"`
+	MinScore float32 `json:"min_score,omitzero"`
+}
+
+// Register 在 mux 上注册页面与 API；prefix 形如 "/label"。
+func (l *Labeler) Register(mux *http.ServeMux, prefix string) {
+	mux.HandleFunc("GET "+prefix+"/{$}", l.handlePage)
+	mux.HandleFunc("GET "+prefix+"/api/classes", l.handleClasses)
+	mux.HandleFunc("GET "+prefix+"/api/frames", l.handleList)
+	mux.HandleFunc("GET "+prefix+"/api/frames/{name}", l.handleGet)
+	mux.HandleFunc("GET "+prefix+"/api/frames/{name}/image", l.handleImage)
+	mux.HandleFunc("PUT "+prefix+"/api/frames/{name}", l.handlePut)
+	mux.HandleFunc("DELETE "+prefix+"/api/frames/{name}", l.handleDelete)
+	mux.HandleFunc("POST "+prefix+"/api/export", l.handleExport)
+}
+
+func (l *Labeler) handlePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(labelerHtml)
+}
+
+func (l *Labeler) handleClasses(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, l.classNames)
+}
+
+func (l *Labeler) handleList(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+	page = max(page, 0)
+	if size <= 0 {
+		size = DEFAULT_PAGE_SIZE
+	}
+	size = min(size, MAX_PAGE_SIZE)
+
+	records, err := l.store.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := FramePage{Total: len(records), Page: page, Size: size, Frames: []FrameSummary{}}
+	start := min(page*size, len(records))
+	end := min(start+size, len(records))
+	for _, rec := range records[start:end] {
+		res.Frames = append(res.Frames, FrameSummary{
+			Name:     rec.Name,
+			Time:     rec.Time,
+			Trigger:  rec.Trigger,
+			Boxes:    len(rec.Boxes),
+			Reviewed: rec.Reviewed,
+		})
+	}
+	writeJson(w, http.StatusOK, res)
+}
+
+func (l *Labeler) handleGet(w http.ResponseWriter, r *http.Request) {
+	rec, err := l.store.Load(r.PathValue("name"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJson(w, http.StatusOK, rec)
+}
+
+func (l *Labeler) handleImage(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !validName(name) {
+		writeError(w, http.StatusNotFound, ErrNotFound)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeFile(w, r, l.store.ImagePath(name))
+}
+
+func (l *Labeler) handlePut(w http.ResponseWriter, r *http.Request) {
+	rec, err := l.store.Load(r.PathValue("name"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	var update LabelUpdate
+	if err := jsonv2.UnmarshalRead(io.LimitReader(r.Body, 4<<20), &update); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	boxes, err := l.validateBoxes(rec, update.Boxes)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rec.Boxes = boxes
+	rec.Reviewed = update.Reviewed
+	if err := l.store.SaveRecord(rec); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	log.Debug().
+		Str("name", rec.Name).
+		Int("boxes", len(rec.Boxes)).
+		Bool("reviewed", rec.Reviewed).
+		Msg("labels saved")
+	writeJson(w, http.StatusOK, rec)
+}
+
+// validateBoxes 检查类别, 规整坐标并裁剪到图像范围。
+func (l *Labeler) validateBoxes(rec Record, boxes []Box) ([]Box, error) {
+	valid := make([]Box, 0, len(boxes))
+	for i, b := range boxes {
+		if b.Class < 0 || (len(l.classNames) > 0 && b.Class >= len(l.classNames)) {
+			return nil, fmt.Errorf("box %d: unknown class %d", i, b.Class)
+		}
+		rect := b.Rect().Canon()
+		rect.Min.X, rect.Min.Y = max(rect.Min.X, 0), max(rect.Min.Y, 0)
+		rect.Max.X, rect.Max.Y = min(rect.Max.X, rec.Width), min(rect.Max.Y, rec.Height)
+		if rect.Dx() < 2 || rect.Dy() < 2 {
+			return nil, fmt.Errorf("box %d: empty after clipping to %dx%d", i, rec.Width, rec.Height)
+		}
+		b.X1, b.Y1, b.X2, b.Y2 = rect.Min.X, rect.Min.Y, rect.Max.X, rect.Max.Y
+		if b.Source == "" {
+			b.Source = SOURCE_MANUAL
+		}
+		valid = append(valid, b)
+	}
+	return valid, nil
+}
+
+func (l *Labeler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := l.store.Delete(name); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	log.Info().
+		Str("name", name).
+		Msg("frame deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (l *Labeler) handleExport(w http.ResponseWriter, r *http.Request) {
+	var req ExportRequest
+	if err := jsonv2.UnmarshalRead(io.LimitReader(r.Body, 1<<20), &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Out == "" {
+		req.Out = filepath.Join(l.store.Dir(), "export_"+req.Format)
+	}
+	if req.ValRatio < 0 || req.ValRatio > 1 {
+		writeError(w, http.StatusBadRequest, errors.New("val_ratio must be within 0-1"))
+		return
+	}
+
+	stats, err := l.store.Export(ExportConfig{
+		Format:     req.Format,
+		Out:        req.Out,
+		ValRatio:   req.ValRatio,
+		ClassNames: l.classNames,
+		MinScore:   req.MinScore,
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]any{
+		"out":   req.Out,
+		"train": stats.Train,
+		"val":   stats.Val,
+		"boxes": stats.Boxes,
+	})
+}
+
+func writeJson(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = jsonv2.MarshalWrite(w, v, jsontext.WithIndent("  "))
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJson(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeStoreError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
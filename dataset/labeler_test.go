@@ -0,0 +1,167 @@
+package dataset
+
+import (
+	"bytes"
+	jsonv2 "encoding/json/v2"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newLabelerServer(t *testing.T, n int) (*httptest.Server, *Store) {
+	t.Helper()
+	store := newTestStore(t, n)
+	mux := http.NewServeMux()
+	NewLabeler(store, []string{"person", "bicycle", "car"}).Register(mux, "/label")
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, store
+}
+
+func doJson(t *testing.T, method, url string, body any, out any) int {
+	t.Helper()
+	var r io.Reader
+	if body != nil {
+		data, err := jsonv2.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := jsonv2.UnmarshalRead(resp.Body, out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestLabelerPage(t *testing.T) {
+	srv, _ := newLabelerServer(t, 1)
+	resp, err := http.Get(srv.URL + "/label/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "<canvas") {
+		t.Fatalf("page: %d", resp.StatusCode)
+	}
+}
+
+func TestLabelerList(t *testing.T) {
+	srv, _ := newLabelerServer(t, 7)
+
+	var page FramePage
+	if code := doJson(t, "GET", srv.URL+"/label/api/frames?page=1&size=3", nil, &page); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if page.Total != 7 || len(page.Frames) != 3 || page.Frames[0].Boxes != 3 {
+		t.Fatalf("page = %+v", page)
+	}
+
+	doJson(t, "GET", srv.URL+"/label/api/frames?page=2&size=3", nil, &page)
+	if len(page.Frames) != 1 {
+		t.Fatalf("last page = %+v", page)
+	}
+	doJson(t, "GET", srv.URL+"/label/api/frames?page=9&size=3", nil, &page)
+	if len(page.Frames) != 0 {
+		t.Fatalf("out of range page = %+v", page)
+	}
+}
+
+func TestLabelerEdit(t *testing.T) {
+	srv, store := newLabelerServer(t, 2)
+	records, _ := store.List()
+	name := records[0].Name
+	frameUrl := srv.URL + "/label/api/frames/" + name
+
+	var rec Record
+	if code := doJson(t, "GET", frameUrl, nil, &rec); code != http.StatusOK || rec.Name != name {
+		t.Fatalf("get: %d %+v", code, rec)
+	}
+
+	// 删除一个框、移动一个框、改一个框的类别、新增一个越界框
+	update := LabelUpdate{
+		Boxes: []Box{
+			{Class: 1, Score: 0.9, X1: 60, Y1: 30, X2: 160, Y2: 80, Source: SOURCE_LOCAL},
+			{Class: 2, X1: 150, Y1: 50, X2: 300, Y2: 90},
+		},
+		Reviewed: true,
+	}
+	if code := doJson(t, "PUT", frameUrl, update, &rec); code != http.StatusOK {
+		t.Fatalf("put: %d", code)
+	}
+	saved, _ := store.Load(name)
+	if !saved.Reviewed || len(saved.Boxes) != 2 {
+		t.Fatalf("saved = %+v", saved)
+	}
+	if b := saved.Boxes[1]; b.X2 != 200 || b.Source != SOURCE_MANUAL {
+		t.Fatalf("new box not clipped/marked manual: %+v", b)
+	}
+
+	bad := LabelUpdate{Boxes: []Box{{Class: 7, X1: 0, Y1: 0, X2: 10, Y2: 10}}}
+	if code := doJson(t, "PUT", frameUrl, bad, nil); code != http.StatusBadRequest {
+		t.Fatalf("unknown class: %d", code)
+	}
+	bad = LabelUpdate{Boxes: []Box{{Class: 0, X1: 500, Y1: 500, X2: 600, Y2: 600}}}
+	if code := doJson(t, "PUT", frameUrl, bad, nil); code != http.StatusBadRequest {
+		t.Fatalf("box outside image: %d", code)
+	}
+	if code := doJson(t, "PUT", srv.URL+"/label/api/frames/..%2Fescape", update, nil); code != http.StatusNotFound {
+		t.Fatalf("path escape: %d", code)
+	}
+
+	resp, err := http.Get(frameUrl + "/image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("image: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	if code := doJson(t, "DELETE", frameUrl, nil, nil); code != http.StatusNoContent {
+		t.Fatalf("delete: %d", code)
+	}
+	if code := doJson(t, "GET", frameUrl, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("get after delete: %d", code)
+	}
+	if _, err := os.Stat(store.ImagePath(name)); !os.IsNotExist(err) {
+		t.Fatalf("image not deleted: %v", err)
+	}
+}
+
+func TestLabelerExport(t *testing.T) {
+	srv, store := newLabelerServer(t, 4)
+
+	var res struct {
+		Out   string `json:"out"`
+		Train int    `json:"train"`
+		Val   int    `json:"val"`
+	}
+	code := doJson(t, "POST", srv.URL+"/label/api/export", ExportRequest{Format: FORMAT_COCO, ValRatio: 0.25}, &res)
+	if code != http.StatusOK || res.Train+res.Val != 4 {
+		t.Fatalf("export: %d %+v", code, res)
+	}
+	if _, err := os.Stat(filepath.Join(store.Dir(), "export_coco", "annotations", "instances_train.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := doJson(t, "POST", srv.URL+"/label/api/export", ExportRequest{Format: "voc"}, nil); code != http.StatusBadRequest {
+		t.Fatalf("unknown format: %d", code)
+	}
+}
@@ -0,0 +1,59 @@
+package dataset
+
+import (
+	"image"
+	"math/bits"
+)
+
+// DHash 差值感知哈希：缩到 9×8 灰度, 逐行比较相邻像素亮度得到 64 bit。
+// 对缩放/压缩/轻微亮度变化不敏感, 汉明距离小即画面几乎相同。
+func DHash(img image.Image) uint64 {
+	const w, h = 9, 8
+	var gray [h][w]uint64
+
+	b := img.Bounds()
+	rgba, _ := img.(*image.RGBA)
+	for gy := range h {
+		y0 := b.Min.Y + gy*b.Dy()/h
+		y1 := max(y0+1, b.Min.Y+(gy+1)*b.Dy()/h)
+		for gx := range w {
+			x0 := b.Min.X + gx*b.Dx()/w
+			x1 := max(x0+1, b.Min.X+(gx+1)*b.Dx()/w)
+
+			// 块内隔点采样求均值, 大图上足够稳定且开销可控
+			stepX, stepY := max(1, (x1-x0)/16), max(1, (y1-y0)/16)
+			var sum, n uint64
+			for y := y0; y < y1; y += stepY {
+				for x := x0; x < x1; x += stepX {
+					var r, g, bb uint32
+					if rgba != nil {
+						i := rgba.PixOffset(x, y)
+						r, g, bb = uint32(rgba.Pix[i]), uint32(rgba.Pix[i+1]), uint32(rgba.Pix[i+2])
+					} else {
+						r, g, bb, _ = img.At(x, y).RGBA()
+						r, g, bb = r>>8, g>>8, bb>>8
+					}
+					sum += uint64(299*r + 587*g + 114*bb)
+					n++
+				}
+			}
+			gray[gy][gx] = sum / max(n, 1)
+		}
+	}
+
+	var hash uint64
+	for y := range h {
+		for x := range w - 1 {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HashDistance 两个哈希的汉明距离（0~64）。
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,312 @@
+package dataset
+
+import (
+	"context"
+	"image"
+	"sync"
+	"time"
+
+	"github.com/Miuzarte/GoCVStreamer/detector"
+)
+
+const (
+	TRIGGER_INTERVAL = "interval" // 定时采样
+	TRIGGER_LOW_CONF = "lowconf"  // 存在低置信度检测
+	TRIGGER_DISAGREE = "disagree" // 本地与远程结果不一致
+	TRIGGER_MANUAL   = "manual"
+)
+
+type Config struct {
+	Dir string
+
+	Interval    time.Duration // 定时采样间隔, 0=关闭
+	LowConf     float32       // 任一检测得分低于该值时采样, 0=关闭
+	Disagree    bool          // 本地与远程结果不一致时采样（需要两个推理源）
+	DisagreeIou float32       // 同类框 IoU 达到该值视为一致
+
+	Poll   time.Duration // 触发器检查周期
+	MinGap time.Duration // 任意两次自动采样的最小间隔
+
+	HashDistance int // 与已有样本 DHash 汉明距离 ≤ 该值视为重复, <0 关闭去重
+	JpegQuality  int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Dir: "dataset",
+
+		Interval:    0,
+		LowConf:     0.6,
+		Disagree:    true,
+		DisagreeIou: 0.5,
+
+		Poll:   100 * time.Millisecond,
+		MinGap: 2 * time.Second,
+
+		HashDistance: 4,
+		JpegQuality:  95,
+	}
+}
+
+// FrameSource 采样帧来源（由 capturer.Server 实现）。
+type FrameSource interface {
+	ReadFrameId() uint64
+	CloneRgba() *image.RGBA
+}
+
+type Stats struct {
+	Saved       uint64
+	Duplicates  uint64
+	Failures    uint64
+	LastTrigger string
+	LastSaved   time.Time
+}
+
+// Recorder 按触发器从实时画面采集样本。
+// 检测结果取自采样时刻的 Snapshot, 与帧之间可能相差一次推理的延迟。
+type Recorder struct {
+	cfg    Config
+	store  *Store
+	frames FrameSource
+	local  detector.Snapshotter // 可为 nil
+	remote detector.Snapshotter // 可为 nil
+
+	mu           sync.Mutex
+	hashes       []uint64
+	stats        Stats
+	lastSave     time.Time
+	lastInterval time.Time
+}
+
+// New 打开（或创建）cfg.Dir, 并载入已有样本的哈希用于去重。
+func New(frames FrameSource, local, remote detector.Snapshotter, cfg Config) (*Recorder, error) {
+	def := DefaultConfig()
+	if cfg.Poll <= 0 {
+		cfg.Poll = def.Poll
+	}
+	if cfg.JpegQuality <= 0 {
+		cfg.JpegQuality = def.JpegQuality
+	}
+
+	store, err := Open(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	records, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]uint64, len(records))
+	for i, rec := range records {
+		hashes[i] = rec.Hash
+	}
+
+	log.Info().
+		Str("dir", cfg.Dir).
+		Int("existing", len(records)).
+		Msg("dataset recorder opened")
+
+	return &Recorder{
+		cfg:    cfg,
+		store:  store,
+		frames: frames,
+		local:  local,
+		remote: remote,
+		hashes: hashes,
+	}, nil
+}
+
+func (r *Recorder) Store() *Store {
+	return r.store
+}
+
+func (r *Recorder) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+func (r *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Poll)
+	defer ticker.Stop()
+
+	var lastFrameId uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			id := r.frames.ReadFrameId()
+			if id == lastFrameId {
+				continue
+			}
+			lastFrameId = id
+
+			trigger := r.check(now)
+			if trigger == "" {
+				continue
+			}
+			if _, _, err := r.Capture(trigger); err != nil {
+				log.Warn().
+					Err(err).
+					Str("trigger", trigger).
+					Msg("capture failed")
+			}
+		}
+	}
+}
+
+// check 返回本次应触发的采样原因, 不采样时为空。
+func (r *Recorder) check(now time.Time) string {
+	r.mu.Lock()
+	lastSave, lastInterval := r.lastSave, r.lastInterval
+	r.mu.Unlock()
+
+	if now.Sub(lastSave) < r.cfg.MinGap {
+		return ""
+	}
+	if r.cfg.Interval > 0 && now.Sub(lastInterval) >= r.cfg.Interval {
+		r.mu.Lock()
+		r.lastInterval = now
+		r.mu.Unlock()
+		return TRIGGER_INTERVAL
+	}
+
+	local := snapshot(r.local)
+	remote := snapshot(r.remote)
+	if r.cfg.LowConf > 0 && (lowConf(local, r.cfg.LowConf) || lowConf(remote, r.cfg.LowConf)) {
+		return TRIGGER_LOW_CONF
+	}
+	if r.cfg.Disagree && r.local != nil && r.remote != nil && disagree(local, remote, r.cfg.DisagreeIou) {
+		return TRIGGER_DISAGREE
+	}
+	return ""
+}
+
+// Capture 立即采集当前帧；与已有样本重复时返回 saved=false。
+func (r *Recorder) Capture(trigger string) (rec Record, saved bool, err error) {
+	frameId := r.frames.ReadFrameId()
+	img := r.frames.CloneRgba()
+	if img == nil {
+		return Record{}, false, nil
+	}
+	now := time.Now()
+	hash := DHash(img)
+
+	r.mu.Lock()
+	if r.isDuplicateLocked(hash) {
+		r.stats.Duplicates++
+		r.lastSave = now // 重复画面同样计入间隔, 避免静止画面下反复计算哈希
+		r.mu.Unlock()
+		log.Debug().
+			Str("trigger", trigger).
+			Msg("skip near-duplicate frame")
+		return Record{}, false, nil
+	}
+	r.mu.Unlock()
+
+	rec = Record{
+		Name:    recordName(now, frameId),
+		FrameId: frameId,
+		Time:    now,
+		Trigger: trigger,
+		Hash:    hash,
+	}
+
+	// 本地结果作为标注, 远程结果作为复核参考；只有远程时用远程结果标注
+	primary, secondary := snapshot(r.local), snapshot(r.remote)
+	if r.local == nil {
+		primary, secondary = secondary, nil
+	}
+	rec.Boxes = make([]Box, 0, len(primary))
+	for _, res := range primary {
+		rec.Boxes = append(rec.Boxes, BoxFromResult(res))
+	}
+	for _, res := range secondary {
+		rec.Candidates = append(rec.Candidates, BoxFromResult(res))
+	}
+
+	err = r.store.Save(rec, img, r.cfg.JpegQuality)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastSave = now
+	if err != nil {
+		r.stats.Failures++
+		return Record{}, false, err
+	}
+	r.hashes = append(r.hashes, hash)
+	r.stats.Saved++
+	r.stats.LastTrigger = trigger
+	r.stats.LastSaved = now
+
+	log.Info().
+		Str("name", rec.Name).
+		Str("trigger", trigger).
+		Int("boxes", len(rec.Boxes)).
+		Msg("frame recorded")
+	return rec, true, nil
+}
+
+func (r *Recorder) isDuplicateLocked(hash uint64) bool {
+	if r.cfg.HashDistance < 0 {
+		return false
+	}
+	for _, h := range r.hashes {
+		if HashDistance(h, hash) <= r.cfg.HashDistance {
+			return true
+		}
+	}
+	return false
+}
+
+func snapshot(src detector.Snapshotter) []detector.Result {
+	if src == nil {
+		return nil
+	}
+	results, _, fresh := src.Snapshot()
+	if !fresh {
+		return nil
+	}
+	return results
+}
+
+func lowConf(results []detector.Result, thresh float32) bool {
+	for _, res := range results {
+		if res.Score < thresh {
+			return true
+		}
+	}
+	return false
+}
+
+// disagree 两组结果按类别与 IoU 贪心配对, 任一侧存在未配对的框即视为不一致。
+func disagree(a, b []detector.Result, iouThresh float32) bool {
+	if len(a) != len(b) {
+		return true
+	}
+	used := make([]bool, len(b))
+next:
+	for _, ra := range a {
+		for j, rb := range b {
+			if used[j] || ra.ClassID != rb.ClassID {
+				continue
+			}
+			if iou(ra.Box, rb.Box) >= iouThresh {
+				used[j] = true
+				continue next
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func iou(a, b image.Rectangle) float32 {
+	inter := a.Intersect(b)
+	if inter.Empty() {
+		return 0
+	}
+	i := inter.Dx() * inter.Dy()
+	return float32(i) / float32(a.Dx()*a.Dy()+b.Dx()*b.Dy()-i)
+}
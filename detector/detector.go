@@ -1,20 +1,19 @@
 package detector
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
 	"image"
-	"image/draw"
-	"math"
-	"runtime"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gioui.org/layout"
 
-	"github.com/Miuzarte/GoCVStreamer/capturer"
-	"github.com/Miuzarte/GoCVStreamer/cuda"
 	"github.com/Miuzarte/GoCVStreamer/fps"
-	"github.com/Miuzarte/GoCVStreamer/libyuv"
 	"github.com/Miuzarte/GoCVStreamer/logger"
 	"github.com/Miuzarte/GoCVStreamer/timing"
 	"github.com/Miuzarte/GoCVStreamer/ui"
@@ -28,10 +27,15 @@ type Config struct {
 	Fps     int
 	FpsIdle int
 
+	// Backend 推理后端：yolo（默认，本地 ONNX Runtime）、mock、http
+	Backend string
+	Http    HttpConfig
+
 	ModelPath          string
 	OnnxLibPath        string
 	ConfThresh         float32
 	InputSize          int
+	ClassNames         []string // 为空时为 CocoClassNames
 	UseCuda            bool
 	UseTensorRT        bool
 	TensorRTPluginPath string
@@ -40,6 +44,14 @@ type Config struct {
 	ResultIds utils.Set[int]
 
 	CropSize int // 中心裁剪边长：-1=屏幕短边（自动），0=不裁剪，>0=固定值
+
+	// 多区域检测（见 PlanRegions）：每个区域单独缩放到 InputSize 推理，
+	// 结果映射回屏幕坐标后跨区域做类别感知 NMS
+	Regions     []image.Rectangle // 显式区域（屏幕坐标），优先于 TileSize/CropSize
+	TileSize    int               // >0 时按 SAHI 方式自动切重叠正方形块
+	TileOverlap float64           // 相邻块重叠比例
+	NmsIou      float32           // 同类框 IoU 超过该值视为重复
+	NmsIos      float32           // 不同区域同类框，小框被覆盖超过该比例视为块边缘截断的重复
 }
 
 func DefaultConfig() Config {
@@ -47,6 +59,9 @@ func DefaultConfig() Config {
 		Fps:     30,
 		FpsIdle: 0,
 
+		Backend: BACKEND_YOLO,
+		Http:    DefaultHttpConfig(),
+
 		ModelPath:          `B:\Git\go-vision\_weights\yolo26_weights\yolo26n.onnx`,
 		OnnxLibPath:        `B:\Git\GoCVStreamer\libs\onnxruntime-win-x64-gpu_cuda13-1.28.0\lib\onnxruntime.dll`,
 		ConfThresh:         0.45,
@@ -56,22 +71,59 @@ func DefaultConfig() Config {
 		TensorRTPluginPath: `B:\Lib\TensorRT-RTX-EP-ABI-v0.3.0-cu13\onnxruntime_providers_nv_tensorrt_rtx.dll`,
 
 		ResultIds: utils.NewSet(0),
+
+		TileOverlap: 0.2,
+		NmsIou:      0.5,
+		NmsIos:      0.8,
 	}
 }
 
 type Stats struct {
-	Fps   float64
-	Cost  time.Duration
-	Count int
+	Fps        float64
+	Cost       time.Duration
+	ServerCost time.Duration // 远程后端上报的纯推理耗时（Cost 其余部分为网络与编解码）
+	Count      int
+	Regions    []RegionStats // 每个区域的耗时与检测数，Cost 为其总和
+	Profile    string        // 当前生效的模型配置档
+	Backend    string
+
+	// 流水线各阶段：Cost 为推理阶段
+	PreCost  time.Duration // 读帧 + 裁剪缩放
+	PostCost time.Duration // 映射 + 合并
+	Latency  time.Duration // 开始预处理到结果发布
+	FrameId  uint64        // 当前结果对应的帧号
+	Dropped  uint64        // 累计丢弃的过期帧
+}
+
+// FrameSource 检测帧来源（由 capturer.Server 实现，测试时可替换）。
+type FrameSource interface {
+	Bounds() image.Rectangle
+	RaiseCeiling(fps int)
+	ReadFrameId() uint64
+	ReadRgba() *image.RGBA
+}
+
+// model 当前生效的推理后端及其相关参数，换模型时整体替换。
+type model struct {
+	inferencer Inferencer
+	profile    Profile
+	inputSize  int
+	resultIds  utils.Set[int]
 }
 
 type Engine struct {
 	mu         sync.RWMutex
 	fpsCounter fps.Counter
 
-	capturerServer *capturer.Server
-	detEngine      *yolo26.DetEngine
-	cfg            Config
+	source FrameSource
+	cfg    Config
+
+	// model 由 mu 保护；inferMu 在一帧推理期间持有，换模型时借此等待进行中的推理结束
+	inferMu       sync.Mutex
+	model         *model
+	prevProfile   *Profile
+	loadMu        sync.Mutex // 串行化 LoadProfile
+	newInferencer func(Config) (Inferencer, error)
 
 	// result
 	stats         Stats
@@ -80,65 +132,320 @@ type Engine struct {
 
 	idleCheck func() bool
 	diag      *timing.Diag
+
+	// 供 LocalSource 的统计与健康状态，由 mu 保护
+	published   uint64
+	lastPublish time.Time
+	lastErr     error
+	idle        bool
+
+	dropped atomic.Uint64
+
+	// DetectFrame 的区域缓冲, 按帧尺寸缓存
+	frameBounds image.Rectangle
+	frameJob    *frameJob
 }
 
-func New(capturerServer *capturer.Server, cfg Config) (*Engine, error) {
-	yCfg := yolo26.Config{
-		ModelPath:          cfg.ModelPath,
-		OnnxRuntimeLibPath: cfg.OnnxLibPath,
-		ConfThreshold:      cfg.ConfThresh,
-		InputSize:          cfg.InputSize,
-		UseCuda:            cfg.UseCuda,
-		UseTensorRT:        cfg.UseTensorRT,
-		TensorRTPluginPath: cfg.TensorRTPluginPath,
+// New 按 cfg.Backend 创建推理后端。
+func New(source FrameSource, cfg Config) (*Engine, error) {
+	inferencer, err := NewInferencer(cfg)
+	if err != nil {
+		return nil, err
 	}
+	return NewWithInferencer(source, cfg, inferencer), nil
+}
 
-	detEngine, err := yolo26.NewDetEngine(yCfg)
+// NewWithInferencer 使用外部创建的推理后端；cfg.InputSize 为 0 时取后端元数据。
+func NewWithInferencer(source FrameSource, cfg Config, inferencer Inferencer) *Engine {
+	if cfg.InputSize <= 0 {
+		cfg.InputSize = inferencer.Meta().InputSize
+	}
+	e := newEngine(source, cfg)
+	e.model = &model{
+		inferencer: inferencer,
+		profile:    ProfileFromConfig(PROFILE_BUILTIN, cfg),
+		inputSize:  cfg.InputSize,
+		resultIds:  cfg.ResultIds,
+	}
+	return e
+}
+
+// NewFromProfile 按配置档（覆盖 cfg）创建后端并预热。
+func NewFromProfile(source FrameSource, cfg Config, p Profile) (*Engine, error) {
+	e := newEngine(source, cfg)
+	m, err := e.buildModel(p)
 	if err != nil {
 		return nil, err
 	}
+	e.model = m
+	if e.cfg.InputSize <= 0 {
+		e.cfg.InputSize = m.inputSize
+	}
+	return e, nil
+}
 
+func newEngine(source FrameSource, cfg Config) *Engine {
 	return &Engine{
 		fpsCounter: fps.NewCounter(time.Second),
 
-		cfg:            cfg,
-		capturerServer: capturerServer,
-		detEngine:      detEngine,
+		cfg:           cfg,
+		source:        source,
+		newInferencer: NewInferencer,
 
 		diag: timing.NewDiag("Detect"),
-	}, nil
+	}
 }
 
 func (e *Engine) Close() error {
-	if e.detEngine != nil {
-		e.detEngine.Destroy()
+	e.inferMu.Lock()
+	defer e.inferMu.Unlock()
+	if m := e.current(); m != nil && m.inferencer != nil {
+		return m.inferencer.Close()
 	}
-	cuda.DestroyCurrentContext()
 	return nil
 }
 
-func (e *Engine) Detect(img image.Image) error {
-	tStart := time.Now()
+func (e *Engine) current() *model {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.model
+}
 
-	results, err := e.detEngine.Predict(img)
-	e.stats.Cost = time.Since(tStart)
+// Meta 返回当前推理后端的元数据。
+func (e *Engine) Meta() Meta {
+	return e.current().inferencer.Meta()
+}
+
+// Profile 返回当前生效的模型配置档。
+func (e *Engine) Profile() Profile {
+	return e.current().profile
+}
+
+// WARMUP_RUNS 换模型前的预热推理次数（TensorRT 首次推理会构建引擎，耗时较长）
+const WARMUP_RUNS = 3
+
+// buildModel 按配置档依次尝试各执行提供者创建后端并预热，返回第一个成功的。
+func (e *Engine) buildModel(p Profile) (*model, error) {
+	cfg := p.Apply(e.cfg)
+	providers := p.Providers
+	if (cfg.Backend != "" && cfg.Backend != BACKEND_YOLO) || len(providers) == 0 {
+		providers = []string{""}
+	}
+
+	var errs []error
+	for _, provider := range providers {
+		c := withProvider(cfg, provider)
+		inferencer, err := e.newInferencer(c)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cmp.Or(provider, c.Backend), err))
+			continue
+		}
+		inputSize := c.InputSize
+		if inputSize <= 0 {
+			inputSize = inferencer.Meta().InputSize
+		}
+		cost, err := warmUp(inferencer, inputSize)
+		if err != nil {
+			closeInferencer(inferencer)
+			errs = append(errs, fmt.Errorf("%s: warm-up: %w", cmp.Or(provider, c.Backend), err))
+			continue
+		}
+		log.Info().
+			Str("profile", p.Name).
+			Str("backend", inferencer.Meta().Name).
+			Str("provider", provider).
+			Int("inputSize", inputSize).
+			Dur("warmup", cost).
+			Msg("model warmed up")
+		return &model{
+			inferencer: inferencer,
+			profile:    p,
+			inputSize:  inputSize,
+			resultIds:  c.ResultIds,
+		}, nil
+	}
+	return nil, fmt.Errorf("profile %q: %w", p.Name, errors.Join(errs...))
+}
+
+// warmUp 用空白图推理 WARMUP_RUNS 次，返回最后一次耗时。
+func warmUp(inferencer Inferencer, inputSize int) (cost time.Duration, err error) {
+	img := image.NewRGBA(image.Rect(0, 0, inputSize, inputSize))
+	for range WARMUP_RUNS {
+		tStart := time.Now()
+		if _, err = inferencer.Predict(img); err != nil {
+			return 0, err
+		}
+		cost = time.Since(tStart)
+	}
+	return cost, nil
+}
+
+// closeInferencer 换下的后端只释放自身资源（不销毁共享的 CUDA 上下文）。
+func closeInferencer(inferencer Inferencer) {
+	var err error
+	if r, ok := inferencer.(releaser); ok {
+		err = r.Release()
+	} else {
+		err = inferencer.Close()
+	}
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("backend", inferencer.Meta().Name).
+			Msg("failed to release replaced model")
+	}
+}
+
+// LoadProfile 按配置档创建新后端并预热，成功后原子替换当前后端，Run 无需停止；
+// 创建或预热失败时保留原后端并返回错误。
+func (e *Engine) LoadProfile(p Profile) error {
+	e.loadMu.Lock()
+	defer e.loadMu.Unlock()
+
+	next, err := e.buildModel(p)
 	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("profile", p.Name).
+			Str("active", e.Profile().Name).
+			Msg("model load failed, keeping active model")
 		return err
 	}
 
+	// 等待进行中的推理结束后再替换，旧后端此后不再被引用
+	e.inferMu.Lock()
 	e.mu.Lock()
-	e.personBuf = e.personBuf[:0]
-	for _, r := range results {
-		if e.cfg.ResultIds.Has1(r.ClassID) {
-			e.personBuf = append(e.personBuf, r)
-		}
+	prev := e.model
+	e.model = next
+	e.prevProfile = &prev.profile
+	e.personResults = nil // 新模型的类别与坐标不一定与旧结果可比
+	e.mu.Unlock()
+	e.inferMu.Unlock()
+
+	closeInferencer(prev.inferencer)
+	log.Info().
+		Str("profile", p.Name).
+		Str("previous", prev.profile.Name).
+		Msg("model swapped")
+	return nil
+}
+
+// Rollback 重新加载上一个配置档。
+func (e *Engine) Rollback() error {
+	e.mu.RLock()
+	prev := e.prevProfile
+	e.mu.RUnlock()
+	if prev == nil {
+		return ErrNoPreviousProfile
 	}
-	e.personResults = e.personBuf
+	return e.LoadProfile(*prev)
+}
+
+// Detect 对单张图像推理，结果保持在输入图像坐标系。
+func (e *Engine) Detect(img image.Image) error {
+	e.inferMu.Lock()
+	dets, cost, err := e.predict(e.current(), img)
+	e.inferMu.Unlock()
+	e.mu.Lock()
+	e.stats.Cost = cost
+	e.stats.Regions = nil
 	e.mu.Unlock()
+	if err != nil {
+		return err
+	}
 
+	e.mu.Lock()
+	e.personResults = dets
+	e.mu.Unlock()
 	return nil
 }
 
+// predict 推理并按 ResultIds 过滤，同时记录服务端耗时。
+// 调用方需持有 inferMu。
+func (e *Engine) predict(m *model, img image.Image) ([]yolo26.DetResult, time.Duration, error) {
+	tStart := time.Now()
+	results, err := m.inferencer.Predict(img)
+	cost := time.Since(tStart)
+	if lr, ok := m.inferencer.(latencyReporter); ok {
+		e.mu.Lock()
+		e.stats.ServerCost = lr.ServerLatency()
+		e.mu.Unlock()
+	}
+	if err != nil {
+		return nil, cost, err
+	}
+
+	dets := results[:0:0]
+	for _, r := range results {
+		if m.resultIds.Has1(r.ClassID) {
+			dets = append(dets, r)
+		}
+	}
+	return dets, cost, nil
+}
+
+// regionBuf 单个检测区域及其复用的缓冲。
+type regionBuf struct {
+	rect      image.Rectangle
+	inputSize int
+	img       *image.RGBA
+	resizeDst *image.RGBA
+}
+
+func newRegionBuf(r image.Rectangle, inputSize int) regionBuf {
+	buf := regionBuf{
+		rect:      r,
+		inputSize: inputSize,
+		img:       image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy())),
+	}
+	if r.Dx() != inputSize || r.Dy() != inputSize {
+		buf.resizeDst = image.NewRGBA(image.Rect(0, 0, inputSize, inputSize))
+	}
+	return buf
+}
+
+// input 返回送入推理的图像。
+func (r regionBuf) input() *image.RGBA {
+	if r.resizeDst != nil {
+		return r.resizeDst
+	}
+	return r.img
+}
+
+func newRegionBufs(regions []image.Rectangle, inputSize int) []regionBuf {
+	bufs := make([]regionBuf, len(regions))
+	for i, r := range regions {
+		bufs[i] = newRegionBuf(r, inputSize)
+	}
+	return bufs
+}
+
+// DetectFrame 对单帧同步走与 Run 相同的预处理→推理→后处理流程, 返回帧坐标结果。
+// 供离线评估等场景使用, 不可与 Run 并发调用。
+func (e *Engine) DetectFrame(frame *image.RGBA) ([]yolo26.DetResult, error) {
+	if e.frameJob == nil || e.frameBounds != frame.Bounds() {
+		e.frameBounds = frame.Bounds()
+		inputSize := e.current().inputSize
+		e.frameJob = &frameJob{
+			regions:   newRegionBufs(PlanRegions(frame.Bounds(), e.cfg), inputSize),
+			inputSize: inputSize,
+		}
+	}
+	if len(e.frameJob.regions) == 0 {
+		return nil, fmt.Errorf("no detection region inside frame %v", frame.Bounds())
+	}
+
+	job := e.frameJob
+	job.start = time.Now()
+	job.prepare(frame, e.current().inputSize)
+	res, err := e.infer(job)
+	if err != nil {
+		e.recordFailure(res, err)
+		return nil, err
+	}
+	return slices.Clone(e.publish(res)), nil
+}
+
 // Snapshot 实现 Source 接口：返回本地结果（屏幕坐标系）与最近一次推理延迟。
 func (e *Engine) Snapshot() (results []Result, latency time.Duration, fresh bool) {
 	e.mu.RLock()
@@ -156,164 +463,89 @@ func (e *Engine) Snapshot() (results []Result, latency time.Duration, fresh bool
 func (e *Engine) Stats() Stats {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
-	return e.stats
+	s := e.stats
+	s.Regions = slices.Clone(s.Regions)
+	s.Profile = e.model.profile.Name
+	s.Backend = e.model.inferencer.Meta().Name
+	s.Dropped = e.dropped.Load()
+	return s
 }
 
-func (e *Engine) OffsetResults(offset image.Point) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	for i := range e.personResults {
-		e.personResults[i].Box = e.personResults[i].Box.Add(offset)
+// health 推理失败为 down；空闲或超过 staleAfter 未出结果为 stale。
+func (e *Engine) health() Health {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	switch {
+	case e.lastErr != nil:
+		return Health{State: HealthDown, Reason: e.lastErr.Error()}
+	case e.idle:
+		return Health{State: HealthStale, Reason: "idle"}
+	case e.lastPublish.IsZero():
+		return Health{State: HealthStale, Reason: "no results yet"}
+	}
+	if since := time.Since(e.lastPublish); since > e.staleAfter() {
+		return Health{State: HealthStale, Reason: fmt.Sprintf("no results for %s", since.Round(time.Millisecond))}
 	}
+	return Health{State: HealthFresh}
 }
 
-func (e *Engine) ScaleResults(scaleX, scaleY float64) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	for i := range e.personResults {
-		r := &e.personResults[i]
-		r.Box = image.Rect(
-			int(float64(r.Box.Min.X)*scaleX),
-			int(float64(r.Box.Min.Y)*scaleY),
-			int(float64(r.Box.Max.X)*scaleX),
-			int(float64(r.Box.Max.Y)*scaleY),
-		)
-	}
+// staleAfter 5 个检测周期，至少 500ms。
+func (e *Engine) staleAfter() time.Duration {
+	return max(500*time.Millisecond, 5*time.Second/time.Duration(max(e.cfg.Fps, 1)))
 }
 
 func (e *Engine) SetIdleChecker(fn func() bool) {
 	e.idleCheck = fn
 }
 
+// Run 启动三段流水线：本 goroutine 读帧并预处理，inferLoop 推理，postLoop 映射合并并发布结果。
+// 预处理与推理通过 PIPELINE_BUFFERS 组区域缓冲并行；推理繁忙时待推理的只保留最新一帧。
 func (e *Engine) Run(ctx context.Context) {
-	runtime.LockOSThread()
-	defer runtime.UnlockOSThread()
-
-	bounds := e.capturerServer.Bounds()
-	localImg := image.NewRGBA(bounds)
-	var lastFrameId uint64
+	bounds := e.source.Bounds()
+	plan := PlanRegions(bounds, e.cfg)
+	if len(plan) == 0 {
+		log.Error().
+			Any("regions", e.cfg.Regions).
+			Any("bounds", bounds).
+			Msg("no detection region inside capture bounds")
+		return
+	}
+	inputSize := e.current().inputSize
+	log.Info().
+		Int("regions", len(plan)).
+		Int("inputSize", inputSize).
+		Msg("detection regions planned")
+
+	pool := make(chan *frameJob, PIPELINE_BUFFERS)
+	for range PIPELINE_BUFFERS {
+		pool <- &frameJob{regions: newRegionBufs(plan, inputSize), inputSize: inputSize}
+	}
+	inferCh := make(chan *frameJob, 1)
+	postCh := make(chan frameResult, 1)
+
+	var wg sync.WaitGroup
+	wg.Go(func() { e.inferLoop(inferCh, pool, postCh) })
+	wg.Go(func() { e.postLoop(postCh) })
+	e.prepareLoop(ctx, pool, inferCh)
+	close(inferCh)
+	wg.Wait()
+}
 
-	cropSize := e.cfg.CropSize
-	cropOffset := image.Pt(0, 0)
-	cropNeeded := false
-	if cropSize < 0 {
+// CenterCrop 计算居中正方形裁剪：cropSize -1=屏幕短边（自动），0=不裁剪，>0=固定值。
+// needed=false 时整帧直接缩放，offset 为零。
+func CenterCrop(bounds image.Rectangle, cropSize int) (size int, offset image.Point, needed bool) {
+	size = cropSize
+	if size < 0 {
 		// -1：自动使用屏幕短边（横屏下即屏幕高度），视野最大且保持正方形。
-		cropSize = min(bounds.Dx(), bounds.Dy())
-	} else if cropSize > 0 {
-		cropSize = min(cropSize, bounds.Dx(), bounds.Dy())
-	}
-	if cropSize > 0 && (cropSize < bounds.Dx() || cropSize < bounds.Dy()) {
-		cropNeeded = true
-		cropOffset = image.Pt((bounds.Dx()-cropSize)/2, (bounds.Dy()-cropSize)/2)
-	}
-
-	cropImg := image.NewRGBA(image.Rect(0, 0, cropSize, cropSize))
-	resizeDst := image.NewRGBA(image.Rect(0, 0, e.cfg.InputSize, e.cfg.InputSize))
-
-	interval := time.Second / time.Duration(e.cfg.Fps)
-	intervalIdle := time.Duration(math.MaxInt64)
-	if e.cfg.FpsIdle != 0 {
-		intervalIdle = time.Second / time.Duration(e.cfg.FpsIdle)
-	}
-
-	tickerNormal := time.NewTicker(interval)
-	defer tickerNormal.Stop()
-	tickerIdle := time.NewTicker(intervalIdle)
-	defer tickerIdle.Stop()
-	mixinTicker := make(chan time.Time, 2)
-	defer close(mixinTicker)
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-
-		case t, ok := <-tickerNormal.C:
-			if !ok {
-				return
-			}
-			if e.idleCheck != nil && e.idleCheck() {
-				e.mu.Lock()
-				e.stats = Stats{}
-				e.personResults = nil
-				e.mu.Unlock()
-				continue
-			}
-			select {
-			case mixinTicker <- t:
-			default:
-			}
-			continue
-
-		case t, ok := <-tickerIdle.C:
-			if !ok {
-				return
-			}
-			if e.idleCheck == nil || !e.idleCheck() {
-				continue
-			}
-			select {
-			case mixinTicker <- t:
-			default:
-			}
-			continue
-
-		case _, ok := <-mixinTicker:
-			if !ok {
-				return
-			}
-		}
-
-		fps := e.cfg.Fps
-		if e.idleCheck != nil && e.idleCheck() {
-			fps = e.cfg.FpsIdle
-		}
-		e.capturerServer.RaiseCeiling(fps)
-
-		id := e.capturerServer.ReadFrameId()
-		if id == lastFrameId {
-			continue
-		}
-		lastFrameId = id
-
-		captureRgba := e.capturerServer.ReadRgba()
-		if captureRgba == nil {
-			continue
-		}
-
-		var detectImg image.Image
-		if cropNeeded {
-			draw.Draw(cropImg, cropImg.Bounds(), captureRgba, cropOffset, draw.Src)
-			detectImg = cropImg
-		} else {
-			copy(localImg.Pix, captureRgba.Pix)
-			detectImg = localImg
-		}
-		origW := detectImg.Bounds().Dx()
-		origH := detectImg.Bounds().Dy()
-		libyuv.ResizeRGBAInto(resizeDst, detectImg.(*image.RGBA), e.cfg.InputSize, e.cfg.InputSize)
-		detectImg = resizeDst
-
-		err := e.Detect(detectImg)
-		if err != nil {
-			log.Warn().
-				Err(err).
-				Msg("yolo detection failed")
-			time.Sleep(time.Millisecond * 100)
-			continue
-		}
-		e.diag.Observe(e.stats.Cost, log)
-		if origW != e.cfg.InputSize || origH != e.cfg.InputSize {
-			e.ScaleResults(float64(origW)/float64(e.cfg.InputSize), float64(origH)/float64(e.cfg.InputSize))
-		}
-		if cropNeeded {
-			e.OffsetResults(cropOffset)
-		}
-
-		e.stats.Fps, _ = e.fpsCounter.Count()
+		size = min(bounds.Dx(), bounds.Dy())
+	} else if size > 0 {
+		size = min(size, bounds.Dx(), bounds.Dy())
+	}
+	if size > 0 && (size < bounds.Dx() || size < bounds.Dy()) {
+		needed = true
+		offset = image.Pt((bounds.Dx()-size)/2, (bounds.Dy()-size)/2)
 	}
+	return
 }
 
 func (e *Engine) Draw(gtx layout.Context, s ui.DScale) {
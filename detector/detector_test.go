@@ -0,0 +1,264 @@
+package detector
+
+import (
+	"context"
+	"image"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getcharzp/go-vision/yolo26"
+)
+
+// fakeFrames 是 FrameSource 的最小实现：固定尺寸的纯黑帧，每次读取帧号递增。
+type fakeFrames struct {
+	mu    sync.Mutex
+	rgba  *image.RGBA
+	id    uint64
+	delay time.Duration // 模拟读帧耗时
+}
+
+func newFakeFrames(w, h int) *fakeFrames {
+	return &fakeFrames{rgba: image.NewRGBA(image.Rect(0, 0, w, h))}
+}
+
+func (f *fakeFrames) Bounds() image.Rectangle { return f.rgba.Bounds() }
+func (f *fakeFrames) RaiseCeiling(int)        {}
+
+func (f *fakeFrames) ReadFrameId() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.id++
+	return f.id
+}
+
+func (f *fakeFrames) ReadRgba() *image.RGBA {
+	if f.delay > 0 {
+		time.Sleep(f.delay)
+	}
+	return f.rgba
+}
+
+func (f *fakeFrames) lastId() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.id
+}
+
+// waitFresh 等待 Engine 产出新鲜结果。
+func waitFresh(t *testing.T, e *Engine) []Result {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if results, _, fresh := e.Snapshot(); fresh {
+			return results
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("no fresh results")
+	return nil
+}
+
+func TestCenterCrop(t *testing.T) {
+	bounds := image.Rect(0, 0, 1920, 1080)
+	tests := []struct {
+		cropSize   int
+		wantSize   int
+		wantOffset image.Point
+		wantNeeded bool
+	}{
+		{-1, 1080, image.Pt(420, 0), true},
+		{0, 0, image.Point{}, false},
+		{640, 640, image.Pt(640, 220), true},
+		{4096, 1080, image.Pt(420, 0), true},
+	}
+	for _, tt := range tests {
+		size, offset, needed := CenterCrop(bounds, tt.cropSize)
+		if size != tt.wantSize || offset != tt.wantOffset || needed != tt.wantNeeded {
+			t.Errorf("CenterCrop(%d) = %d, %v, %v; want %d, %v, %v",
+				tt.cropSize, size, offset, needed, tt.wantSize, tt.wantOffset, tt.wantNeeded)
+		}
+	}
+
+	if _, _, needed := CenterCrop(image.Rect(0, 0, 640, 640), -1); needed {
+		t.Error("square frame should not need cropping")
+	}
+}
+
+// TestRunCropScaleOffset 验证 Run 的裁剪→缩放→推理→缩放回→偏移整条链路。
+func TestRunCropScaleOffset(t *testing.T) {
+	mock := NewMockBackend(Meta{InputSize: 640},
+		yolo26.DetResult{ClassID: 0, Score: 0.9, Box: image.Rect(64, 128, 320, 640)},
+		yolo26.DetResult{ClassID: 2, Score: 0.8, Box: image.Rect(0, 0, 10, 10)}, // 被 ResultIds 过滤
+	)
+
+	cfg := DefaultConfig()
+	cfg.Backend = BACKEND_MOCK
+	cfg.CropSize = 1280
+	cfg.Fps = 200
+	e := NewWithInferencer(newFakeFrames(2560, 1440), cfg, mock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx)
+
+	results := waitFresh(t, e)
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1: %+v", len(results), results)
+	}
+	if in := mock.LastInput(); in.Dx() != 640 || in.Dy() != 640 {
+		t.Fatalf("inference input = %v, want 640x640", in)
+	}
+
+	// 1280 裁剪 → 640 输入：×2；偏移 ((2560-1280)/2, (1440-1280)/2) = (640, 80)
+	want := image.Rect(64*2+640, 128*2+80, 320*2+640, 640*2+80)
+	if got := results[0].Box; got != want {
+		t.Fatalf("box = %v, want %v", got, want)
+	}
+	if results[0].Kind != KindLocal {
+		t.Fatalf("kind = %v, want KindLocal", results[0].Kind)
+	}
+}
+
+func TestRunNoCrop(t *testing.T) {
+	mock := NewMockBackend(Meta{InputSize: 320},
+		yolo26.DetResult{ClassID: 0, Score: 0.9, Box: image.Rect(32, 32, 160, 160)},
+	)
+
+	cfg := DefaultConfig()
+	cfg.CropSize = 0
+	cfg.InputSize = 0 // 取后端元数据
+	cfg.Fps = 200
+	e := NewWithInferencer(newFakeFrames(1280, 640), cfg, mock)
+	if e.cfg.InputSize != 320 {
+		t.Fatalf("InputSize = %d, want 320 from backend meta", e.cfg.InputSize)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx)
+
+	results := waitFresh(t, e)
+	// 1280×640 → 320×320：X ×4，Y ×2
+	want := image.Rect(32*4, 32*2, 160*4, 160*2)
+	if got := results[0].Box; got != want {
+		t.Fatalf("box = %v, want %v", got, want)
+	}
+}
+
+func TestRunIdleClearsResults(t *testing.T) {
+	mock := NewMockBackend(Meta{},
+		yolo26.DetResult{ClassID: 0, Score: 0.9, Box: image.Rect(0, 0, 64, 64)},
+	)
+
+	cfg := DefaultConfig()
+	cfg.Fps = 200
+	e := NewWithInferencer(newFakeFrames(640, 640), cfg, mock)
+
+	var idle sync.Mutex
+	isIdle := false
+	e.SetIdleChecker(func() bool {
+		idle.Lock()
+		defer idle.Unlock()
+		return isIdle
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx)
+	waitFresh(t, e)
+
+	idle.Lock()
+	isIdle = true
+	idle.Unlock()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if _, _, fresh := e.Snapshot(); !fresh {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("results not cleared when idle")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTiles(t *testing.T) {
+	bounds := image.Rect(0, 0, 1920, 1080)
+	tiles := Tiles(bounds, 640, 0.2)
+	// 步长 512：x 0,512,1024,1280；y 0,440
+	if len(tiles) != 8 {
+		t.Fatalf("got %d tiles: %v", len(tiles), tiles)
+	}
+	if last := tiles[len(tiles)-1]; last.Max != bounds.Max {
+		t.Fatalf("last tile %v not aligned to %v", last, bounds.Max)
+	}
+	for y := 0; y < bounds.Dy(); y += 7 {
+		for x := 0; x < bounds.Dx(); x += 7 {
+			if !slices.ContainsFunc(tiles, image.Pt(x, y).In) {
+				t.Fatalf("(%d,%d) not covered", x, y)
+			}
+		}
+	}
+
+	if tiles := Tiles(image.Rect(0, 0, 320, 200), 640, 0.2); len(tiles) != 1 || tiles[0] != image.Rect(0, 0, 320, 200) {
+		t.Fatalf("small frame tiles = %v", tiles)
+	}
+}
+
+func TestMergeRegions(t *testing.T) {
+	person := func(score float32, box image.Rectangle, region int) regionDet {
+		return regionDet{DetResult: yolo26.DetResult{ClassID: 0, Score: score, Box: box}, region: region}
+	}
+	dets := []regionDet{
+		person(0.9, image.Rect(100, 100, 200, 400), 0),
+		person(0.7, image.Rect(100, 100, 150, 400), 1), // 块边缘截断的半个目标
+		person(0.6, image.Rect(110, 120, 160, 300), 0), // 同区域内被遮挡的另一人
+		{DetResult: yolo26.DetResult{ClassID: 2, Score: 0.8, Box: image.Rect(100, 100, 200, 400)}, region: 1},
+	}
+	got := mergeRegions(dets, 0.5, 0.8)
+	if len(got) != 3 {
+		t.Fatalf("got %d results, want 3: %+v", len(got), got)
+	}
+	if got[0].Score != 0.9 || got[1].ClassID != 2 || got[2].Score != 0.6 {
+		t.Fatalf("unexpected merge: %+v", got)
+	}
+}
+
+func TestRunRegions(t *testing.T) {
+	mock := NewMockBackend(Meta{InputSize: 320},
+		yolo26.DetResult{ClassID: 0, Score: 0.9, Box: image.Rect(0, 0, 160, 320)},
+	)
+
+	cfg := DefaultConfig()
+	cfg.InputSize = 320
+	cfg.Regions = []image.Rectangle{
+		image.Rect(0, 0, 640, 640),
+		image.Rect(640, 0, 1280, 640),
+		image.Rect(2000, 0, 2100, 100), // 越界，忽略
+	}
+	cfg.Fps = 200
+	e := NewWithInferencer(newFakeFrames(1280, 640), cfg, mock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx)
+
+	results := waitFresh(t, e)
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2: %+v", len(results), results)
+	}
+	boxes := []image.Rectangle{results[0].Box, results[1].Box}
+	for _, want := range []image.Rectangle{image.Rect(0, 0, 320, 640), image.Rect(640, 0, 960, 640)} {
+		if !slices.Contains(boxes, want) {
+			t.Fatalf("boxes %v missing %v", boxes, want)
+		}
+	}
+
+	s := e.Stats()
+	if len(s.Regions) != 2 || s.Regions[1].Rect != cfg.Regions[1] || s.Regions[1].Count != 1 {
+		t.Fatalf("region stats = %+v", s.Regions)
+	}
+}
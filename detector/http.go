@@ -0,0 +1,347 @@
+package detector
+
+import (
+	"bytes"
+	"context"
+	jsonv2 "encoding/json/v2"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/getcharzp/go-vision/yolo26"
+)
+
+const (
+	// HTTP_PROTOCOL_JPEG POST image/jpeg，返回 {"detections":[...],"inference_ms":...}
+	HTTP_PROTOCOL_JPEG = "jpeg"
+	// HTTP_PROTOCOL_KSERVE KServe v2 / Triton 推理协议，张量以 JSON 数组传输
+	HTTP_PROTOCOL_KSERVE = "kserve"
+	// HTTP_PROTOCOL_KSERVE_BINARY KServe v2 + Triton binary tensor 扩展
+	HTTP_PROTOCOL_KSERVE_BINARY = "kserve-binary"
+)
+
+// ErrCircuitOpen 熔断期间直接拒绝请求，不再打到远端。
+var ErrCircuitOpen = errors.New("http backend: circuit open")
+
+type HttpConfig struct {
+	Url      string // jpeg: 完整地址；kserve: 形如 http://host:8000/v2/models/yolo26n/infer
+	Protocol string
+	Timeout  time.Duration
+
+	// KServe 张量名；输出形状 [1,N,6] 或 [N,6]，每行 x1,y1,x2,y2,score,class（输入像素坐标）
+	InputName  string
+	OutputName string
+	ConfThresh float32
+
+	// 连续失败 BreakerFailures 次后熔断 BreakerCooldown，之后放行一次探测请求
+	BreakerFailures int
+	BreakerCooldown time.Duration
+}
+
+func DefaultHttpConfig() HttpConfig {
+	return HttpConfig{
+		Protocol: HTTP_PROTOCOL_JPEG,
+		Timeout:  time.Second,
+
+		InputName:  "images",
+		OutputName: "output0",
+		ConfThresh: 0.45,
+
+		BreakerFailures: 5,
+		BreakerCooldown: 5 * time.Second,
+	}
+}
+
+// HttpStats 远程推理的延迟拆分与可用性。
+type HttpStats struct {
+	Requests    uint64
+	Failures    uint64
+	Rejected    uint64 // 熔断期间被拒绝的请求
+	Open        bool
+	LastLatency time.Duration // 全链路：编码 + 网络 + 服务端推理 + 解码
+	LastServer  time.Duration // 服务端上报的推理耗时（协议不提供时为 0）
+	LastError   string
+}
+
+// HttpBackend 远程 HTTP 推理后端，兼容 KServe v2 / Triton 与简单 JPEG 接口。
+type HttpBackend struct {
+	cfg     HttpConfig
+	client  *http.Client
+	meta    Meta
+	breaker breaker
+
+	mu    sync.Mutex
+	stats HttpStats
+}
+
+// httpDetection 与 sender.RemoteDetection 一致：坐标归一化到输入图像。
+type httpDetection struct {
+	X1        float64 `json:"x1"`
+	Y1        float64 `json:"y1"`
+	X2        float64 `json:"x2"`
+	Y2        float64 `json:"y2"`
+	Score     float64 `json:"score"`
+	Class     int     `json:"class"`
+	ClassName string  `json:"class_name"`
+}
+
+type httpResponse struct {
+	Detections  []httpDetection `json:"detections"`
+	InferenceMs float64         `json:"inference_ms"`
+}
+
+func NewHttpBackend(cfg Config) (*HttpBackend, error) {
+	hc := cfg.Http
+	if hc.Url == "" {
+		return nil, errors.New("http backend requires Http.Url")
+	}
+	def := DefaultHttpConfig()
+	if hc.Protocol == "" {
+		hc.Protocol = def.Protocol
+	}
+	switch hc.Protocol {
+	case HTTP_PROTOCOL_JPEG, HTTP_PROTOCOL_KSERVE, HTTP_PROTOCOL_KSERVE_BINARY:
+	default:
+		return nil, fmt.Errorf("unknown http protocol: %q", hc.Protocol)
+	}
+	if hc.Timeout <= 0 {
+		hc.Timeout = def.Timeout
+	}
+	if hc.InputName == "" {
+		hc.InputName = def.InputName
+	}
+	if hc.OutputName == "" {
+		hc.OutputName = def.OutputName
+	}
+	if hc.BreakerFailures <= 0 {
+		hc.BreakerFailures = def.BreakerFailures
+	}
+	if hc.BreakerCooldown <= 0 {
+		hc.BreakerCooldown = def.BreakerCooldown
+	}
+	inputSize := cfg.InputSize
+	if inputSize <= 0 {
+		inputSize = 640
+	}
+	return &HttpBackend{
+		cfg:    hc,
+		client: &http.Client{},
+		meta: Meta{
+			Name:       BACKEND_HTTP + "/" + hc.Protocol,
+			ClassNames: classNames(cfg),
+			InputSize:  inputSize,
+		},
+		breaker: breaker{threshold: hc.BreakerFailures, cooldown: hc.BreakerCooldown},
+	}, nil
+}
+
+func (b *HttpBackend) Predict(img image.Image) ([]yolo26.DetResult, error) {
+	if !b.breaker.allow(time.Now()) {
+		b.mu.Lock()
+		b.stats.Rejected++
+		b.stats.Open = true
+		b.mu.Unlock()
+		return nil, ErrCircuitOpen
+	}
+
+	tStart := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), b.cfg.Timeout)
+	defer cancel()
+
+	var dets []yolo26.DetResult
+	var server time.Duration
+	var err error
+	switch b.cfg.Protocol {
+	case HTTP_PROTOCOL_JPEG:
+		dets, server, err = b.predictJpeg(ctx, img)
+	default:
+		dets, err = b.predictKServe(ctx, img, b.cfg.Protocol == HTTP_PROTOCOL_KSERVE_BINARY)
+	}
+	latency := time.Since(tStart)
+
+	open := false
+	if err != nil {
+		open = b.breaker.failure(time.Now())
+	} else {
+		b.breaker.success()
+	}
+
+	b.mu.Lock()
+	b.stats.Requests++
+	b.stats.Open = open
+	b.stats.LastLatency = latency
+	if err != nil {
+		b.stats.Failures++
+		b.stats.LastError = err.Error()
+	} else {
+		b.stats.LastServer = server
+		b.stats.LastError = ""
+	}
+	b.mu.Unlock()
+
+	if err != nil {
+		if open {
+			log.Warn().
+				Err(err).
+				Dur("cooldown", b.cfg.BreakerCooldown).
+				Msg("http backend circuit opened")
+		}
+		return nil, err
+	}
+	return dets, nil
+}
+
+// Stats 返回请求计数、延迟拆分与熔断状态。
+func (b *HttpBackend) Stats() HttpStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+// ServerLatency 实现 latencyReporter。
+func (b *HttpBackend) ServerLatency() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats.LastServer
+}
+
+func (b *HttpBackend) Meta() Meta {
+	return b.meta
+}
+
+func (b *HttpBackend) Close() error {
+	b.client.CloseIdleConnections()
+	return nil
+}
+
+// post 发送请求并读取响应体（非 200 视为错误）。
+func (b *HttpBackend) post(req *http.Request) (*http.Response, []byte, error) {
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("http backend: %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	return resp, data, nil
+}
+
+func (b *HttpBackend) predictJpeg(ctx context.Context, img image.Image) ([]yolo26.DetResult, time.Duration, error) {
+	var body bytes.Buffer
+	if err := jpeg.Encode(&body, img, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, 0, fmt.Errorf("encode jpeg: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.cfg.Url, &body)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "image/jpeg")
+
+	_, data, err := b.post(req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var res httpResponse
+	if err := jsonv2.Unmarshal(data, &res); err != nil {
+		return nil, 0, fmt.Errorf("decode response: %w", err)
+	}
+
+	w, h := float64(img.Bounds().Dx()), float64(img.Bounds().Dy())
+	dets := make([]yolo26.DetResult, 0, len(res.Detections))
+	for _, d := range res.Detections {
+		dets = append(dets, yolo26.DetResult{
+			ClassID: d.Class,
+			Score:   float32(d.Score),
+			Box: image.Rect(
+				int(d.X1*w+0.5), int(d.Y1*h+0.5),
+				int(d.X2*w+0.5), int(d.Y2*h+0.5),
+			),
+		})
+	}
+	return dets, time.Duration(res.InferenceMs * float64(time.Millisecond)), nil
+}
+
+func (b *HttpBackend) predictKServe(ctx context.Context, img image.Image, binary bool) ([]yolo26.DetResult, error) {
+	body, headerLen, err := encodeKServeRequest(img, b.cfg.InputName, b.cfg.OutputName, binary)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.cfg.Url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if binary {
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set(KSERVE_HEADER_LENGTH, fmt.Sprint(headerLen))
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, data, err := b.post(req)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := decodeKServeResponse(data, resp.Header.Get(KSERVE_HEADER_LENGTH), b.cfg.OutputName)
+	if err != nil {
+		return nil, err
+	}
+	return out.detections(b.cfg.ConfThresh)
+}
+
+// breaker 连续失败计数熔断器：open 期间拒绝请求，冷却结束后放行一次探测（half-open）。
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (br *breaker) allow(now time.Time) bool {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	if br.failures < br.threshold {
+		return true
+	}
+	if now.Before(br.openUntil) || br.probing {
+		return false
+	}
+	br.probing = true
+	return true
+}
+
+func (br *breaker) success() {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.failures = 0
+	br.probing = false
+}
+
+// failure 记录一次失败，返回是否（重新）进入熔断。
+func (br *breaker) failure(now time.Time) bool {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.failures++
+	br.probing = false
+	if br.failures >= br.threshold {
+		br.openUntil = now.Add(br.cooldown)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,234 @@
+package detector
+
+import (
+	"encoding/binary"
+	jsonv2 "encoding/json/v2"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newHttpBackend(t *testing.T, url, protocol string, edit func(*HttpConfig)) *HttpBackend {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Backend = BACKEND_HTTP
+	cfg.Http.Url = url
+	cfg.Http.Protocol = protocol
+	if edit != nil {
+		edit(&cfg.Http)
+	}
+	b, err := NewHttpBackend(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func TestHttpJpeg(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "image/jpeg" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		img, err := jpeg.Decode(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if img.Bounds().Dx() != 640 {
+			t.Errorf("width = %d", img.Bounds().Dx())
+		}
+		fmt.Fprint(w, `{"detections":[{"x1":0.1,"y1":0.2,"x2":0.5,"y2":0.6,"score":0.9,"class":0}],"inference_ms":7.5}`)
+	}))
+	defer srv.Close()
+
+	b := newHttpBackend(t, srv.URL, HTTP_PROTOCOL_JPEG, nil)
+	dets, err := b.Predict(image.NewRGBA(image.Rect(0, 0, 640, 640)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dets) != 1 {
+		t.Fatalf("got %d detections", len(dets))
+	}
+	if want := image.Rect(64, 128, 320, 384); dets[0].Box != want {
+		t.Fatalf("box = %v, want %v", dets[0].Box, want)
+	}
+	if got := b.ServerLatency(); got != 7500*time.Microsecond {
+		t.Fatalf("server latency = %v", got)
+	}
+	if s := b.Stats(); s.Requests != 1 || s.Failures != 0 || s.LastLatency < s.LastServer {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+// kserveServer 模拟 Triton：校验输入张量，返回两行 [x1,y1,x2,y2,score,class]（第二行低于阈值）。
+func kserveServer(t *testing.T, binaryOut bool) *httptest.Server {
+	rows := []float32{10, 20, 110, 220, 0.9, 0, 0, 0, 5, 5, 0.1, 2}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		header := data
+		var blob []byte
+		if s := r.Header.Get(KSERVE_HEADER_LENGTH); s != "" {
+			n, _ := strconv.Atoi(s)
+			header, blob = data[:n], data[n:]
+		}
+		var req kserveRequest
+		if err := jsonv2.Unmarshal(header, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		in := req.Inputs[0]
+		if in.Name != "images" || in.Datatype != "FP32" || fmt.Sprint(in.Shape) != "[1 3 32 64]" {
+			t.Errorf("input = %s %s %v", in.Name, in.Datatype, in.Shape)
+		}
+		n := 3 * 32 * 64
+		if len(in.Data)+len(blob)/4 != n {
+			t.Errorf("input values = %d json + %d binary, want %d", len(in.Data), len(blob)/4, n)
+		}
+
+		if !binaryOut {
+			out := make([]float64, len(rows))
+			for i, v := range rows {
+				out[i] = float64(v)
+			}
+			jsonv2.MarshalWrite(w, kserveResponse{
+				ModelName: "yolo",
+				Outputs:   []kserveTensor{{Name: "output0", Shape: []int{1, 2, 6}, Datatype: "FP32", Data: out}},
+			})
+			return
+		}
+
+		raw := make([]byte, 0, len(rows)*4)
+		for _, v := range rows {
+			raw = binary.LittleEndian.AppendUint32(raw, math.Float32bits(v))
+		}
+		resHeader, _ := jsonv2.Marshal(kserveResponse{
+			ModelName: "yolo",
+			Outputs: []kserveTensor{
+				{Name: "other", Shape: []int{1}, Datatype: "FP32", Parameters: &kserveParams{BinaryDataSize: 4}},
+				{Name: "output0", Shape: []int{1, 2, 6}, Datatype: "FP32", Parameters: &kserveParams{BinaryDataSize: len(raw)}},
+			},
+		})
+		w.Header().Set(KSERVE_HEADER_LENGTH, strconv.Itoa(len(resHeader)))
+		w.Write(resHeader)
+		w.Write([]byte{0, 0, 0, 0})
+		w.Write(raw)
+	}))
+}
+
+func TestHttpKServe(t *testing.T) {
+	for _, tt := range []struct {
+		protocol  string
+		binaryOut bool
+	}{
+		{HTTP_PROTOCOL_KSERVE, false},
+		{HTTP_PROTOCOL_KSERVE_BINARY, true},
+	} {
+		t.Run(tt.protocol, func(t *testing.T) {
+			srv := kserveServer(t, tt.binaryOut)
+			defer srv.Close()
+
+			b := newHttpBackend(t, srv.URL, tt.protocol, nil)
+			dets, err := b.Predict(image.NewRGBA(image.Rect(0, 0, 64, 32)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(dets) != 1 {
+				t.Fatalf("got %d detections, want 1 above threshold: %+v", len(dets), dets)
+			}
+			if want := image.Rect(10, 20, 110, 220); dets[0].Box != want || dets[0].ClassID != 0 {
+				t.Fatalf("det = %+v", dets[0])
+			}
+		})
+	}
+}
+
+func TestHttpTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	b := newHttpBackend(t, srv.URL, HTTP_PROTOCOL_JPEG, func(c *HttpConfig) {
+		c.Timeout = 50 * time.Millisecond
+	})
+	tStart := time.Now()
+	if _, err := b.Predict(image.NewRGBA(image.Rect(0, 0, 64, 64))); err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if d := time.Since(tStart); d > 500*time.Millisecond {
+		t.Fatalf("timeout took %v", d)
+	}
+	if s := b.Stats(); s.Failures != 1 || s.LastError == "" {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+func TestHttpCircuitBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if !healthy.Load() {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"detections":[]}`)
+	}))
+	defer srv.Close()
+
+	b := newHttpBackend(t, srv.URL, HTTP_PROTOCOL_JPEG, func(c *HttpConfig) {
+		c.BreakerFailures = 2
+		c.BreakerCooldown = 100 * time.Millisecond
+	})
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+
+	for range 2 {
+		if _, err := b.Predict(img); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("want server error, got %v", err)
+		}
+	}
+	if _, err := b.Predict(img); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("want ErrCircuitOpen, got %v", err)
+	}
+	if hits.Load() != 2 {
+		t.Fatalf("server hit %d times while open", hits.Load())
+	}
+	if s := b.Stats(); !s.Open || s.Rejected != 1 {
+		t.Fatalf("stats = %+v", s)
+	}
+
+	// 冷却结束后放行一次探测：仍失败则重新熔断
+	time.Sleep(120 * time.Millisecond)
+	if _, err := b.Predict(img); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("probe: want server error, got %v", err)
+	}
+	if _, err := b.Predict(img); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("want reopened circuit, got %v", err)
+	}
+
+	// 恢复后探测成功，熔断关闭
+	healthy.Store(true)
+	time.Sleep(120 * time.Millisecond)
+	if _, err := b.Predict(img); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Predict(img); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.Stats(); s.Open {
+		t.Fatalf("stats = %+v", s)
+	}
+}
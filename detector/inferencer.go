@@ -0,0 +1,81 @@
+package detector
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"github.com/getcharzp/go-vision/yolo26"
+)
+
+const (
+	BACKEND_YOLO = "yolo"
+	BACKEND_MOCK = "mock"
+	BACKEND_HTTP = "http"
+)
+
+// Meta 推理后端元数据。
+type Meta struct {
+	Name       string
+	ClassNames []string
+	InputSize  int // 期望的正方形输入边长
+}
+
+// ClassName 返回类别名，越界时返回编号。
+func (m Meta) ClassName(id int) string {
+	if id >= 0 && id < len(m.ClassNames) {
+		return m.ClassNames[id]
+	}
+	return fmt.Sprint(id)
+}
+
+// Inferencer 推理后端：输入 InputSize×InputSize 的图像，返回输入坐标系下的检测框。
+type Inferencer interface {
+	Predict(img image.Image) ([]yolo26.DetResult, error)
+	Meta() Meta
+	Close() error
+}
+
+// latencyReporter 可选：远程后端能单独上报服务端推理耗时。
+type latencyReporter interface {
+	ServerLatency() time.Duration
+}
+
+// releaser 可选：只释放后端自身资源，换模型时代替 Close（Close 还会销毁共享的 CUDA 上下文）。
+type releaser interface {
+	Release() error
+}
+
+// NewInferencer 按 cfg.Backend 创建推理后端。
+func NewInferencer(cfg Config) (Inferencer, error) {
+	switch cfg.Backend {
+	case "", BACKEND_YOLO:
+		return NewYoloBackend(cfg)
+	case BACKEND_MOCK:
+		return NewMockBackend(Meta{InputSize: cfg.InputSize, ClassNames: cfg.ClassNames}), nil
+	case BACKEND_HTTP:
+		return NewHttpBackend(cfg)
+	default:
+		return nil, fmt.Errorf("unknown detector backend: %q", cfg.Backend)
+	}
+}
+
+// classNames 返回 cfg.ClassNames，为空时为 CocoClassNames。
+func classNames(cfg Config) []string {
+	if len(cfg.ClassNames) > 0 {
+		return cfg.ClassNames
+	}
+	return CocoClassNames
+}
+
+// https://github.com/ultralytics/ultralytics/blob/main/ultralytics/cfg/datasets/coco.yaml
+var CocoClassNames = []string{
+	"person", "bicycle", "car", "motorcycle", "airplane", "bus", "train", "truck", "boat", "traffic light",
+	"fire hydrant", "stop sign", "parking meter", "bench", "bird", "cat", "dog", "horse", "sheep", "cow",
+	"elephant", "bear", "zebra", "giraffe", "backpack", "umbrella", "handbag", "tie", "suitcase", "frisbee",
+	"skis", "snowboard", "sports ball", "kite", "baseball bat", "baseball glove", "skateboard", "surfboard", "tennis racket", "bottle",
+	"wine glass", "cup", "fork", "knife", "spoon", "bowl", "banana", "apple", "sandwich", "orange",
+	"broccoli", "carrot", "hot dog", "pizza", "donut", "cake", "chair", "couch", "potted plant", "bed",
+	"dining table", "toilet", "tv", "laptop", "mouse", "remote", "keyboard", "cell phone", "microwave", "oven",
+	"toaster", "sink", "refrigerator", "book", "clock", "vase", "scissors", "teddy bear", "hair drier", "toothbrush",
+}
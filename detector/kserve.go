@@ -0,0 +1,198 @@
+package detector
+
+import (
+	"encoding/binary"
+	jsonv2 "encoding/json/v2"
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+
+	"github.com/getcharzp/go-vision/yolo26"
+)
+
+// KSERVE_HEADER_LENGTH Triton binary tensor 扩展：JSON 头长度，其后紧跟原始张量字节。
+const KSERVE_HEADER_LENGTH = "Inference-Header-Content-Length"
+
+// https://kserve.github.io/website/latest/modelserving/data_plane/v2_protocol/
+type kserveParams struct {
+	BinaryDataSize int  `json:"binary_data_size,omitzero"`
+	BinaryData     bool `json:"binary_data,omitzero"`
+}
+
+type kserveTensor struct {
+	Name       string        `json:"name"`
+	Shape      []int         `json:"shape,omitzero"`
+	Datatype   string        `json:"datatype,omitzero"`
+	Parameters *kserveParams `json:"parameters,omitzero"`
+	Data       []float64     `json:"data,omitzero"`
+}
+
+type kserveRequest struct {
+	Inputs  []kserveTensor `json:"inputs"`
+	Outputs []kserveTensor `json:"outputs,omitzero"`
+}
+
+type kserveResponse struct {
+	ModelName string         `json:"model_name"`
+	Outputs   []kserveTensor `json:"outputs"`
+}
+
+// kserveOutput 解码后的输出张量（统一转为 float64）。
+type kserveOutput struct {
+	shape []int
+	data  []float64
+}
+
+// imageToCHW 把图像转为 [3,H,W] float32（RGB，归一化到 0~1），即 YOLO 的标准输入。
+func imageToCHW(img image.Image) (data []float32, w, h int) {
+	b := img.Bounds()
+	w, h = b.Dx(), b.Dy()
+	plane := w * h
+	data = make([]float32, 3*plane)
+
+	if rgba, ok := img.(*image.RGBA); ok {
+		for y := range h {
+			row := rgba.Pix[(y+b.Min.Y-rgba.Rect.Min.Y)*rgba.Stride+(b.Min.X-rgba.Rect.Min.X)*4:]
+			for x := range w {
+				i := y*w + x
+				data[i] = float32(row[x*4]) / 255
+				data[plane+i] = float32(row[x*4+1]) / 255
+				data[2*plane+i] = float32(row[x*4+2]) / 255
+			}
+		}
+		return
+	}
+
+	for y := range h {
+		for x := range w {
+			r, g, bb, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			i := y*w + x
+			data[i] = float32(r>>8) / 255
+			data[plane+i] = float32(g>>8) / 255
+			data[2*plane+i] = float32(bb>>8) / 255
+		}
+	}
+	return
+}
+
+// encodeKServeRequest 构造推理请求体；binary=true 时返回 JSON 头长度。
+func encodeKServeRequest(img image.Image, inputName, outputName string, binaryData bool) (body []byte, headerLen int, err error) {
+	chw, w, h := imageToCHW(img)
+	input := kserveTensor{
+		Name:     inputName,
+		Shape:    []int{1, 3, h, w},
+		Datatype: "FP32",
+	}
+	output := kserveTensor{Name: outputName}
+
+	if !binaryData {
+		input.Data = make([]float64, len(chw))
+		for i, v := range chw {
+			input.Data[i] = float64(v)
+		}
+		body, err = jsonv2.Marshal(kserveRequest{Inputs: []kserveTensor{input}, Outputs: []kserveTensor{output}})
+		return body, 0, err
+	}
+
+	input.Parameters = &kserveParams{BinaryDataSize: len(chw) * 4}
+	output.Parameters = &kserveParams{BinaryData: true}
+	header, err := jsonv2.Marshal(kserveRequest{Inputs: []kserveTensor{input}, Outputs: []kserveTensor{output}})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	body = make([]byte, len(header), len(header)+len(chw)*4)
+	copy(body, header)
+	for _, v := range chw {
+		body = binary.LittleEndian.AppendUint32(body, math.Float32bits(v))
+	}
+	return body, len(header), nil
+}
+
+// decodeKServeResponse 解析响应（JSON 或 binary 扩展），取出名为 outputName 的张量。
+func decodeKServeResponse(data []byte, headerLenStr string, outputName string) (kserveOutput, error) {
+	header := data
+	var blob []byte
+	if headerLenStr != "" {
+		n, err := strconv.Atoi(headerLenStr)
+		if err != nil || n < 0 || n > len(data) {
+			return kserveOutput{}, fmt.Errorf("bad %s: %q", KSERVE_HEADER_LENGTH, headerLenStr)
+		}
+		header, blob = data[:n], data[n:]
+	}
+
+	var res kserveResponse
+	if err := jsonv2.Unmarshal(header, &res); err != nil {
+		return kserveOutput{}, fmt.Errorf("decode kserve response: %w", err)
+	}
+
+	// binary 输出按 outputs 顺序依次排列在 JSON 头之后。
+	offset := 0
+	for _, t := range res.Outputs {
+		size := 0
+		if t.Parameters != nil {
+			size = t.Parameters.BinaryDataSize
+		}
+		if t.Name != outputName {
+			offset += size
+			continue
+		}
+		if size == 0 {
+			return kserveOutput{shape: t.Shape, data: t.Data}, nil
+		}
+		if offset+size > len(blob) {
+			return kserveOutput{}, fmt.Errorf("output %q: binary data truncated", t.Name)
+		}
+		raw := blob[offset : offset+size]
+		out := kserveOutput{shape: t.Shape}
+		switch t.Datatype {
+		case "FP32":
+			out.data = make([]float64, len(raw)/4)
+			for i := range out.data {
+				out.data[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:])))
+			}
+		case "FP64":
+			out.data = make([]float64, len(raw)/8)
+			for i := range out.data {
+				out.data[i] = math.Float64frombits(binary.LittleEndian.Uint64(raw[i*8:]))
+			}
+		default:
+			return kserveOutput{}, fmt.Errorf("output %q: unsupported binary datatype %q", t.Name, t.Datatype)
+		}
+		return out, nil
+	}
+	return kserveOutput{}, fmt.Errorf("output %q not found in response", outputName)
+}
+
+// detections 把 [1,N,6] / [N,6] 输出（x1,y1,x2,y2,score,class）转为检测框。
+func (o kserveOutput) detections(confThresh float32) ([]yolo26.DetResult, error) {
+	shape := o.shape
+	if len(shape) == 3 && shape[0] == 1 {
+		shape = shape[1:]
+	}
+	if len(shape) != 2 || shape[1] != 6 {
+		return nil, fmt.Errorf("unexpected output shape %v, want [1,N,6]", o.shape)
+	}
+	if len(o.data) != shape[0]*6 {
+		return nil, fmt.Errorf("output has %d values, want %d", len(o.data), shape[0]*6)
+	}
+
+	dets := make([]yolo26.DetResult, 0, shape[0])
+	for i := range shape[0] {
+		row := o.data[i*6 : i*6+6]
+		score := float32(row[4])
+		if score < confThresh {
+			continue
+		}
+		dets = append(dets, yolo26.DetResult{
+			ClassID: int(row[5]),
+			Score:   score,
+			Box: image.Rect(
+				int(math.Round(row[0])), int(math.Round(row[1])),
+				int(math.Round(row[2])), int(math.Round(row[3])),
+			),
+		})
+	}
+	return dets, nil
+}
@@ -0,0 +1,95 @@
+package detector
+
+import (
+	"image"
+	"sync"
+	"time"
+
+	"github.com/getcharzp/go-vision/yolo26"
+)
+
+// MockBackend 确定性推理后端：每次 Predict 返回同一组结果（输入坐标系），
+// 可选模拟推理耗时，用于在没有模型/GPU 的机器上测试 Engine。
+type MockBackend struct {
+	meta Meta
+
+	mu      sync.Mutex
+	results []yolo26.DetResult
+	latency time.Duration
+	err     error
+	calls   int
+	lastIn  image.Rectangle
+}
+
+func NewMockBackend(meta Meta, results ...yolo26.DetResult) *MockBackend {
+	if meta.Name == "" {
+		meta.Name = BACKEND_MOCK
+	}
+	if meta.InputSize <= 0 {
+		meta.InputSize = 640
+	}
+	if meta.ClassNames == nil {
+		meta.ClassNames = CocoClassNames
+	}
+	return &MockBackend{meta: meta, results: results}
+}
+
+// SetResults 替换之后每次 Predict 返回的结果。
+func (b *MockBackend) SetResults(results ...yolo26.DetResult) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.results = results
+}
+
+// SetLatency 设置模拟推理耗时。
+func (b *MockBackend) SetLatency(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.latency = d
+}
+
+// SetError 设置之后每次 Predict 返回的错误（nil 恢复正常）。
+func (b *MockBackend) SetError(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+}
+
+// Calls 返回 Predict 被调用的次数。
+func (b *MockBackend) Calls() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.calls
+}
+
+// LastInput 返回最近一次 Predict 的输入图像边界。
+func (b *MockBackend) LastInput() image.Rectangle {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastIn
+}
+
+func (b *MockBackend) Predict(img image.Image) ([]yolo26.DetResult, error) {
+	b.mu.Lock()
+	b.calls++
+	b.lastIn = img.Bounds()
+	latency, err := b.latency, b.err
+	results := append([]yolo26.DetResult(nil), b.results...)
+	b.mu.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (b *MockBackend) Meta() Meta {
+	return b.meta
+}
+
+func (b *MockBackend) Close() error {
+	return nil
+}
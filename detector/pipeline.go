@@ -0,0 +1,277 @@
+package detector
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/draw"
+	"math"
+	"runtime"
+	"time"
+
+	"github.com/Miuzarte/GoCVStreamer/libyuv"
+	"github.com/getcharzp/go-vision/yolo26"
+)
+
+// PIPELINE_BUFFERS 区域缓冲组数：一组在推理，一组已预处理待推理（与前者构成双缓冲），
+// 一组供下一帧预处理；预处理不必等推理结束
+const PIPELINE_BUFFERS = 3
+
+// errModelChanged 预处理与推理之间换了模型（输入尺寸不同），该帧作废。
+var errModelChanged = errors.New("model changed after preprocessing")
+
+// frameJob 流水线中的一帧：带帧号的各区域预处理结果，在预处理与推理阶段间复用。
+type frameJob struct {
+	id        uint64
+	start     time.Time // 开始读帧的时刻
+	regions   []regionBuf
+	inputSize int
+	preCost   time.Duration
+}
+
+// frameResult 推理阶段的输出（输入坐标系），交给后处理。
+type frameResult struct {
+	id        uint64
+	start     time.Time
+	inputSize int
+	rects     []image.Rectangle
+	dets      []regionDet
+	regions   []RegionStats
+	preCost   time.Duration
+	inferCost time.Duration
+}
+
+// prepare 裁剪并缩放各区域到模型输入尺寸；换模型后输入尺寸变化时就地重建缓冲。
+// preCost 从 job.start（读帧前）起算。
+func (job *frameJob) prepare(frame *image.RGBA, inputSize int) {
+	for i := range job.regions {
+		if job.regions[i].inputSize != inputSize {
+			job.regions[i] = newRegionBuf(job.regions[i].rect, inputSize)
+		}
+		r := job.regions[i]
+		draw.Draw(r.img, r.img.Bounds(), frame, r.rect.Min, draw.Src)
+		if r.resizeDst != nil {
+			libyuv.ResizeRGBAInto(r.resizeDst, r.img, inputSize, inputSize)
+		}
+	}
+	job.inputSize = inputSize
+	job.preCost = time.Since(job.start)
+}
+
+// infer 逐区域推理；任一区域失败即放弃本帧。
+func (e *Engine) infer(job *frameJob) (frameResult, error) {
+	e.inferMu.Lock()
+	defer e.inferMu.Unlock()
+	m := e.current()
+
+	res := frameResult{
+		id:        job.id,
+		start:     job.start,
+		inputSize: job.inputSize,
+		rects:     make([]image.Rectangle, len(job.regions)),
+		regions:   make([]RegionStats, len(job.regions)),
+		preCost:   job.preCost,
+	}
+	if m.inputSize != job.inputSize {
+		return res, errModelChanged
+	}
+	for i, r := range job.regions {
+		results, cost, err := e.predict(m, r.input())
+		res.inferCost += cost
+		res.rects[i] = r.rect
+		res.regions[i] = RegionStats{Rect: r.rect, Cost: cost, Count: len(results)}
+		if err != nil {
+			return res, err
+		}
+		for _, d := range results {
+			res.dets = append(res.dets, regionDet{DetResult: d, region: i})
+		}
+	}
+	return res, nil
+}
+
+// publish 把结果映射回屏幕坐标、跨区域合并并发布，返回合并后的结果（调用方不得修改）。
+func (e *Engine) publish(res frameResult) []yolo26.DetResult {
+	tStart := time.Now()
+	for i := range res.dets {
+		d := &res.dets[i]
+		d.Box = mapBox(d.Box, res.rects[d.region], res.inputSize)
+	}
+	var merged []yolo26.DetResult
+	if len(res.rects) > 1 {
+		merged = mergeRegions(res.dets, e.cfg.NmsIou, e.cfg.NmsIos)
+	} else {
+		merged = make([]yolo26.DetResult, len(res.dets))
+		for i, d := range res.dets {
+			merged[i] = d.DetResult
+		}
+	}
+	postCost := time.Since(tStart)
+
+	e.mu.Lock()
+	e.stats.PreCost = res.preCost
+	e.stats.Cost = res.inferCost
+	e.stats.PostCost = postCost
+	e.stats.Latency = time.Since(res.start)
+	e.stats.FrameId = res.id
+	e.stats.Regions = res.regions
+	e.stats.Count = len(merged)
+	e.personResults = merged
+	e.published++
+	e.lastPublish = time.Now()
+	e.lastErr = nil
+	e.idle = false
+	e.mu.Unlock()
+	return merged
+}
+
+// recordFailure 推理失败时仍记录已完成区域的耗时。
+func (e *Engine) recordFailure(res frameResult, err error) {
+	e.mu.Lock()
+	e.stats.Cost = res.inferCost
+	e.stats.Regions = res.regions
+	e.lastErr = err
+	e.mu.Unlock()
+}
+
+// prepareLoop 按帧率读帧并预处理；推理尚未取走的旧帧被新帧替换（计入 Dropped）。
+func (e *Engine) prepareLoop(ctx context.Context, pool, inferCh chan *frameJob) {
+	var lastFrameId uint64
+
+	interval := time.Second / time.Duration(e.cfg.Fps)
+	intervalIdle := time.Duration(math.MaxInt64)
+	if e.cfg.FpsIdle != 0 {
+		intervalIdle = time.Second / time.Duration(e.cfg.FpsIdle)
+	}
+
+	tickerNormal := time.NewTicker(interval)
+	defer tickerNormal.Stop()
+	tickerIdle := time.NewTicker(intervalIdle)
+	defer tickerIdle.Stop()
+	mixinTicker := make(chan time.Time, 2)
+	defer close(mixinTicker)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case t, ok := <-tickerNormal.C:
+			if !ok {
+				return
+			}
+			if e.idleCheck != nil && e.idleCheck() {
+				e.mu.Lock()
+				e.stats = Stats{}
+				e.personResults = nil
+				e.idle = true
+				e.mu.Unlock()
+				continue
+			}
+			select {
+			case mixinTicker <- t:
+			default:
+			}
+			continue
+
+		case t, ok := <-tickerIdle.C:
+			if !ok {
+				return
+			}
+			if e.idleCheck == nil || !e.idleCheck() {
+				continue
+			}
+			select {
+			case mixinTicker <- t:
+			default:
+			}
+			continue
+
+		case _, ok := <-mixinTicker:
+			if !ok {
+				return
+			}
+		}
+
+		fps := e.cfg.Fps
+		if e.idleCheck != nil && e.idleCheck() {
+			fps = e.cfg.FpsIdle
+		}
+		e.source.RaiseCeiling(fps)
+
+		id := e.source.ReadFrameId()
+		if id == lastFrameId {
+			continue
+		}
+		lastFrameId = id
+
+		var job *frameJob
+		select {
+		case job = <-pool:
+		case <-ctx.Done():
+			return
+		}
+
+		job.id = id
+		job.start = time.Now()
+		captureRgba := e.source.ReadRgba()
+		if captureRgba == nil {
+			pool <- job
+			continue
+		}
+		job.prepare(captureRgba, e.current().inputSize)
+
+		// 推理仍未取走上一帧时用新帧替换，避免处理过期画面
+		select {
+		case stale := <-inferCh:
+			e.dropped.Add(1)
+			pool <- stale
+		default:
+		}
+		select {
+		case inferCh <- job:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// inferLoop 推理阶段：锁定系统线程（CUDA 上下文按线程绑定），用完的缓冲立即归还给预处理。
+func (e *Engine) inferLoop(inferCh <-chan *frameJob, pool chan<- *frameJob, postCh chan<- frameResult) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer close(postCh)
+
+	for job := range inferCh {
+		res, err := e.infer(job)
+		pool <- job
+		switch {
+		case errors.Is(err, errModelChanged):
+			e.dropped.Add(1)
+			continue
+		case err != nil:
+			e.recordFailure(res, err)
+			log.Warn().
+				Err(err).
+				Uint64("frameId", res.id).
+				Str("backend", e.Meta().Name).
+				Msg("detection failed")
+			time.Sleep(time.Millisecond * 100)
+			continue
+		}
+		postCh <- res
+	}
+}
+
+// postLoop 后处理阶段：映射、合并、发布结果并统计帧率。
+func (e *Engine) postLoop(postCh <-chan frameResult) {
+	for res := range postCh {
+		e.publish(res)
+		e.diag.Observe(res.inferCost, log)
+
+		currFps, _ := e.fpsCounter.Count()
+		e.mu.Lock()
+		e.stats.Fps = currFps
+		e.mu.Unlock()
+	}
+}
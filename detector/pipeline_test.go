@@ -0,0 +1,86 @@
+package detector
+
+import (
+	"context"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/getcharzp/go-vision/yolo26"
+)
+
+// TestPipelineOverlap 读帧与推理各耗时 20ms：串行时每帧 40ms（25fps），
+// 流水线下两阶段重叠，吞吐接近 50fps。
+func TestPipelineOverlap(t *testing.T) {
+	const stage = 20 * time.Millisecond
+	mock := NewMockBackend(Meta{InputSize: 320},
+		yolo26.DetResult{ClassID: 0, Score: 0.9, Box: image.Rect(0, 0, 32, 32)},
+	)
+	mock.SetLatency(stage)
+	frames := newFakeFrames(320, 320) // 与输入同尺寸，不缩放
+	frames.delay = stage
+
+	cfg := DefaultConfig()
+	cfg.CropSize = 0
+	cfg.InputSize = 320
+	cfg.Fps = 200
+	e := NewWithInferencer(frames, cfg, mock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx)
+		close(done)
+	}()
+	waitFresh(t, e)
+	start := mock.Calls()
+	time.Sleep(time.Second)
+	calls := mock.Calls() - start
+	cancel()
+	<-done
+
+	if calls < 35 {
+		t.Fatalf("%d inferences in 1s, want > 35 with overlapping stages", calls)
+	}
+	s := e.Stats()
+	if s.PreCost < stage || s.Cost < stage || s.Latency < s.PreCost+s.Cost {
+		t.Fatalf("stage timing: pre %v, infer %v, post %v, latency %v", s.PreCost, s.Cost, s.PostCost, s.Latency)
+	}
+}
+
+// TestPipelineDropsStale 推理（40ms）远慢于读帧（5ms）时丢弃积压帧，结果始终来自较新的帧。
+func TestPipelineDropsStale(t *testing.T) {
+	mock := NewMockBackend(Meta{InputSize: 320},
+		yolo26.DetResult{ClassID: 0, Score: 0.9, Box: image.Rect(0, 0, 32, 32)},
+	)
+	mock.SetLatency(40 * time.Millisecond)
+	frames := newFakeFrames(320, 320)
+
+	cfg := DefaultConfig()
+	cfg.CropSize = 0
+	cfg.InputSize = 320
+	cfg.Fps = 200
+	e := NewWithInferencer(frames, cfg, mock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx)
+	waitFresh(t, e)
+
+	var lastId uint64
+	for range 10 {
+		time.Sleep(50 * time.Millisecond)
+		s := e.Stats()
+		if s.FrameId < lastId {
+			t.Fatalf("frame id went backwards: %d after %d", s.FrameId, lastId)
+		}
+		lastId = s.FrameId
+		// 推理期间最多积压约 40ms/5ms 帧，再加调度抖动
+		if lag := frames.lastId() - s.FrameId; lag > 30 {
+			t.Fatalf("result lags %d frames behind capture", lag)
+		}
+	}
+	if s := e.Stats(); s.Dropped == 0 {
+		t.Fatal("no stale frames dropped")
+	}
+}
@@ -0,0 +1,319 @@
+package detector
+
+import (
+	"context"
+	jsonv2 "encoding/json/v2"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Miuzarte/GoCVStreamer/utils"
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	PROFILE_SUFFIX = ".json"
+	// PROFILE_BUILTIN 未使用配置档（DefaultConfig + 命令行）时的名称
+	PROFILE_BUILTIN = "builtin"
+)
+
+// 执行提供者（yolo 后端）
+const (
+	PROVIDER_TENSORRT = "tensorrt"
+	PROVIDER_CUDA     = "cuda"
+	PROVIDER_CPU      = "cpu"
+)
+
+var ErrNoPreviousProfile = errors.New("no previous profile to roll back to")
+
+// Profile 模型配置档：配置目录下每个 NAME.json 一份，非零字段覆盖 Config 中与模型相关的部分。
+type Profile struct {
+	Name string `json:"name,omitzero"` // 由文件名（不含扩展名）决定，文件内的值被忽略
+
+	Backend            string   `json:"backend,omitzero"`
+	ModelPath          string   `json:"model_path,omitzero"` // 相对路径相对于配置目录
+	OnnxLibPath        string   `json:"onnx_lib_path,omitzero"`
+	TensorRTPluginPath string   `json:"tensorrt_plugin_path,omitzero"`
+	InputSize          int      `json:"input_size,omitzero"`
+	ClassNames         []string `json:"class_names,omitzero"`
+	ConfThresh         float32  `json:"conf_thresh,omitzero"`
+	ResultIds          []int    `json:"result_ids,omitzero"`
+	// Providers 执行提供者优先级（tensorrt、cuda、cpu），依次尝试直到创建并预热成功
+	Providers []string `json:"providers,omitzero"`
+
+	HttpUrl      string `json:"http_url,omitzero"`
+	HttpProtocol string `json:"http_protocol,omitzero"`
+}
+
+// ProfileFromConfig 由现有 Config 生成配置档，作为回滚的基线。
+func ProfileFromConfig(name string, cfg Config) Profile {
+	p := Profile{
+		Name:               name,
+		Backend:            cfg.Backend,
+		ModelPath:          cfg.ModelPath,
+		OnnxLibPath:        cfg.OnnxLibPath,
+		TensorRTPluginPath: cfg.TensorRTPluginPath,
+		InputSize:          cfg.InputSize,
+		ClassNames:         cfg.ClassNames,
+		ConfThresh:         cfg.ConfThresh,
+		HttpUrl:            cfg.Http.Url,
+		HttpProtocol:       cfg.Http.Protocol,
+	}
+	for id := range cfg.ResultIds {
+		p.ResultIds = append(p.ResultIds, id)
+	}
+	slices.Sort(p.ResultIds)
+	switch {
+	case cfg.UseTensorRT:
+		p.Providers = []string{PROVIDER_TENSORRT}
+	case cfg.UseCuda:
+		p.Providers = []string{PROVIDER_CUDA}
+	default:
+		p.Providers = []string{PROVIDER_CPU}
+	}
+	return p
+}
+
+// Apply 把配置档覆盖到 base 上；Providers 由 withProvider 逐个应用。
+func (p Profile) Apply(base Config) Config {
+	cfg := base
+	if p.Backend != "" {
+		cfg.Backend = p.Backend
+	}
+	if p.ModelPath != "" {
+		cfg.ModelPath = p.ModelPath
+	}
+	if p.OnnxLibPath != "" {
+		cfg.OnnxLibPath = p.OnnxLibPath
+	}
+	if p.TensorRTPluginPath != "" {
+		cfg.TensorRTPluginPath = p.TensorRTPluginPath
+	}
+	if p.InputSize > 0 {
+		cfg.InputSize = p.InputSize
+	}
+	if len(p.ClassNames) > 0 {
+		cfg.ClassNames = p.ClassNames
+	}
+	if p.ConfThresh > 0 {
+		cfg.ConfThresh = p.ConfThresh
+		cfg.Http.ConfThresh = p.ConfThresh
+	}
+	if len(p.ResultIds) > 0 {
+		cfg.ResultIds = utils.NewSet(p.ResultIds...)
+	}
+	if p.HttpUrl != "" {
+		cfg.Http.Url = p.HttpUrl
+	}
+	if p.HttpProtocol != "" {
+		cfg.Http.Protocol = p.HttpProtocol
+	}
+	return cfg
+}
+
+// Validate 检查取值范围与提供者名称。
+func (p Profile) Validate() error {
+	if p.InputSize < 0 {
+		return fmt.Errorf("input_size must be >= 0, got %d", p.InputSize)
+	}
+	if p.ConfThresh < 0 || p.ConfThresh > 1 {
+		return fmt.Errorf("conf_thresh must be in [0, 1], got %v", p.ConfThresh)
+	}
+	for _, prov := range p.Providers {
+		switch prov {
+		case PROVIDER_TENSORRT, PROVIDER_CUDA, PROVIDER_CPU:
+		default:
+			return fmt.Errorf("unknown provider %q", prov)
+		}
+	}
+	return nil
+}
+
+// withProvider 按提供者设置 UseTensorRT / UseCuda；空字符串保持 cfg 原样。
+func withProvider(cfg Config, provider string) Config {
+	switch provider {
+	case PROVIDER_TENSORRT:
+		cfg.UseTensorRT, cfg.UseCuda = true, false
+	case PROVIDER_CUDA:
+		cfg.UseTensorRT, cfg.UseCuda = false, true
+	case PROVIDER_CPU:
+		cfg.UseTensorRT, cfg.UseCuda = false, false
+	}
+	return cfg
+}
+
+// LoadProfile 读取单个配置档文件；未知字段视为错误以便发现拼写问题。
+func LoadProfile(path string) (Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Profile{}, err
+	}
+	var p Profile
+	if err := jsonv2.Unmarshal(data, &p, jsonv2.RejectUnknownMembers(true)); err != nil {
+		return Profile{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := p.Validate(); err != nil {
+		return Profile{}, fmt.Errorf("%s: %w", path, err)
+	}
+	p.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if p.ModelPath != "" && !filepath.IsAbs(p.ModelPath) {
+		p.ModelPath = filepath.Join(filepath.Dir(path), p.ModelPath)
+	}
+	return p, nil
+}
+
+// Registry 模型配置档目录。
+type Registry struct {
+	dir string
+
+	mu       sync.RWMutex
+	profiles map[string]Profile
+}
+
+// NewRegistry 读取 dir 下全部配置档；单个文件出错只记录日志并跳过。
+func NewRegistry(dir string) (*Registry, error) {
+	r := &Registry{dir: dir, profiles: make(map[string]Profile)}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Registry) Dir() string {
+	return r.dir
+}
+
+// Reload 重新扫描目录。
+func (r *Registry) Reload() error {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return err
+	}
+	profiles := make(map[string]Profile, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != PROFILE_SUFFIX {
+			continue
+		}
+		p, err := LoadProfile(filepath.Join(r.dir, e.Name()))
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Msg("skipping invalid model profile")
+			continue
+		}
+		profiles[p.Name] = p
+	}
+
+	r.mu.Lock()
+	r.profiles = profiles
+	r.mu.Unlock()
+	log.Info().
+		Str("dir", r.dir).
+		Int("profiles", len(profiles)).
+		Msg("model profiles loaded")
+	return nil
+}
+
+func (r *Registry) Get(name string) (Profile, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.profiles[name]
+	return p, ok
+}
+
+// List 按名称排序返回全部配置档。
+func (r *Registry) List() []Profile {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]Profile, 0, len(r.profiles))
+	for _, p := range r.profiles {
+		list = append(list, p)
+	}
+	slices.SortFunc(list, func(a, b Profile) int { return strings.Compare(a.Name, b.Name) })
+	return list
+}
+
+// watchDebounce 编辑器保存时常连续触发多次写事件，合并后再读取。
+const watchDebounce = 200 * time.Millisecond
+
+// Watch 监听目录变化并热重载配置档，阻塞至 ctx 结束；
+// 配置档新增或修改且解析成功后调用 onChange（在 Watch 所在 goroutine 中）。
+func (r *Registry) Watch(ctx context.Context, onChange func(Profile)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(r.dir); err != nil {
+		return err
+	}
+
+	pending := make(map[string]struct{})
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Ext(event.Name) != PROFILE_SUFFIX {
+				continue
+			}
+			pending[event.Name] = struct{}{}
+			timer.Reset(watchDebounce)
+
+		case <-timer.C:
+			for path := range pending {
+				r.reloadFile(path, onChange)
+			}
+			clear(pending)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Error().Err(err).Msg("model profile watcher error")
+		}
+	}
+}
+
+func (r *Registry) reloadFile(path string, onChange func(Profile)) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		r.mu.Lock()
+		delete(r.profiles, name)
+		r.mu.Unlock()
+		log.Info().
+			Str("profile", name).
+			Msg("model profile removed")
+		return
+	}
+
+	p, err := LoadProfile(path)
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Msg("model profile reload failed, keeping previous version")
+		return
+	}
+	r.mu.Lock()
+	r.profiles[p.Name] = p
+	r.mu.Unlock()
+	log.Info().
+		Str("profile", p.Name).
+		Msg("model profile reloaded")
+	if onChange != nil {
+		onChange(p)
+	}
+}
@@ -0,0 +1,195 @@
+package detector
+
+import (
+	"context"
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/getcharzp/go-vision/yolo26"
+)
+
+func writeProfile(t *testing.T, dir, name, data string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name+PROFILE_SUFFIX), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	dir := t.TempDir()
+	writeProfile(t, dir, "small", `{
+		"model_path": "yolo26s.onnx",
+		"input_size": 320,
+		"conf_thresh": 0.3,
+		"result_ids": [0, 2],
+		"providers": ["tensorrt", "cuda"]
+	}`)
+	writeProfile(t, dir, "typo", `{"input_sise": 320}`)
+	writeProfile(t, dir, "badprov", `{"providers": ["directml"]}`)
+	os.WriteFile(filepath.Join(dir, "readme.txt"), []byte("ignored"), 0o644)
+
+	r, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := r.List()
+	if len(list) != 1 || list[0].Name != "small" {
+		t.Fatalf("profiles = %+v, want only small", list)
+	}
+
+	p := list[0]
+	if p.ModelPath != filepath.Join(dir, "yolo26s.onnx") {
+		t.Fatalf("model path = %q, want resolved against profile dir", p.ModelPath)
+	}
+	cfg := p.Apply(DefaultConfig())
+	if cfg.InputSize != 320 || cfg.ConfThresh != 0.3 || !cfg.ResultIds.Has(0, 2) || cfg.ResultIds.Has1(1) {
+		t.Fatalf("applied config = %+v", cfg)
+	}
+	if cfg.OnnxLibPath != DefaultConfig().OnnxLibPath {
+		t.Fatal("unset fields must keep base values")
+	}
+}
+
+func TestRegistryWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeProfile(t, dir, "a", `{"input_size": 320}`)
+	r, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan Profile, 4)
+	go r.Watch(ctx, func(p Profile) { changed <- p })
+	time.Sleep(50 * time.Millisecond) // 等待 watcher 就绪
+
+	writeProfile(t, dir, "a", `{"input_size": 416}`)
+	select {
+	case p := <-changed:
+		if p.Name != "a" || p.InputSize != 416 {
+			t.Fatalf("changed = %+v", p)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no reload after write")
+	}
+	if p, _ := r.Get("a"); p.InputSize != 416 {
+		t.Fatalf("registry still has %+v", p)
+	}
+
+	// 解析失败时保留旧版本
+	writeProfile(t, dir, "a", `{"input_size": `)
+	time.Sleep(2 * watchDebounce)
+	if p, _ := r.Get("a"); p.InputSize != 416 {
+		t.Fatalf("invalid edit replaced profile: %+v", p)
+	}
+}
+
+// TestLoadProfileSwap Run 运行中换模型：预热成功后原子替换（输入尺寸变化时重建区域缓冲），
+// 预热失败保留原模型，Rollback 回到上一个配置档。
+func TestLoadProfileSwap(t *testing.T) {
+	first := NewMockBackend(Meta{InputSize: 640},
+		yolo26.DetResult{ClassID: 0, Score: 0.9, Box: image.Rect(0, 0, 64, 64)},
+	)
+	cfg := DefaultConfig()
+	cfg.CropSize = 0
+	cfg.Fps = 200
+	e := NewWithInferencer(newFakeFrames(640, 640), cfg, first)
+
+	backends := map[string]*MockBackend{}
+	e.newInferencer = func(c Config) (Inferencer, error) {
+		b, ok := backends[c.ModelPath]
+		if !ok {
+			return nil, errors.New("no such model")
+		}
+		return b, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx)
+	waitFresh(t, e)
+	if s := e.Stats(); s.Profile != PROFILE_BUILTIN {
+		t.Fatalf("profile = %q, want %q", s.Profile, PROFILE_BUILTIN)
+	}
+
+	// 320 输入：框 ×2 映射回 640 帧
+	second := NewMockBackend(Meta{InputSize: 320},
+		yolo26.DetResult{ClassID: 0, Score: 0.8, Box: image.Rect(10, 10, 50, 50)},
+	)
+	backends["second.onnx"] = second
+	if err := e.LoadProfile(Profile{Name: "second", ModelPath: "second.onnx", InputSize: 320}); err != nil {
+		t.Fatal(err)
+	}
+	if second.Calls() < WARMUP_RUNS {
+		t.Fatalf("warm-up calls = %d", second.Calls())
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		results, _, fresh := e.Snapshot()
+		if fresh && results[0].Box == image.Rect(20, 20, 100, 100) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("results after swap = %+v", results)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s := e.Stats(); s.Profile != "second" {
+		t.Fatalf("profile = %q, want second", s.Profile)
+	}
+
+	// 预热失败：保留 second
+	broken := NewMockBackend(Meta{})
+	broken.SetError(errors.New("engine build failed"))
+	backends["broken.onnx"] = broken
+	if err := e.LoadProfile(Profile{Name: "broken", ModelPath: "broken.onnx"}); err == nil {
+		t.Fatal("broken profile loaded")
+	}
+	if e.Profile().Name != "second" {
+		t.Fatalf("profile after failed load = %q", e.Profile().Name)
+	}
+
+	if err := e.Rollback(); err == nil {
+		t.Fatal("rollback to builtin should rebuild its backend and fail with the fake factory")
+	}
+	backends[cfg.ModelPath] = first
+	if err := e.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if e.Profile().Name != PROFILE_BUILTIN || e.Meta().InputSize != 640 {
+		t.Fatalf("after rollback: %q, %+v", e.Profile().Name, e.Meta())
+	}
+}
+
+// TestProviderFallback 依次尝试执行提供者，第一个失败时退到下一个。
+func TestProviderFallback(t *testing.T) {
+	e := NewWithInferencer(nil, DefaultConfig(), NewMockBackend(Meta{}))
+	var tried []string
+	e.newInferencer = func(c Config) (Inferencer, error) {
+		switch {
+		case c.UseTensorRT:
+			tried = append(tried, PROVIDER_TENSORRT)
+			return nil, errors.New("plugin not found")
+		case c.UseCuda:
+			tried = append(tried, PROVIDER_CUDA)
+			return NewMockBackend(Meta{InputSize: c.InputSize}), nil
+		}
+		t.Fatal("cpu should not be tried")
+		return nil, nil
+	}
+	err := e.LoadProfile(Profile{
+		Name:      "gpu",
+		Providers: []string{PROVIDER_TENSORRT, PROVIDER_CUDA, PROVIDER_CPU},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tried) != 2 || tried[1] != PROVIDER_CUDA {
+		t.Fatalf("tried = %v", tried)
+	}
+}
@@ -0,0 +1,157 @@
+package detector
+
+import (
+	"image"
+	"slices"
+	"time"
+
+	"github.com/getcharzp/go-vision/yolo26"
+)
+
+// RegionStats 单个检测区域最近一次推理的统计。
+type RegionStats struct {
+	Rect  image.Rectangle // 帧坐标
+	Cost  time.Duration
+	Count int // 过滤 ResultIds 后、合并前的检测数
+}
+
+// PlanRegions 按配置计算每帧要推理的区域（帧坐标）：
+// Regions 非空时使用显式列表（裁掉越界部分）；否则 TileSize>0 时自动切重叠块；
+// 都未设置时退回 CropSize 的单个中心裁剪（不裁剪时为整帧）。
+func PlanRegions(bounds image.Rectangle, cfg Config) []image.Rectangle {
+	if len(cfg.Regions) > 0 {
+		regions := make([]image.Rectangle, 0, len(cfg.Regions))
+		for _, r := range cfg.Regions {
+			r = r.Canon().Intersect(bounds)
+			if !r.Empty() {
+				regions = append(regions, r)
+			}
+		}
+		return regions
+	}
+
+	if cfg.TileSize > 0 {
+		return Tiles(bounds, cfg.TileSize, cfg.TileOverlap)
+	}
+
+	size, offset, needed := CenterCrop(bounds, cfg.CropSize)
+	if !needed {
+		return []image.Rectangle{bounds}
+	}
+	min := bounds.Min.Add(offset)
+	return []image.Rectangle{{Min: min, Max: min.Add(image.Pt(size, size))}}
+}
+
+// Tiles 以 SAHI 方式把 bounds 切成边长 size、相邻重叠 overlap（0~0.9）的正方形块，
+// 最后一行/列贴齐边缘，保证整帧被完全覆盖；size 超过某一边时该方向只有一块。
+func Tiles(bounds image.Rectangle, size int, overlap float64) []image.Rectangle {
+	overlap = max(0, min(overlap, 0.9))
+	step := max(1, int(float64(size)*(1-overlap)))
+
+	xs := tileStarts(bounds.Dx(), size, step)
+	ys := tileStarts(bounds.Dy(), size, step)
+	w, h := min(size, bounds.Dx()), min(size, bounds.Dy())
+
+	tiles := make([]image.Rectangle, 0, len(xs)*len(ys))
+	for _, y := range ys {
+		for _, x := range xs {
+			min := bounds.Min.Add(image.Pt(x, y))
+			tiles = append(tiles, image.Rectangle{Min: min, Max: min.Add(image.Pt(w, h))})
+		}
+	}
+	return tiles
+}
+
+func tileStarts(length, size, step int) []int {
+	if size >= length {
+		return []int{0}
+	}
+	var starts []int
+	for s := 0; ; s += step {
+		if s+size >= length {
+			return append(starts, length-size)
+		}
+		starts = append(starts, s)
+	}
+}
+
+// mapBox 把推理输入坐标（inputSize 正方形）映射回区域所在的帧坐标。
+func mapBox(box image.Rectangle, region image.Rectangle, inputSize int) image.Rectangle {
+	sx := float64(region.Dx()) / float64(inputSize)
+	sy := float64(region.Dy()) / float64(inputSize)
+	return image.Rect(
+		int(float64(box.Min.X)*sx),
+		int(float64(box.Min.Y)*sy),
+		int(float64(box.Max.X)*sx),
+		int(float64(box.Max.Y)*sy),
+	).Add(region.Min)
+}
+
+// regionDet 记录检测框来自哪个区域，用于区分块边缘重复与同区域内的相邻目标。
+type regionDet struct {
+	yolo26.DetResult
+	region int
+}
+
+// mergeRegions 类别感知 NMS：同类且 IoU 超过 iouThresh 的框只保留得分最高者；
+// 来自不同区域的同类框，较小框被较大框覆盖 iosThresh 以上（块边缘被截断的半个目标）时同样合并。
+func mergeRegions(dets []regionDet, iouThresh, iosThresh float32) []yolo26.DetResult {
+	slices.SortStableFunc(dets, func(a, b regionDet) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+
+	kept := make([]regionDet, 0, len(dets))
+next:
+	for _, d := range dets {
+		for _, k := range kept {
+			if k.ClassID != d.ClassID {
+				continue
+			}
+			iou, ios := overlap(k.Box, d.Box)
+			if iou > iouThresh || (k.region != d.region && ios > iosThresh) {
+				continue next
+			}
+		}
+		kept = append(kept, d)
+	}
+
+	results := make([]yolo26.DetResult, len(kept))
+	for i, k := range kept {
+		results[i] = k.DetResult
+	}
+	return results
+}
+
+// NMS 对同一坐标系下的检测结果做类别感知的非极大值抑制。
+func NMS(dets []yolo26.DetResult, iouThresh float32) []yolo26.DetResult {
+	rds := make([]regionDet, len(dets))
+	for i, d := range dets {
+		rds[i] = regionDet{DetResult: d}
+	}
+	return mergeRegions(rds, iouThresh, 1)
+}
+
+// overlap 返回交并比与交集占较小框的比例。
+func overlap(a, b image.Rectangle) (iou, ios float32) {
+	inter := area(a.Intersect(b))
+	if inter == 0 {
+		return 0, 0
+	}
+	areaA, areaB := area(a), area(b)
+	iou = float32(inter) / float32(areaA+areaB-inter)
+	ios = float32(inter) / float32(min(areaA, areaB))
+	return
+}
+
+func area(r image.Rectangle) int {
+	if r.Empty() {
+		return 0
+	}
+	return r.Dx() * r.Dy()
+}
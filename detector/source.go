@@ -1,12 +1,15 @@
 package detector
 
 import (
+	"fmt"
 	"image"
 	"sync"
 	"time"
 
 	"gioui.org/layout"
 
+	"github.com/Miuzarte/GoCVStreamer/fps"
+	"github.com/Miuzarte/GoCVStreamer/raster"
 	"github.com/Miuzarte/GoCVStreamer/ui"
 	"github.com/getcharzp/go-vision/yolo26"
 )
@@ -19,6 +22,17 @@ const (
 	KindRemote
 )
 
+func (k Kind) String() string {
+	switch k {
+	case KindLocal:
+		return "local"
+	case KindRemote:
+		return "remote"
+	default:
+		return fmt.Sprintf("kind(%d)", int(k))
+	}
+}
+
 // Result 带来源与延迟的检测结果。
 // Latency：本地=推理耗时；远程=帧发出到收到结果的全链路延迟（含网络+手机推理）。
 type Result struct {
@@ -27,28 +41,80 @@ type Result struct {
 	Latency time.Duration
 }
 
-// Source 推理源接口（类比 capturer.Source：可以是本地 YOLO、远程 NPU 等）。
-type Source interface {
+// Snapshotter 只读取结果的最小接口（Source、Sources 与其按类型的视图都实现）。
+type Snapshotter interface {
 	// Snapshot 返回当前结果（拷贝）、该批结果对应延迟与是否新鲜。
 	Snapshot() (results []Result, latency time.Duration, fresh bool)
+}
+
+// Source 推理源接口（类比 capturer.Source：可以是本地 YOLO、远程 NPU 等）。
+type Source interface {
+	Snapshotter
+	// Name 在 Sources 中唯一，如 "local"、"remote/192.168.1.5:51234"
+	Name() string
+	Kind() Kind
+	Stats() SourceStats
+	Health() Health
 	Close() error
 }
 
-// RemoteSource 远程（手机端 NPU）推理源：结果由 WebSocket 回调写入。
+// SourceStats 推理源的通用统计。
+type SourceStats struct {
+	Fps     float64
+	Latency time.Duration // 最近一批结果的延迟
+	Count   int           // 最近一批结果的检测数
+	Batches uint64        // 累计结果批次
+	LastAt  time.Time     // 最近一批结果的时间
+}
+
+// HealthState 推理源状态。
+type HealthState int
+
+const (
+	HealthFresh HealthState = iota // 结果在有效期内
+	HealthStale                    // 仍在运行但结果过期（空闲、断流等）
+	HealthDown                     // 不可用（推理失败、连接断开）
+)
+
+func (h HealthState) String() string {
+	switch h {
+	case HealthFresh:
+		return "fresh"
+	case HealthStale:
+		return "stale"
+	case HealthDown:
+		return "down"
+	default:
+		return fmt.Sprintf("health(%d)", int(h))
+	}
+}
+
+// Health 推理源状态及原因（Fresh 时 Reason 为空）。
+type Health struct {
+	State  HealthState
+	Reason string
+}
+
+// RemoteSource 远程（手机端 NPU）推理源：结果由 WebSocket 回调写入，每个连接一个。
 type RemoteSource struct {
-	ttl time.Duration
+	name string
+	ttl  time.Duration
 
-	mu      sync.RWMutex
-	results []Result
-	latency time.Duration
-	recv    time.Time
+	mu         sync.RWMutex
+	results    []Result
+	latency    time.Duration
+	recv       time.Time
+	batches    uint64
+	fpsCounter fps.Counter
+	fps        float64
+	down       string
 }
 
-func NewRemoteSource(ttl time.Duration) *RemoteSource {
+func NewRemoteSource(name string, ttl time.Duration) *RemoteSource {
 	if ttl < 0 {
 		ttl = 500 * time.Millisecond
 	}
-	return &RemoteSource{ttl: ttl}
+	return &RemoteSource{name: name, ttl: ttl, fpsCounter: fps.NewCounter(time.Second)}
 }
 
 // SetResults 由远程回调写入（屏幕坐标系）；latency 为该帧全链路延迟。
@@ -61,26 +127,99 @@ func (s *RemoteSource) SetResults(dets []yolo26.DetResult, latency time.Duration
 	}
 	s.latency = latency
 	s.recv = time.Now()
+	s.batches++
+	s.fps, _ = s.fpsCounter.Count()
+	s.down = ""
+}
+
+// SetDown 标记为不可用（如连接断开），下一次 SetResults 自动恢复。
+func (s *RemoteSource) SetDown(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = reason
+	s.results = nil
 }
 
 func (s *RemoteSource) Snapshot() ([]Result, time.Duration, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	if time.Since(s.recv) > s.ttl {
+	if s.down != "" || time.Since(s.recv) > s.ttl {
 		return nil, s.latency, false
 	}
 	return append([]Result(nil), s.results...), s.latency, true
 }
 
-func (s *RemoteSource) Close() error { return nil }
+func (s *RemoteSource) Name() string { return s.name }
+func (s *RemoteSource) Kind() Kind   { return KindRemote }
 
-// Drawer 聚合绘制多个推理源：本地绿框、远程青框。
+func (s *RemoteSource) Stats() SourceStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return SourceStats{
+		Fps:     s.fps,
+		Latency: s.latency,
+		Count:   len(s.results),
+		Batches: s.batches,
+		LastAt:  s.recv,
+	}
+}
+
+func (s *RemoteSource) Health() Health {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	switch {
+	case s.down != "":
+		return Health{State: HealthDown, Reason: s.down}
+	case s.recv.IsZero():
+		return Health{State: HealthStale, Reason: "no results yet"}
+	case time.Since(s.recv) > s.ttl:
+		return Health{State: HealthStale, Reason: fmt.Sprintf("no results for %s", time.Since(s.recv).Round(time.Millisecond))}
+	}
+	return Health{State: HealthFresh}
+}
+
+func (s *RemoteSource) Close() error {
+	s.SetDown("closed")
+	return nil
+}
+
+// LocalSource 把 Engine 包装为 Source（Engine.Stats 返回的是检测器自身的统计）。
+type LocalSource struct {
+	*Engine
+	name string
+}
+
+func NewLocalSource(e *Engine, name string) *LocalSource {
+	return &LocalSource{Engine: e, name: name}
+}
+
+func (s *LocalSource) Name() string { return s.name }
+func (s *LocalSource) Kind() Kind   { return KindLocal }
+
+func (s *LocalSource) Stats() SourceStats {
+	st := s.Engine.Stats()
+	s.Engine.mu.RLock()
+	defer s.Engine.mu.RUnlock()
+	return SourceStats{
+		Fps:     st.Fps,
+		Latency: st.Cost,
+		Count:   st.Count,
+		Batches: s.Engine.published,
+		LastAt:  s.Engine.lastPublish,
+	}
+}
+
+func (s *LocalSource) Health() Health {
+	return s.Engine.health()
+}
+
+// Drawer 绘制 Sources 中所有推理源：本地绿框、远程青框。
 type Drawer struct {
-	Sources []Source
+	Sources *Sources
 }
 
 func (d *Drawer) Draw(gtx layout.Context, s ui.DScale) {
-	for _, src := range d.Sources {
+	for _, src := range d.Sources.List() {
 		results, _, fresh := src.Snapshot()
 		if !fresh {
 			continue
@@ -97,3 +236,23 @@ func (d *Drawer) Draw(gtx layout.Context, s ui.DScale) {
 		}
 	}
 }
+
+// Paint 实现 raster.Painter：观战帧上的检测框（配色同 Draw）。
+func (d *Drawer) Paint(c *raster.Canvas) {
+	for _, src := range d.Sources.List() {
+		results, _, fresh := src.Snapshot()
+		if !fresh {
+			continue
+		}
+		for _, r := range results {
+			color := ui.ColorGreen
+			if r.Kind == KindRemote {
+				color = ui.ColorCyan
+			}
+			rect := c.Rect(r.Box)
+			c.DrawBorder(color.NRGBA(), rect)
+			labelPos := image.Pt(rect.Min.X, rect.Min.Y-raster.LineHeight-2)
+			c.DrawLabel(color.NRGBA(), labelPos, ui.FormatPct(r.Score))
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package detector
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Sources 运行时推理源注册表：本地引擎与每个远程连接各为一个 Source，可随时加入或离开。
+// 指标、GUI 叠加层、HTTP 接口与辅助逻辑都通过它枚举推理源。
+type Sources struct {
+	mu      sync.RWMutex
+	sources []Source // 按加入顺序
+}
+
+func NewSources() *Sources {
+	return &Sources{}
+}
+
+// Add 加入推理源；同名的旧推理源被替换并返回（由调用方决定是否 Close）。
+func (s *Sources) Add(src Source) (replaced Source) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.indexLocked(src.Name()); i >= 0 {
+		replaced = s.sources[i]
+		s.sources[i] = src
+	} else {
+		s.sources = append(s.sources, src)
+	}
+	log.Info().
+		Str("source", src.Name()).
+		Stringer("kind", src.Kind()).
+		Int("total", len(s.sources)).
+		Msg("inference source joined")
+	return replaced
+}
+
+// Remove 移除并返回推理源，不存在时返回 nil。
+func (s *Sources) Remove(name string) Source {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.indexLocked(name)
+	if i < 0 {
+		return nil
+	}
+	src := s.sources[i]
+	s.sources = slices.Delete(s.sources, i, i+1)
+	log.Info().
+		Str("source", name).
+		Int("total", len(s.sources)).
+		Msg("inference source left")
+	return src
+}
+
+func (s *Sources) Get(name string) (Source, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if i := s.indexLocked(name); i >= 0 {
+		return s.sources[i], true
+	}
+	return nil, false
+}
+
+// List 返回当前推理源的拷贝（按加入顺序）。
+func (s *Sources) List() []Source {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.sources)
+}
+
+func (s *Sources) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.sources)
+}
+
+func (s *Sources) indexLocked(name string) int {
+	return slices.IndexFunc(s.sources, func(src Source) bool { return src.Name() == name })
+}
+
+// Snapshot 实现 Snapshotter：取新鲜且非空的推理源中延迟最低的一个。
+// 本地延迟=推理耗时；远程延迟=帧发出到收到结果（网络+手机推理）。
+func (s *Sources) Snapshot() ([]Result, time.Duration, bool) {
+	return best(s.List(), func(Source) bool { return true })
+}
+
+// OfKind 返回只看某一类推理源的 Snapshotter（选取策略同 Snapshot）。
+func (s *Sources) OfKind(kind Kind) Snapshotter {
+	return kindView{s, kind}
+}
+
+type kindView struct {
+	sources *Sources
+	kind    Kind
+}
+
+func (v kindView) Snapshot() ([]Result, time.Duration, bool) {
+	return best(v.sources.List(), func(src Source) bool { return src.Kind() == v.kind })
+}
+
+func best(sources []Source, accept func(Source) bool) (results []Result, latency time.Duration, fresh bool) {
+	for _, src := range sources {
+		if !accept(src) {
+			continue
+		}
+		srcResults, srcLatency, srcFresh := src.Snapshot()
+		if !srcFresh || len(srcResults) == 0 {
+			continue
+		}
+		if !fresh || srcLatency < latency {
+			results, latency, fresh = srcResults, srcLatency, true
+		}
+	}
+	return
+}
@@ -0,0 +1,117 @@
+package detector
+
+import (
+	"context"
+	"errors"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/getcharzp/go-vision/yolo26"
+)
+
+func TestSourcesRegistry(t *testing.T) {
+	s := NewSources()
+	a := NewRemoteSource("remote/a", time.Second)
+	b := NewRemoteSource("remote/b", time.Second)
+	s.Add(a)
+	s.Add(b)
+	if s.Len() != 2 {
+		t.Fatalf("len = %d", s.Len())
+	}
+
+	// 无结果时不新鲜
+	if _, _, fresh := s.Snapshot(); fresh {
+		t.Fatal("empty sources reported fresh")
+	}
+
+	box := image.Rect(0, 0, 10, 10)
+	a.SetResults([]yolo26.DetResult{{Box: box}}, 80*time.Millisecond)
+	b.SetResults([]yolo26.DetResult{{Box: box}, {Box: box}}, 40*time.Millisecond)
+	results, latency, fresh := s.Snapshot()
+	if !fresh || latency != 40*time.Millisecond || len(results) != 2 {
+		t.Fatalf("best = %d results, %v, %v; want b", len(results), latency, fresh)
+	}
+	if _, _, fresh := s.OfKind(KindLocal).Snapshot(); fresh {
+		t.Fatal("no local source registered")
+	}
+
+	// 同名替换，返回旧源
+	b2 := NewRemoteSource("remote/b", time.Second)
+	if replaced := s.Add(b2); replaced != b || s.Len() != 2 {
+		t.Fatalf("replace: got %v, len %d", replaced, s.Len())
+	}
+	if removed := s.Remove("remote/a"); removed != a || s.Len() != 1 {
+		t.Fatalf("remove: got %v, len %d", removed, s.Len())
+	}
+	if s.Remove("remote/a") != nil {
+		t.Fatal("second remove returned a source")
+	}
+	if src, ok := s.Get("remote/b"); !ok || src != b2 {
+		t.Fatal("get after replace")
+	}
+}
+
+func TestRemoteSourceHealth(t *testing.T) {
+	s := NewRemoteSource("remote/x", 50*time.Millisecond)
+	if h := s.Health(); h.State != HealthStale || h.Reason != "no results yet" {
+		t.Fatalf("initial health = %+v", h)
+	}
+	s.SetResults([]yolo26.DetResult{{}}, 10*time.Millisecond)
+	if h := s.Health(); h.State != HealthFresh {
+		t.Fatalf("health after result = %+v", h)
+	}
+	if st := s.Stats(); st.Batches != 1 || st.Count != 1 || st.Latency != 10*time.Millisecond {
+		t.Fatalf("stats = %+v", st)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if h := s.Health(); h.State != HealthStale {
+		t.Fatalf("health after ttl = %+v", h)
+	}
+	s.Close()
+	if h := s.Health(); h.State != HealthDown || h.Reason != "closed" {
+		t.Fatalf("health after close = %+v", h)
+	}
+	if _, _, fresh := s.Snapshot(); fresh {
+		t.Fatal("closed source reported fresh")
+	}
+}
+
+func TestLocalSourceHealth(t *testing.T) {
+	mock := NewMockBackend(Meta{},
+		yolo26.DetResult{ClassID: 0, Score: 0.9, Box: image.Rect(0, 0, 64, 64)},
+	)
+	cfg := DefaultConfig()
+	cfg.Fps = 200
+	e := NewWithInferencer(newFakeFrames(640, 640), cfg, mock)
+	src := NewLocalSource(e, "local")
+	if src.Kind() != KindLocal || src.Name() != "local" {
+		t.Fatalf("source = %s/%s", src.Name(), src.Kind())
+	}
+	if h := src.Health(); h.State != HealthStale {
+		t.Fatalf("health before Run = %+v", h)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx)
+	waitFresh(t, e)
+	if h := src.Health(); h.State != HealthFresh {
+		t.Fatalf("health while running = %+v", h)
+	}
+	if st := src.Stats(); st.Batches == 0 || st.Count != 1 {
+		t.Fatalf("stats = %+v", st)
+	}
+
+	mock.SetError(errors.New("device lost"))
+	deadline := time.Now().Add(3 * time.Second)
+	for src.Health().State != HealthDown {
+		if time.Now().After(deadline) {
+			t.Fatalf("health after failure = %+v", src.Health())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if h := src.Health(); h.Reason != "device lost" {
+		t.Fatalf("reason = %q", h.Reason)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+
+package detector
+
+import (
+	"errors"
+	"image"
+
+	"github.com/getcharzp/go-vision/yolo26"
+)
+
+// YoloBackend 依赖 Windows 下的 CUDA/TensorRT 运行时，其他平台不可用。
+type YoloBackend struct{}
+
+func NewYoloBackend(cfg Config) (*YoloBackend, error) {
+	return nil, errors.New("yolo backend is only supported on windows")
+}
+
+func (b *YoloBackend) Predict(img image.Image) ([]yolo26.DetResult, error) {
+	return nil, errors.New("yolo backend is only supported on windows")
+}
+
+func (b *YoloBackend) Meta() Meta {
+	return Meta{Name: BACKEND_YOLO}
+}
+
+func (b *YoloBackend) Close() error {
+	return nil
+}
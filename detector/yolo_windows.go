@@ -0,0 +1,63 @@
+package detector
+
+import (
+	"image"
+
+	"github.com/Miuzarte/GoCVStreamer/cuda"
+	"github.com/getcharzp/go-vision/yolo26"
+)
+
+// YoloBackend 本地 ONNX Runtime（CUDA/TensorRT）推理后端。
+type YoloBackend struct {
+	detEngine *yolo26.DetEngine
+	meta      Meta
+}
+
+func NewYoloBackend(cfg Config) (*YoloBackend, error) {
+	yCfg := yolo26.Config{
+		ModelPath:          cfg.ModelPath,
+		OnnxRuntimeLibPath: cfg.OnnxLibPath,
+		ConfThreshold:      cfg.ConfThresh,
+		InputSize:          cfg.InputSize,
+		UseCuda:            cfg.UseCuda,
+		UseTensorRT:        cfg.UseTensorRT,
+		TensorRTPluginPath: cfg.TensorRTPluginPath,
+	}
+
+	detEngine, err := yolo26.NewDetEngine(yCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &YoloBackend{
+		detEngine: detEngine,
+		meta: Meta{
+			Name:       BACKEND_YOLO,
+			ClassNames: classNames(cfg),
+			InputSize:  cfg.InputSize,
+		},
+	}, nil
+}
+
+func (b *YoloBackend) Predict(img image.Image) ([]yolo26.DetResult, error) {
+	return b.detEngine.Predict(img)
+}
+
+func (b *YoloBackend) Meta() Meta {
+	return b.meta
+}
+
+// Release 只销毁推理引擎，保留当前 CUDA 上下文供替换后的模型继续使用。
+func (b *YoloBackend) Release() error {
+	if b.detEngine != nil {
+		b.detEngine.Destroy()
+		b.detEngine = nil
+	}
+	return nil
+}
+
+func (b *YoloBackend) Close() error {
+	b.Release()
+	cuda.DestroyCurrentContext()
+	return nil
+}
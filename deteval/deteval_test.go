@@ -0,0 +1,192 @@
+package deteval
+
+import (
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Miuzarte/GoCVStreamer/detector"
+	"github.com/Miuzarte/GoCVStreamer/utils"
+	"github.com/getcharzp/go-vision/yolo26"
+)
+
+var testClasses = []string{"person", "bicycle", "car"}
+
+func det(class int, score float32, box image.Rectangle) yolo26.DetResult {
+	return yolo26.DetResult{ClassID: class, Score: score, Box: box}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestEvaluatePerfect(t *testing.T) {
+	box := image.Rect(10, 10, 110, 210)
+	m := Evaluate([]ImageResult{{
+		Gt:   []GtBox{{Class: 0, Box: box}},
+		Dets: []yolo26.DetResult{det(0, 0.9, box)},
+	}}, testClasses)
+	if !near(m.MAP50, 1) || !near(m.MAP, 1) || !near(m.Precision, 1) || !near(m.Recall, 1) {
+		t.Fatalf("metrics = %+v", m)
+	}
+}
+
+func TestEvaluateAP(t *testing.T) {
+	a, b := image.Rect(0, 0, 100, 100), image.Rect(200, 0, 300, 100)
+	m := Evaluate([]ImageResult{{
+		Gt: []GtBox{{Class: 0, Box: a}, {Class: 0, Box: b}},
+		Dets: []yolo26.DetResult{
+			det(0, 0.9, a),
+			det(0, 0.8, image.Rect(500, 500, 600, 600)), // FP
+			det(0, 0.7, b),
+		},
+	}}, testClasses)
+
+	// precision [1, .5, .667] → 包络 [1, .667, .667]；recall [.5, .5, 1]
+	// r∈[0, .5] 共 51 点取 1, r∈(.5, 1] 共 50 点取 2/3
+	want := (51 + 50*2.0/3) / 101
+	c := m.Classes[0]
+	if !near(c.AP50, want) || c.TP != 2 || c.FP != 1 || c.FN != 0 {
+		t.Fatalf("class = %+v, want AP50 %.4f", c, want)
+	}
+	if !near(c.Precision, 2.0/3) || !near(c.Recall, 1) {
+		t.Fatalf("P/R = %v/%v", c.Precision, c.Recall)
+	}
+}
+
+func TestEvaluateIouRange(t *testing.T) {
+	gt := image.Rect(0, 0, 100, 100)
+	// IoU = 80×100 / 100×100 = 0.8：0.50~0.80 的 7 个阈值命中, 0.85~0.95 的 3 个不命中
+	m := Evaluate([]ImageResult{{
+		Gt:   []GtBox{{Class: 0, Box: gt}},
+		Dets: []yolo26.DetResult{det(0, 0.9, image.Rect(0, 0, 80, 100))},
+	}}, testClasses)
+	if !near(m.MAP50, 1) || !near(m.MAP, 0.7) {
+		t.Fatalf("mAP50 = %v, mAP = %v", m.MAP50, m.MAP)
+	}
+}
+
+func TestConfusion(t *testing.T) {
+	box := image.Rect(0, 0, 100, 100)
+	m := Evaluate([]ImageResult{{
+		Gt: []GtBox{
+			{Class: 0, Box: box},
+			{Class: 0, Box: image.Rect(300, 300, 400, 400)}, // 漏检
+		},
+		Dets: []yolo26.DetResult{
+			det(2, 0.9, box), // 类别错误
+			det(2, 0.8, image.Rect(600, 0, 700, 100)), // 误检
+		},
+	}}, testClasses)
+
+	c := m.Confusion
+	if strings.Join(c.Labels, ",") != "person,car,background" {
+		t.Fatalf("labels = %v", c.Labels)
+	}
+	want := [][]int{
+		{0, 1, 1},
+		{0, 0, 0},
+		{0, 1, 0},
+	}
+	for i := range want {
+		for j := range want[i] {
+			if c.Matrix[i][j] != want[i][j] {
+				t.Fatalf("matrix = %v, want %v", c.Matrix, want)
+			}
+		}
+	}
+}
+
+func TestLatency(t *testing.T) {
+	var samples []time.Duration
+	for i := 100; i >= 1; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	l := Latency(samples)
+	if l.P50 != 50 || l.P90 != 90 || l.P99 != 99 || l.Max != 100 || !near(l.Mean, 50.5) {
+		t.Fatalf("latency = %+v", l)
+	}
+}
+
+func TestParseYoloLabels(t *testing.T) {
+	boxes, err := parseYoloLabels("0 0.5 0.5 0.5 0.25\n\n2 0.1 0.1 0.2 0.2\n", 200, 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boxes) != 2 || boxes[0].Box != image.Rect(50, 150, 150, 250) || boxes[1].Class != 2 {
+		t.Fatalf("boxes = %+v", boxes)
+	}
+	if _, err := parseYoloLabels("0 0.5 0.5", 10, 10); err == nil {
+		t.Fatal("short line accepted")
+	}
+}
+
+// TestRunMock 用 mock 后端跑完整链路：YOLO 目录 → DetectFrame（裁剪缩放）→ 指标。
+func TestRunMock(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"images/val", "labels/val"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"a", "b"} {
+		f, err := os.Create(filepath.Join(dir, "images/val", name+".png"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		png.Encode(f, image.NewRGBA(image.Rect(0, 0, 1280, 1280)))
+		f.Close()
+	}
+	// a: 与 mock 输出一致；b: 真值在别处 → 1 FP + 1 FN
+	os.WriteFile(filepath.Join(dir, "labels/val/a.txt"), []byte("0 0.125 0.25 0.125 0.25\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "labels/val/b.txt"), []byte("0 0.75 0.75 0.1 0.1\n"), 0o644)
+
+	samples, err := LoadYolo(dir, "val")
+	if err != nil || len(samples) != 2 {
+		t.Fatalf("load: %d samples, %v", len(samples), err)
+	}
+
+	// 1280 → 640 输入：mock 框 (40,80)-(120,240) 映射回 (80,160)-(240,480)
+	mock := detector.NewMockBackend(detector.Meta{InputSize: 640},
+		det(0, 0.9, image.Rect(40, 80, 120, 240)),
+	)
+	mock.SetLatency(time.Millisecond)
+	cfg := detector.DefaultConfig()
+	cfg.CropSize = 0
+	cfg.ResultIds = utils.NewSet(0, 1, 2)
+	engine := detector.NewWithInferencer(nil, cfg, mock)
+
+	var progressed int
+	report, err := Run(engine, cfg, samples, testClasses, func(done, total int) { progressed = done })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Images != 2 || progressed != 2 || mock.Calls() != 2 {
+		t.Fatalf("images = %d, progress = %d, calls = %d", report.Images, progressed, mock.Calls())
+	}
+	c := report.Classes[0]
+	if c.TP != 1 || c.FP != 1 || c.FN != 1 || !near(c.Precision, 0.5) || !near(c.Recall, 0.5) {
+		t.Fatalf("class = %+v", c)
+	}
+	if report.Latency.P50 < 1 || report.Inference.Count != 2 {
+		t.Fatalf("latency = %+v, inference = %+v", report.Latency, report.Inference)
+	}
+
+	md := report.Markdown()
+	for _, want := range []string{"mAP@0.5:", "| 0 person | 2 | 2 | 1 | 1 | 1 |", "| **background** | 1 | 0 |"} {
+		if !strings.Contains(md, want) {
+			t.Fatalf("markdown missing %q:\n%s", want, md)
+		}
+	}
+
+	mock.SetError(os.ErrDeadlineExceeded)
+	report, err = Run(engine, cfg, samples, testClasses, nil)
+	if err != nil || report.Failures != 2 || report.Images != 0 {
+		t.Fatalf("failures = %+v, %v", report, err)
+	}
+}
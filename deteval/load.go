@@ -0,0 +1,189 @@
+package deteval
+
+import (
+	jsonv2 "encoding/json/v2"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Sample 标注集中的一张图像。
+type Sample struct {
+	Path string
+	Gt   []GtBox
+}
+
+var imageExts = []string{".jpg", ".jpeg", ".png"}
+
+// LoadYolo 读取 YOLO 布局：dir/images/{split}/NAME.jpg 与 dir/labels/{split}/NAME.txt
+// （split 为空时为 dir/images 与 dir/labels）；没有标签文件的图像视为无目标。
+func LoadYolo(dir, split string) ([]Sample, error) {
+	imagesDir := filepath.Join(dir, "images", split)
+	labelsDir := filepath.Join(dir, "labels", split)
+
+	entries, err := os.ReadDir(imagesDir)
+	if err != nil {
+		return nil, err
+	}
+	var samples []Sample
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || !slices.Contains(imageExts, ext) {
+			continue
+		}
+		path := filepath.Join(imagesDir, e.Name())
+		w, h, err := imageSize(path)
+		if err != nil {
+			return nil, err
+		}
+
+		sample := Sample{Path: path}
+		labelPath := filepath.Join(labelsDir, strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))+".txt")
+		data, err := os.ReadFile(labelPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		sample.Gt, err = parseYoloLabels(string(data), w, h)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", labelPath, err)
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// parseYoloLabels 解析 "class cx cy w h"（归一化坐标）。
+func parseYoloLabels(data string, w, h int) ([]GtBox, error) {
+	var boxes []GtBox
+	for n, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("line %d: want 5 fields, got %d", n+1, len(fields))
+		}
+		class, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		var v [4]float64
+		for i := range v {
+			if v[i], err = strconv.ParseFloat(fields[i+1], 64); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+		}
+		cx, cy, bw, bh := v[0]*float64(w), v[1]*float64(h), v[2]*float64(w), v[3]*float64(h)
+		boxes = append(boxes, GtBox{
+			Class: class,
+			Box: image.Rect(
+				int(math.Round(cx-bw/2)), int(math.Round(cy-bh/2)),
+				int(math.Round(cx+bw/2)), int(math.Round(cy+bh/2)),
+			),
+		})
+	}
+	return boxes, nil
+}
+
+type cocoFile struct {
+	Images []struct {
+		Id       int    `json:"id"`
+		FileName string `json:"file_name"`
+	} `json:"images"`
+	Annotations []struct {
+		ImageId    int        `json:"image_id"`
+		CategoryId int        `json:"category_id"`
+		Bbox       [4]float64 `json:"bbox"`
+		IsCrowd    int        `json:"iscrowd"`
+	} `json:"annotations"`
+	Categories []struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"categories"`
+}
+
+// LoadCoco 读取 COCO instances JSON, 图像位于 imagesDir/file_name。
+// 类别按名称映射到 classNames 下标（兼容官方 1~90 的 category_id）, 找不到名称时直接用 id；
+// iscrowd 标注不参与评估。
+func LoadCoco(annotations, imagesDir string, classNames []string) ([]Sample, error) {
+	data, err := os.ReadFile(annotations)
+	if err != nil {
+		return nil, err
+	}
+	var f cocoFile
+	if err := jsonv2.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", annotations, err)
+	}
+
+	category := make(map[int]int, len(f.Categories))
+	for _, c := range f.Categories {
+		category[c.Id] = c.Id
+		if i := slices.Index(classNames, c.Name); i >= 0 {
+			category[c.Id] = i
+		}
+	}
+
+	index := make(map[int]int, len(f.Images))
+	samples := make([]Sample, len(f.Images))
+	for i, img := range f.Images {
+		index[img.Id] = i
+		samples[i].Path = filepath.Join(imagesDir, img.FileName)
+	}
+	for _, a := range f.Annotations {
+		i, ok := index[a.ImageId]
+		if !ok || a.IsCrowd != 0 {
+			continue
+		}
+		class, ok := category[a.CategoryId]
+		if !ok {
+			class = a.CategoryId
+		}
+		x, y, w, h := a.Bbox[0], a.Bbox[1], a.Bbox[2], a.Bbox[3]
+		samples[i].Gt = append(samples[i].Gt, GtBox{
+			Class: class,
+			Box:   image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+w)), int(math.Round(y+h))),
+		})
+	}
+	return samples, nil
+}
+
+func imageSize(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// loadRgba 解码图像为 *image.RGBA（DetectFrame 的输入格式）。
+func loadRgba(path string) (*image.RGBA, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba, nil
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba, nil
+}
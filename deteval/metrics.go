@@ -0,0 +1,329 @@
+// Package deteval 离线评估检测后端：在标注集（YOLO / COCO）上跑与 detector.Run
+// 相同的裁剪缩放流程, 统计各类别 P/R、mAP@0.5、mAP@0.5:0.95、延迟分位与混淆矩阵
+package deteval
+
+import (
+	"image"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/getcharzp/go-vision/yolo26"
+)
+
+// IouThresholds COCO 标准的 0.50:0.05:0.95。
+var IouThresholds = []float64{0.5, 0.55, 0.6, 0.65, 0.7, 0.75, 0.8, 0.85, 0.9, 0.95}
+
+// GtBox 一个真值框（图像像素坐标）。
+type GtBox struct {
+	Class int
+	Box   image.Rectangle
+}
+
+// ImageResult 一张图像的真值与检测结果。
+type ImageResult struct {
+	Gt   []GtBox
+	Dets []yolo26.DetResult
+}
+
+type ClassMetrics struct {
+	Class     int     `json:"class"`
+	Name      string  `json:"name"`
+	Gt        int     `json:"gt"`
+	Dets      int     `json:"dets"`
+	TP        int     `json:"tp"` // IoU ≥ 0.5
+	FP        int     `json:"fp"`
+	FN        int     `json:"fn"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	AP50      float64 `json:"ap50"`
+	AP        float64 `json:"ap"` // AP@0.5:0.95
+}
+
+// Confusion 混淆矩阵（IoU ≥ 0.5, 类别无关匹配）：Matrix[真值][预测],
+// 最后一行/列为 background（漏检记在真值行的 background 列, 误检记在 background 行）。
+type Confusion struct {
+	Labels []string `json:"labels"`
+	Matrix [][]int  `json:"matrix"`
+}
+
+type Metrics struct {
+	Classes   []ClassMetrics `json:"classes"`
+	MAP50     float64        `json:"map50"`
+	MAP       float64        `json:"map"` // mAP@0.5:0.95
+	Precision float64        `json:"precision"`
+	Recall    float64        `json:"recall"`
+	Confusion Confusion      `json:"confusion"`
+}
+
+// Evaluate 计算检测指标；只有存在真值的类别参与 mAP 平均（与 COCO 一致）。
+func Evaluate(images []ImageResult, classNames []string) Metrics {
+	var classes []int
+	for _, img := range images {
+		for _, g := range img.Gt {
+			classes = append(classes, g.Class)
+		}
+		for _, d := range img.Dets {
+			classes = append(classes, d.ClassID)
+		}
+	}
+	slices.Sort(classes)
+	classes = slices.Compact(classes)
+
+	var m Metrics
+	var tp, fp, gtTotal int
+	var apCount int
+	for _, c := range classes {
+		cm := ClassMetrics{Class: c, Name: className(classNames, c)}
+		var apSum float64
+		for _, t := range IouThresholds {
+			ap, ctp, cfp, ngt, ndet := classAP(images, c, t)
+			apSum += ap
+			if t == IouThresholds[0] {
+				cm.AP50 = ap
+				cm.TP, cm.FP, cm.Gt, cm.Dets = ctp, cfp, ngt, ndet
+			}
+		}
+		cm.AP = apSum / float64(len(IouThresholds))
+		cm.FN = cm.Gt - cm.TP
+		cm.Precision = ratio(cm.TP, cm.TP+cm.FP)
+		cm.Recall = ratio(cm.TP, cm.Gt)
+
+		tp += cm.TP
+		fp += cm.FP
+		gtTotal += cm.Gt
+		if cm.Gt > 0 {
+			m.MAP50 += cm.AP50
+			m.MAP += cm.AP
+			apCount++
+		}
+		m.Classes = append(m.Classes, cm)
+	}
+	if apCount > 0 {
+		m.MAP50 /= float64(apCount)
+		m.MAP /= float64(apCount)
+	}
+	m.Precision = ratio(tp, tp+fp)
+	m.Recall = ratio(tp, gtTotal)
+	m.Confusion = confusion(images, classes, classNames)
+	return m
+}
+
+type scoredDet struct {
+	score float32
+	tp    bool
+}
+
+// classAP 单类别单 IoU 阈值：按得分降序贪心匹配, 101 点插值 AP（COCO）。
+func classAP(images []ImageResult, class int, iouThresh float64) (ap float64, tp, fp, nGt, nDet int) {
+	var dets []scoredDet
+	for _, img := range images {
+		var gts []image.Rectangle
+		for _, g := range img.Gt {
+			if g.Class == class {
+				gts = append(gts, g.Box)
+			}
+		}
+		nGt += len(gts)
+
+		var own []yolo26.DetResult
+		for _, d := range img.Dets {
+			if d.ClassID == class {
+				own = append(own, d)
+			}
+		}
+		slices.SortStableFunc(own, func(a, b yolo26.DetResult) int { return cmpScore(a.Score, b.Score) })
+
+		matched := make([]bool, len(gts))
+		for _, d := range own {
+			best, bestIou := -1, iouThresh
+			for j, g := range gts {
+				if matched[j] {
+					continue
+				}
+				if v := iou(d.Box, g); v >= bestIou {
+					best, bestIou = j, v
+				}
+			}
+			if best >= 0 {
+				matched[best] = true
+			}
+			dets = append(dets, scoredDet{score: d.Score, tp: best >= 0})
+		}
+	}
+	nDet = len(dets)
+	if nGt == 0 {
+		for _, d := range dets {
+			if !d.tp {
+				fp++
+			}
+		}
+		return 0, 0, fp, 0, nDet
+	}
+
+	slices.SortStableFunc(dets, func(a, b scoredDet) int { return cmpScore(a.score, b.score) })
+	precision := make([]float64, len(dets))
+	recall := make([]float64, len(dets))
+	for i, d := range dets {
+		if d.tp {
+			tp++
+		} else {
+			fp++
+		}
+		precision[i] = float64(tp) / float64(tp+fp)
+		recall[i] = float64(tp) / float64(nGt)
+	}
+	// 精度包络：从右向左取最大值, 使曲线单调不增
+	for i := len(precision) - 2; i >= 0; i-- {
+		precision[i] = max(precision[i], precision[i+1])
+	}
+	for r := range 101 {
+		target := float64(r) / 100
+		i, _ := slices.BinarySearchFunc(recall, target, func(v, t float64) int {
+			switch {
+			case v < t:
+				return -1
+			case v > t:
+				return 1
+			}
+			return 0
+		})
+		if i < len(precision) {
+			ap += precision[i]
+		}
+	}
+	return ap / 101, tp, fp, nGt, nDet
+}
+
+func confusion(images []ImageResult, classes []int, classNames []string) Confusion {
+	index := make(map[int]int, len(classes))
+	labels := make([]string, 0, len(classes)+1)
+	for i, c := range classes {
+		index[c] = i
+		labels = append(labels, className(classNames, c))
+	}
+	bg := len(classes)
+	labels = append(labels, "background")
+	matrix := make([][]int, bg+1)
+	for i := range matrix {
+		matrix[i] = make([]int, bg+1)
+	}
+
+	type pair struct {
+		gt, det int
+		iou     float64
+	}
+	for _, img := range images {
+		var pairs []pair
+		for gi, g := range img.Gt {
+			for di, d := range img.Dets {
+				if v := iou(d.Box, g.Box); v >= 0.5 {
+					pairs = append(pairs, pair{gi, di, v})
+				}
+			}
+		}
+		slices.SortStableFunc(pairs, func(a, b pair) int {
+			switch {
+			case a.iou > b.iou:
+				return -1
+			case a.iou < b.iou:
+				return 1
+			}
+			return 0
+		})
+
+		gtUsed := make([]bool, len(img.Gt))
+		detUsed := make([]bool, len(img.Dets))
+		for _, p := range pairs {
+			if gtUsed[p.gt] || detUsed[p.det] {
+				continue
+			}
+			gtUsed[p.gt], detUsed[p.det] = true, true
+			matrix[index[img.Gt[p.gt].Class]][index[img.Dets[p.det].ClassID]]++
+		}
+		for gi, g := range img.Gt {
+			if !gtUsed[gi] {
+				matrix[index[g.Class]][bg]++
+			}
+		}
+		for di, d := range img.Dets {
+			if !detUsed[di] {
+				matrix[bg][index[d.ClassID]]++
+			}
+		}
+	}
+	return Confusion{Labels: labels, Matrix: matrix}
+}
+
+// LatencyStats 延迟分布（毫秒）。
+type LatencyStats struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean_ms"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P95   float64 `json:"p95_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+// Latency 计算延迟分位（nearest-rank）。
+func Latency(samples []time.Duration) LatencyStats {
+	if len(samples) == 0 {
+		return LatencyStats{}
+	}
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+
+	const ms = float64(time.Millisecond)
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+	pct := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		return float64(sorted[max(0, min(i, len(sorted)-1))]) / ms
+	}
+	return LatencyStats{
+		Count: len(sorted),
+		Mean:  float64(sum) / float64(len(sorted)) / ms,
+		P50:   pct(0.5),
+		P90:   pct(0.9),
+		P95:   pct(0.95),
+		P99:   pct(0.99),
+		Max:   float64(sorted[len(sorted)-1]) / ms,
+	}
+}
+
+func iou(a, b image.Rectangle) float64 {
+	inter := a.Intersect(b)
+	if inter.Empty() {
+		return 0
+	}
+	i := float64(inter.Dx() * inter.Dy())
+	return i / (float64(a.Dx()*a.Dy()+b.Dx()*b.Dy()) - i)
+}
+
+func cmpScore(a, b float32) int {
+	switch {
+	case a > b:
+		return -1
+	case a < b:
+		return 1
+	}
+	return 0
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func className(names []string, class int) string {
+	if class >= 0 && class < len(names) {
+		return names[class]
+	}
+	return "class" + strconv.Itoa(class)
+}
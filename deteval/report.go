@@ -0,0 +1,126 @@
+package deteval
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Miuzarte/GoCVStreamer/detector"
+	"github.com/Miuzarte/GoCVStreamer/logger"
+)
+
+var log = logger.New("DetEval")
+
+// Report 一次评估的完整结果（JSON 字段即报告格式）。
+type Report struct {
+	Backend    string  `json:"backend"`
+	Images     int     `json:"images"`
+	Failures   int     `json:"failures"`
+	ConfThresh float32 `json:"conf_thresh"`
+	InputSize  int     `json:"input_size"`
+	CropSize   int     `json:"crop_size"`
+	TileSize   int     `json:"tile_size,omitzero"`
+
+	Metrics
+	Latency   LatencyStats `json:"latency"`   // DetectFrame 全程：裁剪 + 缩放 + 推理 + 合并
+	Inference LatencyStats `json:"inference"` // 仅后端推理（各区域之和）
+}
+
+// Run 逐张图像走 engine.DetectFrame 并统计指标；单张推理失败计入 Failures 后继续。
+// progress 可为 nil。
+func Run(engine *detector.Engine, cfg detector.Config, samples []Sample, classNames []string, progress func(done, total int)) (*Report, error) {
+	results := make([]ImageResult, 0, len(samples))
+	var latency, inference []time.Duration
+	report := &Report{
+		Backend:    engine.Meta().Name,
+		ConfThresh: cfg.ConfThresh,
+		InputSize:  engine.Meta().InputSize,
+		CropSize:   cfg.CropSize,
+		TileSize:   cfg.TileSize,
+	}
+	if cfg.InputSize > 0 {
+		report.InputSize = cfg.InputSize
+	}
+
+	for i, s := range samples {
+		img, err := loadRgba(s.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		tStart := time.Now()
+		dets, err := engine.DetectFrame(img)
+		cost := time.Since(tStart)
+		if err != nil {
+			report.Failures++
+			log.Warn().
+				Err(err).
+				Str("image", s.Path).
+				Msg("detection failed")
+			continue
+		}
+		latency = append(latency, cost)
+		inference = append(inference, engine.Stats().Cost)
+		results = append(results, ImageResult{Gt: s.Gt, Dets: dets})
+
+		if progress != nil {
+			progress(i+1, len(samples))
+		}
+	}
+
+	report.Images = len(results)
+	report.Metrics = Evaluate(results, classNames)
+	report.Latency = Latency(latency)
+	report.Inference = Latency(inference)
+	return report, nil
+}
+
+// Markdown 渲染为便于贴到 PR / 对比的 Markdown。
+func (r *Report) Markdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Detector evaluation: %s\n\n", r.Backend)
+	fmt.Fprintf(&sb, "- images: %d (failures: %d)\n", r.Images, r.Failures)
+	fmt.Fprintf(&sb, "- conf thresh: %.2f, input size: %d, crop size: %d", r.ConfThresh, r.InputSize, r.CropSize)
+	if r.TileSize > 0 {
+		fmt.Fprintf(&sb, ", tile size: %d", r.TileSize)
+	}
+	sb.WriteString("\n")
+	fmt.Fprintf(&sb, "- **mAP@0.5: %.4f**, **mAP@0.5:0.95: %.4f**\n", r.MAP50, r.MAP)
+	fmt.Fprintf(&sb, "- precision: %.4f, recall: %.4f\n\n", r.Precision, r.Recall)
+
+	sb.WriteString("## Per-class\n\n")
+	sb.WriteString("| class | gt | dets | tp | fp | fn | precision | recall | AP@0.5 | AP@0.5:0.95 |\n")
+	sb.WriteString("|---|---:|---:|---:|---:|---:|---:|---:|---:|---:|\n")
+	for _, c := range r.Classes {
+		fmt.Fprintf(&sb, "| %d %s | %d | %d | %d | %d | %d | %.4f | %.4f | %.4f | %.4f |\n",
+			c.Class, c.Name, c.Gt, c.Dets, c.TP, c.FP, c.FN, c.Precision, c.Recall, c.AP50, c.AP)
+	}
+
+	sb.WriteString("\n## Latency (ms)\n\n")
+	sb.WriteString("| | mean | p50 | p90 | p95 | p99 | max |\n")
+	sb.WriteString("|---|---:|---:|---:|---:|---:|---:|\n")
+	for _, l := range []struct {
+		name string
+		LatencyStats
+	}{{"end-to-end", r.Latency}, {"inference", r.Inference}} {
+		fmt.Fprintf(&sb, "| %s | %.2f | %.2f | %.2f | %.2f | %.2f | %.2f |\n",
+			l.name, l.Mean, l.P50, l.P90, l.P95, l.P99, l.Max)
+	}
+
+	sb.WriteString("\n## Confusion matrix (rows: ground truth, columns: predicted, IoU ≥ 0.5)\n\n")
+	sb.WriteString("| |")
+	for _, l := range r.Confusion.Labels {
+		fmt.Fprintf(&sb, " %s |", l)
+	}
+	sb.WriteString("\n|---|")
+	sb.WriteString(strings.Repeat("---:|", len(r.Confusion.Labels)))
+	sb.WriteString("\n")
+	for i, row := range r.Confusion.Matrix {
+		fmt.Fprintf(&sb, "| **%s** |", r.Confusion.Labels[i])
+		for _, v := range row {
+			fmt.Fprintf(&sb, " %d |", v)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
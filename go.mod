@@ -13,6 +13,7 @@ require (
 	github.com/rs/zerolog v1.35.1
 	github.com/shirou/gopsutil/v4 v4.26.6
 	gocv.io/x/gocv v0.43.0
+	golang.org/x/image v0.44.0
 	golang.org/x/sys v0.47.0
 )
 
@@ -34,7 +35,6 @@ require (
 	github.com/up-zero/gotool v0.0.0-20260523024851-bb65a4eb7e2b // indirect
 	github.com/yusufpapurcu/wmi v1.2.4 // indirect
 	golang.org/x/exp/shiny v0.0.0-20260727155853-b88d891fe743 // indirect
-	golang.org/x/image v0.44.0 // indirect
 	golang.org/x/net v0.57.0 // indirect
 	golang.org/x/text v0.40.0 // indirect
 )
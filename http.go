@@ -1,12 +1,23 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"encoding/json/jsontext"
 	jsonv2 "encoding/json/v2"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
 	"net/http"
 	"runtime/debug"
+	"strconv"
 	"time"
+
+	"github.com/Miuzarte/GoCVStreamer/dataset"
+	"github.com/Miuzarte/GoCVStreamer/detector"
+	"github.com/Miuzarte/GoCVStreamer/matcher"
+	"github.com/Miuzarte/GoCVStreamer/weapon"
 )
 
 type MetricsSnapshot struct {
@@ -18,18 +29,43 @@ type MetricsSnapshot struct {
 	MatchCostMs    float64 `json:"match_cost_ms"`
 	MatchCount     int     `json:"match_count"`
 	MatchCostAvgMs float64 `json:"match_cost_avg_ms"`
+	MatchScale     float64 `json:"match_scale"`
+	MatchCoarse    int     `json:"match_coarse"` // 最近一次在半分辨率上粗筛的模板数
+	MatchErrors    int     `json:"match_errors"`
+	// MatchCalibration 校准进度（searching 3/30、proposed、failed），未校准时为空
+	MatchCalibration string `json:"match_calibration,omitzero"`
+	MatchDrift       bool   `json:"match_drift"`
 
 	Idle          bool    `json:"idle"`
 	Narrowing     bool    `json:"narrowing"`
+	MatchRemote   bool    `json:"match_remote"`
 	WeaponFound   bool    `json:"weapon_found"`
 	WeaponVal     float32 `json:"weapon_val"`
-	CurrentWeapon string  `json:"current_weapon"`
+	CurrentWeapon string  `json:"current_weapon"` // 经迟滞确认的结果
+	// WeaponValSmoothed 确认结果的平滑置信度
+	WeaponValSmoothed float32 `json:"weapon_val_smoothed"`
+
+	DetectionFps       float64                  `json:"detection_fps"`
+	DetectionCostMs    float64                  `json:"detection_cost_ms"`
+	DetectionServerMs  float64                  `json:"detection_server_ms"`
+	DetectionPreMs     float64                  `json:"detection_pre_ms"`
+	DetectionPostMs    float64                  `json:"detection_post_ms"`
+	DetectionLatencyMs float64                  `json:"detection_latency_ms"`
+	DetectionFrameId   uint64                   `json:"detection_frame_id"`
+	DetectionDropped   uint64                   `json:"detection_dropped"`
+	DetectionCount     int                      `json:"detection_count"`
+	DetectionRegions   []DetectionRegionMetrics `json:"detection_regions,omitzero"`
+	DetectionProfile   string                   `json:"detection_profile,omitzero"`
+	DetectionBackend   string                   `json:"detection_backend,omitzero"`
+
+	Sources []SourceMetrics `json:"sources"`
 
-	DetectionFps    float64 `json:"detection_fps"`
-	DetectionCostMs float64 `json:"detection_cost_ms"`
-	DetectionCount  int     `json:"detection_count"`
+	DatasetSaved      uint64 `json:"dataset_saved"`
+	DatasetDuplicates uint64 `json:"dataset_duplicates"`
 
 	StreamClients     int     `json:"stream_clients"`
+	StreamSpectators  int     `json:"stream_spectators"`
+	StreamMatchers    int     `json:"stream_matchers"`
 	StreamFps         float64 `json:"stream_fps"`
 	StreamFramesSent  uint64  `json:"stream_frames_sent"`
 	StreamDetections  uint64  `json:"stream_detections"`
@@ -47,6 +83,48 @@ type MetricsSnapshot struct {
 	GcSinceLastS float64 `json:"gc_since_last_s"`
 }
 
+// DetectionRegionMetrics 单个检测区域（屏幕坐标）的耗时与检测数。
+type DetectionRegionMetrics struct {
+	X      int     `json:"x"`
+	Y      int     `json:"y"`
+	W      int     `json:"w"`
+	H      int     `json:"h"`
+	CostMs float64 `json:"cost_ms"`
+	Count  int     `json:"count"`
+}
+
+// SourceMetrics 单个推理源（本地引擎或一台手机）的状态。
+type SourceMetrics struct {
+	Name      string  `json:"name"`
+	Kind      string  `json:"kind"`
+	Health    string  `json:"health"`
+	Reason    string  `json:"reason,omitzero"`
+	Fps       float64 `json:"fps"`
+	LatencyMs float64 `json:"latency_ms"`
+	Count     int     `json:"count"`
+	Batches   uint64  `json:"batches"`
+}
+
+func sourceMetrics() []SourceMetrics {
+	const ms = float64(time.Millisecond)
+	sources := inferenceSources.List()
+	list := make([]SourceMetrics, len(sources))
+	for i, src := range sources {
+		s, h := src.Stats(), src.Health()
+		list[i] = SourceMetrics{
+			Name:      src.Name(),
+			Kind:      src.Kind().String(),
+			Health:    h.State.String(),
+			Reason:    h.Reason,
+			Fps:       s.Fps,
+			LatencyMs: float64(s.Latency) / ms,
+			Count:     s.Count,
+			Batches:   s.Batches,
+		}
+	}
+	return list
+}
+
 var lastGCStats debug.GCStats
 
 func snapshotMetrics() (m MetricsSnapshot) {
@@ -65,21 +143,23 @@ func snapshotMetrics() (m MetricsSnapshot) {
 		m.MatchFps = s.Fps
 		m.MatchCostMs = float64(s.Cost) / ms
 		m.MatchCount = s.Matched
+		m.MatchCoarse = s.Coarse
+		m.MatchErrors = s.Errors
+		m.MatchScale = s.Scale
+		m.MatchDrift = s.Drift
+		m.MatchCalibration = calibrationState(matcherEngine.Calibration())
 		if s.Matched > 0 {
 			m.MatchCostAvgMs = m.MatchCostMs / float64(s.Matched)
 		}
 		m.Idle = s.Idle
 		m.Narrowing = s.Narrowing
+		m.MatchRemote = s.Remote
 		m.WeaponFound = s.Found
 		m.WeaponVal = s.Confidence
+		m.WeaponValSmoothed = s.Smoothed
 
-		if s.Found {
-			res := matcherEngine.Result()
-			idx := res.WeaponIndex
-			wps := matcherEngine.Weapons()
-			if idx >= 0 && idx < len(wps) {
-				m.CurrentWeapon = wps[idx].String()
-			}
+		if wp, ok := matcherEngine.Weapon(); ok {
+			m.CurrentWeapon = wp.String()
 		}
 	}
 
@@ -87,8 +167,29 @@ func snapshotMetrics() (m MetricsSnapshot) {
 		s := detectorEngine.Stats()
 		m.DetectionFps = s.Fps
 		m.DetectionCostMs = float64(s.Cost) / ms
+		m.DetectionServerMs = float64(s.ServerCost) / ms
+		m.DetectionPreMs = float64(s.PreCost) / ms
+		m.DetectionPostMs = float64(s.PostCost) / ms
+		m.DetectionLatencyMs = float64(s.Latency) / ms
+		m.DetectionFrameId = s.FrameId
+		m.DetectionDropped = s.Dropped
+		m.DetectionProfile = s.Profile
+		m.DetectionBackend = s.Backend
+		for _, r := range s.Regions {
+			m.DetectionRegions = append(m.DetectionRegions, DetectionRegionMetrics{
+				X: r.Rect.Min.X, Y: r.Rect.Min.Y, W: r.Rect.Dx(), H: r.Rect.Dy(),
+				CostMs: float64(r.Cost) / ms,
+				Count:  r.Count,
+			})
+		}
+	}
+	if datasetRecorder != nil {
+		s := datasetRecorder.Stats()
+		m.DatasetSaved = s.Saved
+		m.DatasetDuplicates = s.Duplicates
 	}
-	for _, src := range inferenceSources {
+	m.Sources = sourceMetrics()
+	for _, src := range inferenceSources.List() {
 		results, _, fresh := src.Snapshot()
 		if fresh {
 			m.DetectionCount += len(results)
@@ -98,19 +199,19 @@ func snapshotMetrics() (m MetricsSnapshot) {
 	if streamServer != nil {
 		s := streamServer.Stats()
 		m.StreamClients = s.Clients
+		m.StreamSpectators = s.Spectators
+		m.StreamMatchers = s.Matchers
 		m.StreamFps = s.Fps
 		m.StreamFramesSent = s.FramesSent
 		m.StreamDetections = s.Detections
-		if remoteSource != nil {
-			if _, _, fresh := remoteSource.Snapshot(); fresh {
-				m.StreamFresh = true
-				m.StreamLastCount = s.LastCount
-				m.StreamLatencyMs = float64(s.LastLatency) / ms
-				m.StreamInferenceMs = float64(s.LastInference) / ms
-				m.StreamNetworkMs = m.StreamLatencyMs - m.StreamInferenceMs
-				if m.StreamNetworkMs < 0 {
-					m.StreamNetworkMs = 0
-				}
+		if _, _, fresh := inferenceSources.OfKind(detector.KindRemote).Snapshot(); fresh {
+			m.StreamFresh = true
+			m.StreamLastCount = s.LastCount
+			m.StreamLatencyMs = float64(s.LastLatency) / ms
+			m.StreamInferenceMs = float64(s.LastInference) / ms
+			m.StreamNetworkMs = m.StreamLatencyMs - m.StreamInferenceMs
+			if m.StreamNetworkMs < 0 {
+				m.StreamNetworkMs = 0
 			}
 		}
 	}
@@ -130,7 +231,9 @@ func snapshotMetrics() (m MetricsSnapshot) {
 	return
 }
 
-func startHttpServer(ctx context.Context, addr string) {
+// newHttpMux 注册全部路由；对应引擎未启用时相关路由回复 503。
+// 处理函数直接读取引擎全局变量，须在这些变量初始化完毕后再开始服务。
+func newHttpMux() *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
 		m := snapshotMetrics()
@@ -144,7 +247,30 @@ func startHttpServer(ctx context.Context, addr string) {
 		_, _ = w.Write(data)
 	})
 
-	srv := &http.Server{Addr: addr, Handler: mux}
+	if dir := cmp.Or(*labelDir, *recordDir); dir != "" {
+		store, err := dataset.Open(dir)
+		if err != nil {
+			log.Warn().Err(err).Str("dir", dir).Msg("labelling UI disabled")
+		} else {
+			dataset.NewLabeler(store, detector.CocoClassNames).Register(mux, "/label")
+			log.Info().Str("dir", dir).Msg("labelling UI at /label/")
+		}
+	}
+
+	mux.HandleFunc("GET /sources", func(w http.ResponseWriter, r *http.Request) {
+		writeJsonResponse(w, http.StatusOK, sourceMetrics())
+	})
+	registerModelHandlers(mux)
+	registerRoiHandlers(mux)
+	registerCalibrationHandlers(mux)
+	registerMatcherPreviewHandler(mux)
+	registerTemplateCaptureHandlers(mux)
+	registerTemplateHandlers(mux)
+	return mux
+}
+
+func startHttpServer(ctx context.Context, addr string) {
+	srv := &http.Server{Addr: addr, Handler: newHttpMux()}
 
 	go func() {
 		<-ctx.Done()
@@ -158,3 +284,418 @@ func startHttpServer(ctx context.Context, addr string) {
 		}
 	}()
 }
+
+// ModelProfiles GET /detector/profiles 的返回。
+type ModelProfiles struct {
+	Active   string             `json:"active"`
+	Profiles []detector.Profile `json:"profiles"`
+}
+
+// requireModels 检测器或模型配置档未启用时回复 503。
+func requireModels(w http.ResponseWriter) bool {
+	if detectorEngine == nil || modelRegistry == nil {
+		http.Error(w, "model profiles disabled", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// registerModelHandlers 模型配置档的查看、切换与回滚；切换在请求中同步完成（含预热）。
+func registerModelHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("GET /detector/profiles", func(w http.ResponseWriter, r *http.Request) {
+		if !requireModels(w) {
+			return
+		}
+		writeJsonResponse(w, http.StatusOK, ModelProfiles{
+			Active:   detectorEngine.Profile().Name,
+			Profiles: modelRegistry.List(),
+		})
+	})
+	mux.HandleFunc("POST /detector/profiles/{name}", func(w http.ResponseWriter, r *http.Request) {
+		if !requireModels(w) {
+			return
+		}
+		p, ok := modelRegistry.Get(r.PathValue("name"))
+		if !ok {
+			http.Error(w, "profile not found", http.StatusNotFound)
+			return
+		}
+		if err := detectorEngine.LoadProfile(p); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		writeJsonResponse(w, http.StatusOK, detectorEngine.Profile())
+	})
+	mux.HandleFunc("POST /detector/rollback", func(w http.ResponseWriter, r *http.Request) {
+		if !requireModels(w) {
+			return
+		}
+		err := detectorEngine.Rollback()
+		switch {
+		case errors.Is(err, detector.ErrNoPreviousProfile):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		writeJsonResponse(w, http.StatusOK, detectorEngine.Profile())
+	})
+}
+
+// RoiCalibration GET /matcher/calibration 的返回。
+type RoiCalibration struct {
+	State    string  `json:"state"`
+	Roi      Rect    `json:"roi"`
+	Proposal *Rect   `json:"proposal,omitzero"`
+	Scale    float64 `json:"scale,omitzero"`
+	Frames   int     `json:"frames"`
+	Hits     int     `json:"hits"`
+}
+
+// Rect 屏幕坐标矩形。
+type Rect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+func rectOf(r image.Rectangle) Rect {
+	return Rect{X: r.Min.X, Y: r.Min.Y, W: r.Dx(), H: r.Dy()}
+}
+
+func calibrationState(c matcher.Calibration) string {
+	switch {
+	case c.Active:
+		return fmt.Sprintf("searching %d/%d", c.Frames, matcher.CALIBRATION_MAX_FRAMES)
+	case !c.Proposal.Empty():
+		return "proposed"
+	case c.Failed:
+		return "failed"
+	}
+	return ""
+}
+
+func roiCalibration(e *matcher.Engine) RoiCalibration {
+	c := e.Calibration()
+	rc := RoiCalibration{
+		State:  cmp.Or(calibrationState(c), "idle"),
+		Roi:    rectOf(e.Roi()),
+		Scale:  c.Scale,
+		Frames: c.Frames,
+		Hits:   c.Hits,
+	}
+	if !c.Proposal.Empty() {
+		proposal := rectOf(c.Proposal)
+		rc.Proposal = &proposal
+	}
+	return rc
+}
+
+// registerCalibrationHandlers ROI 校准：POST 开始（可选 x,y,w,h 限定搜索范围），
+// POST confirm 采用建议，DELETE 取消；均可用 roi 参数指定 ROI，缺省为主 ROI。
+func registerCalibrationHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("GET /matcher/calibration", func(w http.ResponseWriter, r *http.Request) {
+		e, ok := requestRoi(w, r)
+		if !ok {
+			return
+		}
+		writeJsonResponse(w, http.StatusOK, roiCalibration(e))
+	})
+	mux.HandleFunc("POST /matcher/calibration", func(w http.ResponseWriter, r *http.Request) {
+		e, ok := requestRoi(w, r)
+		if !ok {
+			return
+		}
+		area, ok := queryRect(w, r)
+		if !ok {
+			return
+		}
+		e.StartCalibration(area)
+		writeJsonResponse(w, http.StatusAccepted, roiCalibration(e))
+	})
+	mux.HandleFunc("POST /matcher/calibration/confirm", func(w http.ResponseWriter, r *http.Request) {
+		e, ok := requestRoi(w, r)
+		if !ok {
+			return
+		}
+		if _, err := e.ConfirmCalibration(); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writeJsonResponse(w, http.StatusOK, roiCalibration(e))
+	})
+	mux.HandleFunc("DELETE /matcher/calibration", func(w http.ResponseWriter, r *http.Request) {
+		e, ok := requestRoi(w, r)
+		if !ok {
+			return
+		}
+		e.CancelCalibration()
+		writeJsonResponse(w, http.StatusOK, roiCalibration(e))
+	})
+}
+
+// queryRect 请求的 x,y,w,h 参数，没有 w 时为空矩形；格式错误时回复 400。
+func queryRect(w http.ResponseWriter, r *http.Request) (image.Rectangle, bool) {
+	q := r.URL.Query()
+	if !q.Has("w") {
+		return image.Rectangle{}, true
+	}
+	var x, y, rw, rh int
+	for _, f := range []struct {
+		name string
+		v    *int
+	}{{"x", &x}, {"y", &y}, {"w", &rw}, {"h", &rh}} {
+		n, err := strconv.Atoi(q.Get(f.name))
+		if err != nil {
+			http.Error(w, "invalid "+f.name, http.StatusBadRequest)
+			return image.Rectangle{}, false
+		}
+		*f.v = n
+	}
+	return image.Rect(x, y, x+rw, y+rh), true
+}
+
+// requireMatcher 匹配器未启用时回复 503。
+func requireMatcher(w http.ResponseWriter) bool {
+	if matcherSet == nil {
+		http.Error(w, "matcher disabled", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// requestRoi 请求的 roi 参数指定的 ROI，缺省为主 ROI；不存在时回复 404，匹配器未启用时回复 503。
+func requestRoi(w http.ResponseWriter, r *http.Request) (*matcher.Engine, bool) {
+	if !requireMatcher(w) {
+		return nil, false
+	}
+	name := r.URL.Query().Get("roi")
+	if name == "" {
+		return matcherEngine, true
+	}
+	e, ok := matcherSet.Get(name)
+	if !ok {
+		http.Error(w, "ROI not found", http.StatusNotFound)
+	}
+	return e, ok
+}
+
+// RoiState GET /matcher/rois 的一项，为经迟滞确认的结果。
+type RoiState struct {
+	Name       string  `json:"name"`
+	Roi        Rect    `json:"roi"`
+	Found      bool    `json:"found"`
+	Template   string  `json:"template,omitzero"`
+	Confidence float32 `json:"confidence,omitzero"`
+	Fps        float64 `json:"fps"`
+	Idle       bool    `json:"idle"`
+}
+
+// RoiEvent GET /matcher/events 推送的一项。
+type RoiEvent struct {
+	Roi        string  `json:"roi"`
+	Found      bool    `json:"found"`
+	Template   string  `json:"template,omitzero"`
+	Confidence float32 `json:"confidence,omitzero"`
+	TimeMs     int64   `json:"time_ms"`
+}
+
+func roiStates() []RoiState {
+	engines := matcherSet.Engines()
+	states := make([]RoiState, 0, len(engines))
+	for _, e := range engines {
+		state, s := e.State(), e.Stats()
+		st := RoiState{
+			Name:       e.Name(),
+			Roi:        rectOf(e.Roi()),
+			Found:      state.ID != matcher.WEAPON_ID_NONE,
+			Confidence: state.Confidence,
+			Fps:        s.Fps,
+			Idle:       s.Idle,
+		}
+		if st.Found {
+			if wp, ok := e.Weapons().Get(state.ID); ok {
+				st.Template = wp.Name
+			}
+		}
+		states = append(states, st)
+	}
+	return states
+}
+
+// registerRoiHandlers 各 ROI 的最新结果，以及全部 ROI 合并的结果变化流（SSE）。
+func registerRoiHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("GET /matcher/rois", func(w http.ResponseWriter, r *http.Request) {
+		if !requireMatcher(w) {
+			return
+		}
+		writeJsonResponse(w, http.StatusOK, roiStates())
+	})
+	mux.HandleFunc("GET /matcher/events", func(w http.ResponseWriter, r *http.Request) {
+		if !requireMatcher(w) {
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		events, cancel := matcherSet.Subscribe()
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case ev := <-events:
+				data, err := jsonv2.Marshal(RoiEvent{
+					Roi:        ev.Roi,
+					Found:      ev.Result.Found,
+					Template:   ev.Name,
+					Confidence: ev.Result.Confidence,
+					TimeMs:     ev.Time.UnixMilli(),
+				})
+				if err != nil {
+					return
+				}
+				if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	})
+}
+
+// registerMatcherPreviewHandler 预处理后的 ROI（PNG，roi 参数指定 ROI），用于调整预处理链。
+func registerMatcherPreviewHandler(mux *http.ServeMux) {
+	mux.HandleFunc("GET /matcher/preview.png", func(w http.ResponseWriter, r *http.Request) {
+		e, ok := requestRoi(w, r)
+		if !ok {
+			return
+		}
+		preview := e.Preview()
+		if preview.Image == nil {
+			http.Error(w, "no local match yet", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("X-Preprocess", preview.Preprocess)
+		w.Header().Set("X-Frame-Id", strconv.FormatUint(preview.FrameID, 10))
+		_ = png.Encode(w, preview.Image)
+	})
+}
+
+// CapturedTemplate POST / DELETE /templates/capture 与 POST /templates/capture/pending 的返回。
+type CapturedTemplate struct {
+	Path string `json:"path"`
+	Roi  string `json:"roi,omitzero"`
+	Rect *Rect  `json:"rect,omitzero"`
+}
+
+// requestCapture 按 roi 与 x,y,w,h（相对 ROI 左上角，缺省为整个 ROI）截取最新一帧。
+func requestCapture(w http.ResponseWriter, r *http.Request) (capturedTemplate, bool) {
+	e, ok := requestRoi(w, r)
+	if !ok {
+		return capturedTemplate{}, false
+	}
+	sub, ok := queryRect(w, r)
+	if !ok {
+		return capturedTemplate{}, false
+	}
+	if !sub.Empty() {
+		sub = sub.Add(e.Roi().Min)
+	}
+	t, err := tmplCapture.grab(e, sub)
+	switch {
+	case errors.Is(err, errSelectionOutside):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return capturedTemplate{}, false
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return capturedTemplate{}, false
+	}
+	return t, true
+}
+
+// registerTemplateCaptureHandlers 从实时画面截取模板：GET 预览，POST 以 class 与 name 写入该 ROI 的模板目录
+// （由热重载加载），DELETE 撤销最近一次截取；/pending 命名（POST）或放弃（DELETE）快捷键截取的等待命名的模板。
+func registerTemplateCaptureHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("GET /templates/capture.png", func(w http.ResponseWriter, r *http.Request) {
+		t, ok := requestCapture(w, r)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = png.Encode(w, t.img)
+	})
+	mux.HandleFunc("POST /templates/capture", func(w http.ResponseWriter, r *http.Request) {
+		t, ok := requestCapture(w, r)
+		if !ok {
+			return
+		}
+		q := r.URL.Query()
+		path, err := tmplCapture.save(t, q.Get("class"), q.Get("name"))
+		switch {
+		case errors.Is(err, weapon.ErrTemplateExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rect := rectOf(t.rect)
+		writeJsonResponse(w, http.StatusCreated, CapturedTemplate{Path: path, Roi: t.roi.Name(), Rect: &rect})
+	})
+	mux.HandleFunc("POST /templates/capture/pending", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		path, err := tmplCapture.name(q.Get("class"), q.Get("name"))
+		switch {
+		case errors.Is(err, errNoPendingCapture), errors.Is(err, weapon.ErrTemplateExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJsonResponse(w, http.StatusCreated, CapturedTemplate{Path: path})
+	})
+	mux.HandleFunc("DELETE /templates/capture/pending", func(w http.ResponseWriter, r *http.Request) {
+		if err := tmplCapture.cancel(); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("DELETE /templates/capture", func(w http.ResponseWriter, r *http.Request) {
+		path, err := tmplCapture.undo()
+		switch {
+		case errors.Is(err, errNothingToUndo):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJsonResponse(w, http.StatusOK, CapturedTemplate{Path: path})
+	})
+}
+
+func writeJsonResponse(w http.ResponseWriter, status int, v any) {
+	data, err := jsonv2.Marshal(v, jsontext.WithIndent("  "))
+	if err != nil {
+		http.Error(w, "marshal: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(data, '\n'))
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	jsonv2 "encoding/json/v2"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Miuzarte/GoCVStreamer/capturer"
+	"github.com/Miuzarte/GoCVStreamer/matcher"
+	"github.com/Miuzarte/GoCVStreamer/weapon"
+	ws "github.com/Miuzarte/GoCVStreamer/weapons"
+	"gocv.io/x/gocv"
+)
+
+// fakeSource 是 capturer.Source 的最小实现：输出全黑帧。
+type fakeSource struct {
+	bounds image.Rectangle
+}
+
+func (f *fakeSource) Bounds() image.Rectangle                 { return f.bounds }
+func (f *fakeSource) GetImage(*image.RGBA) error              { return nil }
+func (f *fakeSource) GetImageTimeout(*image.RGBA, uint) error { return nil }
+func (f *fakeSource) ProvideMat(*gocv.Mat) bool               { return false }
+func (f *fakeSource) FramesElapsed() int                      { return 0 }
+func (f *fakeSource) ResetFramesElapsed()                     {}
+func (f *fakeSource) Close() error                            { return nil }
+
+// TestHttpRoutes 路由与引擎是否启用无关，引擎未启用时回复 503 而不是 404。
+func TestHttpRoutes(t *testing.T) {
+	mux := newHttpMux()
+	for _, route := range []struct {
+		method, path, pattern string
+	}{
+		{"GET", "/metrics", "GET /metrics"},
+		{"GET", "/sources", "GET /sources"},
+		{"GET", "/detector/profiles", "GET /detector/profiles"},
+		{"POST", "/detector/profiles/fast", "POST /detector/profiles/{name}"},
+		{"POST", "/detector/rollback", "POST /detector/rollback"},
+		{"GET", "/matcher/rois", "GET /matcher/rois"},
+		{"GET", "/matcher/events", "GET /matcher/events"},
+		{"POST", "/matcher/calibration/confirm", "POST /matcher/calibration/confirm"},
+		{"GET", "/matcher/preview.png", "GET /matcher/preview.png"},
+		{"POST", "/templates/capture", "POST /templates/capture"},
+		{"POST", "/templates/capture/pending", "POST /templates/capture/pending"},
+		{"GET", "/templates/", "GET /templates/{$}"},
+		{"GET", "/templates/api/templates", "GET /templates/api/templates"},
+		{"PATCH", "/templates/api/templates/1", "PATCH /templates/api/templates/{id}"},
+		{"POST", "/templates/api/reload", "POST /templates/api/reload"},
+	} {
+		req := httptest.NewRequest(route.method, route.path, nil)
+		if _, pattern := mux.Handler(req); pattern != route.pattern {
+			t.Errorf("%s %s -> %q, want %q", route.method, route.path, pattern, route.pattern)
+		}
+	}
+
+	for _, path := range []string{"/detector/profiles", "/matcher/rois", "/templates/api/templates"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("GET %s without engines = %d, want 503", path, rec.Code)
+		}
+	}
+}
+
+// serveRequest 经 mux 处理一个请求。
+func serveRequest(mux *http.ServeMux, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec
+}
+
+// TestTemplateRenameKeepsID 经 HTTP 重命名模板再对账整个目录，模板 ID 不变。
+func TestTemplateRenameKeepsID(t *testing.T) {
+	dir := t.TempDir()
+	img := image.NewGray(image.Rect(0, 0, 8, 4))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	f, err := os.Create(filepath.Join(dir, "{AR_10_--} A.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = png.Encode(f, img)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := ws.NewRegistry()
+	t.Cleanup(func() { reg.Close() })
+	watcher, err := ws.NewWatcher(reg, ws.WatcherConfig{
+		Dir:          dir,
+		Suffix:       ".png",
+		IgnorePrefix: "__",
+		Flag:         gocv.IMReadGrayScale,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { watcher.Close() })
+	watcher.Rescan()
+	id := reg.IDByName("A")
+	if id == weapon.ID_NONE {
+		t.Fatal("template not loaded")
+	}
+
+	// 与 main 相同的全局状态：匹配器、该目录的模板库
+	capturerServer = capturer.NewServer(&fakeSource{bounds: image.Rect(0, 0, 1280, 720)}, capturer.Config{DisableOpenCV: true}, nil)
+	matcherSet, err = matcher.NewSet(capturerServer, []matcher.Config{{
+		Templates: dir,
+		Weapons:   reg,
+		Layout:    matcher.DefaultLayout(),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	matcherEngine = matcherSet.Primary()
+	librariesMu.Lock()
+	templateLibraries[filepath.Clean(dir)] = ws.NewLibrary(watcher)
+	librariesMu.Unlock()
+	t.Cleanup(func() {
+		librariesMu.Lock()
+		delete(templateLibraries, filepath.Clean(dir))
+		librariesMu.Unlock()
+		capturerServer, matcherSet, matcherEngine = nil, nil, nil
+	})
+
+	mux := newHttpMux()
+	rec := serveRequest(mux, "PATCH", "/templates/api/templates/"+strconv.FormatUint(uint64(id), 10), `{"name":"B"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PATCH = %d %s", rec.Code, rec.Body)
+	}
+	var info TemplateInfo
+	if err := jsonv2.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != id || info.Name != "B" {
+		t.Fatalf("renamed = %+v, want id %d", info, id)
+	}
+
+	if rec := serveRequest(mux, "POST", "/templates/api/reload", ""); rec.Code != http.StatusOK {
+		t.Fatalf("reload = %d %s", rec.Code, rec.Body)
+	}
+	rec = serveRequest(mux, "GET", "/templates/api/templates", "")
+	var list TemplateList
+	if err := jsonv2.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Templates) != 1 || list.Templates[0].ID != id || list.Templates[0].Name != "B" {
+		t.Fatalf("templates after reload = %+v, want B with id %d", list.Templates, id)
+	}
+}
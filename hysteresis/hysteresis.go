@@ -0,0 +1,170 @@
+// Package hysteresis 把逐帧的原始识别结果整理为稳定的状态与变化事件：
+// N-of-M 确认、进入与退出分别设置迟滞、最短停留时长与置信度平滑。
+package hysteresis
+
+import (
+	"time"
+)
+
+// Clock 时间来源，nil 为 time.Now；测试中注入脚本化的时间。
+type Clock func() time.Time
+
+type Config struct {
+	// EnterHits / EnterWindow 最近 EnterWindow 帧中同一 ID 命中不少于 EnterHits 帧才进入（或切换到）该 ID
+	EnterHits   int
+	EnterWindow int
+	// ExitMisses / ExitWindow 最近 ExitWindow 帧中当前 ID 未命中不少于 ExitMisses 帧才可能退出为无
+	ExitMisses int
+	ExitWindow int
+	// ExitDelay 当前 ID 最后一次命中后至少经过该时长才退出为无
+	ExitDelay time.Duration
+	// MinDwell 进入或退出后至少保持该时长才允许再次变化
+	MinDwell time.Duration
+	// Smoothing 置信度指数平滑系数（0, 1]，新一帧的权重；0 与 1 都不平滑
+	Smoothing float32
+
+	Clock Clock
+}
+
+// DefaultConfig 逐帧跟随原始结果，不做任何迟滞。
+func DefaultConfig() Config {
+	return Config{
+		EnterHits:   1,
+		EnterWindow: 1,
+		ExitMisses:  1,
+		ExitWindow:  1,
+		Smoothing:   1,
+	}
+}
+
+// normalize 把零值与越界的设置换成逐帧跟随的取值。
+func (c Config) normalize() Config {
+	c.EnterWindow = max(c.EnterWindow, 1)
+	c.EnterHits = min(max(c.EnterHits, 1), c.EnterWindow)
+	c.ExitWindow = max(c.ExitWindow, 1)
+	c.ExitMisses = min(max(c.ExitMisses, 1), c.ExitWindow)
+	if c.Smoothing <= 0 || c.Smoothing > 1 {
+		c.Smoothing = 1
+	}
+	if c.Clock == nil {
+		c.Clock = time.Now
+	}
+	return c
+}
+
+// State 当前确认的状态。
+type State[K comparable] struct {
+	ID K
+	// Confidence 平滑后的置信度，无时为 0
+	Confidence float32
+	// Since 进入当前状态的时间，尚未变化过时为零值
+	Since time.Time
+}
+
+// Event 状态变化。
+type Event[K comparable] struct {
+	From, To   K
+	Confidence float32
+	Time       time.Time
+}
+
+type sample[K comparable] struct {
+	id         K
+	confidence float32
+}
+
+// Machine 识别结果状态机，none 为“无”的取值。不是并发安全的，由调用方加锁。
+type Machine[K comparable] struct {
+	cfg  Config
+	none K
+
+	samples  []sample[K] // 最近的原始结果，最新的在末尾
+	state    State[K]
+	lastSeen time.Time // 当前 ID 最近一次命中
+}
+
+func New[K comparable](cfg Config, none K) *Machine[K] {
+	m := &Machine[K]{none: none}
+	m.SetConfig(cfg)
+	m.state.ID = none
+	return m
+}
+
+// SetConfig 更新设置，保留当前状态与已记录的原始结果。
+func (m *Machine[K]) SetConfig(cfg Config) {
+	m.cfg = cfg.normalize()
+	if n := m.capacity(); len(m.samples) > n {
+		m.samples = append(m.samples[:0], m.samples[len(m.samples)-n:]...)
+	}
+}
+
+func (m *Machine[K]) State() State[K] {
+	return m.state
+}
+
+// Reset 回到无，清空原始结果，不产生事件。
+func (m *Machine[K]) Reset() {
+	m.samples = m.samples[:0]
+	m.state = State[K]{ID: m.none}
+	m.lastSeen = time.Time{}
+}
+
+// Observe 记录一帧原始结果（未命中时 id 为 none），状态变化时返回事件。
+func (m *Machine[K]) Observe(id K, confidence float32) (Event[K], bool) {
+	now := m.cfg.Clock()
+	if id == m.none {
+		confidence = 0
+	}
+	m.samples = append(m.samples, sample[K]{id, confidence})
+	if n := m.capacity(); len(m.samples) > n {
+		m.samples = append(m.samples[:0], m.samples[len(m.samples)-n:]...)
+	}
+
+	cur := m.state.ID
+	if id == cur && cur != m.none {
+		m.lastSeen = now
+		a := m.cfg.Smoothing
+		m.state.Confidence = a*confidence + (1-a)*m.state.Confidence
+	}
+
+	if !m.state.Since.IsZero() && now.Sub(m.state.Since) < m.cfg.MinDwell {
+		return Event[K]{}, false
+	}
+	if id != m.none && id != cur {
+		if hits, sum := m.hits(id, m.cfg.EnterWindow); hits >= m.cfg.EnterHits {
+			return m.transition(id, sum/float32(hits), now), true
+		}
+	}
+	if cur != m.none {
+		hits, _ := m.hits(cur, m.cfg.ExitWindow)
+		misses := min(len(m.samples), m.cfg.ExitWindow) - hits
+		if misses >= m.cfg.ExitMisses && now.Sub(m.lastSeen) >= m.cfg.ExitDelay {
+			return m.transition(m.none, 0, now), true
+		}
+	}
+	return Event[K]{}, false
+}
+
+func (m *Machine[K]) transition(to K, confidence float32, now time.Time) Event[K] {
+	ev := Event[K]{From: m.state.ID, To: to, Confidence: confidence, Time: now}
+	m.state = State[K]{ID: to, Confidence: confidence, Since: now}
+	if to != m.none {
+		m.lastSeen = now
+	}
+	return ev
+}
+
+// hits 最近 n 帧中 id 的命中数与置信度之和。
+func (m *Machine[K]) hits(id K, n int) (hits int, sum float32) {
+	for _, s := range m.samples[max(len(m.samples)-n, 0):] {
+		if s.id == id {
+			hits++
+			sum += s.confidence
+		}
+	}
+	return
+}
+
+func (m *Machine[K]) capacity() int {
+	return max(m.cfg.EnterWindow, m.cfg.ExitWindow)
+}
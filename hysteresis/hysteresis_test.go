@@ -0,0 +1,149 @@
+package hysteresis
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+const NONE = 0
+
+// step 脚本中的一帧：先推进 dt，再观察 id。
+type step struct {
+	dt   time.Duration
+	id   int
+	conf float32
+	// want 该帧之后的状态，change 该帧是否产生事件
+	want   int
+	change bool
+}
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func run(t *testing.T, cfg Config, steps []step) *Machine[int] {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cfg.Clock = clock.Now
+	m := New(cfg, NONE)
+	for i, s := range steps {
+		clock.now = clock.now.Add(s.dt)
+		ev, changed := m.Observe(s.id, s.conf)
+		if changed != s.change {
+			t.Fatalf("step %d: changed = %v, want %v", i, changed, s.change)
+		}
+		if got := m.State().ID; got != s.want {
+			t.Fatalf("step %d: state = %d, want %d", i, got, s.want)
+		}
+		if changed && (ev.To != s.want || !ev.Time.Equal(clock.now)) {
+			t.Fatalf("step %d: event %+v", i, ev)
+		}
+	}
+	return m
+}
+
+func TestDefaultFollowsRawResults(t *testing.T) {
+	const frame = 200 * time.Millisecond
+	run(t, Config{}, []step{
+		{frame, NONE, 0, NONE, false},
+		{frame, 1, 0.9, 1, true},
+		{frame, 1, 0.9, 1, false},
+		{frame, 2, 0.8, 2, true},
+		{frame, NONE, 0, NONE, true},
+	})
+}
+
+// TestNOfM 2-of-3 进入，3-of-3 未命中才退出，单帧误检与单帧漏检都被滤掉。
+func TestNOfM(t *testing.T) {
+	const frame = 200 * time.Millisecond
+	cfg := Config{EnterHits: 2, EnterWindow: 3, ExitMisses: 3, ExitWindow: 3}
+	run(t, cfg, []step{
+		{frame, 1, 0.9, NONE, false}, // 单帧不足以进入
+		{frame, NONE, 0, NONE, false},
+		{frame, 1, 0.9, 1, true}, // 3 帧中 2 帧
+		{frame, NONE, 0, 1, false},
+		{frame, 2, 0.7, 1, false}, // 单帧误检不切换
+		{frame, 1, 0.9, 1, false},
+		{frame, NONE, 0, 1, false},
+		{frame, NONE, 0, 1, false},
+		{frame, NONE, 0, NONE, true}, // 连续 3 帧未命中
+	})
+}
+
+// TestExitDelay 与 main 原有的防抖一致：退出为无前保持 1.5 秒，期间命中则重新计时；切换到其他 ID 不受影响。
+func TestExitDelay(t *testing.T) {
+	const frame = 500 * time.Millisecond
+	cfg := DefaultConfig()
+	cfg.ExitDelay = 1500 * time.Millisecond
+	run(t, cfg, []step{
+		{frame, 1, 0.9, 1, true},
+		{frame, NONE, 0, 1, false},
+		{frame, NONE, 0, 1, false},
+		{frame, 1, 0.9, 1, false}, // 重新计时
+		{frame, NONE, 0, 1, false},
+		{frame, NONE, 0, 1, false},
+		{frame, NONE, 0, NONE, true}, // 最后一次命中后 1.5 秒
+		{frame, 2, 0.8, 2, true},
+		{frame, 3, 0.8, 3, true},
+	})
+}
+
+// TestMinDwell 进入后停留期间不切换也不退出，停留结束后按当前结果变化。
+func TestMinDwell(t *testing.T) {
+	const frame = 100 * time.Millisecond
+	cfg := DefaultConfig()
+	cfg.MinDwell = 300 * time.Millisecond
+	run(t, cfg, []step{
+		{frame, 1, 0.9, 1, true},
+		{frame, 2, 0.9, 1, false},
+		{frame, NONE, 0, 1, false},
+		{frame, 2, 0.9, 2, true},
+		{frame, NONE, 0, 2, false},
+		{frame, NONE, 0, 2, false},
+		{frame, NONE, 0, NONE, true},
+		{frame, 1, 0.9, NONE, false},
+	})
+}
+
+func TestConfidenceSmoothing(t *testing.T) {
+	const frame = 100 * time.Millisecond
+	cfg := Config{EnterHits: 2, EnterWindow: 2, Smoothing: 0.5}
+	m := run(t, cfg, []step{
+		{frame, 1, 0.6, NONE, false},
+		{frame, 1, 0.8, 1, true},
+	})
+	// 进入时取窗口内的平均值
+	if got := m.State().Confidence; math.Abs(float64(got-0.7)) > 1e-6 {
+		t.Fatalf("confidence on enter = %v, want 0.7", got)
+	}
+	m.Observe(1, 0.9)
+	if got := m.State().Confidence; math.Abs(float64(got-0.8)) > 1e-6 {
+		t.Fatalf("smoothed confidence = %v, want 0.8", got)
+	}
+}
+
+func TestSetConfigKeepsState(t *testing.T) {
+	const frame = 100 * time.Millisecond
+	cfg := DefaultConfig()
+	cfg.ExitDelay = time.Second
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cfg.Clock = clock.Now
+	m := New(cfg, NONE)
+	m.Observe(1, 0.9)
+	clock.now = clock.now.Add(frame)
+	if _, changed := m.Observe(NONE, 0); changed {
+		t.Fatal("exited before delay")
+	}
+
+	// 关闭退出迟滞（调试）后下一帧即退出
+	cfg.ExitDelay = 0
+	m.SetConfig(cfg)
+	if m.State().ID != 1 {
+		t.Fatal("SetConfig changed state")
+	}
+	clock.now = clock.now.Add(frame)
+	if ev, changed := m.Observe(NONE, 0); !changed || ev.From != 1 || ev.To != NONE {
+		t.Fatalf("event = %+v, changed %v", ev, changed)
+	}
+}
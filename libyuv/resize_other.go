@@ -0,0 +1,20 @@
+//go:build !windows
+
+package libyuv
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// ResizeRGBA 非 Windows 平台没有 libyuv.dll，退回纯 Go 双线性缩放（供测试使用）
+func ResizeRGBA(src *image.RGBA, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	ResizeRGBAInto(dst, src, w, h)
+	return dst
+}
+
+func ResizeRGBAInto(dst *image.RGBA, src *image.RGBA, w, h int) {
+	draw.BiLinear.Scale(dst, image.Rect(0, 0, w, h), src, src.Bounds(), draw.Src, nil)
+}
@@ -9,10 +9,11 @@ import (
 	"fmt"
 	"image"
 	"io"
+	"maps"
 	"math/bits"
 	"os"
 	"path/filepath"
-	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -28,19 +29,21 @@ import (
 	"github.com/Miuzarte/GoCVStreamer/capturer"
 	cwg "github.com/Miuzarte/GoCVStreamer/contextWaitGroup"
 	"github.com/Miuzarte/GoCVStreamer/cuda"
+	"github.com/Miuzarte/GoCVStreamer/dataset"
 	"github.com/Miuzarte/GoCVStreamer/detector"
+	"github.com/Miuzarte/GoCVStreamer/hysteresis"
 	"github.com/Miuzarte/GoCVStreamer/keystate"
 	"github.com/Miuzarte/GoCVStreamer/logger"
 	"github.com/Miuzarte/GoCVStreamer/matcher"
 	"github.com/Miuzarte/GoCVStreamer/mouse"
 	"github.com/Miuzarte/GoCVStreamer/remoteclient"
 	"github.com/Miuzarte/GoCVStreamer/sender"
+	"github.com/Miuzarte/GoCVStreamer/template"
 	"github.com/Miuzarte/GoCVStreamer/ui"
 	w "github.com/Miuzarte/GoCVStreamer/weapon"
 	ws "github.com/Miuzarte/GoCVStreamer/weapons"
 	"github.com/Miuzarte/GoCVStreamer/wgc"
 	"github.com/Miuzarte/GoCVStreamer/widgets"
-	"github.com/fsnotify/fsnotify"
 	"github.com/getcharzp/go-vision/yolo26"
 	"github.com/kbinani/screenshot"
 	"github.com/shirou/gopsutil/v4/process"
@@ -55,9 +58,16 @@ var (
 	httpPort    = flag.String("port", ":8080", "HTTP metrics server port")
 	nohttp      = flag.Bool("nohttp", false, "disable HTTP metrics server")
 	noyolo      = flag.Bool("noyolo", false, "disable YOLO person detection")
+	detBackend  = flag.String("detbackend", detector.BACKEND_YOLO, "detector backend: yolo, http, mock")
+	detUrl      = flag.String("deturl", "", "detector HTTP backend endpoint (for -detbackend http)")
+	detProtocol = flag.String("detprotocol", detector.HTTP_PROTOCOL_JPEG, "detector HTTP protocol: jpeg, kserve, kserve-binary")
+	detTile     = flag.Int("dettile", 0, "detector tile size in screen pixels, tiles overlap and are merged with NMS (0 = single centre crop)")
+	detProfiles = flag.String("detprofiles", "models", "detector model profile directory (NAME.json each), hot reloaded")
+	detProfile  = flag.String("detprofile", "", "detector model profile to start with (empty = built-in defaults and -det* flags)")
 	autodisplay = flag.Bool("autodisplay", false, "skip display selection, auto-select largest")
 	noopencv    = flag.Bool("noopencv", false, "disable OpenCV template matching")
 	game        = flag.String("game", "r6s", "game mode: r6s, cs2")
+	catalogDir  = flag.String("catalogs", "catalogs", "class catalogue directory (GAME.json each, built-in R6S classes if missing)")
 	source      = flag.String("source", "auto", "capture source: dxgi, obs, wgc, auto (DXGI preferred, WGC fallback)")
 	winname     = flag.String("window", "", "WGC window capture: process name or window title; 'auto' = current game process")
 	obsIndex    = flag.Int("obsindex", 0, "OBS Virtual Camera device index")
@@ -70,6 +80,20 @@ var (
 	streamCrop    = flag.Int("streamcrop", 1280, "WebSocket stream center crop size (-1=screen short edge, 0=no crop)")
 	nosender      = flag.Bool("nosender", false, "disable WebSocket stream server")
 	streamTtl     = flag.Int("streamttl", 500, "remote results TTL in ms (0 disables remote results)")
+	remoteMatch   = flag.Bool("remotematch", false, "offload template matching to remote matcher clients (ws://<host>/stream?role=matcher), local matching as fallback")
+	matchTtl      = flag.Int("matchttl", 1000, "remote match results TTL in ms before falling back to local matching")
+	matchScales   = flag.String("matchscales", "1,0.95,1.05,0.9,1.1,0.85,1.15", "template scale pyramid, relative to the scale derived from capture height (templates cut at 2560x1440)")
+	matchStrategy = flag.String("matchstrategy", "", "default matching strategy for templates without one: ccoeff_normed, ccorr_normed, sqdiff_normed, edge, orb (empty = ccoeff_normed)")
+	matchThresh   = flag.Float64("matchthreshold", 0, "default match threshold for templates without one (0 = strategy default)")
+	matchWorkers  = flag.Int("matchworkers", 1, "template matching goroutines (1 = match templates one by one)")
+	matchCoarse   = flag.Bool("matchcoarse", false, "prune templates on a half-resolution pass before full-resolution matching")
+	roiDir        = flag.String("rois", "rois", "ROI layout directory (GAME.json each: named ROIs with their own template directory, strategy, fps and idle policy), saved when an ROI is moved or calibrated")
+	matchPre      = flag.String("matchpreprocess", "gray", "ROI/template preprocessing chain, e.g. gray,clahe:2,blur:3 (steps: gray, bgr, hsv, hsv_v, lab_l, normalize, gamma:G, equalize, clahe[:CLIP], blur[:K], threshold[:T], T=0 for Otsu)")
+	spectatorSize = flag.Int("spectatorsize", 1280, "spectator stream long edge in px (annotated full-screen frames, ws://<host>/stream?role=spectator)")
+
+	recordDir      = flag.String("record", "", "dataset recorder directory (empty to disable), frames are saved on low confidence / local-remote disagreement")
+	recordInterval = flag.Int("recordinterval", 0, "dataset recorder periodic sampling interval in seconds (0 = off)")
+	labelDir       = flag.String("labeldir", "", "dataset directory served by the labelling UI at /label/ (default: -record)")
 
 	mhubAddr = flag.String("mhub-addr", "", "mhub remote injection address (e.g. 127.0.0.1:9000, empty = local injection)")
 )
@@ -78,13 +102,20 @@ var log = logger.New("Streamer")
 
 const (
 	TEMPLATES_DIRECTORY     = "templates"
+	TEMPLATES_DEPTH         = 3 // 允许按游戏/类别分子目录
 	TEMPLATES_SUFFIX        = ".png"
 	TEMPLATES_PREFIX_IGNORE = "__"
 )
 
 const (
-	CREATE_MASK                   = false
-	MATCHING_MODE gocv.IMReadFlag = gocv.IMReadGrayScale
+	LOCAL_SOURCE_NAME    = "local"
+	REMOTE_SOURCE_PREFIX = "remote/" // + 手机端地址
+)
+
+const (
+	CREATE_MASK = false
+	// TEMPLATE_READ_FLAG 模板按彩色读取，匹配用的色彩空间由 ROI 的预处理链决定
+	TEMPLATE_READ_FLAG gocv.IMReadFlag = gocv.IMReadColor
 )
 
 var (
@@ -94,26 +125,27 @@ var (
 	windowHandel    windows.HWND
 	windowTitle     = strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
 
-	weaponsMu sync.RWMutex
-	weapons   ws.Weapons
-)
+	weapons = ws.NewRegistry()
+	// roiTemplates 其他 ROI 的模板库，按模板目录（默认目录即 weapons）
+	roiTemplates = map[string]*ws.Registry{}
 
-var (
-	roiRectSize = image.Point{8 * 11, 8 * 13}
-	roiRectPos  = image.Point{2000, 1200}
-	// Xmid: 2045
-	roiRect = image.Rectangle{roiRectPos, roiRectPos.Add(roiRectSize)}
+	librariesMu sync.Mutex
+	// templateLibraries 各模板目录的管理操作（HTTP），热重载启动后可用
+	templateLibraries = map[string]*ws.Library{}
 )
 
 var (
 	capturerServer   *capturer.Server
 	streamServer     *sender.Server
-	matcherEngine    *matcher.Engine
+	matcherSet       *matcher.Set
+	matcherEngine    *matcher.Engine // 主 ROI（武器）
+	selectedRoi      *matcher.Engine // 方向键与校准操作的 ROI
 	detectorEngine   *detector.Engine
-	remoteSource     *detector.RemoteSource
+	modelRegistry    *detector.Registry
 	assistEngine     *assist.Engine
+	datasetRecorder  *dataset.Recorder
 	window           *ui.Window
-	inferenceSources []detector.Source
+	inferenceSources = detector.NewSources()
 
 	cpu         float64
 	forceUpdate bool
@@ -132,6 +164,9 @@ var mover mouse.Mover = mouse.LocalMover{}
 // weaponAlt 是否使用武器备选速度档 (Alt+Insert 切换, 复刻原 recoil 行为)
 var weaponAlt atomic.Bool
 
+// stdin 控制台输入唯一的读取者：各处共用，避免一个 reader 缓冲走另一处的输入
+var stdin = bufio.NewReader(os.Stdin)
+
 var _ = debuggingWaitForInput()
 
 func debuggingWaitForInput() (_ struct{}) {
@@ -143,7 +178,7 @@ func debuggingWaitForInput() (_ struct{}) {
 		Int("ppid", parentProcessId).
 		Msg("process ids")
 	fmt.Print("waiting for any input...")
-	_, err := bufio.NewReader(os.Stdin).ReadBytes('\n')
+	_, err := stdin.ReadBytes('\n')
 	if err == io.EOF {
 		err = nil
 	}
@@ -151,7 +186,8 @@ func debuggingWaitForInput() (_ struct{}) {
 	return
 }
 
-func init() {
+// setup 解析参数并准备采集源与模板；不放在 init 中，测试二进制才能解析自己的参数。
+func setup() {
 	flag.Parse()
 
 	var err error
@@ -169,10 +205,31 @@ func init() {
 	selectDisplay()
 
 	if !*noopencv {
+		loadCatalog()
 		loadTemplates()
 	}
 }
 
+// loadCatalog 按 -game 加载类别表数据文件，不存在时沿用内置（R6S）类别表。
+func loadCatalog() {
+	path := filepath.Join(*catalogDir, *game+".json")
+	catalog, err := w.LoadCatalog(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Warn().
+			Str("path", path).
+			Msg("class catalogue not found, using built-in R6S classes")
+		return
+	}
+	if err != nil {
+		log.Panic().Err(err).Msg("failed to load class catalogue")
+	}
+	w.SetActiveCatalog(catalog)
+	log.Info().
+		Str("game", catalog.Game).
+		Int("classes", len(catalog.Classes())).
+		Msg("class catalogue loaded")
+}
+
 func selectDisplay() {
 	var src capturer.Source
 	var err error
@@ -228,15 +285,13 @@ func selectDisplay() {
 		Int("height", bounds.Dy()).
 		Msg("using capture source")
 
-	roiRect = image.Rectangle{roiRectPos, roiRectPos.Add(roiRectSize)}
-
 	ui.InitTheme()
 
 	cfg := capturer.Config{
 		MinFps:        1,
 		DisableOpenCV: *noopencv,
 	}
-	capturerServer = capturer.NewServer(src, cfg, MATCHING_MODE, func() {
+	capturerServer = capturer.NewServer(src, cfg, func() {
 		if window != nil && !*nogui {
 			window.SetBounds(capturerServer.Bounds().Max)
 			window.App().Invalidate()
@@ -309,10 +364,9 @@ func selectDisplayInteractive() (int, error) {
 		fmt.Fprintf(os.Stdout, "[%d] %dx%d (X:%d, Y:%d)\n", i, size.X, size.Y, displayBounds[i].Min.X, displayBounds[i].Min.Y)
 	}
 
-	reader := bufio.NewReader(os.Stdin)
 	for {
 		fmt.Fprintf(os.Stdout, "input index in range [0,%d]: ", numDisplays-1)
-		input, err := reader.ReadString('\n')
+		input, err := stdin.ReadString('\n')
 		if err != nil && !errors.Is(err, io.EOF) {
 			return 0, fmt.Errorf("failed to read os.Stdin: %w", err)
 		}
@@ -348,26 +402,150 @@ func selectDisplayInteractive() (int, error) {
 	}
 }
 
+// loadTemplates 读取全部模板目录（启动时与 Ctrl+Shift+W）。
+// 热重载已接管的目录经 Library.Reload 对账，已有模板沿用原 ID；其余目录整个重新读取。
 func loadTemplates() {
-	weaponsMu.Lock()
-	defer weaponsMu.Unlock()
+	librariesMu.Lock()
+	libs := maps.Clone(templateLibraries)
+	librariesMu.Unlock()
 
 	tStart := time.Now()
-	if len(weapons) != 0 {
-		panicIf(weapons.Close())
+	if lib, ok := libs[filepath.Clean(TEMPLATES_DIRECTORY)]; ok {
+		lib.Reload()
+	} else {
+		if weapons.Len() != 0 {
+			panicIf(weapons.Close())
+		}
+		if err := weapons.ReadFrom(TEMPLATES_DIRECTORY, TEMPLATES_DEPTH, TEMPLATES_SUFFIX, TEMPLATES_PREFIX_IGNORE, CREATE_MASK, TEMPLATE_READ_FLAG); err != nil {
+			log.Warn().
+				Err(err).
+				Msg("some templates failed to load")
+		}
 	}
-	panicIf(weapons.ReadFrom(TEMPLATES_DIRECTORY, 1, TEMPLATES_SUFFIX, TEMPLATES_PREFIX_IGNORE, CREATE_MASK, MATCHING_MODE))
 	log.Info().
-		Int("templates", len(weapons)).
+		Int("templates", weapons.Len()).
 		Dur("cost", time.Since(tStart)).
 		Msg("templates loaded")
+
+	for dir, reg := range roiTemplates {
+		if lib, ok := libs[dir]; ok {
+			lib.Reload()
+			continue
+		}
+		readRoiTemplates(dir, reg)
+	}
+}
+
+// readRoiTemplates 重新读取其他 ROI 的模板目录；出错只记录日志，加载失败的模板跳过。
+func readRoiTemplates(dir string, reg *ws.Registry) {
+	if reg.Len() != 0 {
+		if err := reg.Close(); err != nil {
+			log.Warn().Err(err).Str("dir", dir).Msg("failed to close templates")
+		}
+	}
+	if err := reg.ReadFrom(dir, TEMPLATES_DEPTH, TEMPLATES_SUFFIX, TEMPLATES_PREFIX_IGNORE, CREATE_MASK, TEMPLATE_READ_FLAG); err != nil {
+		log.Warn().
+			Err(err).
+			Str("dir", dir).
+			Msg("some ROI templates failed to load")
+	}
+	log.Info().
+		Str("dir", dir).
+		Int("templates", reg.Len()).
+		Msg("ROI templates loaded")
+}
+
+// templateRegistry ROI 模板目录对应的模板库，默认模板目录即 weapons；
+// 其他目录首次用到时读取。
+func templateRegistry(dir string) *ws.Registry {
+	dir = filepath.Clean(dir)
+	if dir == TEMPLATES_DIRECTORY {
+		return weapons
+	}
+	if reg, ok := roiTemplates[dir]; ok {
+		return reg
+	}
+	reg := ws.NewRegistry()
+	readRoiTemplates(dir, reg)
+	roiTemplates[dir] = reg
+	return reg
+}
+
+func roiProfilePath() string {
+	return filepath.Join(*roiDir, *game+matcher.PROFILE_SUFFIX)
+}
+
+// initMatcher 按 -rois 下的 GAME.json 创建各 ROI，未声明的设置沿用 base（命令行）；
+// 文件不存在时只有按 base 配置的主 ROI。只有主 ROI 参与远程匹配。
+func initMatcher(base matcher.Config) {
+	base.Templates = TEMPLATES_DIRECTORY
+	specs := []matcher.RoiSpec{{Name: matcher.ROI_DEFAULT_NAME}}
+	path := roiProfilePath()
+	p, err := matcher.LoadProfile(path)
+	switch {
+	case err == nil:
+		specs = p.Rois
+		log.Info().
+			Str("path", path).
+			Int("rois", len(specs)).
+			Msg("ROI layout loaded")
+	case errors.Is(err, os.ErrNotExist):
+	default:
+		log.Warn().
+			Err(err).
+			Msg("invalid ROI layout, using the weapon ROI only")
+	}
+
+	cfgs := make([]matcher.Config, 0, len(specs))
+	for _, spec := range specs {
+		cfg, err := spec.Config(base)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Msg("invalid ROI, skipped")
+			continue
+		}
+		if len(cfgs) != 0 {
+			cfg.Offloader = nil
+		}
+		cfg.Weapons = templateRegistry(cfg.Templates)
+		cfgs = append(cfgs, cfg)
+	}
+
+	matcherSet, err = matcher.NewSet(capturerServer, cfgs)
+	if err != nil {
+		log.Panic().
+			Err(err).
+			Str("path", path).
+			Msg("failed to create matchers")
+	}
+	matcherSet.OnLayout = saveRoiProfile
+	matcherEngine = matcherSet.Primary()
+	selectedRoi = matcherEngine
+}
+
+var roiProfileMu sync.Mutex
+
+// saveRoiProfile 把当前全部 ROI 的布局写回 -rois 下的 GAME.json（界面与 HTTP 都可能触发）。
+func saveRoiProfile(p matcher.Profile) {
+	roiProfileMu.Lock()
+	defer roiProfileMu.Unlock()
+	path := roiProfilePath()
+	if err := matcher.SaveProfile(path, p); err != nil {
+		log.Warn().
+			Err(err).
+			Str("path", path).
+			Msg("failed to save ROI layout")
+		return
+	}
+	log.Debug().
+		Str("path", path).
+		Msg("ROI layout saved")
 }
 
 func main() {
+	setup()
 	defer func() {
-		weaponsMu.Lock()
-		defer weaponsMu.Unlock()
-
 		if capturerServer != nil {
 			capturerServer.Close()
 		}
@@ -390,21 +568,36 @@ func main() {
 			Msg("mhub remote injection enabled")
 	}
 
-	if !*nohttp {
-		cwg.Go(func(ctx context.Context) {
-			startHttpServer(ctx, *httpPort)
-		})
-	}
-
 	if *streamAddr != "" && !*nosender {
 		streamServer = sender.NewServer(sender.Config{
 			Addr:        *streamAddr,
 			Fps:         *streamFps,
 			JpegQuality: *streamQuality,
 			CropSize:    *streamCrop,
+
+			SpectatorSize: *spectatorSize,
 		}, capturerServer)
-		remoteSource = detector.NewRemoteSource(time.Duration(*streamTtl) * time.Millisecond)
+		// 每台手机一个推理源, 随连接加入/离开
+		streamServer.OnClient = func(remote string, role sender.Role, connected bool) {
+			if role != sender.RoleInference {
+				return
+			}
+			name := REMOTE_SOURCE_PREFIX + remote
+			if connected {
+				inferenceSources.Add(detector.NewRemoteSource(name, time.Duration(*streamTtl)*time.Millisecond))
+			} else if src := inferenceSources.Remove(name); src != nil {
+				src.Close()
+			}
+		}
 		streamServer.OnResult = func(res sender.RemoteResult, latency time.Duration) {
+			src, ok := inferenceSources.Get(REMOTE_SOURCE_PREFIX + res.Client)
+			if !ok {
+				return
+			}
+			remoteSource, ok := src.(*detector.RemoteSource)
+			if !ok {
+				return
+			}
 			dets := make([]yolo26.DetResult, 0, len(res.Detections))
 			for _, d := range res.Detections {
 				if d.Class != 0 {
@@ -418,12 +611,31 @@ func main() {
 			}
 			remoteSource.SetResults(dets, latency)
 			log.Trace().
+				Str("client", res.Client).
 				Uint64("frame_id", res.FrameID).
 				Int("detections", len(res.Detections)).
 				Dur("latency", latency).
 				Msg("remote detection result")
 		}
-		cwg.Go(streamServer.Run)
+		streamServer.OnMatch = func(res sender.RemoteMatch, latency time.Duration) {
+			if matcherEngine == nil {
+				return
+			}
+			matcherEngine.SetRemoteResult(matcher.RemoteResult{
+				FrameID:    res.FrameID,
+				Found:      res.Found,
+				Name:       res.Name,
+				Confidence: float32(res.Confidence),
+				Loc:        image.Pt(res.X, res.Y),
+				Latency:    latency,
+			})
+			log.Trace().
+				Uint64("frame_id", res.FrameID).
+				Str("name", res.Name).
+				Float64("confidence", res.Confidence).
+				Dur("latency", latency).
+				Msg("remote match result")
+		}
 	}
 
 	if !*noopencv {
@@ -432,22 +644,50 @@ func main() {
 			FpsIdle:          2,
 			DropIdleDuration: time.Second * 5,
 
-			Weapons:   weapons,
-			WeaponsMu: &weaponsMu,
-			RoiRect:   roiRect,
-			Debugging: debugging,
+			Weapons:    weapons,
+			Layout:     matcher.DefaultLayout(),
+			Hysteresis: weaponHysteresis(debugging),
+			Workers:    *matchWorkers,
+			Coarse:     *matchCoarse,
+			Debugging:  debugging,
+		}
+		if scales, err := matcher.ParseScales(*matchScales); err != nil {
+			log.Warn().
+				Err(err).
+				Str("matchscales", *matchScales).
+				Msg("invalid match scales, using defaults")
+		} else {
+			matcherCfg.Layout.Scales = scales
+		}
+		if strategy, err := template.ParseStrategy(*matchStrategy); err != nil {
+			log.Warn().
+				Err(err).
+				Str("matchstrategy", *matchStrategy).
+				Msg("invalid match strategy, using default")
+		} else {
+			matcherCfg.Layout.Strategy = strategy
+		}
+		matcherCfg.Layout.Threshold = float32(*matchThresh)
+		if pre, err := template.ParsePreprocess(*matchPre); err != nil {
+			log.Warn().
+				Err(err).
+				Str("matchpreprocess", *matchPre).
+				Msg("invalid match preprocessing, using defaults")
+		} else {
+			matcherCfg.Layout.Preprocess = pre
+		}
+		if *remoteMatch && streamServer != nil {
+			matcherCfg.Offloader = streamServer
+			matcherCfg.RemoteTTL = time.Duration(*matchTtl) * time.Millisecond
 		}
-		matcherEngine = matcher.New(capturerServer, matcherCfg)
+		initMatcher(matcherCfg)
 	}
 
 	if !*noyolo {
 		detectorEngine = initDetector()
 	}
 	if detectorEngine != nil {
-		inferenceSources = append(inferenceSources, detectorEngine)
-	}
-	if remoteSource != nil {
-		inferenceSources = append(inferenceSources, remoteSource)
+		inferenceSources.Add(detector.NewLocalSource(detectorEngine, LOCAL_SOURCE_NAME))
 	}
 	if detectorEngine == nil {
 		log.Warn().
@@ -492,6 +732,17 @@ func main() {
 		}
 	}
 
+	if *recordDir != "" {
+		datasetRecorder = initRecorder()
+	}
+
+	if streamServer != nil {
+		if matcherSet != nil {
+			streamServer.AddPainter(matcherSet)
+		}
+		streamServer.AddPainter(&detector.Drawer{Sources: inferenceSources})
+	}
+
 	if !*nogui {
 		window = ui.NewWindow(ui.Config{
 			Title:   windowTitle,
@@ -504,10 +755,12 @@ func main() {
 			inputMainOrAlt: &inputMainOrAlt,
 			inputBuf:       &inputBuf,
 		})
-		if matcherEngine != nil {
-			window.Register(matcherEngine)
+		if matcherSet != nil {
+			window.Register(matcherSet)
+			window.Register(&tmplCapture)
+			window.SetOnSelect(tmplCapture.setSelection)
 		}
-		if len(inferenceSources) != 0 {
+		if detectorEngine != nil || streamServer != nil {
 			window.Register(&detector.Drawer{Sources: inferenceSources})
 		}
 		if assistEngine != nil {
@@ -516,9 +769,19 @@ func main() {
 		window.SetBounds(capturerServer.Bounds().Max)
 	}
 
+	if !*nohttp {
+		// 路由读取的匹配器、检测器等在此之前已初始化完毕。
+		cwg.Go(func(ctx context.Context) {
+			startHttpServer(ctx, *httpPort)
+		})
+	}
 	cwg.Go(capturerServer.Run)
-	if matcherEngine != nil {
-		cwg.Go(matcherEngine.Run)
+	if streamServer != nil {
+		// 回调引用的 matcherEngine 等在此之前已初始化完毕。
+		cwg.Go(streamServer.Run)
+	}
+	if matcherSet != nil {
+		cwg.Go(matcherSet.Run)
 	}
 
 	if detectorEngine != nil {
@@ -526,6 +789,13 @@ func main() {
 			detectorEngine.Run(ctx)
 		})
 	}
+	if detectorEngine != nil && modelRegistry != nil {
+		cwg.Go(modelWatchLoop)
+	}
+
+	if datasetRecorder != nil {
+		cwg.Go(datasetRecorder.Run)
+	}
 
 	if matcherEngine != nil {
 		cwg.Go(r6sLoop)
@@ -569,63 +839,33 @@ func main() {
 }
 
 func r6sLoop(ctx context.Context) {
-	// the actual duration is exactly a second,
-	// more for template matching loop delay
-	const debounceInterval = 1500 * time.Millisecond
-
-	lastIndex := matcher.WEAPON_INDEX_NONE
-	var toNoneDebounce bool
-	var lastSwitchToNone time.Time
-
-	applyWeapon := func(newIndex int) {
-		weaponsMu.RLock()
-		defer weaponsMu.RUnlock()
-
-		var to *w.Weapon
+	lastId := matcher.WEAPON_ID_NONE
+
+	// newId 已经过匹配器的迟滞确认（切换为无的防抖见 weaponHysteresis）
+	applyWeapon := func(newId w.ID) {
+		// 模板可能在匹配后被删除，按未命中处理
+		to, ok := weapons.Get(newId)
+		if !ok {
+			newId = matcher.WEAPON_ID_NONE
+		}
 		toName := "N/A"
-		if newIndex >= 0 {
-			to = weapons[newIndex]
+		if ok {
 			toName = to.String()
 		}
 
-		if newIndex >= 0 {
-			toNoneDebounce = false
-		}
-
 		if forceUpdate {
 			forceUpdate = false
-		} else if newIndex == lastIndex {
+		} else if newId == lastId {
 			return
 		} else {
 			log.Trace().
-				Int("fromIndex", lastIndex).
-				Int("toIndex", newIndex).
+				Uint64("fromId", uint64(lastId)).
+				Uint64("toId", uint64(newId)).
 				Str("toName", toName).
 				Msg("switching weapon")
 		}
 
-		// no debounce when debugging
-		if !debugging && lastIndex >= 0 && newIndex == matcher.WEAPON_INDEX_NONE {
-			// from notnone to none
-			if !toNoneDebounce {
-				// going to none, enter debounce
-				toNoneDebounce = true
-				lastSwitchToNone = time.Now()
-				return
-			} else {
-				// debounce skipping
-				timeToNone := lastSwitchToNone.Add(debounceInterval)
-				if time.Now().Before(timeToNone) {
-					log.Trace().
-						Msg("switching skipped due to debounce")
-					return
-				}
-				// exit debounce
-				toNoneDebounce = false
-			}
-		}
-
-		lastIndex = newIndex
+		lastId = newId
 		// 推送武器状态到 mhub (远程模式); 本地模式 no-op
 		pushWeaponState(to, toName, debugging)
 	}
@@ -634,12 +874,22 @@ func r6sLoop(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
-		case newIndex := <-matcherEngine.ResultCh():
-			applyWeapon(newIndex)
+		case newId := <-matcherEngine.ResultCh():
+			applyWeapon(newId)
 		}
 	}
 }
 
+// weaponHysteresis 武器 ROI 的迟滞：切枪动画约 1 秒，加上匹配间隔，
+// 切换为无前保持 1.5 秒；调试时不防抖。
+func weaponHysteresis(debug bool) hysteresis.Config {
+	cfg := hysteresis.DefaultConfig()
+	if !debug {
+		cfg.ExitDelay = 1500 * time.Millisecond
+	}
+	return cfg
+}
+
 // pushWeaponState 把当前武器类型与速度参数推送给 mhub 脚本
 // weapon 为 nil 时推送 "none"
 func pushWeaponState(to *w.Weapon, name string, debug bool) {
@@ -705,12 +955,8 @@ func weaponAltLoop(ctx context.Context) {
 			weaponAlt.Store(next)
 			log.Info().Bool("weaponAlt", next).Msg("weapon alt toggled")
 			// Alt 档切换后重推当前武器状态
-			idx := matcherEngine.WeaponIndex()
-			if idx >= 0 {
-				wps := matcherEngine.Weapons()
-				if idx < len(wps) {
-					pushWeaponState(wps[idx], wps[idx].String(), debugging)
-				}
+			if wp, ok := matcherEngine.Weapon(); ok {
+				pushWeaponState(wp, wp.String(), debugging)
 			}
 		}
 	}
@@ -729,152 +975,107 @@ func cpuMeasureLoop(ctx context.Context) {
 }
 
 func tmplWatchLoop(ctx context.Context) {
-	type myFsEvent struct {
-		Name        string
-		Op          fsnotify.Op
-		renamedFrom string
-	}
-	if unsafe.Sizeof(myFsEvent{}) != unsafe.Sizeof(fsnotify.Event{}) ||
-		reflect.TypeOf(myFsEvent{}).NumField() != reflect.TypeOf(fsnotify.Event{}).NumField() {
-		log.Panic().Msg("[FIXME] definition of fsnotify.Event has been changed")
+	var wg sync.WaitGroup
+	for dir, reg := range roiTemplates {
+		wg.Go(func() {
+			watchTemplates(ctx, dir, reg)
+		})
 	}
+	watchTemplates(ctx, TEMPLATES_DIRECTORY, weapons)
+	wg.Wait()
+}
 
-	watcher, err := fsnotify.NewWatcher()
+func watchTemplates(ctx context.Context, dir string, reg *ws.Registry) {
+	watcher, err := ws.NewWatcher(reg, ws.WatcherConfig{
+		Dir:          dir,
+		Depth:        TEMPLATES_DEPTH,
+		Suffix:       TEMPLATES_SUFFIX,
+		IgnorePrefix: TEMPLATES_PREFIX_IGNORE,
+		CreateMask:   CREATE_MASK,
+		Flag:         TEMPLATE_READ_FLAG,
+	})
+	if err != nil && reg != weapons {
+		log.Warn().
+			Err(err).
+			Str("dir", dir).
+			Msg("ROI template hot reload disabled")
+		return
+	}
 	panicIf(err)
 	defer watcher.Close()
-	panicIf(watcher.Add(TEMPLATES_DIRECTORY))
 
-	muDelWeapon := func(event fsnotify.Event) (int, error) {
-		weaponsMu.Lock()
-		defer weaponsMu.Unlock()
+	dir = filepath.Clean(dir)
+	librariesMu.Lock()
+	templateLibraries[dir] = ws.NewLibrary(watcher)
+	librariesMu.Unlock()
+	defer func() {
+		librariesMu.Lock()
+		delete(templateLibraries, dir)
+		librariesMu.Unlock()
+	}()
 
-		n, err := weapons.DeleteByPath(event.Name)
-		if err != nil {
-			return n, fmt.Errorf("failed to delete weapon %q: %w", event.Name, err)
+	watcher.Run(ctx)
+}
+
+func initDetector() *detector.Engine {
+	cfg := detector.DefaultConfig()
+	cfg.Fps = 30
+	cfg.CropSize = cfg.InputSize * 2
+	cfg.Backend = *detBackend
+	cfg.Http.Url = *detUrl
+	cfg.Http.Protocol = *detProtocol
+	cfg.TileSize = *detTile
+
+	if cfg.Backend == detector.BACKEND_YOLO {
+		if _, err := cuda.InitContextCiG(); err != nil {
+			log.Warn().
+				Err(err).
+				Msg("CUDA context init failed, ORT will use default context")
 		}
-		return n, nil
 	}
 
-	muAddWeapon := func(name string) error {
-		weaponsMu.Lock()
-		defer weaponsMu.Unlock()
-
-		time.Sleep(time.Millisecond * 100)
-		err := weapons.Append(name, CREATE_MASK, MATCHING_MODE)
+	if _, err := os.Stat(*detProfiles); err == nil {
+		modelRegistry, err = detector.NewRegistry(*detProfiles)
 		if err != nil {
-			return fmt.Errorf("failed to add new weapon %q: %w", name, err)
+			log.Warn().
+				Err(err).
+				Str("dir", *detProfiles).
+				Msg("failed to load model profiles")
 		}
-		return nil
 	}
-
-	muModWeapon := func(from, to string) error {
-		weaponsMu.Lock()
-		defer weaponsMu.Unlock()
-
-		if strings.HasPrefix(to, TEMPLATES_PREFIX_IGNORE) {
+	if *detProfile != "" {
+		if modelRegistry == nil {
+			log.Error().
+				Str("dir", *detProfiles).
+				Msg("model profile requested but profile directory unavailable")
 			return nil
 		}
-
-		origI := -1
-		origName, _, err := w.ParseFileName(from)
-		if err == nil {
-			origI = weapons.IndexByName(origName)
-		}
-
-		if origI < 0 {
-			err := weapons.Append(to, CREATE_MASK, MATCHING_MODE)
-			if err != nil {
-				return fmt.Errorf("failed to add new weapon %q: %w", to, err)
-			}
-		} else {
-			if strings.HasPrefix(filepath.Base(to), TEMPLATES_PREFIX_IGNORE) {
-				err := weapons.Delete(origI)
-				if err != nil {
-					return fmt.Errorf("failed to delete %q: %w", origName, err)
-				}
-				return nil
-			}
-			return weapons[origI].DecodeFrom(to, CREATE_MASK, MATCHING_MODE)
+		p, ok := modelRegistry.Get(*detProfile)
+		if !ok {
+			log.Error().
+				Str("profile", *detProfile).
+				Msg("model profile not found")
+			return nil
 		}
-
-		return nil
-	}
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-
-		case event, ok := <-watcher.Events:
-			if !ok {
-				return
-			}
-
-			log.Debug().Any("event", event).Msg("fs event")
-
-			switch event.Op {
-			case fsnotify.Create:
-				var err error
-				renameFrom := (*myFsEvent)(unsafe.Pointer(&event)).renamedFrom
-
-				if renameFrom == "" {
-					err = muAddWeapon(event.Name)
-				} else {
-					err = muModWeapon(renameFrom, event.Name)
-				}
-				if err != nil {
-					log.Warn().Err(err).Msg("failed to handle create/rename event")
-					continue
-				}
-
-				if renameFrom == "" {
-					log.Info().Str("path", event.Name).Msg("weapon added successfully")
-				} else {
-					log.Info().Str("path", event.Name).Str("renameFrom", renameFrom).Msg("weapon modified successfully")
-				}
-
-			case fsnotify.Remove:
-				deleted, err := muDelWeapon(event)
-				if err != nil {
-					log.Warn().Err(err).Msg("failed to handle remove event")
-					continue
-				}
-
-				switch deleted {
-				case 1:
-					log.Info().Str("path", event.Name).Msg("weapon deleted successfully")
-				case 0:
-					log.Warn().Str("path", event.Name).Msg("weapon failed to delete")
-				default:
-					log.Warn().Str("path", event.Name).Int("deleted", deleted).Msg("weapon triggered multiple deletions")
-				}
-			}
-
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				return
-			}
-			log.Error().Err(err).Msg("fsnotify error")
+		engine, err := detector.NewFromProfile(capturerServer, cfg, p)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to init person engine")
+			return nil
 		}
-	}
-}
-
-func initDetector() *detector.Engine {
-	cfg := detector.DefaultConfig()
-	cfg.Fps = 30
-	cfg.CropSize = cfg.InputSize * 2
-
-	if _, err := cuda.InitContextCiG(); err != nil {
-		log.Warn().
-			Err(err).
-			Msg("CUDA context init failed, ORT will use default context")
+		log.Info().
+			Str("profile", p.Name).
+			Str("backend", engine.Meta().Name).
+			Msg("person engine initialized")
+		return engine
 	}
 
 	log.Debug().
+		Str("backend", cfg.Backend).
 		Str("modelPath", cfg.ModelPath).
 		Str("onnxLibPath", cfg.OnnxLibPath).
 		Float32("confThresh", cfg.ConfThresh).
 		Int("inputSize", cfg.InputSize).
+		Int("tileSize", cfg.TileSize).
 		Bool("useCuda", cfg.UseCuda).
 		Bool("useTensorRT", cfg.UseTensorRT).
 		Str("tensorRTPluginPath", cfg.TensorRTPluginPath).
@@ -886,7 +1087,9 @@ func initDetector() *detector.Engine {
 		return nil
 	}
 
-	log.Info().Msg("person engine initialized")
+	log.Info().
+		Str("backend", engine.Meta().Name).
+		Msg("person engine initialized")
 	return engine
 }
 
@@ -912,20 +1115,45 @@ func (d *metricsDrawer) Draw(gtx layout.Context, s ui.DScale) {
 
 	fmt.Fprintf(&sb, "| Capture: %.0ffps(%.1fms)", m.CaptureFps, m.CaptureCostMs)
 
-	fmt.Fprintf(&sb, " | Match: %.0ffps(%.1fms/%d=%.2fms) |", m.MatchFps, m.MatchCostMs, m.MatchCount, safeDiv(m.MatchCostMs, float64(m.MatchCount)))
+	if m.MatchRemote {
+		fmt.Fprintf(&sb, " | Match: %.0ffps(remote %.1fms) |", m.MatchFps, m.MatchCostMs)
+	} else {
+		fmt.Fprintf(&sb, " | Match: %.0ffps(%.1fms/%d=%.2fms) |", m.MatchFps, m.MatchCostMs, m.MatchCount, safeDiv(m.MatchCostMs, float64(m.MatchCount)))
+	}
 	sb.WriteByte('\n')
 
-	if len(inferenceSources) != 0 {
+	if m.MatchCalibration != "" || m.MatchDrift {
+		sb.WriteString("| ROI:")
+		if m.MatchCalibration != "" {
+			fmt.Fprintf(&sb, " calibration %s", m.MatchCalibration)
+		}
+		if m.MatchDrift {
+			sb.WriteString(" DRIFT (C to recalibrate)")
+		}
+		sb.WriteString(" |")
+		sb.WriteByte('\n')
+	}
+
+	if len(m.Sources) != 0 {
 		sb.WriteString("| Detection: ")
 		fmt.Fprintf(&sb, "%.0ffps/%d", m.DetectionFps, m.DetectionCount)
 		if detectorEngine != nil {
-			fmt.Fprintf(&sb, " | Local: %.1fms", m.DetectionCostMs)
+			fmt.Fprintf(&sb, " | Local[%s]: %.1f+%.1f+%.1fms (e2e %.1fms, drop %d)",
+				m.DetectionProfile, m.DetectionPreMs, m.DetectionCostMs, m.DetectionPostMs, m.DetectionLatencyMs, m.DetectionDropped)
 		}
 		if m.StreamFresh {
 			fmt.Fprintf(&sb, " | Remote: net %.1fms + inf %.1fms", m.StreamNetworkMs, m.StreamInferenceMs)
 		}
 		sb.WriteString(" |")
 		sb.WriteByte('\n')
+		for _, src := range m.Sources {
+			fmt.Fprintf(&sb, "|  %s: %s", src.Name, src.Health)
+			if src.Reason != "" {
+				fmt.Fprintf(&sb, "(%s)", src.Reason)
+			}
+			fmt.Fprintf(&sb, " %.0ffps %.1fms/%d |", src.Fps, src.LatencyMs, src.Count)
+			sb.WriteByte('\n')
+		}
 	}
 
 	fmt.Fprintf(&sb, "| 0x%04X | CPU: %04.1f%% | GC: %d(avg: %.2fus, last: %.2fs) |", m.FramesElapsed, cpu, m.GcCount, m.GcPauseAvgUs, m.GcSinceLastS)
@@ -952,6 +1180,22 @@ func (d *metricsDrawer) Draw(gtx layout.Context, s ui.DScale) {
 	ui.DrawList(gtx, strings.Split(strings.TrimSpace(sb.String()), "\n"))
 }
 
+// modelWatchLoop 配置档文件变化时, 若为当前生效的配置档则重新加载（预热失败保留原模型）。
+func modelWatchLoop(ctx context.Context) {
+	err := modelRegistry.Watch(ctx, func(p detector.Profile) {
+		if p.Name != detectorEngine.Profile().Name {
+			return
+		}
+		_ = detectorEngine.LoadProfile(p)
+	})
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("dir", modelRegistry.Dir()).
+			Msg("model profile hot reload disabled")
+	}
+}
+
 func safeDiv(a, b float64) float64 {
 	if b == 0 {
 		return 0
@@ -959,6 +1203,28 @@ func safeDiv(a, b float64) float64 {
 	return a / b
 }
 
+func initRecorder() *dataset.Recorder {
+	cfg := dataset.DefaultConfig()
+	cfg.Dir = *recordDir
+	cfg.Interval = time.Duration(*recordInterval) * time.Second
+
+	if detectorEngine == nil && streamServer == nil {
+		log.Warn().
+			Msg("dataset recorder has no inference source, only interval/manual frames will be recorded")
+	}
+
+	// 远程推理源随手机连接动态加入, 按类型取当前最优的一个
+	recorder, err := dataset.New(capturerServer,
+		inferenceSources.OfKind(detector.KindLocal),
+		inferenceSources.OfKind(detector.KindRemote),
+		cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to init dataset recorder")
+		return nil
+	}
+	return recorder
+}
+
 func createShortcuts(receiver any) widgets.Shortcuts {
 	if *nogui || receiver == nil {
 		return widgets.Shortcuts{}
@@ -1015,6 +1281,11 @@ func createShortcuts(receiver any) widgets.Shortcuts {
 			Do(func(_ key.Name, _ key.Modifiers) {
 				debugging = !debugging
 				forceUpdate = true
+				if matcherSet != nil {
+					for _, e := range matcherSet.Engines() {
+						e.SetHysteresis(weaponHysteresis(debugging))
+					}
+				}
 				log.Info().Bool("debugging", debugging).Msg("debugging toggled")
 			}),
 
@@ -1025,6 +1296,31 @@ func createShortcuts(receiver any) widgets.Shortcuts {
 				moveROI(n, mod)
 			}),
 
+		widgets.NewShortcut(key.NameTab).
+			Do(func(_ key.Name, _ key.Modifiers) {
+				selectNextROI()
+			}),
+
+		widgets.NewShortcut("C", "c").
+			Do(func(_ key.Name, mod key.Modifiers) {
+				calibrateROI(mod)
+			}),
+
+		widgets.NewShortcut("G", "g").
+			Do(func(_ key.Name, mod key.Modifiers) {
+				captureTemplate(mod)
+			}),
+
+		widgets.NewShortcut("S", "s").
+			Do(func(_ key.Name, _ key.Modifiers) {
+				if datasetRecorder == nil {
+					return
+				}
+				if _, _, err := datasetRecorder.Capture(dataset.TRIGGER_MANUAL); err != nil {
+					log.Warn().Err(err).Msg("manual capture failed")
+				}
+			}),
+
 		widgets.NewShortcut("T", "t").
 			Do(func(_ key.Name, mod key.Modifiers) {
 				toggleWDA(mod)
@@ -1041,7 +1337,24 @@ func createShortcuts(receiver any) widgets.Shortcuts {
 	)
 }
 
+// selectNextROI 方向键与校准改为操作下一个 ROI。
+func selectNextROI() {
+	if matcherSet == nil {
+		return
+	}
+	engines := matcherSet.Engines()
+	i := slices.Index(engines, selectedRoi)
+	selectedRoi = engines[(i+1)%len(engines)]
+	showPosTill = time.Now().Add(time.Second * 3)
+	log.Info().
+		Str("roi", selectedRoi.Name()).
+		Msg("ROI selected")
+}
+
 func moveROI(name key.Name, mod key.Modifiers) {
+	if selectedRoi == nil {
+		return
+	}
 	boundaryCheck := func(constraints image.Rectangle, rect *image.Rectangle) {
 		boundaryCheckPos := func(constraints image.Rectangle, pos *image.Point) {
 			pos.X = max(pos.X, constraints.Min.X)
@@ -1062,9 +1375,13 @@ func moveROI(name key.Name, mod key.Modifiers) {
 	}
 
 	var newRect image.Rectangle
+	roiRect := selectedRoi.Roi()
 	switch name {
 	case "R", "r":
-		newRect = image.Rectangle{roiRectPos, roiRectPos.Add(roiRectSize)}
+		selectedRoi.ResetRoi()
+		showPosTill = time.Now().Add(time.Second * 3)
+		log.Debug().Any("roiRect", selectedRoi.Roi()).Msg("roiRect reset")
+		return
 	case key.NameUpArrow:
 		newRect = roiRect.Sub(image.Pt(0, offset))
 	case key.NameDownArrow:
@@ -1076,10 +1393,48 @@ func moveROI(name key.Name, mod key.Modifiers) {
 	}
 
 	boundaryCheck(capturerServer.Bounds(), &newRect)
-	roiRect = newRect
-	matcherEngine.SetRoi(newRect)
+	selectedRoi.SetRoi(newRect)
 	showPosTill = time.Now().Add(time.Second * 3)
-	log.Debug().Any("roiRect", roiRect).Msg("roiRect moved")
+	log.Debug().Any("roiRect", newRect).Msg("roiRect moved")
+}
+
+// calibrateROI 无建议时开始全画面校准，有建议时确认；Shift 取消。
+func calibrateROI(mod key.Modifiers) {
+	if selectedRoi == nil {
+		return
+	}
+	if mod.Contain(key.ModShift) {
+		selectedRoi.CancelCalibration()
+		log.Info().Msg("ROI calibration cancelled")
+		return
+	}
+	calib := selectedRoi.Calibration()
+	switch {
+	case calib.Active:
+		return
+	case !calib.Proposal.Empty():
+		if _, err := selectedRoi.ConfirmCalibration(); err != nil {
+			log.Warn().Err(err).Msg("failed to apply ROI calibration")
+			return
+		}
+		showPosTill = time.Now().Add(time.Second * 3)
+	default:
+		selectedRoi.StartCalibration(image.Rectangle{})
+	}
+}
+
+// captureTemplate 截取选中的 ROI（或鼠标拖选的区域）为新模板，已有截取等待命名时放弃它；Shift 撤销最近一次截取。
+func captureTemplate(mod key.Modifiers) {
+	if matcherSet == nil {
+		return
+	}
+	if mod.Contain(key.ModShift) {
+		if _, err := tmplCapture.undo(); err != nil {
+			log.Warn().Err(err).Msg("failed to undo template capture")
+		}
+		return
+	}
+	tmplCapture.interactive()
 }
 
 func toggleWDA(mod key.Modifiers) {
@@ -1152,8 +1507,8 @@ func handleInput(k key.Name, m key.Modifiers) {
 }
 
 func modWeapon(mainOrAlt bool, newSpeed string) {
-	idx := matcherEngine.WeaponIndex()
-	if idx == matcher.WEAPON_INDEX_NONE {
+	orig, ok := matcherEngine.Weapon()
+	if !ok {
 		log.Warn().Msg("weapon unselected")
 		return
 	}
@@ -1168,11 +1523,6 @@ func modWeapon(mainOrAlt bool, newSpeed string) {
 		newSpeed = w.SPEED_SIGN_COPY
 	}
 
-	weaponsMu.RLock()
-	wps := matcherEngine.Weapons()
-	orig := wps[idx]
-	weaponsMu.RUnlock()
-
 	dir := filepath.Dir(orig.Path)
 	origName := filepath.Base(orig.Path)
 	ext := filepath.Ext(origName)
@@ -1194,6 +1544,29 @@ func modWeapon(mainOrAlt bool, newSpeed string) {
 		speedAlt = newSpeed
 	}
 
+	// 元数据来自附属文件或目录清单时文件名参数不生效，改写元数据
+	if meta, err := w.LoadMeta(orig.Path); err == nil {
+		meta.SpeedMain, err = strconv.ParseFloat(speedMain, 64)
+		if err != nil {
+			log.Error().Err(err).Str("speed", speedMain).Msg("invalid weapon speed")
+			return
+		}
+		meta.SpeedAlt = speedAlt
+		if meta.SpeedAlt == w.SPEED_SIGN_AUTO {
+			meta.SpeedAlt = ""
+		}
+		if err := w.UpdateMeta(orig.Path, meta); err != nil {
+			log.Error().Err(err).Str("path", orig.Path).Msg("failed to update weapon metadata")
+		} else {
+			log.Info().Str("path", orig.Path).Str("speedMain", speedMain).Str("speedAlt", speedAlt).Msg("updated weapon metadata")
+		}
+		forceUpdate = true
+		return
+	} else if !errors.Is(err, w.ErrNoMeta) {
+		log.Error().Err(err).Str("path", orig.Path).Msg("failed to load weapon metadata")
+		return
+	}
+
 	newName := fmt.Sprintf(
 		"{%s_%s_%s} %s%s",
 		orig.Class.ToString(true),
@@ -1220,9 +1593,8 @@ func modWeapon(mainOrAlt bool, newSpeed string) {
 func listWeapons() {
 	const indexLength = 3
 
-	weaponsMu.RLock()
-	wps := matcherEngine.Weapons()
-	weaponsMu.RUnlock()
+	wps := matcherEngine.Weapons().List()
+	scores := matcherEngine.Scores()
 
 	onceWeaponNameLongest.Do(func() {
 		for _, w := range wps {
@@ -1237,15 +1609,20 @@ func listWeapons() {
 			continue
 		}
 
+		// 未匹配过的模板没有得分
+		score := "-"
+		if s, ok := scores[w.ID]; ok {
+			score = fmt.Sprintf("%.2f%%", s.Score*100)
+		}
 		speedMain, speedMainF, speedAlt, speedAltF := w.GetAllSpeeds(debugging)
 		fmt.Fprintf(
 			os.Stdout,
-			"[%0*d] {%s_%02d.%d_%02d.%d} %-*s %.2f%%\n",
+			"[%0*d] {%s_%02d.%d_%02d.%d} %-*s %s\n",
 			indexLength, i,
 			w.Class.ToString(true),
 			speedMain, speedMainF, speedAlt, speedAltF,
 			weaponNameLongest, w.Name,
-			w.Template.MaxVal*100,
+			score,
 		)
 	}
 	if skipped > 0 {
@@ -0,0 +1,303 @@
+package matcher
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"slices"
+
+	"github.com/Miuzarte/GoCVStreamer/template"
+	ws "github.com/Miuzarte/GoCVStreamer/weapons"
+	"gocv.io/x/gocv"
+)
+
+const (
+	// CALIBRATION_DOWNSCALE 大范围搜索前把画面缩小的倍率
+	CALIBRATION_DOWNSCALE = 0.5
+	// CALIBRATION_MARGIN 缩小后的画面上命中的置信度下限比模板的命中阈值低这么多
+	CALIBRATION_MARGIN = 0.1
+	// CALIBRATION_MIN_HITS 位置一致的命中帧数达到后给出建议 ROI
+	CALIBRATION_MIN_HITS = 5
+	// CALIBRATION_MAX_FRAMES 搜索帧数上限，仍无一致位置则放弃
+	CALIBRATION_MAX_FRAMES = 30
+	// CALIBRATION_PADDING 建议 ROI 在命中范围外各边留出的比例（相对模板尺寸）
+	CALIBRATION_PADDING = 0.25
+
+	// DRIFT_MARGIN 最佳位置距 ROI 边缘不超过该像素数视为贴边
+	DRIFT_MARGIN = 2
+	// DRIFT_FRAMES 连续贴边的命中帧数达到后标记漂移
+	DRIFT_FRAMES = 15
+)
+
+var ErrNoProposal = errors.New("no calibration proposal to confirm")
+
+// Calibration 校准状态：Active 时用大范围搜索代替正常匹配，
+// 得到 Proposal 后恢复正常匹配，等待 ConfirmCalibration。
+type Calibration struct {
+	Active   bool
+	Area     image.Rectangle // 搜索范围（采集坐标）
+	Frames   int             // 已搜索帧数
+	Hits     int             // 命中帧数
+	Proposal image.Rectangle // 建议 ROI，空表示尚无
+	Scale    float64         // 命中处的模板缩放（全分辨率）
+	Failed   bool            // 达到帧数上限仍无一致位置
+}
+
+// calibHit 单帧大范围搜索的最佳命中（采集坐标）。
+type calibHit struct {
+	box   image.Rectangle
+	scale float64
+	// val 得分减去模板的命中阈值，各策略间可比
+	val float32
+}
+
+// StartCalibration 开始在 area 内大范围搜索模板，area 为空时搜索整个画面。
+func (e *Engine) StartCalibration(area image.Rectangle) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calib = Calibration{Active: true, Area: area}
+	e.calibHits = e.calibHits[:0]
+	log.Info().
+		Any("area", area).
+		Msg("ROI calibration started")
+}
+
+// CancelCalibration 放弃校准与未确认的建议。
+func (e *Engine) CancelCalibration() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calib = Calibration{}
+	e.calibHits = e.calibHits[:0]
+}
+
+func (e *Engine) Calibration() Calibration {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.calib
+}
+
+// ConfirmCalibration 采用建议 ROI（经 SetRoi）并缓存命中处的缩放。
+func (e *Engine) ConfirmCalibration() (image.Rectangle, error) {
+	e.mu.RLock()
+	calib := e.calib
+	e.mu.RUnlock()
+	if calib.Proposal.Empty() {
+		return image.Rectangle{}, ErrNoProposal
+	}
+
+	e.SetRoi(calib.Proposal)
+	e.mu.Lock()
+	e.scale = calib.Scale
+	e.stats.Scale = calib.Scale
+	e.calib = Calibration{}
+	e.mu.Unlock()
+	log.Info().
+		Any("roi", calib.Proposal).
+		Float64("scale", calib.Scale).
+		Msg("ROI calibration applied")
+	return calib.Proposal, nil
+}
+
+func (e *Engine) calibrating() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.calib.Active
+}
+
+// calibrateStep 在缩小的搜索范围内按缩放序列匹配全部模板，记录最佳命中；
+// 一致的命中足够时给出建议 ROI。搜索范围按 ROI 的预处理链处理后再缩小。
+func (e *Engine) calibrateStep(bounds image.Rectangle) {
+	e.mu.RLock()
+	area := e.calib.Area
+	scales := e.layout.Pyramid(e.baseScale, e.scale)
+	pre := e.layout.Preprocess
+	e.mu.RUnlock()
+	if area.Empty() {
+		area = bounds
+	}
+	area = area.Intersect(bounds)
+	if area.Empty() {
+		return
+	}
+
+	region := gocv.NewMat()
+	defer region.Close()
+	if err := e.capturerServer.CropMat(area, &region); err != nil {
+		log.Debug().Err(err).Msg("failed to crop calibration area")
+		return
+	}
+	if err := pre.Apply(region, &region); err != nil {
+		log.Warn().Err(err).Msg("failed to preprocess calibration area")
+		return
+	}
+	small := gocv.NewMat()
+	defer small.Close()
+	gocv.Resize(region, &small, image.Point{}, CALIBRATION_DOWNSCALE, CALIBRATION_DOWNSCALE, gocv.InterpolationArea)
+
+	hit, ok, err := e.searchBest(small, scales)
+	if err != nil {
+		log.Warn().Err(err).Msg("template matching failed during calibration")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.calib.Active {
+		return
+	}
+	e.calib.Frames++
+	if ok {
+		// 缩小画面上的坐标换算回采集坐标
+		hit.box = scaleRect(hit.box, 1/CALIBRATION_DOWNSCALE).Add(area.Min)
+		e.calibHits = append(e.calibHits, hit)
+		e.calib.Hits++
+	}
+
+	if proposal, scale, ok := proposeRoi(e.calibHits, bounds, CALIBRATION_MIN_HITS); ok {
+		e.calib.Active = false
+		e.calib.Proposal = proposal
+		e.calib.Scale = scale
+		log.Info().
+			Any("proposal", proposal).
+			Float64("scale", scale).
+			Int("frames", e.calib.Frames).
+			Int("hits", e.calib.Hits).
+			Msg("ROI calibration proposal ready, waiting for confirmation")
+		return
+	}
+	if e.calib.Frames >= CALIBRATION_MAX_FRAMES {
+		e.calib.Active = false
+		e.calib.Failed = true
+		log.Warn().
+			Int("frames", e.calib.Frames).
+			Int("hits", e.calib.Hits).
+			Msg("ROI calibration found no consistent template location")
+	}
+}
+
+// searchBest 返回全部模板、全部缩放中相对各自阈值得分最高的位置（缩小画面坐标）；
+// 出错的模板跳过，返回遇到的第一个错误。
+func (e *Engine) searchBest(img gocv.Mat, scales []float64) (best calibHit, ok bool, err error) {
+	e.mu.RLock()
+	layout := e.layout
+	e.mu.RUnlock()
+
+	e.ensureScratches()
+
+	frame := template.NewFrame(img)
+	defer frame.Close()
+
+	best.val = -math.MaxFloat32
+	e.cfg.Weapons.Read(func(weapons ws.Weapons) {
+		for i, scale := range scales {
+			for _, wp := range weapons {
+				strategy, threshold := layout.strategyFor(wp)
+				if i > 0 && template.IsScaleInvariant(strategy) {
+					continue
+				}
+				// 只用 Eval：注册表可能被其他 ROI 共用
+				r, mErr := wp.Template.Eval(strategy, frame, scale*CALIBRATION_DOWNSCALE, e.scratches[0])
+				if mErr != nil {
+					if err == nil {
+						err = fmt.Errorf("template %s: %w", wp.Name, mErr)
+					}
+					continue
+				}
+				if val := r.Score - threshold; val > best.val {
+					best = calibHit{box: r.Box, scale: scale, val: val}
+				}
+			}
+		}
+	})
+	return best, best.val >= -CALIBRATION_MARGIN, err
+}
+
+// proposeRoi 取各命中中心的中位数，保留中心偏离不超过半个模板的命中；
+// 数量达到 minHits 时返回包住它们并留边的 ROI（限制在 bounds 内）与缩放中位数。
+func proposeRoi(hits []calibHit, bounds image.Rectangle, minHits int) (roi image.Rectangle, scale float64, ok bool) {
+	if len(hits) < minHits {
+		return
+	}
+	xs := make([]int, len(hits))
+	ys := make([]int, len(hits))
+	for i, h := range hits {
+		c := center(h.box)
+		xs[i], ys[i] = c.X, c.Y
+	}
+	slices.Sort(xs)
+	slices.Sort(ys)
+	median := image.Pt(xs[len(xs)/2], ys[len(ys)/2])
+
+	var consistent []calibHit
+	for _, h := range hits {
+		d := center(h.box).Sub(median)
+		if abs(d.X) <= h.box.Dx()/2 && abs(d.Y) <= h.box.Dy()/2 {
+			consistent = append(consistent, h)
+		}
+	}
+	if len(consistent) < minHits {
+		return
+	}
+
+	scales := make([]float64, len(consistent))
+	union := consistent[0].box
+	for i, h := range consistent {
+		union = union.Union(h.box)
+		scales[i] = h.scale
+	}
+	slices.Sort(scales)
+
+	pad := image.Pt(
+		max(1, int(math.Ceil(float64(consistent[0].box.Dx())*CALIBRATION_PADDING))),
+		max(1, int(math.Ceil(float64(consistent[0].box.Dy())*CALIBRATION_PADDING))),
+	)
+	roi = image.Rectangle{union.Min.Sub(pad), union.Max.Add(pad)}.Intersect(bounds)
+	return roi, scales[len(scales)/2], !roi.Empty()
+}
+
+// hugsEdge 最佳位置是否贴着 ROI（size 为 ROI 尺寸，box 相对 ROI 左上角）的任一边。
+func hugsEdge(box image.Rectangle, size image.Point, margin int) bool {
+	return box.Min.X <= margin || box.Min.Y <= margin ||
+		box.Max.X >= size.X-margin || box.Max.Y >= size.Y-margin
+}
+
+// observeDrift 记录一次命中的位置，连续 DRIFT_FRAMES 次贴边时标记漂移；调用方持有 e.mu。
+func (e *Engine) observeDrift(box image.Rectangle, roiSize image.Point) {
+	if !hugsEdge(box, roiSize, DRIFT_MARGIN) {
+		if e.stats.Drift {
+			log.Info().Msg("ROI drift cleared")
+		}
+		e.edgeHits = 0
+		e.stats.Drift = false
+		return
+	}
+	e.edgeHits++
+	if e.edgeHits >= DRIFT_FRAMES && !e.stats.Drift {
+		e.stats.Drift = true
+		log.Warn().
+			Int("frames", e.edgeHits).
+			Any("box", box).
+			Any("roiSize", roiSize).
+			Msg("best match keeps hugging the ROI edge, consider recalibrating")
+	}
+}
+
+func scaleRect(r image.Rectangle, f float64) image.Rectangle {
+	return image.Rect(
+		int(math.Round(float64(r.Min.X)*f)),
+		int(math.Round(float64(r.Min.Y)*f)),
+		int(math.Round(float64(r.Max.X)*f)),
+		int(math.Round(float64(r.Max.Y)*f)),
+	)
+}
+
+func center(r image.Rectangle) image.Point {
+	return r.Min.Add(r.Max).Div(2)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,60 @@
+package matcher
+
+import (
+	"image"
+	"testing"
+)
+
+func TestProposeRoi(t *testing.T) {
+	bounds := image.Rect(0, 0, 2560, 1440)
+	hit := func(x, y int) calibHit {
+		return calibHit{box: image.Rect(x, y, x+40, y+48), scale: 1, val: 0.9}
+	}
+
+	// 不足 minHits 不出建议
+	if _, _, ok := proposeRoi([]calibHit{hit(2020, 1230)}, bounds, 3); ok {
+		t.Fatal("proposal from a single hit")
+	}
+
+	// 一个离群命中被剔除，其余包住并留边（40*0.25=10, 48*0.25=12）
+	hits := []calibHit{hit(2020, 1230), hit(2022, 1231), hit(2019, 1229), hit(300, 200)}
+	roi, scale, ok := proposeRoi(hits, bounds, 3)
+	if !ok {
+		t.Fatal("no proposal")
+	}
+	if want := image.Rect(2009, 1217, 2072, 1291); roi != want || scale != 1 {
+		t.Fatalf("proposal %v scale %v, want %v", roi, scale, want)
+	}
+
+	// 贴近画面边缘时裁剪到 bounds 内
+	edge := []calibHit{hit(2520, 1392), hit(2520, 1392), hit(2520, 1392)}
+	if roi, _, _ := proposeRoi(edge, bounds, 3); !roi.In(bounds) {
+		t.Fatalf("proposal %v outside %v", roi, bounds)
+	}
+}
+
+func TestObserveDrift(t *testing.T) {
+	e := &Engine{}
+	size := image.Pt(88, 104)
+	centred := image.Rect(20, 30, 60, 78)
+	hugging := image.Rect(1, 30, 41, 78)
+
+	if hugsEdge(centred, size, DRIFT_MARGIN) || !hugsEdge(hugging, size, DRIFT_MARGIN) {
+		t.Fatal("hugsEdge")
+	}
+
+	for range DRIFT_FRAMES - 1 {
+		e.observeDrift(hugging, size)
+	}
+	if e.stats.Drift {
+		t.Fatal("drift flagged too early")
+	}
+	e.observeDrift(hugging, size)
+	if !e.stats.Drift {
+		t.Fatal("drift not flagged")
+	}
+	e.observeDrift(centred, size)
+	if e.stats.Drift || e.edgeHits != 0 {
+		t.Fatal("drift not cleared by a centred match")
+	}
+}
@@ -0,0 +1,186 @@
+package matcher
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Miuzarte/GoCVStreamer/template"
+	w "github.com/Miuzarte/GoCVStreamer/weapon"
+)
+
+// 模板截取时的参考分辨率；其他分辨率按高度换算期望的模板缩放
+var (
+	ReferenceSize = image.Point{2560, 1440}
+	// ReferenceRoi 参考分辨率下的 ROI（Xmid: 2045）
+	ReferenceRoi = image.Rect(2000, 1200, 2000+8*11, 1200+8*13)
+)
+
+// DefaultScales 相对期望缩放的金字塔倍率，覆盖窗口化采集与 HUD 缩放的偏差。
+var DefaultScales = []float64{1, 0.95, 1.05, 0.9, 1.1, 0.85, 1.15}
+
+// NormRect 归一化矩形，坐标为相对采集画面宽高的比例（0~1）。
+type NormRect struct {
+	X0 float64 `json:"x0"`
+	Y0 float64 `json:"y0"`
+	X1 float64 `json:"x1"`
+	Y1 float64 `json:"y1"`
+}
+
+// Normalize 把 bounds 内的像素矩形换算为归一化坐标。
+func Normalize(r, bounds image.Rectangle) NormRect {
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	r = r.Sub(bounds.Min)
+	return NormRect{
+		X0: float64(r.Min.X) / w,
+		Y0: float64(r.Min.Y) / h,
+		X1: float64(r.Max.X) / w,
+		Y1: float64(r.Max.Y) / h,
+	}
+}
+
+// Rect 换算回 bounds 内的像素矩形。
+func (n NormRect) Rect(bounds image.Rectangle) image.Rectangle {
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	r := image.Rect(
+		int(math.Round(n.X0*w)),
+		int(math.Round(n.Y0*h)),
+		int(math.Round(n.X1*w)),
+		int(math.Round(n.Y1*h)),
+	)
+	return r.Add(bounds.Min)
+}
+
+// Anchor 指定分辨率下的精确 ROI（相对画面左上角），优先于归一化坐标。
+type Anchor struct {
+	Size image.Point     `json:"size"`
+	Rect image.Rectangle `json:"rect"`
+	// Scale 该分辨率下的模板缩放，0 则按高度换算
+	Scale float64 `json:"scale,omitzero"`
+}
+
+// Layout 与分辨率无关的 ROI 布局与匹配缩放。
+type Layout struct {
+	Roi     NormRect
+	Anchors []Anchor
+	// RefHeight 模板截取时的画面高度
+	RefHeight int
+	// Scales 相对期望缩放的金字塔倍率，按顺序尝试
+	Scales []float64
+
+	// Strategy 该 ROI 的匹配策略，nil 为 template.DefaultStrategy；模板元数据中的设置优先
+	Strategy template.Strategy
+	// Threshold 该 ROI 的命中阈值，0 为策略默认值；模板元数据中的设置优先
+	Threshold float32
+	// Preprocess 匹配前对 ROI 裁剪的预处理链，模板加载时按同一条链处理
+	Preprocess template.Preprocess
+}
+
+// DefaultPreprocess 默认只转灰度
+var DefaultPreprocess = template.Preprocess{{Op: template.PRE_GRAY}}
+
+// DefaultLayout 以参考分辨率下的 ROI 为锚点。
+func DefaultLayout() Layout {
+	bounds := image.Rectangle{Max: ReferenceSize}
+	return Layout{
+		Roi:        Normalize(ReferenceRoi, bounds),
+		Anchors:    []Anchor{{Size: ReferenceSize, Rect: ReferenceRoi, Scale: 1}},
+		RefHeight:  ReferenceSize.Y,
+		Scales:     DefaultScales,
+		Preprocess: DefaultPreprocess,
+	}
+}
+
+// strategyFor 模板使用的策略与阈值：模板元数据、ROI、策略默认值依次回退。
+func (l Layout) strategyFor(wp *w.Weapon) (template.Strategy, float32) {
+	st := wp.Strategy
+	if st == nil {
+		st = l.Strategy
+	}
+	if st == nil {
+		st = template.DefaultStrategy
+	}
+	return st, cmp.Or(wp.Threshold, l.Threshold, st.DefaultThreshold())
+}
+
+// Resolve 求 bounds 下的 ROI（像素）与期望模板缩放。
+func (l Layout) Resolve(bounds image.Rectangle) (roi image.Rectangle, scale float64) {
+	scale = 1
+	if l.RefHeight > 0 {
+		scale = float64(bounds.Dy()) / float64(l.RefHeight)
+	}
+	for _, a := range l.Anchors {
+		if a.Size == bounds.Size() {
+			if a.Scale > 0 {
+				scale = a.Scale
+			}
+			return a.Rect.Add(bounds.Min), scale
+		}
+	}
+	return l.Roi.Rect(bounds), scale
+}
+
+// WithRoi 以 bounds 下的像素 ROI 更新布局：记为该分辨率的锚点，并同步归一化坐标。
+func (l Layout) WithRoi(r, bounds image.Rectangle) Layout {
+	_, scale := l.Resolve(bounds)
+	anchor := Anchor{Size: bounds.Size(), Rect: r.Sub(bounds.Min), Scale: scale}
+	l.Anchors = slices.Clone(l.Anchors)
+	if i := slices.IndexFunc(l.Anchors, func(a Anchor) bool { return a.Size == anchor.Size }); i >= 0 {
+		l.Anchors[i] = anchor
+	} else {
+		l.Anchors = append(l.Anchors, anchor)
+	}
+	l.Roi = Normalize(r, bounds)
+	return l
+}
+
+// Pyramid 以 base 为期望缩放生成本次匹配尝试的缩放序列：
+// cached（上次命中的缩放，0 表示无）优先，其余按 Scales 顺序，去重。
+func (l Layout) Pyramid(base, cached float64) []float64 {
+	scales := l.Scales
+	if len(scales) == 0 {
+		scales = []float64{1}
+	}
+	out := make([]float64, 0, len(scales)+1)
+	seen := make(map[int]bool, len(scales)+1)
+	add := func(s float64) {
+		key := int(math.Round(s * 1000))
+		if s <= 0 || seen[key] {
+			return
+		}
+		seen[key] = true
+		out = append(out, s)
+	}
+	add(cached)
+	for _, s := range scales {
+		add(base * s)
+	}
+	return out
+}
+
+// ParseScales 解析逗号分隔的金字塔倍率，如 "1,0.9,1.1"。
+func ParseScales(s string) ([]float64, error) {
+	var scales []float64
+	for field := range strings.SplitSeq(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, err
+		}
+		if v <= 0 {
+			return nil, fmt.Errorf("scale must be > 0, got %v", v)
+		}
+		scales = append(scales, v)
+	}
+	if len(scales) == 0 {
+		return nil, fmt.Errorf("no scales in %q", s)
+	}
+	return scales, nil
+}
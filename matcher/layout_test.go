@@ -0,0 +1,69 @@
+package matcher
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestLayoutResolve(t *testing.T) {
+	l := DefaultLayout()
+
+	// 参考分辨率命中锚点，像素完全一致
+	roi, scale := l.Resolve(image.Rectangle{Max: ReferenceSize})
+	if roi != ReferenceRoi || scale != 1 {
+		t.Fatalf("reference: roi %v scale %v", roi, scale)
+	}
+
+	// 4K：归一化坐标换算，缩放按高度 1.5 倍
+	roi, scale = l.Resolve(image.Rect(0, 0, 3840, 2160))
+	if roi != image.Rect(3000, 1800, 3132, 1956) || scale != 1.5 {
+		t.Fatalf("4K: roi %v scale %v", roi, scale)
+	}
+
+	// 非零原点的窗口采集（WGC）按 bounds 偏移
+	bounds := image.Rect(100, 50, 100+1280, 50+720)
+	roi, scale = l.Resolve(bounds)
+	if roi != image.Rect(1100, 650, 1144, 702) || scale != 0.5 {
+		t.Fatalf("windowed: roi %v scale %v", roi, scale)
+	}
+}
+
+func TestLayoutWithRoi(t *testing.T) {
+	bounds := image.Rect(0, 0, 1920, 1080)
+	l := DefaultLayout()
+	moved := image.Rect(1510, 900, 1576, 978)
+	l2 := l.WithRoi(moved, bounds)
+
+	if roi, _ := l2.Resolve(bounds); roi != moved {
+		t.Fatalf("anchored roi = %v, want %v", roi, moved)
+	}
+	if len(l.Anchors) != 1 {
+		t.Fatal("WithRoi must not modify the original layout")
+	}
+	// 其他分辨率跟随新的归一化坐标
+	if roi, _ := l2.Resolve(image.Rect(0, 0, 3840, 2160)); roi != image.Rect(3020, 1800, 3152, 1956) {
+		t.Fatalf("4K after move = %v", roi)
+	}
+}
+
+func TestLayoutPyramid(t *testing.T) {
+	l := Layout{Scales: []float64{1, 0.9, 1.1}}
+	got := l.Pyramid(1.5, 1.65)
+	want := []float64{1.65, 1.5, 1.35}
+	if len(got) != len(want) {
+		t.Fatalf("pyramid = %v, want %v", got, want)
+	}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			t.Fatalf("pyramid = %v, want %v", got, want)
+		}
+	}
+
+	if _, err := ParseScales("1, 0.9,,1.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseScales("1,-0.5"); err == nil {
+		t.Fatal("negative scale accepted")
+	}
+}
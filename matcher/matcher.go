@@ -1,10 +1,13 @@
 package matcher
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
+	"maps"
 	"math"
 	"runtime"
 	"sync"
@@ -14,7 +17,11 @@ import (
 
 	"github.com/Miuzarte/GoCVStreamer/capturer"
 	"github.com/Miuzarte/GoCVStreamer/fps"
+	"github.com/Miuzarte/GoCVStreamer/hysteresis"
+	"github.com/Miuzarte/GoCVStreamer/libyuv"
 	"github.com/Miuzarte/GoCVStreamer/logger"
+	"github.com/Miuzarte/GoCVStreamer/raster"
+	"github.com/Miuzarte/GoCVStreamer/template"
 	"github.com/Miuzarte/GoCVStreamer/timing"
 	"github.com/Miuzarte/GoCVStreamer/ui"
 	w "github.com/Miuzarte/GoCVStreamer/weapon"
@@ -23,31 +30,73 @@ import (
 )
 
 const (
-	MATCH_THRESHOLD      = 0.9
-	WEAPON_INDEX_NONE    = -1
+	MATCH_THRESHOLD      = template.THRESHOLD_TEMPLATE_MATCH // 默认策略的命中阈值
+	WEAPON_ID_NONE       = w.ID_NONE
 	DRAW_NEGATIVE_RESULT = false
+	// ROI_DEFAULT_NAME 未命名 ROI 的名称（主 ROI：武器图标）
+	ROI_DEFAULT_NAME = "weapon"
+	// REMOTE_FRAME_WINDOW 远程结果的帧号最多落后最近提交的帧号多少帧
+	REMOTE_FRAME_WINDOW = 30
 )
 
 var log = logger.New("Matcher")
 
 type Config struct {
+	// Name ROI 名称，同一 Set 内唯一，空为 ROI_DEFAULT_NAME
+	Name string
+	// Templates 该 ROI 的模板目录，由调用方据此加载 Weapons
+	Templates string
+
 	Fps              int
 	FpsIdle          int
 	DropIdleDuration time.Duration
 
-	Weapons   ws.Weapons
-	WeaponsMu *sync.RWMutex
+	Weapons *ws.Registry
 
-	RoiRect   image.Rectangle
+	// Layout ROI 布局与缩放金字塔，采集分辨率变化时重新求解
+	Layout    Layout
 	Debugging bool
+
+	// Hysteresis 原始结果到确认结果的迟滞，零值为逐帧跟随
+	Hysteresis hysteresis.Config
+
+	// Workers 并行匹配的 goroutine 数，<= 1 为逐个匹配
+	Workers int
+	// Coarse 全分辨率匹配前先在半分辨率上粗筛候选模板
+	Coarse bool
+
+	// Offloader 非 nil 且有远程匹配端在线时，ROI 裁剪交给远程匹配；
+	// 远程结果超过 RemoteTTL 未更新，或帧号落后最近提交的帧号超过 RemoteFrames 时回退本地匹配。
+	Offloader    Offloader
+	RemoteTTL    time.Duration
+	RemoteFrames uint64 // 0 为 REMOTE_FRAME_WINDOW
+}
+
+// Offloader 远程模板匹配通道（由 sender.Server 实现）。
+type Offloader interface {
+	// HasMatchers 是否有远程匹配端在线。
+	HasMatchers() bool
+	// SubmitRoi 提交一帧 ROI 裁剪（不阻塞，忙时丢弃）。
+	SubmitRoi(frameID uint64, roi *image.RGBA)
+}
+
+// RemoteResult 远程匹配端回传的结果，Loc 为模板在 ROI 内的左上角。
+type RemoteResult struct {
+	FrameID    uint64 // 结果对应的 ROI 裁剪帧号（SubmitRoi 提交的帧号）
+	Found      bool
+	Name       string
+	Confidence float32
+	Loc        image.Point
+	Latency    time.Duration
+	Recv       time.Time
 }
 
 type MatchResult struct {
-	Found       bool
-	WeaponIndex int
-	Confidence  float32
-	Matched     int
-	Box         image.Rectangle
+	Found      bool
+	WeaponID   w.ID
+	Confidence float32
+	Matched    int
+	Box        image.Rectangle
 }
 
 type Stats struct {
@@ -56,8 +105,28 @@ type Stats struct {
 	Matched    int
 	Found      bool
 	Confidence float32
+	Smoothed   float32 // 确认结果的平滑置信度
 	Idle       bool
 	Narrowing  bool
+	Remote     bool    // 最近一次结果来自远程匹配端
+	Scale      float64 // 当前缓存的模板缩放（未命中过时为期望缩放）
+	Drift      bool    // 最佳位置持续贴着 ROI 边缘，ROI 可能已偏移
+	Errors     int     // 累计的模板匹配错误
+	Coarse     int     // 最近一次粗筛匹配的模板数
+}
+
+// Score 某个模板最近一次全分辨率匹配的得分。
+type Score struct {
+	Score float32
+	Box   image.Rectangle // 最佳位置，相对 ROI
+	Time  time.Time
+}
+
+// Preview 最近一次本地匹配时预处理后的 ROI，供调试查看。
+type Preview struct {
+	Image      image.Image
+	Preprocess string
+	FrameID    uint64
 }
 
 type Engine struct {
@@ -67,60 +136,209 @@ type Engine struct {
 	capturerServer *capturer.Server
 	cfg            Config
 
-	roiRect image.Rectangle
+	layout    Layout
+	bounds    image.Rectangle // 求解 layout 时的采集范围
+	roiRect   image.Rectangle
+	baseScale float64 // 按分辨率换算的期望缩放
+	scale     float64 // 最近命中的缩放，0 表示尚未命中
+
+	calib     Calibration
+	calibHits []calibHit
+	edgeHits  int // 连续贴边的命中次数
 
 	// state
-	lastFoundTime  time.Time
 	inIdle         bool
+	lastFoundTime  time.Time // 最近一次原始命中（未经迟滞）
 	narrowing      bool
 	lastSlot       w.Slot
-	lastTmpl       int
+	lastTmpl       w.ID
 	showRoiPosTill time.Time
 
 	// result
-	stats    Stats
-	result   MatchResult
-	resultCh chan int
+	stats Stats
+	// lastMatchErr 最近记录的匹配错误，相同错误不重复记录
+	lastMatchErr string
+	result       MatchResult
+	resultCh     chan w.ID
+	preview      Preview
+
+	remoteMu sync.Mutex
+	remote   RemoteResult
+	// submitted 最近一次提交给远程匹配端的帧号
+	submitted uint64
+
+	// scores 各模板最近一次的匹配得分，匹配 worker 并发写入
+	scoresMu sync.Mutex
+	scores   map[w.ID]Score
+
+	// scratches 每个匹配 worker 的缓冲，仅在 Run 所在 goroutine 中按需创建
+	scratches []*template.Scratch
+
+	// state 原始结果的迟滞确认
+	state *hysteresis.Machine[w.ID]
+
+	// onResult 确认的结果变化时调用，onLayout ROI 被修改后调用；由 Set 在 Run 前设置
+	onResult func(hysteresis.Event[w.ID])
+	onLayout func()
 
 	diag *timing.Diag
 }
 
 func New(capturerServer *capturer.Server, cfg Config) *Engine {
-	return &Engine{
+	if cfg.RemoteTTL <= 0 {
+		cfg.RemoteTTL = time.Second
+	}
+	if cfg.RemoteFrames == 0 {
+		cfg.RemoteFrames = REMOTE_FRAME_WINDOW
+	}
+	cfg.Name = cmp.Or(cfg.Name, ROI_DEFAULT_NAME)
+	e := &Engine{
 		cfg:            cfg,
 		fpsCounter:     fps.NewCounter(time.Second),
 		capturerServer: capturerServer,
 
-		roiRect:  cfg.RoiRect,
-		lastSlot: w.Slot(w.SLOT_UNDEFINED),
+		layout:    cfg.Layout,
+		baseScale: 1,
+		lastSlot:  w.Slot(w.SLOT_UNDEFINED),
 
-		resultCh: make(chan int, 1),
+		resultCh: make(chan w.ID, 1),
+		state:    hysteresis.New(cfg.Hysteresis, WEAPON_ID_NONE),
 
 		diag: timing.NewDiag("Match"),
 	}
+	e.relayout(capturerServer.Bounds())
+	if err := cfg.Weapons.SetPreprocess(cfg.Layout.Preprocess); err != nil {
+		log.Warn().
+			Err(err).
+			Str("roi", cfg.Name).
+			Stringer("preprocess", cfg.Layout.Preprocess).
+			Msg("failed to preprocess templates")
+	}
+	return e
+}
+
+func (e *Engine) Name() string {
+	return e.cfg.Name
 }
 
-func (e *Engine) ResultCh() <-chan int {
+// Scores 仍在模板库中的各模板最近一次的匹配得分；命中即停，排在命中模板之后的得分可能较旧。
+func (e *Engine) Scores() map[w.ID]Score {
+	e.scoresMu.Lock()
+	defer e.scoresMu.Unlock()
+	for id := range e.scores {
+		if _, ok := e.cfg.Weapons.Get(id); !ok {
+			delete(e.scores, id)
+		}
+	}
+	return maps.Clone(e.scores)
+}
+
+func (e *Engine) recordScore(id w.ID, r template.Result) {
+	e.scoresMu.Lock()
+	if e.scores == nil {
+		e.scores = make(map[w.ID]Score)
+	}
+	e.scores[id] = Score{Score: r.Score, Box: r.Box, Time: time.Now()}
+	e.scoresMu.Unlock()
+}
+
+// Templates 该 ROI 的模板目录。
+func (e *Engine) Templates() string {
+	return e.cfg.Templates
+}
+
+// Layout 当前布局（含手动移动与校准后的锚点）。
+func (e *Engine) Layout() Layout {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.layout
+}
+
+// ResultCh 每次匹配后经迟滞确认的模板 ID（无为 WEAPON_ID_NONE），满时丢弃。
+func (e *Engine) ResultCh() <-chan w.ID {
 	return e.resultCh
 }
 
+// Result 最近一次匹配的原始结果。
 func (e *Engine) Result() MatchResult {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 	return e.result
 }
 
+// Preview 最近一次本地匹配的预处理后 ROI；尚未匹配时 Image 为 nil。
+func (e *Engine) Preview() Preview {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.preview
+}
+
 func (e *Engine) Stats() Stats {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 	return e.stats
 }
 
+// Roi 当前分辨率下的 ROI。
+func (e *Engine) Roi() image.Rectangle {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.roiRect
+}
+
+// SetRoi 手动设置当前分辨率下的 ROI，同时记为该分辨率的锚点并更新归一化坐标。
 func (e *Engine) SetRoi(r image.Rectangle) {
 	e.mu.Lock()
+	e.layout = e.layout.WithRoi(r, e.bounds)
 	e.roiRect = r
+	e.edgeHits = 0
+	e.stats.Drift = false
+	e.showRoiPosTill = time.Now().Add(time.Second * 3)
+	e.mu.Unlock()
+	e.layoutChanged()
+}
+
+// ResetRoi 恢复配置中的布局并按当前分辨率重新求解。
+func (e *Engine) ResetRoi() {
+	e.mu.Lock()
+	e.layout = e.cfg.Layout
+	e.relayout(e.bounds)
 	e.showRoiPosTill = time.Now().Add(time.Second * 3)
 	e.mu.Unlock()
+	e.layoutChanged()
+}
+
+func (e *Engine) layoutChanged() {
+	if e.onLayout != nil {
+		e.onLayout()
+	}
+}
+
+// relayout 按采集范围重新求解 ROI 与期望缩放，并清空缓存的缩放；调用方持有 e.mu。
+func (e *Engine) relayout(bounds image.Rectangle) {
+	e.bounds = bounds
+	e.roiRect, e.baseScale = e.layout.Resolve(bounds)
+	e.scale = 0
+	e.stats.Scale = e.baseScale
+	e.edgeHits = 0
+	e.stats.Drift = false
+	log.Info().
+		Str("name", e.cfg.Name).
+		Int("width", bounds.Dx()).
+		Int("height", bounds.Dy()).
+		Any("roi", e.roiRect).
+		Float64("scale", e.baseScale).
+		Msg("matcher layout resolved")
+}
+
+// SetRemoteResult 由远程匹配回调写入（Recv 为零时取当前时间）。
+func (e *Engine) SetRemoteResult(r RemoteResult) {
+	if r.Recv.IsZero() {
+		r.Recv = time.Now()
+	}
+	e.remoteMu.Lock()
+	e.remote = r
+	e.remoteMu.Unlock()
 }
 
 func (e *Engine) InIdle() bool {
@@ -129,30 +347,36 @@ func (e *Engine) InIdle() bool {
 	return e.inIdle
 }
 
-func (e *Engine) Weapons() ws.Weapons {
-	e.cfg.WeaponsMu.RLock()
-	defer e.cfg.WeaponsMu.RUnlock()
+func (e *Engine) Weapons() *ws.Registry {
 	return e.cfg.Weapons
 }
 
-func (e *Engine) ReloadWeapons() {
-	e.cfg.WeaponsMu.Lock()
-	defer e.cfg.WeaponsMu.Unlock()
-	if len(e.cfg.Weapons) != 0 {
-		e.cfg.Weapons.Close()
-	}
+// State 经迟滞确认的结果。
+func (e *Engine) State() hysteresis.State[w.ID] {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.state.State()
 }
 
-func (e *Engine) AddWeapon(path string, createMask bool, flag gocv.IMReadFlag) error {
-	e.cfg.WeaponsMu.Lock()
-	defer e.cfg.WeaponsMu.Unlock()
-	return e.cfg.Weapons.Append(path, createMask, flag)
+// SetHysteresis 更新迟滞设置（如调试时关闭防抖），保留当前确认的结果。
+func (e *Engine) SetHysteresis(cfg hysteresis.Config) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.state.SetConfig(cfg)
 }
 
-func (e *Engine) WeaponIndex() int {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-	return e.result.WeaponIndex
+// WeaponID 经迟滞确认的模板 ID。
+func (e *Engine) WeaponID() w.ID {
+	return e.State().ID
+}
+
+// Weapon 经迟滞确认的模板；无或模板已被删除时返回 false。
+func (e *Engine) Weapon() (*w.Weapon, bool) {
+	id := e.WeaponID()
+	if id == WEAPON_ID_NONE {
+		return nil, false
+	}
+	return e.cfg.Weapons.Get(id)
 }
 
 func (e *Engine) Run(ctx context.Context) {
@@ -161,6 +385,8 @@ func (e *Engine) Run(ctx context.Context) {
 
 	capture := gocv.NewMat()
 	defer capture.Close()
+	preprocessed := gocv.NewMat()
+	defer preprocessed.Close()
 
 	interval := time.Second / time.Duration(e.cfg.Fps)
 	intervalIdle := time.Duration(math.MaxInt64)
@@ -222,189 +448,374 @@ func (e *Engine) Run(ctx context.Context) {
 			continue
 		}
 
-		frameMat := e.capturerServer.ReadMat()
-		frameMat.CopyTo(&capture)
-
-		e.mu.RLock()
+		bounds := e.capturerServer.Bounds()
+		e.mu.Lock()
+		if bounds != e.bounds {
+			e.relayout(bounds)
+		}
 		roi := e.roiRect
-		e.mu.RUnlock()
-		if !roi.In(e.capturerServer.Bounds()) {
+		pre := e.layout.Preprocess
+		e.mu.Unlock()
+
+		if e.calibrating() {
+			e.calibrateStep(bounds)
+			continue
+		}
+
+		if !roi.In(bounds) {
 			continue
 		}
 
-		captureRoi := capture.Region(roi)
 		tStart := time.Now()
 
-		slotFilter := w.SLOT_UNDEFINED
-		e.mu.RLock()
-		if e.narrowing {
-			slotFilter = e.lastSlot.Opposite()
-		}
-		e.mu.RUnlock()
+		var id w.ID
+		var matched, coarse int
+		var cost time.Duration
+		var found bool
+		var confidence float32
+		var box image.Rectangle // 命中位置（相对 ROI）
+		remote, isRemote := e.offload(frameId, roi)
+		if isRemote {
+			id, found, confidence = remote.id, remote.Found, remote.Confidence
+			cost = remote.Latency
+		} else {
+			// 只转换、预处理 ROI 区域
+			if err := e.capturerServer.CropMat(roi, &capture); err != nil {
+				log.Debug().Err(err).Msg("failed to crop ROI")
+				continue
+			}
+			if err := pre.Apply(capture, &preprocessed); err != nil {
+				e.reportMatchError(fmt.Errorf("preprocess %s: %w", pre, err))
+				continue
+			}
+			e.updatePreview(preprocessed, pre, frameId)
 
-		idx, matched, found := e.matchWeapon(captureRoi, slotFilter)
-		e.stats.Cost = time.Since(tStart)
-		e.diag.Observe(time.Since(tStart), log)
-		e.stats.Matched = matched
-		captureRoi.Close()
+			slotFilter := w.SLOT_UNDEFINED
+			e.mu.RLock()
+			if e.narrowing {
+				slotFilter = e.lastSlot.Opposite()
+			}
+			scales := e.layout.Pyramid(e.baseScale, e.scale)
+			e.mu.RUnlock()
+
+			out := e.matchWeapon(preprocessed, slotFilter, scales)
+			id, matched, found = out.id, out.matched, out.found
+			confidence, box = out.res.Score, out.res.Box
+			if out.err != nil {
+				e.reportMatchError(out.err)
+			}
+			coarse = out.coarse
+			if found {
+				e.mu.Lock()
+				if e.scale != out.scale {
+					log.Debug().
+						Float64("scale", out.scale).
+						Msg("template scale cached")
+				}
+				e.scale = out.scale
+				e.stats.Scale = out.scale
+				e.mu.Unlock()
+			}
+			cost = time.Since(tStart)
+			e.diag.Observe(cost, log)
+		}
 
-		e.stats.Fps, _ = e.fpsCounter.Count()
+		rate, _ := e.fpsCounter.Count()
 
 		e.mu.Lock()
-
+		e.stats.Cost = cost
+		e.stats.Matched = matched
+		if !isRemote {
+			e.stats.Coarse = coarse
+		}
+		e.stats.Fps = rate
 		e.stats.Found = found
+		e.stats.Remote = isRemote
 		if found {
-			e.lastFoundTime = time.Now()
-			e.inIdle = false
 			e.narrowing = false
 
-			e.cfg.WeaponsMu.RLock()
-			var confidence float32
-			if idx >= 0 && idx < len(e.cfg.Weapons) {
-				confidence = e.cfg.Weapons[idx].Template.MaxVal
-				e.lastSlot = e.cfg.Weapons[idx].Class.Detail().Slot
+			// 匹配结束后模板可能已被删除，按 ID 重新取
+			if wp, ok := e.cfg.Weapons.Get(id); ok {
+				e.lastSlot = wp.Class.Detail().Slot
 				if e.lastSlot != w.SLOT_UNDEFINED && !e.lastSlot.Is(w.SLOT_MIX) {
 					e.narrowing = true
 				}
-				e.lastTmpl = idx
+				e.lastTmpl = id
 			}
-			e.cfg.WeaponsMu.RUnlock()
 
 			e.result = MatchResult{
-				Found:       true,
-				WeaponIndex: idx,
-				Confidence:  confidence,
-				Matched:     matched,
+				Found:      true,
+				WeaponID:   id,
+				Confidence: confidence,
+				Matched:    matched,
 			}
-			e.stats.Confidence = confidence
-			e.stats.Idle = e.inIdle
-			e.stats.Narrowing = e.narrowing
-			e.mu.Unlock()
-
-			select {
-			case e.resultCh <- idx:
-			default:
+			if isRemote {
+				box = remote.box
 			}
-		} else {
-			if time.Since(e.lastFoundTime) > e.cfg.DropIdleDuration && !e.cfg.Debugging {
-				e.inIdle = true
-				e.narrowing = false
-				e.lastSlot = w.SLOT_UNDEFINED
+			if !box.Empty() {
+				e.result.Box = box.Add(roi.Min)
+				e.observeDrift(box, roi.Size())
 			}
-
+			e.stats.Confidence = confidence
+		} else {
+			id, confidence = WEAPON_ID_NONE, 0
 			e.result = MatchResult{}
-			e.stats.Idle = e.inIdle
-			e.stats.Narrowing = e.narrowing
-			e.mu.Unlock()
+		}
 
-			select {
-			case e.resultCh <- WEAPON_INDEX_NONE:
-			default:
-			}
+		// 原始结果经迟滞确认；确认为无且距最近一次原始命中超过 DropIdleDuration 后进入空闲
+		ev, changed := e.state.Observe(id, confidence)
+		st := e.state.State()
+		if found {
+			e.inIdle = false
+			e.lastFoundTime = time.Now()
+		} else if st.ID == WEAPON_ID_NONE && time.Since(e.lastFoundTime) > e.cfg.DropIdleDuration && !e.cfg.Debugging {
+			e.inIdle = true
+			e.narrowing = false
+			e.lastSlot = w.SLOT_UNDEFINED
+		}
+		e.stats.Smoothed = st.Confidence
+		e.stats.Idle = e.inIdle
+		e.stats.Narrowing = e.narrowing
+		e.mu.Unlock()
+
+		if changed && e.onResult != nil {
+			e.onResult(ev)
+		}
+		select {
+		case e.resultCh <- st.ID:
+		default:
 		}
 	}
 }
 
-func (e *Engine) matchWeapon(image gocv.Mat, slotFilter w.Slot) (templateIndex, templateMatched int, found bool) {
-	e.cfg.WeaponsMu.RLock()
-	defer e.cfg.WeaponsMu.RUnlock()
+// remoteMatch 是映射到本地模板库后的远程结果。
+type remoteMatch struct {
+	RemoteResult
+	id  w.ID
+	box image.Rectangle
+}
 
-	const method = gocv.TmCcoeffNormed
+// offload 把 ROI 裁剪提交给远程匹配端，并返回仍然新鲜的最近一次远程结果。
+// 无远程匹配端、结果过期（超过 RemoteTTL 或帧号过旧）或模板名在本地库中不存在时返回 false，由本地匹配兜底。
+// 远程端只按模板原尺寸匹配，裁剪先缩放回参考分辨率，回传坐标再按缩放换算。
+func (e *Engine) offload(frameId uint64, roi image.Rectangle) (res remoteMatch, ok bool) {
+	off := e.cfg.Offloader
+	if off == nil || !off.HasMatchers() {
+		return
+	}
 
 	e.mu.RLock()
-	start := e.lastTmpl
+	scale := cmp.Or(e.scale, e.baseScale)
 	e.mu.RUnlock()
 
-	// 从上次成功的模板开始往下匹配
-	for j := range e.cfg.Weapons {
-		i := j + start
-		i %= len(e.cfg.Weapons)
-		templateIndex = i
+	if rgba := e.capturerServer.ReadRgba(); rgba != nil {
+		crop := image.NewRGBA(image.Rectangle{Max: roi.Size()})
+		draw.Draw(crop, crop.Bounds(), rgba, roi.Min, draw.Src)
+		if math.Abs(scale-1) > 1e-3 {
+			crop = libyuv.ResizeRGBA(crop,
+				max(1, int(math.Round(float64(roi.Dx())/scale))),
+				max(1, int(math.Round(float64(roi.Dy())/scale))),
+			)
+		}
+		off.SubmitRoi(frameId, crop)
+		e.remoteMu.Lock()
+		e.submitted = frameId
+		e.remoteMu.Unlock()
+	}
 
-		tmpl := e.cfg.Weapons[i]
+	e.remoteMu.Lock()
+	res.RemoteResult = e.remote
+	submitted := e.submitted
+	e.remoteMu.Unlock()
+	if res.Recv.IsZero() || time.Since(res.Recv) > e.cfg.RemoteTTL ||
+		!remoteFresh(res.FrameID, submitted, e.cfg.RemoteFrames) {
+		return res, false
+	}
 
-		if slotFilter != w.SLOT_UNDEFINED && j != 0 && !tmpl.Class.Detail().Slot.Has(slotFilter) {
-			continue
-		}
+	res.id = WEAPON_ID_NONE
+	if !res.Found {
+		return res, true
+	}
 
-		if err := tmpl.Template.Match(image, method); err != nil {
-			panic(err)
-		}
+	res.id = e.cfg.Weapons.IDByName(res.Name)
+	wp, ok := e.cfg.Weapons.Get(res.id)
+	if !ok {
+		log.Debug().
+			Str("name", res.Name).
+			Msg("remote match names unknown template, falling back to local")
+		return res, false
+	}
+	tmpl := &wp.Template
+	loc := image.Pt(int(math.Round(float64(res.Loc.X)*scale)), int(math.Round(float64(res.Loc.Y)*scale)))
+	size := image.Pt(int(math.Round(float64(tmpl.Width)*scale)), int(math.Round(float64(tmpl.Height)*scale)))
+	res.box = image.Rectangle{loc, loc.Add(size)}
+	return res, true
+}
 
-		templateMatched++
+// remoteFresh 远程结果的帧号是否落在 [submitted-window, submitted] 内：
+// 太旧的结果（匹配端积压或重连后迟到）与从未提交过的帧号都不采用。
+func remoteFresh(frameID, submitted, window uint64) bool {
+	if frameID > submitted {
+		return false
+	}
+	return submitted-frameID <= window
+}
 
-		if tmpl.Template.MaxVal >= MATCH_THRESHOLD {
-			// 跳过剩余匹配
-			found = true
-			break
-		}
+// updatePreview 保存预处理后的 ROI（尺寸很小，每帧转换的开销可忽略）。
+func (e *Engine) updatePreview(mat gocv.Mat, pre template.Preprocess, frameId uint64) {
+	img, err := mat.ToImage()
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to convert preprocessed ROI")
+		return
 	}
+	e.mu.Lock()
+	e.preview = Preview{Image: img, Preprocess: pre.String(), FrameID: frameId}
+	e.mu.Unlock()
+}
 
-	return
+// reportMatchError 同一错误只在首次出现时记录，避免每帧刷屏。
+func (e *Engine) reportMatchError(err error) {
+	e.mu.Lock()
+	e.stats.Errors++
+	msg := err.Error()
+	repeated := msg == e.lastMatchErr
+	e.lastMatchErr = msg
+	e.mu.Unlock()
+	if !repeated {
+		log.Warn().Err(err).Msg("template matching failed")
+	}
 }
 
 func (e *Engine) Draw(gtx layout.Context, s ui.DScale) {
+	e.mu.RLock()
 	roi := e.roiRect
 	result := e.result
 	showPosTill := e.showRoiPosTill
+	calib := e.calib
+	drift := e.stats.Drift
+	e.mu.RUnlock()
 
 	roiRect := s.Rect(roi)
 	ui.DrawBorder(gtx, ui.ColorCoral.NRGBA(), roiRect)
 
+	// 调试：ROI 左侧显示预处理后的裁剪（原始像素尺寸）
+	if e.cfg.Debugging {
+		if preview := e.Preview(); preview.Image != nil {
+			pos := roiRect.Min.Sub(image.Pt(preview.Image.Bounds().Dx()+ui.BorderThickness, 0))
+			ui.DrawImage(gtx, pos, preview.Image)
+		}
+	}
+
 	labelPos := s.Pos(image.Pt(roi.Min.X, roi.Min.Y))
 	labelPos.Y -= int(float64(ui.FontSize) * 1.25)
 	if time.Now().Before(showPosTill) {
-		ui.DrawLabel(gtx, ui.ColorCoral.NRGBA(), labelPos, ui.FontSize, fmt.Sprint(roi))
+		ui.DrawLabel(gtx, ui.ColorCoral.NRGBA(), labelPos, ui.FontSize, e.cfg.Name+" "+fmt.Sprint(roi))
 	} else {
-		ui.DrawLabel(gtx, ui.ColorCoral.NRGBA(), labelPos, ui.FontSize, "ROI")
+		ui.DrawLabel(gtx, ui.ColorCoral.NRGBA(), labelPos, ui.FontSize, e.cfg.Name)
+	}
+	if drift {
+		ui.DrawTextRight(gtx, ui.ColorRed.NRGBA(), roiRect, 2, "DRIFT")
 	}
 
-	e.cfg.WeaponsMu.RLock()
-	defer e.cfg.WeaponsMu.RUnlock()
+	// 校准：建议 ROI 待确认
+	if !calib.Proposal.Empty() {
+		proposal := s.Rect(calib.Proposal)
+		ui.DrawBorder(gtx, ui.ColorPurple.NRGBA(), proposal)
+		ui.DrawLabel(gtx, ui.ColorPurple.NRGBA(),
+			proposal.Min.Sub(image.Pt(0, int(float64(ui.FontSize)*1.25))), ui.FontSize, "ROI?")
+	}
 
-	// 无可信匹配时, 黄框显示最高匹配的模板
-	colorPos := ui.ColorGreen.NRGBA()
-	var weaponPos *w.Weapon
-	if result.Found && result.WeaponIndex >= 0 && result.WeaponIndex < len(e.cfg.Weapons) {
-		weaponPos = e.cfg.Weapons[result.WeaponIndex]
-	} else {
-		colorPos = ui.ColorYellow.NRGBA()
-		_, max := e.cfg.Weapons.MinMaxIndex()
-		if max >= 0 && max < len(e.cfg.Weapons) {
-			weaponPos = e.cfg.Weapons[max]
+	// 得分取自本 ROI 的记录（模板可能被多个 ROI 共用），须在持注册表读锁之前取出
+	scores := e.Scores()
+	e.cfg.Weapons.Read(func(weapons ws.Weapons) {
+		// 无可信匹配时, 黄框显示最高匹配的模板
+		colorPos := ui.ColorGreen.NRGBA()
+		var weaponPos *w.Weapon
+		var pos Score
+		if i := weapons.IndexByID(result.WeaponID); result.Found && i >= 0 {
+			weaponPos = weapons[i]
+			pos = Score{Score: result.Confidence, Box: result.Box.Sub(roi.Min)}
+		} else {
+			colorPos = ui.ColorYellow.NRGBA()
+			weaponPos, pos = extremeScore(weapons, scores, true)
 		}
-	}
 
-	if weaponPos == nil || weaponPos.Template.MaxVal < 0.5 {
-		return
-	}
+		if weaponPos == nil || pos.Score < 0.5 {
+			return
+		}
+
+		e.drawOpenCVResult(gtx, s, roi, roiRect, weaponPos, pos, colorPos, 0)
 
-	e.drawOpenCVResult(gtx, s, roi, roiRect, weaponPos, colorPos, 0)
+		if DRAW_NEGATIVE_RESULT {
+			colorNeg := ui.ColorCyan.NRGBA()
+			if weaponNeg, neg := extremeScore(weapons, scores, false); weaponNeg != nil {
+				e.drawOpenCVResult(gtx, s, roi, roiRect, weaponNeg, neg, colorNeg, weaponPos.Template.Height)
+			}
+		}
+	})
+}
 
-	if DRAW_NEGATIVE_RESULT {
-		colorNeg := ui.ColorCyan.NRGBA()
-		min, _ := e.cfg.Weapons.MinMaxIndex()
-		if min >= 0 && min < len(e.cfg.Weapons) {
-			e.drawOpenCVResult(gtx, s, roi, roiRect, e.cfg.Weapons[min], colorNeg, weaponPos.Template.Height)
+// extremeScore 本 ROI 记录过得分的模板中得分最高（highest）或最低的一个。
+func extremeScore(weapons ws.Weapons, scores map[w.ID]Score, highest bool) (*w.Weapon, Score) {
+	var best *w.Weapon
+	var bestScore Score
+	for _, wp := range weapons {
+		sc, ok := scores[wp.ID]
+		if !ok {
+			continue
+		}
+		if best == nil || highest && sc.Score > bestScore.Score || !highest && sc.Score < bestScore.Score {
+			best, bestScore = wp, sc
 		}
 	}
+	return best, bestScore
 }
 
-func (e *Engine) drawOpenCVResult(gtx layout.Context, s ui.DScale, roi image.Rectangle, roiRect image.Rectangle, weapon *w.Weapon, color color.NRGBA, tmplPosOffset int) {
+func (e *Engine) drawOpenCVResult(gtx layout.Context, s ui.DScale, roi image.Rectangle, roiRect image.Rectangle, weapon *w.Weapon, score Score, color color.NRGBA, tmplPosOffset int) {
 	// 与ROI左对齐
 	tmplPos := s.Pos(image.Pt(roi.Min.X, roi.Max.Y+tmplPosOffset))
 	tmplPos.Y += ui.BorderThickness / 2
 	// 匹配的模板本身
 	ui.DrawImage(gtx, tmplPos, weapon.Template.Raw)
 
-	box := s.Rect(image.Rect(
-		weapon.Template.MaxLoc.X,
-		weapon.Template.MaxLoc.Y,
-		weapon.Template.MaxLoc.X+weapon.Template.Width,
-		weapon.Template.MaxLoc.Y+weapon.Template.Height,
-	).Add(roi.Min))
+	box := s.Rect(score.Box.Add(roi.Min))
 	ui.DrawBorder(gtx, color, box)
 
 	ui.DrawTextRight(gtx, color, roiRect, 0, weapon.Name)
-	ui.DrawTextRight(gtx, color, roiRect, 1, ui.FormatPct(weapon.Template.MaxVal))
+	ui.DrawTextRight(gtx, color, roiRect, 1, ui.FormatPct(score.Score))
+}
+
+// Paint 实现 raster.Painter：观战帧上的 ROI 与匹配结果（与 Draw 同配色）。
+func (e *Engine) Paint(c *raster.Canvas) {
+	e.mu.RLock()
+	roi := e.roiRect
+	result := e.result
+	calib := e.calib
+	e.mu.RUnlock()
+
+	roiRect := c.Rect(roi)
+	c.DrawBorder(ui.ColorCoral.NRGBA(), roiRect)
+	c.DrawLabel(ui.ColorCoral.NRGBA(), roiRect.Min.Sub(image.Pt(0, raster.LineHeight+2)), e.cfg.Name)
+	if !calib.Proposal.Empty() {
+		proposal := c.Rect(calib.Proposal)
+		c.DrawBorder(ui.ColorPurple.NRGBA(), proposal)
+		c.DrawLabel(ui.ColorPurple.NRGBA(), proposal.Min.Sub(image.Pt(0, raster.LineHeight+2)), "ROI?")
+	}
+
+	e.cfg.Weapons.Read(func(weapons ws.Weapons) {
+		i := weapons.IndexByID(result.WeaponID)
+		if !result.Found || i < 0 {
+			c.DrawTextRight(ui.ColorYellow.NRGBA(), roiRect, 0, "N/A")
+			return
+		}
+		weapon := weapons[i]
+		color := ui.ColorGreen.NRGBA()
+
+		if !result.Box.Empty() {
+			c.DrawBorder(color, c.Rect(result.Box))
+		}
+
+		c.DrawTextRight(color, roiRect, 0, weapon.Name)
+		c.DrawTextRight(color, roiRect, 1, ui.FormatPct(result.Confidence))
+	})
 }
@@ -0,0 +1,170 @@
+package matcher
+
+import (
+	"image"
+	"image/png"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	w "github.com/Miuzarte/GoCVStreamer/weapon"
+	ws "github.com/Miuzarte/GoCVStreamer/weapons"
+	"gocv.io/x/gocv"
+)
+
+// TestMatchWhileReloading 匹配与模板热重载并发时不得访问已释放的模板，返回的 ID 可查到或明确不存在。
+func TestMatchWhileReloading(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "{AR_10_--} R4-C.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(f, image.NewGray(image.Rect(0, 0, 8, 4)))
+	f.Close()
+
+	reg := ws.NewRegistry()
+	defer reg.Close()
+	id, err := reg.Add(path, false, gocv.IMReadGrayScale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Engine{cfg: Config{Weapons: reg}, layout: DefaultLayout()}
+
+	img := gocv.NewMatWithSize(32, 32, gocv.MatTypeCV8UC1)
+	defer img.Close()
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for range 50 {
+			if err := reg.Reload(id, path, false, gocv.IMReadGrayScale); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	})
+	wg.Go(func() {
+		for range 50 {
+			out := e.matchWeapon(img, w.SLOT_UNDEFINED, DefaultScales)
+			if out.err != nil {
+				t.Error(out.err)
+				return
+			}
+			// 未命中时不返回模板 ID
+			if out.found && out.id != id || !out.found && out.id != WEAPON_ID_NONE {
+				t.Errorf("matched id = %d (found %v), want %d", out.id, out.found, id)
+				return
+			}
+			if out.matched == 0 {
+				t.Error("no template matched")
+				return
+			}
+		}
+	})
+	wg.Wait()
+}
+
+// TestSharedRegistry 两个 ROI 共用同一注册表并发匹配：各自的得分与位置只来自自己的画面，
+// 与单独匹配的结果一致（缩放缓存的并发填充由 -race 检查）。
+func TestSharedRegistry(t *testing.T) {
+	dir := t.TempDir()
+	rng := rand.New(rand.NewPCG(3, 4))
+	noise := func(img *image.Gray) {
+		for i := range img.Pix {
+			img.Pix[i] = uint8(rng.IntN(256))
+		}
+	}
+
+	// ref 与 reg 加载同样的模板（ID 相同），单独匹配得到期望结果，reg 的缩放缓存留给并发匹配首次填充
+	reg, ref := ws.NewRegistry(), ws.NewRegistry()
+	defer reg.Close()
+	defer ref.Close()
+	var tmpl *image.Gray
+	for i, name := range []string{"{AR_10_--} A.png", "{SMG_10_--} B.png"} {
+		img := image.NewGray(image.Rect(0, 0, 12, 12))
+		noise(img)
+		if i == 0 {
+			tmpl = img
+		}
+		path := filepath.Join(dir, name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		png.Encode(f, img)
+		f.Close()
+		for _, r := range []*ws.Registry{reg, ref} {
+			if _, err := r.Add(path, false, gocv.IMReadGrayScale); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// 两个 ROI 的画面中模板 A 位于不同位置
+	var frames [2]gocv.Mat
+	for i, at := range []image.Point{{8, 10}, {60, 80}} {
+		frame := image.NewGray(image.Rectangle{Max: ReferenceRoi.Size()})
+		noise(frame)
+		for y := range tmpl.Rect.Dy() {
+			copy(frame.Pix[frame.PixOffset(at.X, at.Y+y):], tmpl.Pix[tmpl.PixOffset(0, y):tmpl.PixOffset(0, y)+tmpl.Rect.Dx()])
+		}
+		mat, err := gocv.ImageGrayToMatGray(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer mat.Close()
+		frames[i] = mat
+	}
+
+	layout := DefaultLayout()
+	layout.Preprocess = nil
+	scales := []float64{0.9, 1}
+	var engines [2]*Engine
+	var want [2]matchOutcome
+	for i := range engines {
+		engines[i] = &Engine{cfg: Config{Weapons: reg, Workers: 2}, layout: layout}
+		alone := &Engine{cfg: Config{Weapons: ref}, layout: layout}
+		want[i] = alone.matchWeapon(frames[i], w.SLOT_UNDEFINED, scales)
+	}
+
+	var wg sync.WaitGroup
+	for i, e := range engines {
+		wg.Go(func() {
+			for range 50 {
+				out := e.matchWeapon(frames[i], w.SLOT_UNDEFINED, scales)
+				if out.err != nil {
+					t.Error(out.err)
+					return
+				}
+				if out.found != want[i].found || out.id != want[i].id ||
+					out.res.Score != want[i].res.Score || out.res.Box != want[i].res.Box {
+					t.Errorf("ROI %d: got %v %v %v, want %v %v %v", i,
+						out.id, out.res.Score, out.res.Box, want[i].id, want[i].res.Score, want[i].res.Box)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// TestRemoteFresh 远程结果只接受最近提交帧号之前窗口内的帧号。
+func TestRemoteFresh(t *testing.T) {
+	for _, c := range []struct {
+		frame, submitted uint64
+		want             bool
+	}{
+		{100, 100, true},
+		{70, 100, true},
+		{69, 100, false},
+		{101, 100, false},
+		{1<<32 + 5, 1<<32 + 5, true},
+		{5, 1<<32 + 5, false}, // 截断为 uint32 的帧号不会被误认为新鲜
+	} {
+		if got := remoteFresh(c.frame, c.submitted, 30); got != c.want {
+			t.Errorf("remoteFresh(%d, %d) = %v, want %v", c.frame, c.submitted, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package matcher
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Miuzarte/GoCVStreamer/template"
+	w "github.com/Miuzarte/GoCVStreamer/weapon"
+	ws "github.com/Miuzarte/GoCVStreamer/weapons"
+	"gocv.io/x/gocv"
+)
+
+const (
+	// COARSE_FACTOR 粗筛时画面与模板的缩放
+	COARSE_FACTOR = 0.5
+	// COARSE_MARGIN 粗筛得分不低于命中阈值减去该值的模板进入全分辨率匹配
+	COARSE_MARGIN = 0.15
+	// COARSE_MIN_SIZE 粗筛缩放后模板短边小于该像素数时不可靠，直接进入全分辨率匹配
+	COARSE_MIN_SIZE = 8
+)
+
+// matchOutcome matchWeapon 的结果。
+type matchOutcome struct {
+	id    w.ID
+	scale float64
+	found bool
+	// res 命中模板的匹配结果（得分与相对 ROI 的位置）
+	res template.Result
+	// matched 全分辨率匹配的模板次数，coarse 粗筛匹配的模板次数
+	matched, coarse int
+	// err 遇到的第一个模板错误，出错的模板被跳过
+	err error
+}
+
+// candidate 某一缩放级别上待匹配的模板。
+type candidate struct {
+	wp        *w.Weapon
+	strategy  template.Strategy
+	threshold float32
+	// coarse 粗筛得分，未参与粗筛时为 +Inf（排在最前）
+	coarse float32
+}
+
+// matchWeapon 按缩放序列逐级匹配全部模板，命中即停；
+// scales 首项为上次命中的缩放，常态下只需匹配一级。
+//
+// 首级先单独匹配上次命中的模板；其余模板在 Config.Coarse 时先在半分辨率上粗筛，
+// 再以 Config.Workers 个 goroutine 并行做全分辨率匹配，多个命中时取候选顺序最靠前的，
+// 与逐个匹配的结果一致。
+//
+// 只用 Eval，不写入模板上记录的结果：多个 ROI 可能共用同一注册表并同时匹配。
+func (e *Engine) matchWeapon(img gocv.Mat, slotFilter w.Slot, scales []float64) (out matchOutcome) {
+	e.mu.RLock()
+	last := e.lastTmpl
+	layout := e.layout
+	e.mu.RUnlock()
+	e.ensureScratches()
+
+	frame := template.NewFrame(img)
+	defer frame.Close()
+	var coarse *template.Frame
+	if e.cfg.Coarse {
+		small := gocv.NewMat()
+		defer small.Close()
+		if err := gocv.Resize(img, &small, image.Point{}, COARSE_FACTOR, COARSE_FACTOR, gocv.InterpolationArea); err == nil {
+			coarse = template.NewFrame(small)
+			defer coarse.Close()
+		}
+	}
+
+	var mu sync.Mutex
+	fail := func(wp *w.Weapon, err error) {
+		mu.Lock()
+		if out.err == nil {
+			out.err = fmt.Errorf("template %s: %w", wp.Name, err)
+		}
+		mu.Unlock()
+	}
+
+	// 持读锁期间模板不会被释放，热重载在两次匹配之间生效
+	e.cfg.Weapons.Read(func(weapons ws.Weapons) {
+		if len(weapons) == 0 {
+			return
+		}
+		// 从上次成功的模板开始往下匹配；已被删除时从头开始
+		start := max(weapons.IndexByID(last), 0)
+		order := make(ws.Weapons, 0, len(weapons))
+		for j := range weapons {
+			tmpl := weapons[(j+start)%len(weapons)]
+			if slotFilter != w.SLOT_UNDEFINED && j != 0 && !tmpl.Class.Detail().Slot.Has(slotFilter) {
+				continue
+			}
+			order = append(order, tmpl)
+		}
+
+		var matched, coarsed atomic.Int64
+		defer func() {
+			out.matched, out.coarse = int(matched.Load()), int(coarsed.Load())
+		}()
+
+		cands := make([]candidate, 0, len(order))
+		for i, scale := range scales {
+			cands = cands[:0]
+			for _, wp := range order {
+				strategy, threshold := layout.strategyFor(wp)
+				if i > 0 && template.IsScaleInvariant(strategy) {
+					continue
+				}
+				cands = append(cands, candidate{wp: wp, strategy: strategy, threshold: threshold, coarse: math.MaxFloat32})
+			}
+
+			rest := cands
+			if i == 0 && len(cands) > 0 && cands[0].wp.ID == last {
+				c := cands[0]
+				matched.Add(1)
+				if r, err := c.wp.Template.Eval(c.strategy, frame, scale, e.scratches[0]); err != nil {
+					fail(c.wp, err)
+				} else {
+					e.recordScore(c.wp.ID, r)
+					if r.Score >= c.threshold {
+						out.id, out.scale, out.found, out.res = c.wp.ID, scale, true, r
+						return
+					}
+				}
+				rest = cands[1:]
+			}
+
+			if coarse != nil {
+				rest = e.prune(rest, coarse, scale, &coarsed)
+			}
+
+			hit := -1
+			results := make([]template.Result, len(rest))
+			e.parallel(len(rest), func(worker, k int) bool {
+				c := rest[k]
+				matched.Add(1)
+				r, err := c.wp.Template.Eval(c.strategy, frame, scale, e.scratches[worker])
+				if err != nil {
+					fail(c.wp, err)
+					return false
+				}
+				e.recordScore(c.wp.ID, r)
+				results[k] = r
+				if r.Score < c.threshold {
+					return false
+				}
+				mu.Lock()
+				if hit < 0 || k < hit {
+					hit = k
+				}
+				mu.Unlock()
+				return true
+			})
+			if hit >= 0 {
+				out.id, out.scale, out.found, out.res = rest[hit].wp.ID, scale, true, results[hit]
+				return
+			}
+		}
+	})
+	return
+}
+
+// prune 在半分辨率画面上粗筛，保留得分不低于阈值减 COARSE_MARGIN 的候选，按粗筛得分降序；
+// 与缩放无关的策略、缩小后过小的模板与粗筛出错的模板不参与粗筛，直接保留。
+// 粗筛不改动模板上记录的结果。
+func (e *Engine) prune(cands []candidate, coarse *template.Frame, scale float64, count *atomic.Int64) []candidate {
+	e.parallel(len(cands), func(worker, k int) bool {
+		c := &cands[k]
+		t := &c.wp.Template
+		if template.IsScaleInvariant(c.strategy) || float64(min(t.Width, t.Height))*scale*COARSE_FACTOR < COARSE_MIN_SIZE {
+			return false
+		}
+		count.Add(1)
+		r, err := t.Eval(c.strategy, coarse, scale*COARSE_FACTOR, e.scratches[worker])
+		if err == nil {
+			c.coarse = r.Score
+		}
+		return false
+	})
+
+	kept := slices.DeleteFunc(cands, func(c candidate) bool {
+		return c.coarse < c.threshold-COARSE_MARGIN
+	})
+	slices.SortStableFunc(kept, func(a, b candidate) int {
+		return cmp.Compare(b.coarse, a.coarse)
+	})
+	return kept
+}
+
+// parallel 以最多 len(e.scratches) 个 goroutine 按下标从小到大领取 [0, n) 的任务，
+// worker 为所用缓冲的下标。fn 返回 true 后，比它大的下标不再领取（已领取的照常完成），
+// 因此比最小的返回 true 的下标更小的任务都已执行。
+func (e *Engine) parallel(n int, fn func(worker, i int) bool) {
+	workers := min(len(e.scratches), n)
+	if workers <= 1 {
+		for i := range n {
+			if fn(0, i) {
+				return
+			}
+		}
+		return
+	}
+
+	var next atomic.Int64
+	var stop atomic.Int64
+	stop.Store(math.MaxInt64)
+	var wg sync.WaitGroup
+	for worker := range workers {
+		wg.Go(func() {
+			for {
+				i := next.Add(1) - 1
+				if i >= int64(n) || i > stop.Load() {
+					return
+				}
+				if !fn(worker, int(i)) {
+					continue
+				}
+				for {
+					cur := stop.Load()
+					if i >= cur || stop.CompareAndSwap(cur, i) {
+						break
+					}
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// ensureScratches 按 Config.Workers 创建各 worker 的匹配缓冲。
+func (e *Engine) ensureScratches() {
+	for len(e.scratches) < max(e.cfg.Workers, 1) {
+		e.scratches = append(e.scratches, template.NewScratch())
+	}
+}
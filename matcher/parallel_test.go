@@ -0,0 +1,161 @@
+package matcher
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	w "github.com/Miuzarte/GoCVStreamer/weapon"
+	ws "github.com/Miuzarte/GoCVStreamer/weapons"
+	"gocv.io/x/gocv"
+)
+
+// TestParallelFirstHit 多个任务命中时，比最小命中下标小的任务必须全部执行，与逐个执行的结果一致。
+func TestParallelFirstHit(t *testing.T) {
+	hits := []int{37, 60, 90}
+	for _, workers := range []int{1, 2, 4, 8} {
+		e := &Engine{cfg: Config{Workers: workers}}
+		e.ensureScratches()
+		for range 20 {
+			var mu sync.Mutex
+			var done []int
+			e.parallel(100, func(worker, i int) bool {
+				if worker < 0 || worker >= workers {
+					t.Errorf("worker %d out of range", worker)
+				}
+				mu.Lock()
+				done = append(done, i)
+				mu.Unlock()
+				return slices.Contains(hits, i)
+			})
+			slices.Sort(done)
+			for i := range hits[0] + 1 {
+				if !slices.Contains(done, i) {
+					t.Fatalf("workers=%d: task %d skipped before first hit", workers, i)
+				}
+			}
+			if workers == 1 && len(done) != hits[0]+1 {
+				t.Fatalf("sequential ran %d tasks, want %d", len(done), hits[0]+1)
+			}
+		}
+	}
+}
+
+func TestParallelNoHit(t *testing.T) {
+	e := &Engine{cfg: Config{Workers: 4}}
+	e.ensureScratches()
+	var mu sync.Mutex
+	seen := make(map[int]int)
+	e.parallel(50, func(_, i int) bool {
+		mu.Lock()
+		seen[i]++
+		mu.Unlock()
+		return false
+	})
+	for i := range 50 {
+		if seen[i] != 1 {
+			t.Fatalf("task %d ran %d times", i, seen[i])
+		}
+	}
+}
+
+const (
+	BENCH_TEMPLATES = 200
+	BENCH_TEMPLATE  = 24
+	// BENCH_TARGET 画面中的模板下标，靠后以覆盖大部分模板
+	BENCH_TARGET = 150
+)
+
+// newBenchEngine 生成 n 个随机纹理模板与一张包含第 target 个模板的 ROI 画面。
+func newBenchEngine(b *testing.B, n, target int, cfg Config) (*Engine, gocv.Mat) {
+	b.Helper()
+	dir := b.TempDir()
+	rng := rand.New(rand.NewPCG(1, 2))
+	roi := ReferenceRoi.Size()
+	frame := image.NewGray(image.Rectangle{Max: roi})
+	for i := range frame.Pix {
+		frame.Pix[i] = uint8(rng.IntN(256))
+	}
+
+	reg := ws.NewRegistry()
+	b.Cleanup(func() { reg.Close() })
+	for i := range n {
+		img := image.NewGray(image.Rect(0, 0, BENCH_TEMPLATE, BENCH_TEMPLATE))
+		for j := range img.Pix {
+			img.Pix[j] = uint8(rng.IntN(256))
+		}
+		if i == target {
+			at := image.Pt(roi.X/3, roi.Y/3)
+			for y := range BENCH_TEMPLATE {
+				copy(frame.Pix[frame.PixOffset(at.X, at.Y+y):], img.Pix[img.PixOffset(0, y):img.PixOffset(0, y)+BENCH_TEMPLATE])
+			}
+		}
+		path := filepath.Join(dir, fmt.Sprintf("{AR_10_--} T%03d.png", i))
+		f, err := os.Create(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := png.Encode(f, img); err != nil {
+			b.Fatal(err)
+		}
+		f.Close()
+		if _, err := reg.Add(path, false, gocv.IMReadGrayScale); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	mat, err := gocv.ImageGrayToMatGray(frame)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { mat.Close() })
+
+	layout := DefaultLayout()
+	layout.Preprocess = nil
+	cfg.Weapons = reg
+	e := &Engine{cfg: cfg, layout: layout}
+	b.Cleanup(func() {
+		for _, sc := range e.scratches {
+			sc.Close()
+		}
+	})
+	return e, mat
+}
+
+// BenchmarkMatchWeapon 200 个模板的库上匹配一帧（无上次命中的模板，单一缩放）：
+// hit 为画面中有第 150 个模板，miss 为全部模板都要匹配。
+func BenchmarkMatchWeapon(b *testing.B) {
+	modes := []struct {
+		name string
+		cfg  Config
+	}{
+		{"sequential", Config{}},
+		{"parallel4", Config{Workers: 4}},
+		{"coarse", Config{Coarse: true}},
+		{"parallel4+coarse", Config{Workers: 4, Coarse: true}},
+	}
+	for _, m := range modes {
+		for _, target := range []int{BENCH_TARGET, -1} {
+			name := m.name + "/hit"
+			if target < 0 {
+				name = m.name + "/miss"
+			}
+			b.Run(name, func(b *testing.B) {
+				e, frame := newBenchEngine(b, BENCH_TEMPLATES, target, m.cfg)
+				scales := []float64{1}
+				var out matchOutcome
+				for b.Loop() {
+					out = e.matchWeapon(frame, w.SLOT_UNDEFINED, scales)
+				}
+				b.ReportMetric(float64(out.matched), "templates/op")
+				b.ReportMetric(float64(out.coarse), "coarse/op")
+			})
+		}
+	}
+}
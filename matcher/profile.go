@@ -0,0 +1,171 @@
+package matcher
+
+import (
+	"cmp"
+	"encoding/json/jsontext"
+	jsonv2 "encoding/json/v2"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/Miuzarte/GoCVStreamer/template"
+)
+
+// PROFILE_SUFFIX ROI 布局文件：布局目录下每个游戏一份 GAME.json
+const PROFILE_SUFFIX = ".json"
+
+// Profile 一个游戏的 ROI 布局，按声明顺序，第一个为主 ROI。
+type Profile struct {
+	Rois []RoiSpec `json:"rois"`
+}
+
+// RoiSpec 单个 ROI 的布局与匹配设置；零值字段沿用命令行给出的默认值。
+type RoiSpec struct {
+	Name string `json:"name"`
+	// Templates 模板目录，空为默认模板目录
+	Templates string `json:"templates,omitzero"`
+
+	Strategy   string  `json:"strategy,omitzero"`
+	Threshold  float32 `json:"threshold,omitzero"`
+	Preprocess string  `json:"preprocess,omitzero"`
+
+	Fps         int `json:"fps,omitzero"`
+	FpsIdle     int `json:"fps_idle,omitzero"`
+	IdleAfterMs int `json:"idle_after_ms,omitzero"`
+
+	// Roi 与 Anchors 同时生效：Roi 为零值时沿用默认布局
+	Roi       NormRect  `json:"roi,omitzero"`
+	Anchors   []Anchor  `json:"anchors,omitzero"`
+	RefHeight int       `json:"ref_height,omitzero"`
+	Scales    []float64 `json:"scales,omitzero"`
+}
+
+// Validate 检查名称与取值范围。
+func (p Profile) Validate() error {
+	if len(p.Rois) == 0 {
+		return fmt.Errorf("no ROI declared")
+	}
+	seen := make(map[string]bool, len(p.Rois))
+	for _, r := range p.Rois {
+		if r.Name == "" {
+			return fmt.Errorf("ROI without name")
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("duplicate ROI name %q", r.Name)
+		}
+		seen[r.Name] = true
+		if r.Threshold < 0 || r.Threshold > 1 {
+			return fmt.Errorf("ROI %q: threshold must be in [0, 1], got %v", r.Name, r.Threshold)
+		}
+		if r.Fps < 0 || r.FpsIdle < 0 || r.IdleAfterMs < 0 {
+			return fmt.Errorf("ROI %q: fps and idle_after_ms must be >= 0", r.Name)
+		}
+	}
+	return nil
+}
+
+// LoadProfile 读取 ROI 布局文件；未知字段视为错误以便发现拼写问题。
+func LoadProfile(path string) (Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Profile{}, err
+	}
+	var p Profile
+	if err := jsonv2.Unmarshal(data, &p, jsonv2.RejectUnknownMembers(true)); err != nil {
+		return Profile{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := p.Validate(); err != nil {
+		return Profile{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// SaveProfile 写入 ROI 布局文件（先写临时文件再替换）。
+func SaveProfile(path string, p Profile) error {
+	data, err := jsonv2.Marshal(p, jsontext.WithIndent("  "))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Config 以 base 为默认值生成该 ROI 的 Config；Weapons 由调用方按 Templates 设置。
+func (r RoiSpec) Config(base Config) (Config, error) {
+	cfg := base
+	cfg.Name = r.Name
+	if r.Templates != "" {
+		cfg.Templates = r.Templates
+	}
+	if r.Fps > 0 {
+		cfg.Fps = r.Fps
+	}
+	if r.FpsIdle > 0 {
+		cfg.FpsIdle = r.FpsIdle
+	}
+	if r.IdleAfterMs > 0 {
+		cfg.DropIdleDuration = time.Duration(r.IdleAfterMs) * time.Millisecond
+	}
+
+	l := base.Layout
+	if r.Roi != (NormRect{}) {
+		l.Roi = r.Roi
+		l.Anchors = slices.Clone(r.Anchors)
+	}
+	if r.RefHeight > 0 {
+		l.RefHeight = r.RefHeight
+	}
+	if len(r.Scales) > 0 {
+		l.Scales = slices.Clone(r.Scales)
+	}
+	if r.Strategy != "" {
+		st, err := template.ParseStrategy(r.Strategy)
+		if err != nil {
+			return Config{}, fmt.Errorf("ROI %q: %w", r.Name, err)
+		}
+		l.Strategy = st
+	}
+	if r.Threshold > 0 {
+		l.Threshold = r.Threshold
+	}
+	if r.Preprocess != "" {
+		pre, err := template.ParsePreprocess(r.Preprocess)
+		if err != nil {
+			return Config{}, fmt.Errorf("ROI %q: %w", r.Name, err)
+		}
+		l.Preprocess = pre
+	}
+	cfg.Layout = l
+	return cfg, nil
+}
+
+// Spec 当前布局（含手动移动与校准）与匹配设置，可写回 ROI 布局文件。
+func (e *Engine) Spec() RoiSpec {
+	l := e.Layout()
+	r := RoiSpec{
+		Name:      e.cfg.Name,
+		Templates: e.cfg.Templates,
+		Threshold: l.Threshold,
+		// 空链（不预处理）写作 bgr，避免读回时变成默认链
+		Preprocess:  cmp.Or(l.Preprocess.String(), template.PRE_BGR),
+		Fps:         e.cfg.Fps,
+		FpsIdle:     e.cfg.FpsIdle,
+		IdleAfterMs: int(e.cfg.DropIdleDuration / time.Millisecond),
+		Roi:         l.Roi,
+		Anchors:     l.Anchors,
+		RefHeight:   l.RefHeight,
+		Scales:      l.Scales,
+	}
+	if l.Strategy != nil {
+		r.Strategy = l.Strategy.Name()
+	}
+	return r
+}
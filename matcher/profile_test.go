@@ -0,0 +1,85 @@
+package matcher
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Miuzarte/GoCVStreamer/template"
+)
+
+func TestProfileRoundTrip(t *testing.T) {
+	base := Config{Fps: 5, FpsIdle: 2, DropIdleDuration: 5 * time.Second, Templates: "templates", Layout: DefaultLayout()}
+	bounds := image.Rect(0, 0, 1920, 1080)
+	moved := image.Rect(1510, 900, 1576, 978)
+
+	e := &Engine{cfg: base, layout: base.Layout.WithRoi(moved, bounds)}
+	e.cfg.Name = "weapon"
+	gadget := RoiSpec{
+		Name:       "gadget",
+		Templates:  "templates/gadgets",
+		Strategy:   template.STRATEGY_EDGE,
+		Preprocess: "gray,clahe:2",
+		Fps:        2,
+		Roi:        NormRect{X0: 0.1, Y0: 0.8, X1: 0.15, Y1: 0.9},
+	}
+	p := Profile{Rois: []RoiSpec{e.Spec(), gadget}}
+
+	path := filepath.Join(t.TempDir(), "rois", "r6s"+PROFILE_SUFFIX)
+	if err := SaveProfile(path, p); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadProfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Rois) != 2 {
+		t.Fatalf("loaded %d ROIs", len(loaded.Rois))
+	}
+
+	cfg, err := loaded.Rois[0].Config(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roi, _ := cfg.Layout.Resolve(bounds); roi != moved {
+		t.Fatalf("weapon roi = %v, want %v", roi, moved)
+	}
+	if cfg.Layout.Preprocess.String() != DefaultPreprocess.String() || cfg.DropIdleDuration != base.DropIdleDuration {
+		t.Fatalf("weapon config = %+v", cfg)
+	}
+
+	cfg, err = loaded.Rois[1].Config(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "gadget" || cfg.Templates != "templates/gadgets" || cfg.Fps != 2 || cfg.FpsIdle != 2 {
+		t.Fatalf("gadget config = %+v", cfg)
+	}
+	if cfg.Layout.Strategy == nil || cfg.Layout.Strategy.Name() != template.STRATEGY_EDGE {
+		t.Fatalf("gadget strategy = %v", cfg.Layout.Strategy)
+	}
+	if roi, _ := cfg.Layout.Resolve(bounds); roi != image.Rect(192, 864, 288, 972) {
+		t.Fatalf("gadget roi = %v", roi)
+	}
+}
+
+func TestLoadProfileRejects(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"unknown":   `{"rois": [{"name": "weapon", "fsp": 5}]}`,
+		"empty":     `{"rois": []}`,
+		"unnamed":   `{"rois": [{"fps": 5}]}`,
+		"duplicate": `{"rois": [{"name": "weapon"}, {"name": "weapon"}]}`,
+		"threshold": `{"rois": [{"name": "weapon", "threshold": 2}]}`,
+	} {
+		path := filepath.Join(dir, name+PROFILE_SUFFIX)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadProfile(path); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
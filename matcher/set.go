@@ -0,0 +1,191 @@
+package matcher
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"gioui.org/layout"
+
+	"github.com/Miuzarte/GoCVStreamer/capturer"
+	"github.com/Miuzarte/GoCVStreamer/hysteresis"
+	"github.com/Miuzarte/GoCVStreamer/raster"
+	"github.com/Miuzarte/GoCVStreamer/ui"
+	w "github.com/Miuzarte/GoCVStreamer/weapon"
+)
+
+// EVENT_BUFFER 每个订阅者的事件缓冲，满时丢弃新事件
+const EVENT_BUFFER = 16
+
+// Event 合并结果流中的一项：某个 ROI 经迟滞确认的结果发生了变化。
+type Event struct {
+	Roi    string
+	Result MatchResult
+	// Name 确认的模板名，无或模板已被删除时为空
+	Name string
+	Time time.Time
+}
+
+// Set 同时识别多个 HUD 元素：每个命名 ROI 一个 Engine，各自的模板库、策略、帧率与空闲策略。
+type Set struct {
+	engines []*Engine
+	byName  map[string]*Engine
+
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+
+	// OnLayout 任一 ROI 被移动、重置或校准后调用，参数为当前全部 ROI 的布局（用于保存）
+	OnLayout func(Profile)
+}
+
+// NewSet 按声明顺序创建各 ROI 的 Engine；名称必须唯一，
+// 共用同一模板库的 ROI 必须使用相同的预处理链（模板只按一条链处理）；
+// 匹配只读模板，得分与位置由各 Engine 自行记录，共用的 ROI 可以同时匹配。
+func NewSet(capturerServer *capturer.Server, cfgs []Config) (*Set, error) {
+	if len(cfgs) == 0 {
+		return nil, fmt.Errorf("no ROI declared")
+	}
+	cfgs = slices.Clone(cfgs)
+	for i := range cfgs {
+		cfgs[i].Name = cmp.Or(cfgs[i].Name, ROI_DEFAULT_NAME)
+	}
+	if err := validateConfigs(cfgs); err != nil {
+		return nil, err
+	}
+	s := &Set{
+		byName: make(map[string]*Engine, len(cfgs)),
+		subs:   make(map[chan Event]struct{}),
+	}
+	for _, cfg := range cfgs {
+		e := New(capturerServer, cfg)
+		e.onResult = func(ev hysteresis.Event[w.ID]) { s.publish(e, ev) }
+		e.onLayout = s.layoutChanged
+		s.engines = append(s.engines, e)
+		s.byName[e.Name()] = e
+	}
+	return s, nil
+}
+
+func validateConfigs(cfgs []Config) error {
+	seen := make(map[string]bool, len(cfgs))
+	for i, cfg := range cfgs {
+		name := cfg.Name
+		if seen[name] {
+			return fmt.Errorf("duplicate ROI name %q", name)
+		}
+		seen[name] = true
+		if cfg.Weapons == nil {
+			return fmt.Errorf("ROI %q: no template registry", name)
+		}
+		for _, other := range cfgs[:i] {
+			if other.Weapons == cfg.Weapons && other.Layout.Preprocess.String() != cfg.Layout.Preprocess.String() {
+				return fmt.Errorf("ROI %q shares templates with %q but preprocesses %q instead of %q",
+					name, other.Name, cfg.Layout.Preprocess, other.Layout.Preprocess)
+			}
+		}
+	}
+	return nil
+}
+
+// Engines 按声明顺序的全部 ROI。
+func (s *Set) Engines() []*Engine {
+	return s.engines
+}
+
+// Primary 第一个声明的 ROI。
+func (s *Set) Primary() *Engine {
+	return s.engines[0]
+}
+
+func (s *Set) Get(name string) (*Engine, bool) {
+	e, ok := s.byName[name]
+	return e, ok
+}
+
+// Results 各 ROI 最近一次匹配的原始结果。
+func (s *Set) Results() map[string]MatchResult {
+	results := make(map[string]MatchResult, len(s.engines))
+	for _, e := range s.engines {
+		results[e.Name()] = e.Result()
+	}
+	return results
+}
+
+// Profile 当前全部 ROI 的布局与匹配设置。
+func (s *Set) Profile() Profile {
+	p := Profile{Rois: make([]RoiSpec, 0, len(s.engines))}
+	for _, e := range s.engines {
+		p.Rois = append(p.Rois, e.Spec())
+	}
+	return p
+}
+
+// Subscribe 订阅全部 ROI 的合并结果流，调用返回的函数取消订阅；
+// 订阅者消费不及时时丢弃新事件，不阻塞匹配。
+func (s *Set) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, EVENT_BUFFER)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+	return ch, func() {
+		s.mu.Lock()
+		delete(s.subs, ch)
+		s.mu.Unlock()
+	}
+}
+
+func (s *Set) publish(e *Engine, change hysteresis.Event[w.ID]) {
+	ev := Event{
+		Roi: e.Name(),
+		Result: MatchResult{
+			Found:      change.To != WEAPON_ID_NONE,
+			WeaponID:   change.To,
+			Confidence: change.Confidence,
+		},
+		Time: change.Time,
+	}
+	if ev.Result.Found {
+		if wp, ok := e.Weapons().Get(change.To); ok {
+			ev.Name = wp.Name
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+func (s *Set) layoutChanged() {
+	if s.OnLayout != nil {
+		s.OnLayout(s.Profile())
+	}
+}
+
+// Run 并发运行全部 ROI，直到 ctx 结束。
+func (s *Set) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, e := range s.engines {
+		wg.Go(func() { e.Run(ctx) })
+	}
+	wg.Wait()
+}
+
+func (s *Set) Draw(gtx layout.Context, scale ui.DScale) {
+	for _, e := range s.engines {
+		e.Draw(gtx, scale)
+	}
+}
+
+// Paint 实现 raster.Painter。
+func (s *Set) Paint(c *raster.Canvas) {
+	for _, e := range s.engines {
+		e.Paint(c)
+	}
+}
@@ -0,0 +1,73 @@
+package matcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Miuzarte/GoCVStreamer/hysteresis"
+	"github.com/Miuzarte/GoCVStreamer/template"
+	w "github.com/Miuzarte/GoCVStreamer/weapon"
+	ws "github.com/Miuzarte/GoCVStreamer/weapons"
+)
+
+func TestValidateConfigs(t *testing.T) {
+	reg, other := ws.NewRegistry(), ws.NewRegistry()
+	gray := Layout{Preprocess: DefaultPreprocess}
+	clahe := Layout{Preprocess: template.Preprocess{{Op: template.PRE_GRAY}, {Op: template.PRE_CLAHE, Arg: 2}}}
+
+	ok := []Config{
+		{Name: "weapon", Weapons: reg, Layout: gray},
+		{Name: "gadget", Weapons: reg, Layout: gray},
+		{Name: "banner", Weapons: other, Layout: clahe},
+	}
+	if err := validateConfigs(ok); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, cfgs := range map[string][]Config{
+		"duplicate":   {{Name: "weapon", Weapons: reg}, {Name: "weapon", Weapons: other}},
+		"no registry": {{Name: "weapon"}},
+		"shared registry, different preprocessing": {
+			{Name: "weapon", Weapons: reg, Layout: gray},
+			{Name: "gadget", Weapons: reg, Layout: clahe},
+		},
+	} {
+		if err := validateConfigs(cfgs); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+// TestSetEvents 确认结果的变化进入合并结果流，慢订阅者不阻塞。
+func TestSetEvents(t *testing.T) {
+	reg := ws.NewRegistry()
+	s := &Set{subs: make(map[chan Event]struct{})}
+	e := &Engine{cfg: Config{Name: "gadget", Weapons: reg}}
+	s.engines = []*Engine{e}
+
+	events, cancel := s.Subscribe()
+	now := time.Now()
+	s.publish(e, hysteresis.Event[w.ID]{From: WEAPON_ID_NONE, To: w.ID(3), Confidence: 0.9, Time: now})
+	s.publish(e, hysteresis.Event[w.ID]{From: w.ID(3), To: WEAPON_ID_NONE, Time: now})
+
+	for _, want := range []bool{true, false} {
+		select {
+		case ev := <-events:
+			if ev.Roi != "gadget" || ev.Result.Found != want || !ev.Time.Equal(now) {
+				t.Fatalf("event = %+v, want found %v", ev, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("missing event")
+		}
+	}
+
+	hit := hysteresis.Event[w.ID]{To: w.ID(3), Time: now}
+	for range EVENT_BUFFER * 2 {
+		s.publish(e, hit)
+	}
+	cancel()
+	s.publish(e, hit)
+	if n := len(events); n != EVENT_BUFFER {
+		t.Fatalf("buffered %d events, want %d", n, EVENT_BUFFER)
+	}
+}
@@ -0,0 +1,99 @@
+// Package raster 是不依赖 gioui 的软件光栅器,
+// 把 ROI/匹配结果/检测框直接画进 *image.RGBA (供观战端推流使用)
+package raster
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	BorderThickness = 2
+	LineHeight      = 13 // basicfont.Face7x13
+)
+
+// Painter 类比 ui.Drawer：在软件画布上绘制叠加层。
+type Painter interface {
+	Paint(c *Canvas)
+}
+
+// Canvas 是带坐标映射的绘制目标：Paint 方使用屏幕坐标, 由 Canvas 等比缩放到帧坐标。
+type Canvas struct {
+	Img    *image.RGBA
+	Orig   image.Point // 屏幕尺寸
+	Offset image.Point // 帧在屏幕上的起点（裁剪时非 0）
+}
+
+func NewCanvas(img *image.RGBA, orig image.Point, offset image.Point) *Canvas {
+	return &Canvas{Img: img, Orig: orig, Offset: offset}
+}
+
+func (c *Canvas) Ratio() float64 {
+	if c.Orig.X == 0 || c.Orig.Y == 0 {
+		return 1
+	}
+	b := c.Img.Bounds()
+	return min(float64(b.Dx())/float64(c.Orig.X), float64(b.Dy())/float64(c.Orig.Y))
+}
+
+func (c *Canvas) Pos(pos image.Point) image.Point {
+	r := c.Ratio()
+	pos = pos.Sub(c.Offset)
+	return image.Pt(int(float64(pos.X)*r), int(float64(pos.Y)*r)).Add(c.Img.Bounds().Min)
+}
+
+func (c *Canvas) Rect(rect image.Rectangle) image.Rectangle {
+	return image.Rectangle{Min: c.Pos(rect.Min), Max: c.Pos(rect.Max)}
+}
+
+// Fill 填充矩形（帧坐标）, 越界部分自动裁掉。
+func (c *Canvas) Fill(col color.Color, rect image.Rectangle) {
+	rect = rect.Intersect(c.Img.Bounds())
+	if rect.Empty() {
+		return
+	}
+	draw.Draw(c.Img, rect, image.NewUniform(col), image.Point{}, draw.Over)
+}
+
+// DrawBorder 画空心矩形（帧坐标）, 边框向内收 BorderThickness。
+func (c *Canvas) DrawBorder(col color.Color, rect image.Rectangle) {
+	rect = rect.Canon()
+	t := BorderThickness
+	if rect.Dx() <= 2*t || rect.Dy() <= 2*t {
+		c.Fill(col, rect)
+		return
+	}
+	c.Fill(col, image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y+t))
+	c.Fill(col, image.Rect(rect.Min.X, rect.Max.Y-t, rect.Max.X, rect.Max.Y))
+	c.Fill(col, image.Rect(rect.Min.X, rect.Min.Y+t, rect.Min.X+t, rect.Max.Y-t))
+	c.Fill(col, image.Rect(rect.Max.X-t, rect.Min.Y+t, rect.Max.X, rect.Max.Y-t))
+}
+
+// DrawLabel 以 pos 为左上角写一行文字（帧坐标）, 带半透明黑底便于在游戏画面上阅读。
+func (c *Canvas) DrawLabel(col color.Color, pos image.Point, txt string) {
+	if txt == "" {
+		return
+	}
+	face := basicfont.Face7x13
+	width := font.MeasureString(face, txt).Ceil()
+	c.Fill(color.RGBA{A: 0x99}, image.Rect(pos.X, pos.Y, pos.X+width+2, pos.Y+LineHeight+2))
+
+	d := font.Drawer{
+		Dst:  c.Img,
+		Src:  image.NewUniform(col),
+		Face: face,
+		Dot:  fixed.P(pos.X+1, pos.Y+1+face.Ascent),
+	}
+	d.DrawString(txt)
+}
+
+// DrawTextRight 类比 ui.DrawTextRight：在矩形右侧按行写文字（帧坐标）。
+func (c *Canvas) DrawTextRight(col color.Color, rect image.Rectangle, line int, txt string) {
+	pos := image.Pt(rect.Max.X+LineHeight/4, rect.Min.Y+line*(LineHeight+2))
+	c.DrawLabel(col, pos, txt)
+}
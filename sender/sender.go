@@ -9,6 +9,7 @@ import (
 	"image"
 	"image/draw"
 	"image/jpeg"
+	"image/png"
 	"net/http"
 	"sync"
 	"time"
@@ -17,11 +18,52 @@ import (
 	"github.com/Miuzarte/GoCVStreamer/fps"
 	"github.com/Miuzarte/GoCVStreamer/libyuv"
 	"github.com/Miuzarte/GoCVStreamer/logger"
+	"github.com/Miuzarte/GoCVStreamer/raster"
 	"github.com/coder/websocket"
 )
 
 var log = logger.New("Sender")
 
+// Role 客户端角色，连接时通过 /stream?role=xxx 指定（缺省为推理端）。
+type Role int
+
+const (
+	// RoleInference 推理端：接收裁剪后的原始流帧并回传检测结果。
+	RoleInference Role = iota
+	// RoleSpectator 观战端：接收服务端叠加了 ROI/匹配/检测框的全屏帧，不允许回传结果。
+	RoleSpectator
+	// RoleMatcher 远程匹配端：接收 ROI 裁剪（PNG，帧头为完整的 8B 帧号）并回传模板匹配结果，
+	// 参考实现与消息格式见 cmd/matchworker。
+	RoleMatcher
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleInference:
+		return "inference"
+	case RoleSpectator:
+		return "spectator"
+	case RoleMatcher:
+		return "matcher"
+	default:
+		return fmt.Sprintf("unexpected value of client role: %d", r)
+	}
+}
+
+// ParseRole 解析连接参数中的角色名；空串视为推理端。
+func ParseRole(s string) (Role, error) {
+	switch s {
+	case "", "inference":
+		return RoleInference, nil
+	case "spectator":
+		return RoleSpectator, nil
+	case "matcher":
+		return RoleMatcher, nil
+	default:
+		return 0, fmt.Errorf("unknown client role: %q", s)
+	}
+}
+
 // RemoteDetection 是手机端回传的归一化检测框（相对 640×640 流帧）。
 type RemoteDetection struct {
 	X1        float64 `json:"x1"`
@@ -38,6 +80,19 @@ type RemoteResult struct {
 	FrameID     uint64            `json:"frame_id"`
 	Detections  []RemoteDetection `json:"detections"`
 	InferenceMs float64           `json:"inference_ms"`
+
+	Client string `json:"-"` // 发送端地址（由服务端填写），区分多台手机
+}
+
+// RemoteMatch 是远程匹配端回传的模板匹配结果 JSON（X/Y 为模板在 ROI 内的左上角）。
+type RemoteMatch struct {
+	FrameID    uint64  `json:"frame_id"`
+	Found      bool    `json:"found"`
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+	X          int     `json:"x"`
+	Y          int     `json:"y"`
+	MatchMs    float64 `json:"match_ms"`
 }
 
 type Config struct {
@@ -46,10 +101,14 @@ type Config struct {
 	JpegQuality int    // JPEG 质量 1-100
 	InputSize   int    // 流帧边长（正方形），默认 640
 	CropSize    int    // 中心裁剪边长：-1=屏幕短边（自动），0=不裁剪，>0=固定值（默认 1280）
+
+	SpectatorSize int // 观战帧长边（全屏等比缩放），默认 1280
 }
 
 type Stats struct {
 	Clients       int
+	Spectators    int
+	Matchers      int
 	Fps           float64
 	FramesSent    uint64
 	Detections    uint64
@@ -57,6 +116,10 @@ type Stats struct {
 	LastAt        time.Time
 	LastLatency   time.Duration
 	LastInference time.Duration
+
+	RoisSent         uint64
+	Matches          uint64
+	LastMatchLatency time.Duration
 }
 
 type Server struct {
@@ -64,7 +127,10 @@ type Server struct {
 	src *capturer.Server
 
 	clientMu sync.Mutex
-	clients  map[*websocket.Conn]struct{}
+	clients  map[*websocket.Conn]Role
+
+	paintMu  sync.Mutex
+	painters []raster.Painter
 
 	statsMu  sync.Mutex
 	stats    Stats
@@ -72,7 +138,9 @@ type Server struct {
 	lastSent uint64
 
 	sentMu sync.Mutex
-	sentAt map[uint32]time.Time
+	sentAt map[sentKey]time.Time
+
+	roiCh chan roiFrame
 
 	// 裁剪/缩放元数据（runLoop 初始化后只读）
 	cropSize   int
@@ -82,6 +150,23 @@ type Server struct {
 	// OnResult 收到手机端检测 JSON 时回调（nil 时只记 stats）。
 	// 第二个参数是该帧的全链路延迟（帧发出→收到结果）。
 	OnResult func(RemoteResult, time.Duration)
+
+	// OnMatch 收到远程匹配端结果时回调，第二个参数是 ROI 发出→收到结果的延迟。
+	OnMatch func(RemoteMatch, time.Duration)
+
+	// OnClient 客户端连接 / 断开时回调（remote 为对端地址，同 RemoteResult.Client）。
+	OnClient func(remote string, role Role, connected bool)
+}
+
+// sentKey 按角色区分帧发送时间（推理帧与 ROI 裁剪共用捕获帧号）。
+type sentKey struct {
+	role Role
+	id   uint64
+}
+
+type roiFrame struct {
+	id  uint64
+	img *image.RGBA
 }
 
 func NewServer(cfg Config, src *capturer.Server) *Server {
@@ -94,11 +179,15 @@ func NewServer(cfg Config, src *capturer.Server) *Server {
 	if cfg.InputSize <= 0 {
 		cfg.InputSize = 640
 	}
+	if cfg.SpectatorSize <= 0 {
+		cfg.SpectatorSize = 1280
+	}
 	s := &Server{
 		cfg:     cfg,
 		src:     src,
-		clients: make(map[*websocket.Conn]struct{}),
-		sentAt:  make(map[uint32]time.Time),
+		clients: make(map[*websocket.Conn]Role),
+		sentAt:  make(map[sentKey]time.Time),
+		roiCh:   make(chan roiFrame, 1),
 		fp:      fps.NewCounter(time.Second),
 	}
 
@@ -138,6 +227,7 @@ func (s *Server) Run(ctx context.Context) {
 		}
 	}()
 	go s.runLoop(ctx)
+	go s.roiLoop(ctx)
 
 	log.Info().
 		Str("addr", s.cfg.Addr).
@@ -159,18 +249,59 @@ func (s *Server) HasClients() bool {
 	return len(s.clients) != 0
 }
 
-func (s *Server) Stats() Stats {
+// HasMatchers 是否有远程匹配端在连接（实现 matcher.Offloader）。
+func (s *Server) HasMatchers() bool {
 	s.clientMu.Lock()
-	clients := len(s.clients)
-	s.clientMu.Unlock()
+	defer s.clientMu.Unlock()
+	for _, role := range s.clients {
+		if role == RoleMatcher {
+			return true
+		}
+	}
+	return false
+}
+
+// countClients 返回各角色的连接数。
+func (s *Server) countClients() (inference, spectators, matchers int) {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+	for _, role := range s.clients {
+		switch role {
+		case RoleInference:
+			inference++
+		case RoleSpectator:
+			spectators++
+		case RoleMatcher:
+			matchers++
+		}
+	}
+	return
+}
+
+// AddPainter 注册观战帧叠加层（按注册顺序绘制），可在 Run 之后调用。
+func (s *Server) AddPainter(p raster.Painter) {
+	s.paintMu.Lock()
+	defer s.paintMu.Unlock()
+	s.painters = append(s.painters, p)
+}
+
+func (s *Server) Stats() Stats {
+	inference, spectators, matchers := s.countClients()
 	s.statsMu.Lock()
 	defer s.statsMu.Unlock()
-	s.stats.Clients = clients
+	s.stats.Clients = inference
+	s.stats.Spectators = spectators
+	s.stats.Matchers = matchers
 	return s.stats
 }
 
 func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 	remote := r.RemoteAddr
+	role, err := ParseRole(r.URL.Query().Get("role"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	c, err := websocket.Accept(w, r, &websocket.AcceptOptions{
 		// 与 goApp/flutterApp 客户端协商压缩；手机 App 无 Origin 限制，全部放行。
 		CompressionMode: websocket.CompressionContextTakeover,
@@ -182,16 +313,23 @@ func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 	c.SetReadLimit(1 << 20) // 检测 JSON 足够小，1 MiB 上限
 
 	s.clientMu.Lock()
-	s.clients[c] = struct{}{}
+	s.clients[c] = role
 	s.clientMu.Unlock()
 
+	if s.OnClient != nil {
+		s.OnClient(remote, role, true)
+	}
 	defer func() {
 		s.removeClient(c)
 		c.CloseNow()
+		if s.OnClient != nil {
+			s.OnClient(remote, role, false)
+		}
 	}()
 
 	log.Info().
 		Str("remote", remote).
+		Stringer("role", role).
 		Msg("stream client connected")
 
 	// r.Context() 在客户端断开或服务端关闭时自动取消，读循环随之退出，
@@ -205,15 +343,29 @@ func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 		if mt != websocket.MessageText {
 			continue
 		}
+		switch role {
+		case RoleInference:
+		case RoleMatcher:
+			s.handleMatch(data)
+			continue
+		default:
+			// 观战端只看不写：丢弃其上行消息，避免伪造检测结果。
+			log.Debug().
+				Str("remote", remote).
+				Stringer("role", role).
+				Msg("result from non-inference client dropped")
+			continue
+		}
 
 		var res RemoteResult
 		if err := jsonv2.Unmarshal(data, &res); err != nil {
 			log.Debug().Err(err).Msg("bad result json")
 			continue
 		}
+		res.Client = remote
 
 		latency := time.Duration(0)
-		if t, ok := s.frameLatency(uint32(res.FrameID), time.Now()); ok {
+		if t, ok := s.frameLatency(RoleInference, res.FrameID, time.Now()); ok {
 			latency = t
 		}
 		inference := time.Duration(res.InferenceMs * float64(time.Millisecond))
@@ -232,6 +384,29 @@ func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleMatch 解析远程匹配端回传的结果 JSON。
+func (s *Server) handleMatch(data []byte) {
+	var res RemoteMatch
+	if err := jsonv2.Unmarshal(data, &res); err != nil {
+		log.Debug().Err(err).Msg("bad match json")
+		return
+	}
+
+	latency := time.Duration(0)
+	if t, ok := s.frameLatency(RoleMatcher, res.FrameID, time.Now()); ok {
+		latency = t
+	}
+
+	s.statsMu.Lock()
+	s.stats.Matches++
+	s.stats.LastMatchLatency = latency
+	s.statsMu.Unlock()
+
+	if s.OnMatch != nil {
+		s.OnMatch(res, latency)
+	}
+}
+
 func (s *Server) removeClient(c *websocket.Conn) {
 	s.clientMu.Lock()
 	delete(s.clients, c)
@@ -248,10 +423,12 @@ func (s *Server) closeAll() {
 	for c := range s.clients {
 		c.CloseNow()
 	}
-	s.clients = make(map[*websocket.Conn]struct{})
+	s.clients = make(map[*websocket.Conn]Role)
 }
 
-// runLoop 从捕获源拉帧：中心裁剪 → 缩放 640×640 → JPEG → 广播。
+// runLoop 从捕获源拉帧：
+// 推理端：中心裁剪 → 缩放 640×640 → JPEG → 广播；
+// 观战端：全屏等比缩放 → 叠加层 → JPEG → 广播。
 func (s *Server) runLoop(ctx context.Context) {
 	log.Info().
 		Int("cropSize", s.cropSize).
@@ -260,6 +437,7 @@ func (s *Server) runLoop(ctx context.Context) {
 
 	cropImg := image.NewRGBA(image.Rect(0, 0, s.cropSize, s.cropSize))
 	resizeDst := image.NewRGBA(image.Rect(0, 0, s.cfg.InputSize, s.cfg.InputSize))
+	var spectatorDst *image.RGBA
 
 	interval := time.Second / time.Duration(s.cfg.Fps)
 	ticker := time.NewTicker(interval)
@@ -272,7 +450,8 @@ func (s *Server) runLoop(ctx context.Context) {
 		case <-ticker.C:
 		}
 
-		if !s.HasClients() {
+		inference, spectators, _ := s.countClients()
+		if inference == 0 && spectators == 0 {
 			// 无人观看时不推流，也不抬高捕获帧率（保持最低捕获成本）。
 			continue
 		}
@@ -285,45 +464,157 @@ func (s *Server) runLoop(ctx context.Context) {
 			continue
 		}
 
-		rgba := s.src.CloneRgba()
-		if rgba == nil {
-			continue
+		// 各角色独立：一方取帧或编码失败只跳过该角色本帧的发送
+		if inference != 0 {
+			s.sendInference(id, cropImg, resizeDst)
 		}
+		if spectators != 0 {
+			spectatorDst = s.sendSpectator(id, spectatorDst)
+		}
+
+		s.lastSent = id
+	}
+}
+
+// sendInference 推理帧：中心裁剪 → 缩放 → JPEG → 广播；cropImg 与 resizeDst 为复用的缓冲。
+func (s *Server) sendInference(id uint64, cropImg, resizeDst *image.RGBA) {
+	rgba := s.src.CloneRgba()
+	if rgba == nil {
+		return
+	}
+
+	var frame image.Image = rgba
+	if s.cropNeeded {
+		draw.Draw(cropImg, cropImg.Bounds(), rgba, s.cropOffset, draw.Src)
+		frame = cropImg
+	}
+
+	libyuv.ResizeRGBAInto(resizeDst, frame.(*image.RGBA), s.cfg.InputSize, s.cfg.InputSize)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, resizeDst, &jpeg.Options{Quality: s.cfg.JpegQuality}); err != nil {
+		log.Warn().Err(err).Msg("stream: jpeg encode failed")
+		return
+	}
+	s.Broadcast(uint32(id), buf.Bytes())
+}
 
-		var frame image.Image = rgba
-		if s.cropNeeded {
-			draw.Draw(cropImg, cropImg.Bounds(), rgba, s.cropOffset, draw.Src)
-			frame = cropImg
+// sendSpectator 观战帧：全屏等比缩放 → 叠加层 → JPEG → 广播；返回（可能重新分配的）缩放缓冲。
+func (s *Server) sendSpectator(id uint64, dst *image.RGBA) *image.RGBA {
+	// ResizeRGBAInto 会原地改写源帧，观战帧单独拷贝一份。
+	rgba := s.src.CloneRgba()
+	if rgba == nil {
+		return dst
+	}
+	size := spectatorSize(rgba.Bounds().Size(), s.cfg.SpectatorSize)
+	if dst == nil || dst.Bounds().Size() != size {
+		dst = image.NewRGBA(image.Rectangle{Max: size})
+	}
+	libyuv.ResizeRGBAInto(dst, rgba, size.X, size.Y)
+	s.paint(dst, rgba.Bounds().Size())
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: s.cfg.JpegQuality}); err != nil {
+		log.Warn().Err(err).Msg("stream: spectator jpeg encode failed")
+		return dst
+	}
+	s.broadcast(RoleSpectator, id, buf.Bytes())
+	return dst
+}
+
+// SubmitRoi 提交一帧 ROI 裁剪给远程匹配端（实现 matcher.Offloader）。
+// 不阻塞调用方：上一帧尚未发出时丢弃旧帧，只保留最新的。
+func (s *Server) SubmitRoi(frameID uint64, roi *image.RGBA) {
+	f := roiFrame{id: frameID, img: roi}
+	for {
+		select {
+		case s.roiCh <- f:
+			return
+		default:
+		}
+		select {
+		case <-s.roiCh:
+		default:
 		}
+	}
+}
 
-		libyuv.ResizeRGBAInto(resizeDst, frame.(*image.RGBA), s.cfg.InputSize, s.cfg.InputSize)
+// roiLoop 把 ROI 裁剪编码为 PNG（无损，避免压缩伪影影响匹配）后广播给远程匹配端。
+func (s *Server) roiLoop(ctx context.Context) {
+	var buf bytes.Buffer
+	for {
+		var f roiFrame
+		select {
+		case <-ctx.Done():
+			return
+		case f = <-s.roiCh:
+		}
 
-		var buf bytes.Buffer
-		if err := jpeg.Encode(&buf, resizeDst, &jpeg.Options{Quality: s.cfg.JpegQuality}); err != nil {
-			log.Warn().Err(err).Msg("stream: jpeg encode failed")
+		buf.Reset()
+		if err := png.Encode(&buf, f.img); err != nil {
+			log.Warn().Err(err).Msg("stream: roi png encode failed")
 			continue
 		}
+		s.broadcast(RoleMatcher, f.id, buf.Bytes())
+	}
+}
 
-		s.lastSent = id
-		s.Broadcast(uint32(id), buf.Bytes())
+// spectatorSize 把屏幕尺寸等比缩放到长边为 longEdge（不放大）。
+func spectatorSize(screen image.Point, longEdge int) image.Point {
+	long := max(screen.X, screen.Y)
+	if long <= longEdge || long == 0 {
+		return screen
 	}
+	return image.Pt(screen.X*longEdge/long, screen.Y*longEdge/long)
 }
 
-// Broadcast 按协议发送 [4B frame_id LE][JPEG]。
+// paint 在观战帧上依次执行已注册的叠加层（坐标为全屏屏幕坐标）。
+func (s *Server) paint(dst *image.RGBA, screen image.Point) {
+	s.paintMu.Lock()
+	painters := s.painters
+	s.paintMu.Unlock()
+
+	c := raster.NewCanvas(dst, screen, image.Point{})
+	for _, p := range painters {
+		p.Paint(c)
+	}
+}
+
+// Broadcast 按协议向推理端发送 [4B frame_id LE][JPEG]。
 func (s *Server) Broadcast(frameID uint32, jpegData []byte) {
+	s.broadcast(RoleInference, uint64(frameID), jpegData)
+}
+
+// broadcast 按协议向指定角色的客户端发送 [frame_id LE][JPEG/PNG]：
+// 远程匹配端为 8B 完整帧号，其余角色沿用 4B（帧号截断为 uint32）。
+// 观战端不回传结果，不记录帧时间戳。
+func (s *Server) broadcast(role Role, frameID uint64, jpegData []byte) {
 	s.clientMu.Lock()
 
-	n := len(s.clients)
+	n := 0
+	for _, r := range s.clients {
+		if r == role {
+			n++
+		}
+	}
 	if n == 0 {
 		s.clientMu.Unlock()
 		return
 	}
 
-	msg := make([]byte, 4+len(jpegData))
-	binary.LittleEndian.PutUint32(msg, frameID)
-	copy(msg[4:], jpegData)
+	var msg []byte
+	if role == RoleMatcher {
+		msg = binary.LittleEndian.AppendUint64(make([]byte, 0, 8+len(jpegData)), frameID)
+	} else {
+		frameID = uint64(uint32(frameID))
+		msg = binary.LittleEndian.AppendUint32(make([]byte, 0, 4+len(jpegData)), uint32(frameID))
+	}
+	msg = append(msg, jpegData...)
 
-	for c := range s.clients {
+	for c, r := range s.clients {
+		if r != role {
+			continue
+		}
 		writeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		err := c.Write(writeCtx, websocket.MessageBinary, msg)
 		cancel()
@@ -334,31 +625,40 @@ func (s *Server) Broadcast(frameID uint32, jpegData []byte) {
 	}
 	s.clientMu.Unlock()
 
-	s.statsMu.Lock()
-	s.stats.FramesSent += uint64(n)
-	s.stats.Fps, _ = s.fp.Count()
-	s.statsMu.Unlock()
-	s.recordSent(frameID)
+	switch role {
+	case RoleInference:
+		s.statsMu.Lock()
+		s.stats.FramesSent += uint64(n)
+		s.stats.Fps, _ = s.fp.Count()
+		s.statsMu.Unlock()
+	case RoleMatcher:
+		s.statsMu.Lock()
+		s.stats.RoisSent += uint64(n)
+		s.statsMu.Unlock()
+	default:
+		return
+	}
+	s.recordSent(role, frameID)
 }
 
-func (s *Server) recordSent(frameID uint32) {
+func (s *Server) recordSent(role Role, frameID uint64) {
 	now := time.Now()
 	s.sentMu.Lock()
 	if len(s.sentAt) > 256 {
-		for id, t := range s.sentAt {
+		for key, t := range s.sentAt {
 			if now.Sub(t) > 2*time.Second {
-				delete(s.sentAt, id)
+				delete(s.sentAt, key)
 			}
 		}
 	}
-	s.sentAt[frameID] = now
+	s.sentAt[sentKey{role, frameID}] = now
 	s.sentMu.Unlock()
 }
 
-func (s *Server) frameLatency(frameID uint32, now time.Time) (time.Duration, bool) {
+func (s *Server) frameLatency(role Role, frameID uint64, now time.Time) (time.Duration, bool) {
 	s.sentMu.Lock()
 	defer s.sentMu.Unlock()
-	t, ok := s.sentAt[frameID]
+	t, ok := s.sentAt[sentKey{role, frameID}]
 	if !ok {
 		return 0, false
 	}
@@ -1,12 +1,15 @@
 package sender_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
 	jsonv2 "encoding/json/v2"
 	"image"
 	"image/color"
 	"image/draw"
+	"image/jpeg"
+	"image/png"
 	"net"
 	"testing"
 	"time"
@@ -47,7 +50,6 @@ func TestWebSocketLifecycle(t *testing.T) {
 	capSrv := capturer.NewServer(
 		&fakeSource{bounds: image.Rect(0, 0, 1280, 720)},
 		capturer.Config{MinFps: 30, DisableOpenCV: true},
-		0,
 		nil,
 	)
 
@@ -67,6 +69,16 @@ func TestWebSocketLifecycle(t *testing.T) {
 	srv.OnResult = func(res sender.RemoteResult, _ time.Duration) {
 		resultCh <- res
 	}
+	type clientEvent struct {
+		remote    string
+		connected bool
+	}
+	clientCh := make(chan clientEvent, 2)
+	srv.OnClient = func(remote string, role sender.Role, connected bool) {
+		if role == sender.RoleInference {
+			clientCh <- clientEvent{remote, connected}
+		}
+	}
 
 	done := make(chan struct{})
 	go func() {
@@ -118,11 +130,24 @@ func TestWebSocketLifecycle(t *testing.T) {
 		t.Fatalf("write result: %v", err)
 	}
 
+	var joined clientEvent
+	select {
+	case joined = <-clientCh:
+		if !joined.connected || joined.remote == "" {
+			t.Fatalf("bad OnClient: %+v", joined)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnClient not called on connect")
+	}
+
 	select {
 	case got := <-resultCh:
 		if got.FrameID != uint64(frameID) || len(got.Detections) != 1 {
 			t.Fatalf("bad OnResult: %+v", got)
 		}
+		if got.Client != joined.remote {
+			t.Fatalf("result client = %q, want %q", got.Client, joined.remote)
+		}
 	case <-time.After(5 * time.Second):
 		t.Fatal("OnResult not called")
 	}
@@ -139,6 +164,14 @@ func TestWebSocketLifecycle(t *testing.T) {
 		}
 		time.Sleep(20 * time.Millisecond)
 	}
+	select {
+	case left := <-clientCh:
+		if left.connected || left.remote != joined.remote {
+			t.Fatalf("bad OnClient on disconnect: %+v", left)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnClient not called on disconnect")
+	}
 
 	// 5. 取消 ctx：Run 应退出，端口应释放
 	cancel()
@@ -153,3 +186,177 @@ func TestWebSocketLifecycle(t *testing.T) {
 		t.Fatal("port still listening after shutdown")
 	}
 }
+
+// TestSpectatorRole 观战端：收到叠加后的全屏帧，回传的结果被丢弃。
+func TestSpectatorRole(t *testing.T) {
+	const addr = "127.0.0.1:19092"
+
+	capSrv := capturer.NewServer(
+		&fakeSource{bounds: image.Rect(0, 0, 1920, 1080)},
+		capturer.Config{MinFps: 30, DisableOpenCV: true},
+		nil,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go capSrv.Run(ctx)
+	defer capSrv.Close()
+
+	srv := sender.NewServer(sender.Config{
+		Addr:          addr,
+		Fps:           30,
+		JpegQuality:   80,
+		SpectatorSize: 960,
+	}, capSrv)
+
+	resultCh := make(chan sender.RemoteResult, 1)
+	srv.OnResult = func(res sender.RemoteResult, _ time.Duration) {
+		resultCh <- res
+	}
+	go srv.Run(ctx)
+
+	var c *websocket.Conn
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var err error
+		c, _, err = websocket.Dial(context.Background(), "ws://"+addr+"/stream?role=spectator", nil)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("dial: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	defer c.CloseNow()
+
+	readCtx, readCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer readCancel()
+	mt, data, err := c.Read(readCtx)
+	if err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	if mt != websocket.MessageBinary || len(data) < 5 {
+		t.Fatalf("bad frame: mt=%v len=%d", mt, len(data))
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data[4:]))
+	if err != nil {
+		t.Fatalf("decode jpeg: %v", err)
+	}
+	if cfg.Width != 960 || cfg.Height != 540 {
+		t.Fatalf("spectator frame = %dx%d, want 960x540", cfg.Width, cfg.Height)
+	}
+	if s := srv.Stats(); s.Spectators != 1 || s.Clients != 0 {
+		t.Fatalf("stats clients=%d spectators=%d", s.Clients, s.Spectators)
+	}
+
+	payload, _ := jsonv2.Marshal(sender.RemoteResult{
+		FrameID:    uint64(binary.LittleEndian.Uint32(data[:4])),
+		Detections: []sender.RemoteDetection{{X2: 1, Y2: 1, Score: 0.9}},
+	})
+	writeCtx, writeCancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer writeCancel()
+	if err := c.Write(writeCtx, websocket.MessageText, payload); err != nil {
+		t.Fatalf("write result: %v", err)
+	}
+
+	select {
+	case got := <-resultCh:
+		t.Fatalf("spectator result accepted: %+v", got)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	bad, _, err := websocket.Dial(context.Background(), "ws://"+addr+"/stream?role=admin", nil)
+	if err == nil {
+		bad.CloseNow()
+		t.Fatal("unknown role accepted")
+	}
+}
+
+// TestMatcherRole 远程匹配端：收到 SubmitRoi 提交的 PNG 裁剪，回传结果触发 OnMatch。
+func TestMatcherRole(t *testing.T) {
+	const addr = "127.0.0.1:19093"
+
+	capSrv := capturer.NewServer(
+		&fakeSource{bounds: image.Rect(0, 0, 1280, 720)},
+		capturer.Config{MinFps: 30, DisableOpenCV: true},
+		nil,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go capSrv.Run(ctx)
+	defer capSrv.Close()
+
+	srv := sender.NewServer(sender.Config{Addr: addr}, capSrv)
+
+	matchCh := make(chan sender.RemoteMatch, 1)
+	srv.OnMatch = func(res sender.RemoteMatch, _ time.Duration) {
+		matchCh <- res
+	}
+	go srv.Run(ctx)
+
+	var c *websocket.Conn
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var err error
+		c, _, err = websocket.Dial(context.Background(), "ws://"+addr+"/stream?role=matcher", nil)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("dial: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	defer c.CloseNow()
+
+	for !srv.HasMatchers() {
+		if time.Now().After(deadline) {
+			t.Fatal("matcher client not registered")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	roi := image.NewRGBA(image.Rect(0, 0, 88, 104))
+	const frameID = 1<<32 + 42 // 超出 uint32 的帧号应完整往返
+	srv.SubmitRoi(frameID, roi)
+
+	readCtx, readCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer readCancel()
+	mt, data, err := c.Read(readCtx)
+	if err != nil {
+		t.Fatalf("read roi: %v", err)
+	}
+	if mt != websocket.MessageBinary || binary.LittleEndian.Uint64(data[:8]) != frameID {
+		t.Fatalf("bad roi frame: mt=%v len=%d", mt, len(data))
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(data[8:]))
+	if err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+	if cfg.Width != 88 || cfg.Height != 104 {
+		t.Fatalf("roi = %dx%d, want 88x104", cfg.Width, cfg.Height)
+	}
+
+	payload, _ := jsonv2.Marshal(sender.RemoteMatch{
+		FrameID: frameID, Found: true, Name: "9x19VSN", Confidence: 0.95,
+	})
+	writeCtx, writeCancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer writeCancel()
+	if err := c.Write(writeCtx, websocket.MessageText, payload); err != nil {
+		t.Fatalf("write match: %v", err)
+	}
+
+	select {
+	case got := <-matchCh:
+		if !got.Found || got.Name != "9x19VSN" || got.FrameID != frameID {
+			t.Fatalf("bad OnMatch: %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnMatch not called")
+	}
+	if s := srv.Stats(); s.Matchers != 1 || s.RoisSent != 1 || s.Matches != 1 {
+		t.Fatalf("stats matchers=%d rois=%d matches=%d", s.Matchers, s.RoisSent, s.Matches)
+	}
+}
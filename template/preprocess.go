@@ -0,0 +1,257 @@
+package template
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// 预处理步骤名，格式为 "步骤[:参数]"，以逗号串联，如 "gray,clahe:2,blur:3"
+const (
+	// 色彩空间，输入可为灰度、BGR 或 BGRA
+	PRE_GRAY  = "gray"
+	PRE_BGR   = "bgr"
+	PRE_HSV   = "hsv"
+	PRE_HSV_V = "hsv_v" // 只取 HSV 的明度通道
+	PRE_LAB_L = "lab_l" // 只取 Lab 的亮度通道
+
+	PRE_NORMALIZE = "normalize" // 亮度线性拉伸到 0~255
+	PRE_GAMMA     = "gamma"     // 参数为 gamma 值，>1 压暗高光
+	PRE_EQUALIZE  = "equalize"  // 直方图均衡化（逐通道）
+	PRE_CLAHE     = "clahe"     // 限制对比度的自适应均衡化（逐通道），参数为对比度限制
+	PRE_BLUR      = "blur"      // 高斯模糊，参数为核尺寸（奇数）
+	PRE_THRESHOLD = "threshold" // 二值化（逐通道），参数为阈值，0 为 Otsu
+)
+
+const (
+	PRE_CLAHE_CLIP_DEFAULT = 2.0
+	PRE_CLAHE_TILE         = 8
+	PRE_BLUR_DEFAULT       = 3
+)
+
+// Step 预处理的一步。
+type Step struct {
+	Op  string
+	Arg float64
+}
+
+func (s Step) String() string {
+	switch s.Op {
+	case PRE_GAMMA, PRE_CLAHE, PRE_BLUR, PRE_THRESHOLD:
+		return s.Op + ":" + strconv.FormatFloat(s.Arg, 'g', -1, 64)
+	}
+	return s.Op
+}
+
+// Preprocess 匹配前的预处理链，帧的 ROI 裁剪与模板按同一条链处理，不改变尺寸。
+type Preprocess []Step
+
+// ParsePreprocess 解析逗号分隔的预处理链，空字符串为不处理。
+func ParsePreprocess(s string) (Preprocess, error) {
+	var p Preprocess
+	for field := range strings.SplitSeq(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		op, argStr, hasArg := strings.Cut(field, ":")
+		step := Step{Op: op}
+		var arg float64
+		if hasArg {
+			var err error
+			arg, err = strconv.ParseFloat(argStr, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", field, err)
+			}
+		}
+
+		switch op {
+		case PRE_GRAY, PRE_BGR, PRE_HSV, PRE_HSV_V, PRE_LAB_L, PRE_NORMALIZE, PRE_EQUALIZE:
+			if hasArg {
+				return nil, fmt.Errorf("%s takes no argument", op)
+			}
+		case PRE_GAMMA:
+			if !hasArg || arg <= 0 {
+				return nil, fmt.Errorf("gamma must be > 0, got %q", argStr)
+			}
+			step.Arg = arg
+		case PRE_CLAHE:
+			step.Arg = PRE_CLAHE_CLIP_DEFAULT
+			if hasArg {
+				if arg <= 0 {
+					return nil, fmt.Errorf("clahe clip limit must be > 0, got %v", arg)
+				}
+				step.Arg = arg
+			}
+		case PRE_BLUR:
+			step.Arg = PRE_BLUR_DEFAULT
+			if hasArg {
+				if arg < 1 || arg != math.Trunc(arg) || int(arg)%2 == 0 {
+					return nil, fmt.Errorf("blur kernel size must be an odd integer, got %v", arg)
+				}
+				step.Arg = arg
+			}
+		case PRE_THRESHOLD:
+			if arg < 0 || arg > 255 {
+				return nil, fmt.Errorf("threshold must be within 0~255, got %v", arg)
+			}
+			step.Arg = arg
+		default:
+			return nil, fmt.Errorf("unknown preprocessing step %q", op)
+		}
+		p = append(p, step)
+	}
+	return p, nil
+}
+
+func (p Preprocess) String() string {
+	fields := make([]string, len(p))
+	for i, s := range p {
+		fields[i] = s.String()
+	}
+	return strings.Join(fields, ",")
+}
+
+// Apply 依次执行各步，结果写入 dst；空链时复制 src。
+func (p Preprocess) Apply(src gocv.Mat, dst *gocv.Mat) error {
+	if len(p) == 0 {
+		src.CopyTo(dst)
+		return nil
+	}
+	buf := [2]gocv.Mat{gocv.NewMat(), gocv.NewMat()}
+	defer buf[0].Close()
+	defer buf[1].Close()
+
+	cur := src
+	for i, s := range p {
+		out := &buf[i%2]
+		if err := s.apply(cur, out); err != nil {
+			return fmt.Errorf("%s: %w", s, err)
+		}
+		cur = *out
+	}
+	cur.CopyTo(dst)
+	return nil
+}
+
+func (s Step) apply(src gocv.Mat, dst *gocv.Mat) error {
+	switch s.Op {
+	case PRE_GRAY:
+		switch src.Channels() {
+		case 1:
+			src.CopyTo(dst)
+			return nil
+		case 4:
+			return gocv.CvtColor(src, dst, gocv.ColorBGRAToGray)
+		}
+		return gocv.CvtColor(src, dst, gocv.ColorBGRToGray)
+	case PRE_BGR:
+		return toBGR(src, dst)
+	case PRE_HSV:
+		return fromBGR(src, dst, gocv.ColorBGRToHSV, -1)
+	case PRE_HSV_V:
+		return fromBGR(src, dst, gocv.ColorBGRToHSV, 2)
+	case PRE_LAB_L:
+		return fromBGR(src, dst, gocv.ColorBGRToLab, 0)
+
+	case PRE_NORMALIZE:
+		gocv.Normalize(src, dst, 0, 255, gocv.NormMinMax)
+		return nil
+	case PRE_GAMMA:
+		lut := gocv.NewMatWithSize(1, 256, gocv.MatTypeCV8UC1)
+		defer lut.Close()
+		for i := range 256 {
+			v := math.Pow(float64(i)/255, s.Arg) * 255
+			lut.SetUCharAt(0, i, uint8(math.Round(v)))
+		}
+		gocv.LUT(src, lut, dst)
+		return nil
+	case PRE_EQUALIZE:
+		return perChannel(src, dst, func(src gocv.Mat, dst *gocv.Mat) error {
+			return gocv.EqualizeHist(src, dst)
+		})
+	case PRE_CLAHE:
+		clahe := gocv.NewCLAHEWithParams(s.Arg, image.Pt(PRE_CLAHE_TILE, PRE_CLAHE_TILE))
+		defer clahe.Close()
+		return perChannel(src, dst, func(src gocv.Mat, dst *gocv.Mat) error {
+			return clahe.Apply(src, dst)
+		})
+	case PRE_BLUR:
+		k := int(s.Arg)
+		return gocv.GaussianBlur(src, dst, image.Pt(k, k), 0, 0, gocv.BorderDefault)
+	case PRE_THRESHOLD:
+		typ := gocv.ThresholdBinary
+		if s.Arg == 0 {
+			typ |= gocv.ThresholdOtsu
+		}
+		return perChannel(src, dst, func(src gocv.Mat, dst *gocv.Mat) error {
+			gocv.Threshold(src, dst, float32(s.Arg), 255, typ)
+			return nil
+		})
+	}
+	return fmt.Errorf("unknown preprocessing step %q", s.Op)
+}
+
+func toBGR(src gocv.Mat, dst *gocv.Mat) error {
+	switch src.Channels() {
+	case 1:
+		return gocv.CvtColor(src, dst, gocv.ColorGrayToBGR)
+	case 4:
+		return gocv.CvtColor(src, dst, gocv.ColorBGRAToBGR)
+	}
+	src.CopyTo(dst)
+	return nil
+}
+
+// fromBGR 转为 BGR 后按 code 转换色彩空间；channel >= 0 时只保留该通道。
+func fromBGR(src gocv.Mat, dst *gocv.Mat, code gocv.ColorConversionCode, channel int) error {
+	bgr := gocv.NewMat()
+	defer bgr.Close()
+	if err := toBGR(src, &bgr); err != nil {
+		return err
+	}
+	if channel < 0 {
+		return gocv.CvtColor(bgr, dst, code)
+	}
+	converted := gocv.NewMat()
+	defer converted.Close()
+	if err := gocv.CvtColor(bgr, &converted, code); err != nil {
+		return err
+	}
+	channels := gocv.Split(converted)
+	defer func() {
+		for i := range channels {
+			channels[i].Close()
+		}
+	}()
+	channels[channel].CopyTo(dst)
+	return nil
+}
+
+// perChannel 对每个通道分别执行 fn 后合并，单通道直接执行。
+func perChannel(src gocv.Mat, dst *gocv.Mat, fn func(src gocv.Mat, dst *gocv.Mat) error) error {
+	if src.Channels() == 1 {
+		return fn(src, dst)
+	}
+	channels := gocv.Split(src)
+	defer func() {
+		for i := range channels {
+			channels[i].Close()
+		}
+	}()
+	for i := range channels {
+		out := gocv.NewMat()
+		if err := fn(channels[i], &out); err != nil {
+			out.Close()
+			return err
+		}
+		channels[i].Close()
+		channels[i] = out
+	}
+	gocv.Merge(channels, dst)
+	return nil
+}
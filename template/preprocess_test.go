@@ -0,0 +1,54 @@
+package template
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParsePreprocess(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Preprocess
+		str  string
+	}{
+		{"", nil, ""},
+		{"gray", Preprocess{{Op: PRE_GRAY}}, "gray"},
+		{" gray , clahe ,blur", Preprocess{{Op: PRE_GRAY}, {Op: PRE_CLAHE, Arg: 2}, {Op: PRE_BLUR, Arg: 3}}, "gray,clahe:2,blur:3"},
+		{"hsv_v,gamma:0.8,threshold", Preprocess{{Op: PRE_HSV_V}, {Op: PRE_GAMMA, Arg: 0.8}, {Op: PRE_THRESHOLD}}, "hsv_v,gamma:0.8,threshold:0"},
+		{"lab_l,normalize,equalize,threshold:128", Preprocess{{Op: PRE_LAB_L}, {Op: PRE_NORMALIZE}, {Op: PRE_EQUALIZE}, {Op: PRE_THRESHOLD, Arg: 128}}, "lab_l,normalize,equalize,threshold:128"},
+	} {
+		got, err := ParsePreprocess(tc.in)
+		if err != nil {
+			t.Errorf("ParsePreprocess(%q): %v", tc.in, err)
+			continue
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("ParsePreprocess(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+		if got.String() != tc.str {
+			t.Errorf("ParsePreprocess(%q).String() = %q, want %q", tc.in, got.String(), tc.str)
+		}
+		again, err := ParsePreprocess(got.String())
+		if err != nil || !slices.Equal(again, got) {
+			t.Errorf("round trip of %q = %v, %v", got.String(), again, err)
+		}
+	}
+}
+
+func TestParsePreprocessErrors(t *testing.T) {
+	for _, in := range []string{
+		"sharpen",
+		"gray:1",
+		"gamma",
+		"gamma:0",
+		"clahe:-1",
+		"blur:4",
+		"blur:2.5",
+		"threshold:300",
+		"blur:x",
+	} {
+		if p, err := ParsePreprocess(in); err == nil {
+			t.Errorf("ParsePreprocess(%q) = %v, want error", in, p)
+		}
+	}
+}
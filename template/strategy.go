@@ -0,0 +1,307 @@
+package template
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sync"
+
+	"gocv.io/x/gocv"
+)
+
+// 匹配策略名，用于模板元数据与 ROI 配置
+const (
+	STRATEGY_CCOEFF_NORMED = "ccoeff_normed"
+	STRATEGY_CCORR_NORMED  = "ccorr_normed"
+	STRATEGY_SQDIFF_NORMED = "sqdiff_normed"
+	STRATEGY_EDGE          = "edge"
+	STRATEGY_ORB           = "orb"
+)
+
+// 各策略的默认命中阈值（模板与 ROI 均未指定时）
+const (
+	THRESHOLD_TEMPLATE_MATCH = 0.9
+	// THRESHOLD_EDGE 边缘图稀疏，相关系数整体偏低
+	THRESHOLD_EDGE = 0.6
+	// THRESHOLD_ORB 通过比值检验的模板特征点比例
+	THRESHOLD_ORB = 0.5
+)
+
+const (
+	EDGE_CANNY_LOW  = 50
+	EDGE_CANNY_HIGH = 150
+	// ORB_RATIO Lowe 比值检验
+	ORB_RATIO = 0.75
+	// ORB_MIN_KEYPOINTS 模板特征点少于此数时无法可靠匹配；
+	// ORB 不在距边缘 31px 内取点，文字类的细长模板通常达不到，应使用其他策略
+	ORB_MIN_KEYPOINTS = 8
+)
+
+// Strategy 匹配策略：在 f 中寻找 scale 倍缩放后的 t，
+// 返回越大越相似的得分与模板在 f 中的位置；找不到时得分为 -1 且不视为错误。
+// 中间结果写入 sc，策略本身不得持有可变状态。
+type Strategy interface {
+	Name() string
+	DefaultThreshold() float32
+	Match(t *Template, f *Frame, scale float64, sc *Scratch) (score float32, box image.Rectangle, err error)
+}
+
+// Scratch 匹配的中间缓冲；不可并发使用，并行匹配时每个 worker 各持一个。
+type Scratch struct {
+	result gocv.Mat
+	minVal float32
+	minLoc image.Point
+}
+
+func NewScratch() *Scratch {
+	return &Scratch{result: gocv.NewMat()}
+}
+
+func (sc *Scratch) Close() error {
+	return sc.result.Close()
+}
+
+// scaleInvariant 可选：与缩放无关的策略在金字塔中只需匹配一次。
+type scaleInvariant interface {
+	ScaleInvariant() bool
+}
+
+// IsScaleInvariant 策略自身处理缩放（如特征点），无需逐个缩放倍率匹配。
+func IsScaleInvariant(s Strategy) bool {
+	si, ok := s.(scaleInvariant)
+	return ok && si.ScaleInvariant()
+}
+
+// DefaultStrategy 未指定策略时使用
+var DefaultStrategy = TemplateMatch(gocv.TmCcoeffNormed)
+
+// ParseStrategy 策略名转策略，空字符串为 DefaultStrategy。
+func ParseStrategy(name string) (Strategy, error) {
+	switch name {
+	case "", STRATEGY_CCOEFF_NORMED:
+		return DefaultStrategy, nil
+	case STRATEGY_CCORR_NORMED:
+		return TemplateMatch(gocv.TmCcorrNormed), nil
+	case STRATEGY_SQDIFF_NORMED:
+		return TemplateMatch(gocv.TmSqdiffNormed), nil
+	case STRATEGY_EDGE:
+		return EdgeMatch{}, nil
+	case STRATEGY_ORB:
+		return OrbMatch{}, nil
+	}
+	return nil, fmt.Errorf("unknown matching strategy %q", name)
+}
+
+// Frame 一帧待匹配的图像；各策略由其派生的数据（边缘图、特征点）按需计算并在同一帧的模板间共用，
+// 可被多个 goroutine 同时读取。
+type Frame struct {
+	Mat     gocv.Mat
+	derived derivedCache
+}
+
+// NewFrame 不接管 mat，Close 只释放派生数据。
+func NewFrame(mat gocv.Mat) *Frame {
+	return &Frame{Mat: mat}
+}
+
+func (f *Frame) Close() {
+	f.derived.close()
+}
+
+// derivedCache 按键缓存的派生数据与对应的释放函数，并发安全。
+type derivedCache struct {
+	mu      sync.Mutex
+	values  map[string]any
+	closers []func()
+}
+
+func (c *derivedCache) get(key string, compute func() (any, func(), error)) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.values[key]; ok {
+		return v, nil
+	}
+	v, closer, err := compute()
+	if err != nil {
+		return nil, err
+	}
+	if c.values == nil {
+		c.values = make(map[string]any)
+	}
+	c.values[key] = v
+	if closer != nil {
+		c.closers = append(c.closers, closer)
+	}
+	return v, nil
+}
+
+func (c *derivedCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, fn := range c.closers {
+		fn()
+	}
+	c.closers = c.closers[:0]
+	clear(c.values)
+}
+
+// templateMatch OpenCV 模板匹配，SQDIFF 类方法按 1-最小值换算为越大越相似。
+type templateMatch struct {
+	mode gocv.TemplateMatchMode
+}
+
+// TemplateMatch 以 OpenCV 模板匹配方法 mode 匹配。
+func TemplateMatch(mode gocv.TemplateMatchMode) Strategy {
+	return templateMatch{mode: mode}
+}
+
+func (s templateMatch) Name() string {
+	switch s.mode {
+	case gocv.TmCcoeffNormed:
+		return STRATEGY_CCOEFF_NORMED
+	case gocv.TmCcorrNormed:
+		return STRATEGY_CCORR_NORMED
+	case gocv.TmSqdiffNormed:
+		return STRATEGY_SQDIFF_NORMED
+	}
+	return fmt.Sprintf("template_match(%d)", s.mode)
+}
+
+func (templateMatch) DefaultThreshold() float32 { return THRESHOLD_TEMPLATE_MATCH }
+
+func (s templateMatch) Match(t *Template, f *Frame, scale float64, sc *Scratch) (float32, image.Rectangle, error) {
+	mat, mask, size := t.at(scale)
+	return sc.matchTemplate(f.Mat, mat, mask, size, s.mode)
+}
+
+// matchTemplate 在 img 上匹配 tmpl；tmpl 比 img 大时返回 -1。
+func (sc *Scratch) matchTemplate(img, tmpl, mask gocv.Mat, size image.Point, mode gocv.TemplateMatchMode) (float32, image.Rectangle, error) {
+	if size.X == 0 || size.Y == 0 || size.X > img.Cols() || size.Y > img.Rows() {
+		return -1, image.Rectangle{}, nil
+	}
+	if err := gocv.MatchTemplate(img, tmpl, &sc.result, mode, mask); err != nil {
+		return -1, image.Rectangle{}, err
+	}
+	minVal, maxVal, minLoc, maxLoc := gocv.MinMaxLoc(sc.result)
+	if v := float64(minVal); math.IsNaN(v) || math.IsInf(v, 0) {
+		minVal = 1.0
+	}
+	if v := float64(maxVal); math.IsNaN(v) || math.IsInf(v, 0) {
+		maxVal = -1.0
+	}
+	sc.minVal, sc.minLoc = minVal, minLoc
+	score, loc := maxVal, maxLoc
+	if mode == gocv.TmSqdiffNormed {
+		score, loc = 1-minVal, minLoc
+	}
+	return score, image.Rectangle{loc, loc.Add(size)}, nil
+}
+
+// EdgeMatch 在膨胀后的 Canny 边缘图上做相关系数匹配，
+// 只看轮廓，不受 HUD 配色、透明度与背景亮度变化影响。
+type EdgeMatch struct{}
+
+func (EdgeMatch) Name() string              { return STRATEGY_EDGE }
+func (EdgeMatch) DefaultThreshold() float32 { return THRESHOLD_EDGE }
+
+func (EdgeMatch) Match(t *Template, f *Frame, scale float64, sc *Scratch) (float32, image.Rectangle, error) {
+	img, err := f.derived.get(STRATEGY_EDGE, func() (any, func(), error) {
+		return edgeMat(f.Mat)
+	})
+	if err != nil {
+		return -1, image.Rectangle{}, err
+	}
+	mat, mask, size := t.at(scale)
+	tmpl, err := t.derived.get(fmt.Sprintf("%s@%d", STRATEGY_EDGE, scaleKey(scale)), func() (any, func(), error) {
+		return edgeMat(mat)
+	})
+	if err != nil {
+		return -1, image.Rectangle{}, err
+	}
+	return sc.matchTemplate(img.(gocv.Mat), tmpl.(gocv.Mat), mask, size, gocv.TmCcoeffNormed)
+}
+
+// edgeMat 灰度 → Canny → 3×3 膨胀，容忍 1px 的错位。
+func edgeMat(src gocv.Mat) (any, func(), error) {
+	gray := src
+	if src.Channels() > 1 {
+		gray = gocv.NewMat()
+		defer gray.Close()
+		if err := gocv.CvtColor(src, &gray, gocv.ColorBGRToGray); err != nil {
+			return nil, nil, err
+		}
+	}
+	edges := gocv.NewMat()
+	if err := gocv.Canny(gray, &edges, EDGE_CANNY_LOW, EDGE_CANNY_HIGH); err != nil {
+		edges.Close()
+		return nil, nil, err
+	}
+	kernel := gocv.GetStructuringElement(gocv.MorphRect, image.Pt(3, 3))
+	defer kernel.Close()
+	if err := gocv.Dilate(edges, &edges, kernel); err != nil {
+		edges.Close()
+		return nil, nil, err
+	}
+	return edges, func() { edges.Close() }, nil
+}
+
+// OrbMatch ORB 特征点匹配，适用于会缩放或旋转的图标；
+// 得分为通过比值检验的模板特征点比例，位置为匹配点在帧中的外接矩形。
+type OrbMatch struct{}
+
+func (OrbMatch) Name() string              { return STRATEGY_ORB }
+func (OrbMatch) DefaultThreshold() float32 { return THRESHOLD_ORB }
+func (OrbMatch) ScaleInvariant() bool      { return true }
+
+type orbFeatures struct {
+	keypoints []gocv.KeyPoint
+	desc      gocv.Mat
+}
+
+func (OrbMatch) Match(t *Template, f *Frame, scale float64, _ *Scratch) (float32, image.Rectangle, error) {
+	v, err := t.derived.get(STRATEGY_ORB, func() (any, func(), error) {
+		return orbDetect(t.Mat, t.mask)
+	})
+	if err != nil {
+		return -1, image.Rectangle{}, err
+	}
+	tf := v.(*orbFeatures)
+	if len(tf.keypoints) < ORB_MIN_KEYPOINTS {
+		return -1, image.Rectangle{}, fmt.Errorf("template has %d keypoints, need at least %d", len(tf.keypoints), ORB_MIN_KEYPOINTS)
+	}
+
+	v, err = f.derived.get(STRATEGY_ORB, func() (any, func(), error) {
+		noMask := gocv.NewMat()
+		defer noMask.Close()
+		return orbDetect(f.Mat, noMask)
+	})
+	if err != nil {
+		return -1, image.Rectangle{}, err
+	}
+	ff := v.(*orbFeatures)
+	if len(ff.keypoints) < 2 {
+		return -1, image.Rectangle{}, nil
+	}
+
+	bf := gocv.NewBFMatcherWithParams(gocv.NormHamming, false)
+	defer bf.Close()
+	var box image.Rectangle
+	good := 0
+	for _, m := range bf.KnnMatch(tf.desc, ff.desc, 2) {
+		if len(m) == 0 || (len(m) > 1 && m[0].Distance >= ORB_RATIO*m[1].Distance) {
+			continue
+		}
+		kp := ff.keypoints[m[0].TrainIdx]
+		pt := image.Pt(int(kp.X), int(kp.Y))
+		box = box.Union(image.Rectangle{pt, pt.Add(image.Pt(1, 1))})
+		good++
+	}
+	return float32(good) / float32(len(tf.keypoints)), box, nil
+}
+
+func orbDetect(img, mask gocv.Mat) (any, func(), error) {
+	orb := gocv.NewORB()
+	defer orb.Close()
+	keypoints, desc := orb.DetectAndCompute(img, mask)
+	return &orbFeatures{keypoints: keypoints, desc: desc}, func() { desc.Close() }, nil
+}
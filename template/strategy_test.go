@@ -0,0 +1,76 @@
+package template
+
+import (
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestParseStrategy(t *testing.T) {
+	for _, name := range []string{STRATEGY_CCOEFF_NORMED, STRATEGY_CCORR_NORMED, STRATEGY_SQDIFF_NORMED, STRATEGY_EDGE, STRATEGY_ORB} {
+		s, err := ParseStrategy(name)
+		if err != nil {
+			t.Fatalf("ParseStrategy(%q): %v", name, err)
+		}
+		if s.Name() != name {
+			t.Errorf("ParseStrategy(%q).Name() = %q", name, s.Name())
+		}
+	}
+	if s, err := ParseStrategy(""); err != nil || s != DefaultStrategy {
+		t.Errorf("ParseStrategy(\"\") = %v, %v, want DefaultStrategy", s, err)
+	}
+	if _, err := ParseStrategy("sift"); err == nil {
+		t.Error("ParseStrategy(\"sift\") succeeded")
+	}
+}
+
+func TestIsScaleInvariant(t *testing.T) {
+	if IsScaleInvariant(DefaultStrategy) || IsScaleInvariant(EdgeMatch{}) {
+		t.Error("template and edge matching must be run per scale")
+	}
+	if !IsScaleInvariant(OrbMatch{}) {
+		t.Error("ORB should be scale invariant")
+	}
+}
+
+func TestDerivedCache(t *testing.T) {
+	var c derivedCache
+	computed, closed := 0, 0
+	compute := func() (any, func(), error) {
+		computed++
+		return computed, func() { closed++ }, nil
+	}
+	for range 3 {
+		v, err := c.get("k", compute)
+		if err != nil || v.(int) != 1 {
+			t.Fatalf("get = %v, %v, want 1", v, err)
+		}
+	}
+	c.close()
+	if closed != 1 {
+		t.Errorf("closed %d times, want 1", closed)
+	}
+	if v, _ := c.get("k", compute); v.(int) != 2 {
+		t.Errorf("get after close = %v, want recomputed 2", v)
+	}
+	c.close()
+}
+
+func TestMatchWithTemplateTooLarge(t *testing.T) {
+	var tmpl Template
+	tmpl.Mat = gocv.NewMatWithSize(8, 8, gocv.MatTypeCV8UC1)
+	tmpl.mask = gocv.NewMat()
+	tmpl.Width, tmpl.Height = 8, 8
+	defer tmpl.Close()
+
+	img := gocv.NewMatWithSize(4, 4, gocv.MatTypeCV8UC1)
+	defer img.Close()
+	f := NewFrame(img)
+	defer f.Close()
+	if err := tmpl.MatchWith(DefaultStrategy, f, 1); err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.MaxVal != -1 {
+		t.Errorf("MaxVal = %v, want -1", tmpl.MaxVal)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"image"
 	"math"
+	"slices"
+	"sync"
 	"time"
 
 	"gocv.io/x/gocv"
@@ -16,17 +18,35 @@ type Template struct {
 	Width, Height int
 
 	mask gocv.Mat
+	// pre 已应用于 Mat 的预处理链，非空时 orig 为处理前的图像
+	pre  Preprocess
+	orig gocv.Mat
+	// scaled 按缩放倍率（千分比取整）缓存的缩放后模板与掩码，多个 goroutine 可能同时 Eval
+	scaledMu sync.Mutex
+	scaled   map[int]*scaledMat
 
-	result         gocv.Mat
+	// scratch MatchWith 使用的匹配缓冲
+	scratch        *Scratch
 	Cost           time.Duration
 	MinVal, MaxVal float32
 	MinLoc, MaxLoc image.Point
+	// Scale 最近一次匹配使用的缩放倍率，MaxLoc 与 Box 均按此倍率
+	Scale float64
+	box   image.Rectangle
+
+	// derived 各匹配策略由模板派生的数据（边缘图、特征点等）
+	derived derivedCache
+}
+
+type scaledMat struct {
+	mat, mask     gocv.Mat
+	width, height int
 }
 
 func (t *Template) IMReadFrom(path string, createMask bool, flag gocv.IMReadFlag) error {
 	t.Close()
 	t.mask = gocv.NewMat()
-	t.result = gocv.NewMat()
+	t.scratch = NewScratch()
 
 	if !createMask {
 		t.Mat = gocv.IMRead(path, flag)
@@ -76,9 +96,15 @@ func (t *Template) Close() error {
 	if !t.mask.Closed() && !t.mask.Empty() {
 		err2 = t.mask.Close()
 	}
-	if !t.result.Closed() && !t.mask.Empty() {
-		err3 = t.result.Close()
+	if t.scratch != nil {
+		err3 = t.scratch.Close()
+		t.scratch = nil
+	}
+	if len(t.pre) > 0 {
+		t.orig.Close()
+		t.pre = nil
 	}
+	t.closeScaled()
 
 	if err1 != nil {
 		return err1
@@ -92,25 +118,189 @@ func (t *Template) Close() error {
 	return nil
 }
 
+// SetPreprocess 以预处理链 p 重新处理模板（总是基于读取时的原图），
+// 缩放与各策略的派生缓存随之失效；p 为空时恢复原图。Raw 始终为原图。
+func (t *Template) SetPreprocess(p Preprocess) error {
+	if slices.Equal(p, t.pre) {
+		return nil
+	}
+	src := t.Mat
+	if len(t.pre) > 0 {
+		src = t.orig
+	}
+
+	if len(p) == 0 {
+		t.Mat.Close()
+		t.Mat, t.pre = t.orig, nil
+	} else {
+		dst := gocv.NewMat()
+		if err := p.Apply(src, &dst); err != nil {
+			dst.Close()
+			return err
+		}
+		if dst.Cols() != t.Width || dst.Rows() != t.Height {
+			dst.Close()
+			return fmt.Errorf("preprocessing changed template size to %dx%d", dst.Cols(), dst.Rows())
+		}
+		if len(t.pre) == 0 {
+			t.orig = t.Mat
+		} else {
+			t.Mat.Close()
+		}
+		t.Mat, t.pre = dst, slices.Clone(p)
+	}
+	t.Channels = t.Mat.Channels()
+	t.closeScaled()
+	return nil
+}
+
+// Preprocessed 当前应用于模板的预处理链。
+func (t *Template) Preprocessed() Preprocess {
+	return t.pre
+}
+
 func (t *Template) Match(image gocv.Mat, method gocv.TemplateMatchMode) error {
+	return t.MatchScaled(image, method, 1)
+}
+
+// MatchScaled 以 scale 倍缩放后的模板按 OpenCV 模板匹配方法匹配，见 MatchWith。
+func (t *Template) MatchScaled(img gocv.Mat, method gocv.TemplateMatchMode, scale float64) error {
+	f := NewFrame(img)
+	defer f.Close()
+	return t.MatchWith(TemplateMatch(method), f, scale)
+}
+
+// MatchWith 以策略 s 在 f 中匹配 scale 倍缩放后的模板（缩放结果按倍率缓存），
+// 结果写入 MaxVal、MaxLoc 与 Box。缩放后比图像大时不匹配，MaxVal 置为 -1；出错时同样为 -1。
+func (t *Template) MatchWith(s Strategy, f *Frame, scale float64) error {
+	if t.scratch == nil {
+		t.scratch = NewScratch()
+	}
+	return t.MatchWithScratch(s, f, scale, t.scratch)
+}
+
+// MatchWithScratch 同 MatchWith，使用调用方的匹配缓冲 sc。
+func (t *Template) MatchWithScratch(s Strategy, f *Frame, scale float64, sc *Scratch) error {
+	r, err := t.Eval(s, f, scale, sc)
+	t.Record(r)
+	return err
+}
+
+// Result 一次匹配的结果，Box 相对匹配输入的左上角。
+type Result struct {
+	Score float32
+	Box   image.Rectangle
+	Scale float64
+	Cost  time.Duration
+	// MinVal、MinLoc 仅 OpenCV 模板匹配有效，其余策略为 1 与零值
+	MinVal float32
+	MinLoc image.Point
+}
+
+// Eval 匹配但不写入模板上记录的结果。
+// 可在多个 goroutine 中并发 Eval（各用各的 sc，f 可共用），同一模板也可以（如多个 ROI 共用注册表）；
+// Record、MatchWith 会写入模板，同一模板不可并发。
+func (t *Template) Eval(s Strategy, f *Frame, scale float64, sc *Scratch) (Result, error) {
 	tStart := time.Now()
-	err := gocv.MatchTemplate(image, t.Mat, &t.result, method, t.mask)
-	t.Cost = time.Since(tStart)
+	sc.minVal, sc.minLoc = 1.0, image.Point{}
+	score, box, err := s.Match(t, f, scale, sc)
+	r := Result{Score: score, Box: box, Scale: scale, Cost: time.Since(tStart), MinVal: sc.minVal, MinLoc: sc.minLoc}
 	if err != nil {
-		return err
+		r.Score, r.Box = -1.0, image.Rectangle{}
+		return r, fmt.Errorf("%s: %w", s.Name(), err)
 	}
-	t.minMaxLoc()
-	return nil
+	return r, nil
+}
+
+// Record 把 r 记为最近一次匹配的结果。
+func (t *Template) Record(r Result) {
+	t.Scale, t.Cost = r.Scale, r.Cost
+	t.MinVal, t.MinLoc = r.MinVal, r.MinLoc
+	t.MaxVal, t.MaxLoc, t.box = r.Score, r.Box.Min, r.Box
+}
+
+// at 返回 scale 倍缩放后的模板、掩码与尺寸。
+func (t *Template) at(scale float64) (mat, mask gocv.Mat, size image.Point) {
+	if scaleKey(scale) == scaleKey(1) {
+		return t.Mat, t.mask, image.Point{t.Width, t.Height}
+	}
+	s := t.scaledAt(scale)
+	return s.mat, s.mask, image.Point{s.width, s.height}
+}
+
+// MaskCoverage 掩码中有效像素的比例；未使用掩码时返回 -1，尺寸与模板不符时返回 0。
+func (t *Template) MaskCoverage() float64 {
+	if t.mask.Empty() {
+		return -1
+	}
+	if t.mask.Cols() != t.Width || t.mask.Rows() != t.Height || t.Width*t.Height == 0 {
+		return 0
+	}
+	return float64(gocv.CountNonZero(t.mask)) / float64(t.Width*t.Height)
+}
+
+// Size 按最近一次匹配的缩放倍率换算的模板尺寸。
+func (t *Template) Size() image.Point {
+	if t.Scale == 0 || scaleKey(t.Scale) == scaleKey(1) {
+		return image.Point{t.Width, t.Height}
+	}
+	return image.Point{scaledLen(t.Width, t.Scale), scaledLen(t.Height, t.Scale)}
 }
 
-func (t *Template) minMaxLoc() {
-	t.MinVal, t.MaxVal, t.MinLoc, t.MaxLoc = gocv.MinMaxLoc(t.result)
-	minVal := float64(t.MinVal)
-	if math.IsNaN(minVal) || math.IsInf(minVal, 0) {
-		t.MinVal = 1.0
+// Box 最近一次匹配的最佳位置（相对匹配输入的左上角）。
+func (t *Template) Box() image.Rectangle {
+	if !t.box.Empty() {
+		return t.box
 	}
-	maxVal := float64(t.MaxVal)
-	if math.IsNaN(maxVal) || math.IsInf(maxVal, 0) {
-		t.MaxVal = -1.0
+	return image.Rectangle{t.MaxLoc, t.MaxLoc.Add(t.Size())}
+}
+
+func scaleKey(scale float64) int {
+	return int(math.Round(scale * 1000))
+}
+
+func scaledLen(n int, scale float64) int {
+	return max(1, int(math.Round(float64(n)*scale)))
+}
+
+func (t *Template) scaledAt(scale float64) *scaledMat {
+	key := scaleKey(scale)
+	t.scaledMu.Lock()
+	defer t.scaledMu.Unlock()
+	if s, ok := t.scaled[key]; ok {
+		return s
+	}
+	if t.scaled == nil {
+		t.scaled = make(map[int]*scaledMat)
+	}
+
+	s := &scaledMat{
+		mat:    gocv.NewMat(),
+		mask:   gocv.NewMat(),
+		width:  scaledLen(t.Width, scale),
+		height: scaledLen(t.Height, scale),
+	}
+	// 缩小用 INTER_AREA 保留细节，放大用线性插值；掩码用最近邻保持二值
+	interp := gocv.InterpolationArea
+	if scale > 1 {
+		interp = gocv.InterpolationLinear
+	}
+	size := image.Point{s.width, s.height}
+	gocv.Resize(t.Mat, &s.mat, size, 0, 0, interp)
+	if !t.mask.Empty() {
+		gocv.Resize(t.mask, &s.mask, size, 0, 0, gocv.InterpolationNearestNeighbor)
+	}
+	t.scaled[key] = s
+	return s
+}
+
+func (t *Template) closeScaled() {
+	t.scaledMu.Lock()
+	defer t.scaledMu.Unlock()
+	for _, s := range t.scaled {
+		s.mat.Close()
+		s.mask.Close()
 	}
+	clear(t.scaled)
+	t.derived.close()
 }
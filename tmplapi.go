@@ -0,0 +1,318 @@
+package main
+
+import (
+	_ "embed"
+	jsonv2 "encoding/json/v2"
+	"errors"
+	"image/png"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Miuzarte/GoCVStreamer/matcher"
+	"github.com/Miuzarte/GoCVStreamer/weapon"
+	ws "github.com/Miuzarte/GoCVStreamer/weapons"
+)
+
+//go:embed tmplapi.html
+var templatesHtml []byte
+
+// MAX_TEMPLATE_UPLOAD 上传的模板图片大小上限
+const MAX_TEMPLATE_UPLOAD = 8 << 20
+
+// TemplateInfo 模板库列表中的一项。
+type TemplateInfo struct {
+	ID        weapon.ID `json:"id"`
+	Name      string    `json:"name"`
+	Class     string    `json:"class"`
+	File      string    `json:"file"` // 相对模板目录
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	SpeedMain float64   `json:"speed_main"`
+	SpeedAlt  float64   `json:"speed_alt"`
+	// Score 该 ROI 最近一次匹配该模板的得分，未匹配过时省略
+	Score   *float32 `json:"score,omitzero"`
+	ScoreMs int64    `json:"score_ms,omitzero"`
+}
+
+// TemplateList GET /templates/api/templates 的返回。
+type TemplateList struct {
+	Roi       string         `json:"roi"`
+	Dir       string         `json:"dir"`
+	Templates []TemplateInfo `json:"templates"`
+	// Failed 加载失败的文件（相对模板目录）-> 错误
+	Failed map[string]string `json:"failed,omitzero"`
+}
+
+// TemplateUpdate PATCH 请求体，省略的字段不修改。
+type TemplateUpdate struct {
+	Name  string `json:"name,omitzero"`
+	Class string `json:"class,omitzero"`
+}
+
+// TemplateRemoved DELETE 的返回：移动后（带忽略前缀）的文件，相对模板目录。
+type TemplateRemoved struct {
+	File string `json:"file"`
+}
+
+// TemplateSync POST /templates/api/reload 的返回，路径相对模板目录。
+type TemplateSync struct {
+	Added    []string          `json:"added,omitzero"`
+	Reloaded []string          `json:"reloaded,omitzero"`
+	Renamed  map[string]string `json:"renamed,omitzero"`
+	Removed  []string          `json:"removed,omitzero"`
+	Failed   map[string]string `json:"failed,omitzero"`
+}
+
+// requestLibrary roi 参数指定的 ROI 及其模板目录的管理操作；热重载未启动时回复 503。
+func requestLibrary(w http.ResponseWriter, r *http.Request) (*matcher.Engine, *ws.Library, bool) {
+	e, ok := requestRoi(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+	librariesMu.Lock()
+	lib, ok := templateLibraries[filepath.Clean(e.Templates())]
+	librariesMu.Unlock()
+	if !ok {
+		http.Error(w, "template hot reload not running", http.StatusServiceUnavailable)
+		return nil, nil, false
+	}
+	return e, lib, true
+}
+
+// requestTemplateID 路径中的模板 ID。
+func requestTemplateID(w http.ResponseWriter, r *http.Request) (weapon.ID, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid template id", http.StatusBadRequest)
+		return weapon.ID_NONE, false
+	}
+	return weapon.ID(id), true
+}
+
+// requestTemplate 路径中的模板 ID 对应的模板。
+func requestTemplate(w http.ResponseWriter, r *http.Request, lib *ws.Library) (*weapon.Weapon, bool) {
+	id, ok := requestTemplateID(w, r)
+	if !ok {
+		return nil, false
+	}
+	wp, ok := lib.Registry().Get(id)
+	if !ok {
+		http.Error(w, ws.ErrTemplateNotFound.Error(), http.StatusNotFound)
+	}
+	return wp, ok
+}
+
+func relPath(dir, path string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+func relPaths(dir string, paths []string) []string {
+	rel := make([]string, len(paths))
+	for i, p := range paths {
+		rel[i] = relPath(dir, p)
+	}
+	return rel
+}
+
+func templateInfo(lib *ws.Library, wp *weapon.Weapon, scores map[weapon.ID]matcher.Score) TemplateInfo {
+	info := TemplateInfo{
+		ID:        wp.ID,
+		Name:      wp.Name,
+		Class:     wp.Class.ToString(true),
+		File:      relPath(lib.Dir(), wp.Path),
+		Width:     wp.Width,
+		Height:    wp.Height,
+		SpeedMain: wp.SpeedMain,
+		SpeedAlt:  wp.SpeedAlt,
+	}
+	if s, ok := scores[wp.ID]; ok {
+		info.Score = &s.Score
+		info.ScoreMs = s.Time.UnixMilli()
+	}
+	return info
+}
+
+// writeLibraryError 按错误类型回复：重名 409，找不到 404，其余视为请求错误。
+func writeLibraryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, weapon.ErrTemplateExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ws.ErrTemplateNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	}
+}
+
+// registerTemplateHandlers 模板库管理页面与 API；roi 参数选择 ROI（即其模板目录），缺省为主 ROI。
+// 变更经 weapons.Library 逐个执行并立即同步到匹配器使用的注册表。
+//
+//	GET    /templates/                           管理页面
+//	GET    /templates/api/classes                类别短名
+//	GET    /templates/api/templates              模板列表（含最近得分）
+//	GET    /templates/api/templates/{id}/image   模板图片
+//	POST   /templates/api/templates?name=&class=&speed_main=&speed_alt=  上传 PNG（请求体）
+//	PATCH  /templates/api/templates/{id}         重命名、改类别 {"name","class"}
+//	DELETE /templates/api/templates/{id}         删除（文件名加忽略前缀）
+//	POST   /templates/api/reload                 对账整个目录
+func registerTemplateHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("GET /templates/{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(templatesHtml)
+	})
+	mux.HandleFunc("GET /templates/api/classes", func(w http.ResponseWriter, r *http.Request) {
+		classes := weapon.ActiveCatalog().Classes()
+		names := make([]string, 0, len(classes))
+		for _, c := range classes {
+			names = append(names, c.ShortName)
+		}
+		writeJsonResponse(w, http.StatusOK, names)
+	})
+	mux.HandleFunc("GET /templates/api/templates", func(w http.ResponseWriter, r *http.Request) {
+		e, lib, ok := requestLibrary(w, r)
+		if !ok {
+			return
+		}
+		scores := e.Scores()
+		list := TemplateList{Roi: e.Name(), Dir: lib.Dir(), Templates: []TemplateInfo{}}
+		for _, wp := range lib.Registry().List() {
+			list.Templates = append(list.Templates, templateInfo(lib, wp, scores))
+		}
+		for path, err := range lib.Failed() {
+			if list.Failed == nil {
+				list.Failed = make(map[string]string)
+			}
+			list.Failed[relPath(lib.Dir(), path)] = err.Error()
+		}
+		writeJsonResponse(w, http.StatusOK, list)
+	})
+	mux.HandleFunc("GET /templates/api/templates/{id}/image", func(w http.ResponseWriter, r *http.Request) {
+		_, lib, ok := requestLibrary(w, r)
+		if !ok {
+			return
+		}
+		wp, ok := requestTemplate(w, r, lib)
+		if !ok {
+			return
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeFile(w, r, wp.Path)
+	})
+	mux.HandleFunc("POST /templates/api/templates", func(w http.ResponseWriter, r *http.Request) {
+		e, lib, ok := requestLibrary(w, r)
+		if !ok {
+			return
+		}
+		q := r.URL.Query()
+		m := weapon.Meta{
+			Name:     q.Get("name"),
+			Class:    q.Get("class"),
+			SpeedAlt: q.Get("speed_alt"),
+		}
+		if v := q.Get("speed_main"); v != "" {
+			speed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				http.Error(w, "invalid speed_main", http.StatusBadRequest)
+				return
+			}
+			m.SpeedMain = speed
+		}
+		img, err := png.Decode(io.LimitReader(r.Body, MAX_TEMPLATE_UPLOAD))
+		if err != nil {
+			http.Error(w, "invalid PNG: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		path, err := lib.Add(img, m)
+		if err != nil {
+			writeLibraryError(w, err)
+			return
+		}
+		wp, ok := lib.Registry().Get(lib.Registry().IDByPath(path))
+		if !ok {
+			http.Error(w, "template written but not loaded", http.StatusInternalServerError)
+			return
+		}
+		log.Info().
+			Str("path", path).
+			Msg("template uploaded")
+		writeJsonResponse(w, http.StatusCreated, templateInfo(lib, wp, e.Scores()))
+	})
+	mux.HandleFunc("PATCH /templates/api/templates/{id}", func(w http.ResponseWriter, r *http.Request) {
+		e, lib, ok := requestLibrary(w, r)
+		if !ok {
+			return
+		}
+		id, ok := requestTemplateID(w, r)
+		if !ok {
+			return
+		}
+		var update TemplateUpdate
+		if err := jsonv2.UnmarshalRead(io.LimitReader(r.Body, 1<<20), &update, jsonv2.RejectUnknownMembers(true)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := lib.Update(id, update.Name, update.Class); err != nil {
+			writeLibraryError(w, err)
+			return
+		}
+		updated, ok := lib.Registry().Get(id)
+		if !ok {
+			http.Error(w, "template updated but not loaded", http.StatusInternalServerError)
+			return
+		}
+		writeJsonResponse(w, http.StatusOK, templateInfo(lib, updated, e.Scores()))
+	})
+	mux.HandleFunc("DELETE /templates/api/templates/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, lib, ok := requestLibrary(w, r)
+		if !ok {
+			return
+		}
+		id, ok := requestTemplateID(w, r)
+		if !ok {
+			return
+		}
+		path, err := lib.Remove(id)
+		if err != nil {
+			writeLibraryError(w, err)
+			return
+		}
+		writeJsonResponse(w, http.StatusOK, TemplateRemoved{File: relPath(lib.Dir(), path)})
+	})
+	mux.HandleFunc("POST /templates/api/reload", func(w http.ResponseWriter, r *http.Request) {
+		_, lib, ok := requestLibrary(w, r)
+		if !ok {
+			return
+		}
+		tStart := time.Now()
+		report := lib.Reload()
+		dir := lib.Dir()
+		res := TemplateSync{
+			Added:    relPaths(dir, report.Added),
+			Reloaded: relPaths(dir, report.Reloaded),
+			Removed:  relPaths(dir, report.Removed),
+		}
+		for to, from := range report.Renamed {
+			if res.Renamed == nil {
+				res.Renamed = make(map[string]string)
+			}
+			res.Renamed[relPath(dir, to)] = relPath(dir, from)
+		}
+		for path, err := range report.Failed {
+			if res.Failed == nil {
+				res.Failed = make(map[string]string)
+			}
+			res.Failed[relPath(dir, path)] = err.Error()
+		}
+		log.Info().
+			Int("templates", lib.Registry().Len()).
+			Dur("cost", time.Since(tStart)).
+			Msg("templates reloaded")
+		writeJsonResponse(w, http.StatusOK, res)
+	})
+}
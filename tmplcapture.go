@@ -0,0 +1,287 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"strings"
+	"sync"
+
+	"gioui.org/layout"
+
+	"github.com/Miuzarte/GoCVStreamer/matcher"
+	"github.com/Miuzarte/GoCVStreamer/ui"
+	w "github.com/Miuzarte/GoCVStreamer/weapon"
+)
+
+var (
+	errNothingToUndo    = errors.New("no captured template to undo")
+	errNoPendingCapture = errors.New("no capture is waiting for a name")
+	errSelectionOutside = errors.New("selection outside the ROI")
+)
+
+// capturedTemplate 从最新一帧截取、等待命名的模板。
+type capturedTemplate struct {
+	roi  *matcher.Engine
+	rect image.Rectangle // 画面坐标
+	img  *image.RGBA
+}
+
+// templateCapture 从实时画面截取模板：截取 ROI 或其中鼠标拖出的子区域，
+// 命名后写入该 ROI 的模板目录，由热重载加载；保存过的模板可按倒序撤销。
+type templateCapture struct {
+	mu        sync.Mutex
+	selection image.Rectangle // 鼠标拖选，画面坐标；空为整个 ROI
+	// pending 等待命名的截取，可在控制台或经 HTTP 命名；done 在其命名或取消后关闭
+	pending *capturedTemplate
+	done    chan struct{}
+	saved   []string // 已保存的图片路径
+
+	// naming 串行化命名，控制台与 HTTP 不会把同一截取保存两次
+	naming sync.Mutex
+}
+
+var tmplCapture templateCapture
+
+// setSelection 窗口拖选的回调。
+func (c *templateCapture) setSelection(r image.Rectangle) {
+	c.mu.Lock()
+	c.selection = r
+	c.mu.Unlock()
+	log.Debug().
+		Any("selection", r).
+		Msg("capture selection set")
+}
+
+// grab 截取 e 的 ROI 与 sub（画面坐标）的交集，sub 为空时截取整个 ROI。
+func (c *templateCapture) grab(e *matcher.Engine, sub image.Rectangle) (capturedTemplate, error) {
+	r := e.Roi()
+	if !sub.Empty() {
+		if r = r.Intersect(sub); r.Empty() {
+			return capturedTemplate{}, errSelectionOutside
+		}
+	}
+	img, err := capturerServer.CropRgba(r)
+	if err != nil {
+		return capturedTemplate{}, err
+	}
+	return capturedTemplate{roi: e, rect: r, img: img}, nil
+}
+
+// save 以 class 与 name 写入模板目录，记录以便撤销。
+func (c *templateCapture) save(t capturedTemplate, class, name string) (string, error) {
+	path, err := w.WriteTemplate(t.roi.Templates(), t.img, w.Meta{Name: name, Class: class})
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	c.saved = append(c.saved, path)
+	c.mu.Unlock()
+	log.Info().
+		Str("roi", t.roi.Name()).
+		Str("path", path).
+		Any("rect", t.rect).
+		Msg("template captured")
+	return path, nil
+}
+
+// undo 删除最近一次保存的模板。
+func (c *templateCapture) undo() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.saved) == 0 {
+		return "", errNothingToUndo
+	}
+	path := c.saved[len(c.saved)-1]
+	if err := w.RemoveTemplate(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	c.saved = c.saved[:len(c.saved)-1]
+	log.Info().
+		Str("path", path).
+		Msg("captured template removed")
+	return path, nil
+}
+
+// name 以 class 与 name 保存等待命名的截取；失败时截取保留，可重试。
+func (c *templateCapture) name(class, name string) (string, error) {
+	c.mu.Lock()
+	t := c.pending
+	c.mu.Unlock()
+	return c.nameAs(t, class, name)
+}
+
+// nameAs 同 name，但只在 t 仍在等待命名时保存。
+func (c *templateCapture) nameAs(t *capturedTemplate, class, name string) (string, error) {
+	c.naming.Lock()
+	defer c.naming.Unlock()
+	c.mu.Lock()
+	waiting := t != nil && c.pending == t
+	c.mu.Unlock()
+	if !waiting {
+		return "", errNoPendingCapture
+	}
+	path, err := c.save(*t, class, name)
+	if err != nil {
+		return "", err
+	}
+	c.finish(t)
+	return path, nil
+}
+
+// cancel 放弃等待命名的截取。
+func (c *templateCapture) cancel() error {
+	c.naming.Lock()
+	defer c.naming.Unlock()
+	c.mu.Lock()
+	t := c.pending
+	c.mu.Unlock()
+	if t == nil || !c.finish(t) {
+		return errNoPendingCapture
+	}
+	log.Info().Msg("capture cancelled")
+	return nil
+}
+
+// finish 结束等待命名的截取 t，t 已不再等待时返回 false。
+func (c *templateCapture) finish(t *capturedTemplate) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending != t {
+		return false
+	}
+	c.pending = nil
+	close(c.done)
+	return true
+}
+
+// interactive 截取选中的 ROI（或拖选区域），在界面上预览，等待在控制台或经 HTTP 命名；
+// 已有截取在等待命名时放弃它。
+func (c *templateCapture) interactive() {
+	if selectedRoi == nil {
+		return
+	}
+	if c.cancel() == nil {
+		return
+	}
+	c.mu.Lock()
+	sel := c.selection
+	c.mu.Unlock()
+
+	t, err := c.grab(selectedRoi, sel)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to capture template")
+		return
+	}
+	c.mu.Lock()
+	c.pending = &t
+	c.done = make(chan struct{})
+	c.selection = image.Rectangle{}
+	done := c.done
+	c.mu.Unlock()
+
+	go c.prompt(&t, done)
+}
+
+// prompt 在控制台询问类别与名称，直到截取被保存或取消；控制台不可用时只能经 HTTP 命名。
+func (c *templateCapture) prompt(t *capturedTemplate, done <-chan struct{}) {
+	lines := consoleLines()
+	// 丢弃截取之前输入的行
+	for drained := false; !drained; {
+		select {
+		case <-lines:
+		default:
+			drained = true
+		}
+	}
+	for {
+		fmt.Fprintf(os.Stdout, "template %dx%d from ROI %q, input \"CLASS NAME\" (empty to cancel): ",
+			t.rect.Dx(), t.rect.Dy(), t.roi.Name())
+		var input string
+		select {
+		case <-done:
+			fmt.Fprintln(os.Stdout)
+			return
+		case line, ok := <-lines:
+			if !ok {
+				log.Warn().Msg("console unavailable, name the capture via POST /templates/capture/pending")
+				return
+			}
+			input = strings.TrimSpace(line)
+		}
+		if input == "" {
+			if c.finish(t) {
+				log.Info().Msg("capture cancelled")
+			}
+			return
+		}
+		class, name, _ := strings.Cut(input, " ")
+		if _, err := c.nameAs(t, class, strings.TrimSpace(name)); err != nil {
+			if errors.Is(err, errNoPendingCapture) {
+				return
+			}
+			log.Warn().Err(err).Msg("failed to save template")
+			continue
+		}
+		return
+	}
+}
+
+var (
+	consoleOnce sync.Once
+	consoleCh   chan string
+)
+
+// consoleLines 控制台输入的行，全程共用 stdin 一个读取者；读取出错（如没有控制台）时关闭。
+func consoleLines() <-chan string {
+	consoleOnce.Do(func() {
+		consoleCh = make(chan string)
+		go func() {
+			defer close(consoleCh)
+			for {
+				line, err := stdin.ReadString('\n')
+				if line != "" {
+					consoleCh <- line
+				}
+				if err != nil {
+					log.Debug().Err(err).Msg("console input closed")
+					return
+				}
+			}
+		}()
+	})
+	return consoleCh
+}
+
+// Draw 拖选区域，以及等待命名的预览与命名提示。
+func (c *templateCapture) Draw(gtx layout.Context, s ui.DScale) {
+	c.mu.Lock()
+	sel, pending := c.selection, c.pending
+	c.mu.Unlock()
+
+	if !sel.Empty() {
+		ui.DrawBorder(gtx, ui.ColorCyan.NRGBA(), s.Rect(sel))
+	}
+	if pending != nil {
+		rect := s.Rect(pending.rect)
+		ui.DrawBorder(gtx, ui.ColorYellow.NRGBA(), rect)
+		pos := image.Pt(rect.Min.X, rect.Max.Y+ui.FontSize/2)
+		pos.Y += ui.DrawImage(gtx, pos, pending.img).Size.Y
+		for _, line := range pendingHints(pending) {
+			pos.Y += ui.DrawLabel(gtx, ui.ColorYellow.NRGBA(), pos, ui.FontSize, line).Size.Y
+		}
+	}
+}
+
+// pendingHints 等待命名时预览下方的提示：截取的 ROI 与尺寸、命名途径、快捷键。
+func pendingHints(t *capturedTemplate) []string {
+	hints := []string{
+		fmt.Sprintf("template %dx%d from ROI %q is waiting for a name", t.rect.Dx(), t.rect.Dy(), t.roi.Name()),
+		"type CLASS NAME in the console",
+	}
+	if !*nohttp {
+		hints = append(hints, "or POST "+*httpPort+"/templates/capture/pending?class=CLASS&name=NAME")
+	}
+	return append(hints, "G: cancel, Shift+G: undo")
+}
@@ -0,0 +1,295 @@
+package tmpllint
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"path/filepath"
+	"slices"
+
+	"github.com/Miuzarte/GoCVStreamer/template"
+	"github.com/Miuzarte/GoCVStreamer/utils"
+	"github.com/Miuzarte/GoCVStreamer/weapon"
+	"github.com/Miuzarte/GoCVStreamer/weapons"
+	"gocv.io/x/gocv"
+)
+
+// 问题类型
+const (
+	ISSUE_PARSE     = "parse"     // 文件名/元数据解析或图片读取失败
+	ISSUE_DUPLICATE = "duplicate" // 名称或别名重复
+	ISSUE_SIZE      = "size"      // 模板（按最大缩放）放不进 ROI
+	ISSUE_EMPTY     = "empty"     // 图片为空
+	ISSUE_MASK      = "mask"      // 要求掩码但没有 alpha 通道，或掩码几乎为空/全满
+	ISSUE_MATCH     = "match"     // 匹配策略无法用于该模板（如特征点不足）
+)
+
+const (
+	// MASK_MIN_COVERAGE 掩码有效像素低于此比例时基本无法区分模板
+	MASK_MIN_COVERAGE = 0.05
+	// MASK_MAX_COVERAGE 掩码几乎全满时与不用掩码无异，只多了开销
+	MASK_MAX_COVERAGE = 0.99
+)
+
+type Config struct {
+	Dir          string
+	Depth        int
+	Suffix       string
+	IgnorePrefix string
+	CreateMask   bool
+	Flag         gocv.IMReadFlag
+	// Preprocess 模板与裁剪图匹配前的预处理链（同 ROI 设置）
+	Preprocess template.Preprocess
+
+	// Strategy、Threshold 模板未指定时使用的匹配策略与阈值（同 ROI 设置），
+	// 分别为 nil、0 时使用 template.DefaultStrategy 与策略默认阈值
+	Strategy  template.Strategy
+	Threshold float32
+	// Roi ROI 尺寸，零值不检查
+	Roi image.Point
+	// MaxScale 匹配时的最大缩放倍率，尺寸检查按此放大
+	MaxScale float64
+
+	// Crops 录制的 ROI 裁剪图目录，空为不使用
+	Crops string
+}
+
+type Issue struct {
+	Kind   string `json:"kind"`
+	Path   string `json:"path"`
+	Detail string `json:"detail"`
+}
+
+// Pair 模板 Template 在 Subject（另一模板图片或 ROI 裁剪）上的得分超过其阈值。
+type Pair struct {
+	Template string  `json:"template"`
+	Subject  string  `json:"subject"`
+	Score    float32 `json:"score"`
+}
+
+// Confusion 行为模板，列为被匹配的图片（先模板后裁剪），值为最高得分。
+type Confusion struct {
+	Templates []string    `json:"templates"`
+	Subjects  []string    `json:"subjects"`
+	Matrix    [][]float32 `json:"matrix"`
+}
+
+// CropResult 单张裁剪图上超过阈值的模板，多于一个即为歧义。
+type CropResult struct {
+	Path    string   `json:"path"`
+	Best    string   `json:"best,omitzero"`
+	Score   float32  `json:"score"`
+	Matches []string `json:"matches,omitzero"`
+}
+
+type Report struct {
+	Dir       string       `json:"dir"`
+	Templates int          `json:"templates"`
+	Threshold float32      `json:"threshold"`
+	Roi       image.Point  `json:"roi,omitzero"`
+	Issues    []Issue      `json:"issues"`
+	Pairs     []Pair       `json:"pairs"`
+	Crops     []CropResult `json:"crops,omitzero"`
+	Confusion Confusion    `json:"confusion"`
+}
+
+// Run 加载模板目录并检查；单个模板加载失败记为问题，其余模板照常参与分析。
+func Run(cfg Config) (*Report, error) {
+	r := &Report{Dir: cfg.Dir, Threshold: cfg.Threshold, Roi: cfg.Roi}
+
+	var ws weapons.Weapons
+	defer ws.Close()
+	err := ws.ReadFromEach(cfg.Dir, cfg.Depth, cfg.Suffix, cfg.IgnorePrefix, cfg.CreateMask, cfg.Flag, func(path string, err error) {
+		r.Issues = append(r.Issues, Issue{Kind: ISSUE_PARSE, Path: path, Detail: err.Error()})
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.Templates = len(ws)
+	for _, w := range ws {
+		if err := w.Template.SetPreprocess(cfg.Preprocess); err != nil {
+			r.Issues = append(r.Issues, Issue{Kind: ISSUE_PARSE, Path: w.Path, Detail: "preprocess: " + err.Error()})
+		}
+	}
+
+	r.Issues = append(r.Issues, duplicates(ws)...)
+	for _, w := range ws {
+		r.Issues = append(r.Issues, checkTemplate(cfg, w)...)
+	}
+
+	var crops []string
+	if cfg.Crops != "" {
+		for _, ext := range []string{".png", ".jpg"} {
+			paths, err := utils.WalkDir(cfg.Crops, 1, ext, "")
+			if err != nil {
+				return nil, err
+			}
+			crops = append(crops, paths...)
+		}
+		slices.Sort(crops)
+	}
+
+	strategies := make([]template.Strategy, len(ws))
+	thresholds := make([]float32, len(ws))
+	for i, w := range ws {
+		strategies[i] = cmp.Or(w.Strategy, cfg.Strategy, template.DefaultStrategy)
+		thresholds[i] = cmp.Or(w.Threshold, cfg.Threshold, strategies[i].DefaultThreshold())
+	}
+	var matchIssues []Issue
+	r.Confusion, matchIssues, err = confusion(ws, strategies, crops, cfg.Flag, cfg.Preprocess)
+	if err != nil {
+		return nil, err
+	}
+	r.Issues = append(r.Issues, matchIssues...)
+	r.Pairs = pairs(r.Confusion, len(ws), thresholds)
+	r.Crops = cropResults(r.Confusion, len(ws), thresholds)
+	return r, nil
+}
+
+// duplicates 名称与别名在全部模板间必须唯一，否则按名称查找（远程匹配、IDByName）结果不确定。
+func duplicates(ws weapons.Weapons) (issues []Issue) {
+	owner := make(map[string]string)
+	for _, w := range ws {
+		for _, name := range append([]string{w.Name}, w.Aliases...) {
+			if prev, ok := owner[name]; ok && prev != w.Path {
+				issues = append(issues, Issue{
+					Kind:   ISSUE_DUPLICATE,
+					Path:   w.Path,
+					Detail: fmt.Sprintf("name %q already used by %s", name, filepath.Base(prev)),
+				})
+				continue
+			}
+			owner[name] = w.Path
+		}
+	}
+	return
+}
+
+func checkTemplate(cfg Config, w *weapon.Weapon) (issues []Issue) {
+	t := &w.Template
+	add := func(kind, format string, args ...any) {
+		issues = append(issues, Issue{Kind: kind, Path: w.Path, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	if t.Width == 0 || t.Height == 0 {
+		add(ISSUE_EMPTY, "image is empty or unreadable")
+		return
+	}
+
+	if cfg.Roi != (image.Point{}) {
+		scale := max(cfg.MaxScale, 1)
+		size := image.Pt(int(math.Ceil(float64(t.Width)*scale)), int(math.Ceil(float64(t.Height)*scale)))
+		if size.X > cfg.Roi.X || size.Y > cfg.Roi.Y {
+			add(ISSUE_SIZE, "%dx%d (%dx%d at scale %.2f) exceeds ROI %dx%d",
+				t.Width, t.Height, size.X, size.Y, scale, cfg.Roi.X, cfg.Roi.Y)
+		}
+	}
+
+	wantMask := cfg.CreateMask
+	if m, err := weapon.LoadMeta(w.Path); err == nil && m.Mask != nil {
+		wantMask = *m.Mask
+	}
+	switch coverage := t.MaskCoverage(); {
+	case coverage < 0:
+		if wantMask {
+			add(ISSUE_MASK, "mask requested but image has no alpha channel (%d channels)", t.Channels)
+		}
+	case coverage < MASK_MIN_COVERAGE:
+		add(ISSUE_MASK, "mask covers only %.1f%% of the template", coverage*100)
+	case coverage > MASK_MAX_COVERAGE:
+		add(ISSUE_MASK, "mask covers %.1f%% of the template, matching without it is equivalent", coverage*100)
+	}
+	return
+}
+
+// confusion 每个模板在每张模板图片与裁剪图上匹配。
+// 模板图片四周按最大模板尺寸补黑边，使较大的模板也能在较小的模板上匹配。
+// 策略对某模板出错时该行记为 -1 并作为问题返回。
+func confusion(ws weapons.Weapons, strategies []template.Strategy, crops []string, flag gocv.IMReadFlag, pre template.Preprocess) (c Confusion, issues []Issue, err error) {
+	var pad image.Point
+	for _, w := range ws {
+		c.Templates = append(c.Templates, w.Name)
+		pad = image.Pt(max(pad.X, w.Template.Width), max(pad.Y, w.Template.Height))
+	}
+
+	var subjects []gocv.Mat
+	defer func() {
+		for i := range subjects {
+			subjects[i].Close()
+		}
+	}()
+	for _, w := range ws {
+		padded := gocv.NewMat()
+		if err := gocv.CopyMakeBorder(w.Template.Mat, &padded, pad.Y, pad.Y, pad.X, pad.X, gocv.BorderConstant, color.RGBA{}); err != nil {
+			padded.Close()
+			return c, nil, fmt.Errorf("%s: %w", w.Path, err)
+		}
+		subjects = append(subjects, padded)
+		c.Subjects = append(c.Subjects, w.Name)
+	}
+	for _, path := range crops {
+		img := gocv.IMRead(path, flag)
+		if img.Empty() {
+			img.Close()
+			return c, nil, fmt.Errorf("failed to read crop %s", path)
+		}
+		if err := pre.Apply(img, &img); err != nil {
+			img.Close()
+			return c, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		subjects = append(subjects, img)
+		c.Subjects = append(c.Subjects, filepath.Base(path))
+	}
+
+	c.Matrix = make([][]float32, len(ws))
+	for i, w := range ws {
+		c.Matrix[i] = make([]float32, len(subjects))
+		var matchErr error
+		for j, img := range subjects {
+			f := template.NewFrame(img)
+			err := w.Template.MatchWith(strategies[i], f, 1)
+			f.Close()
+			if err != nil && matchErr == nil {
+				matchErr = fmt.Errorf("on %s: %w", c.Subjects[j], err)
+			}
+			c.Matrix[i][j] = w.Template.MaxVal
+		}
+		if matchErr != nil {
+			issues = append(issues, Issue{Kind: ISSUE_MATCH, Path: w.Path, Detail: matchErr.Error()})
+		}
+	}
+	return c, issues, nil
+}
+
+// pairs 模板之间（不含自身）超过阈值的组合，按得分降序。
+func pairs(c Confusion, templates int, thresholds []float32) (ps []Pair) {
+	for i := range templates {
+		for j := range templates {
+			if i != j && c.Matrix[i][j] >= thresholds[i] {
+				ps = append(ps, Pair{Template: c.Templates[i], Subject: c.Subjects[j], Score: c.Matrix[i][j]})
+			}
+		}
+	}
+	slices.SortStableFunc(ps, func(a, b Pair) int { return cmp.Compare(b.Score, a.Score) })
+	return
+}
+
+// cropResults 每张裁剪图的最佳模板与全部超过阈值的模板。
+func cropResults(c Confusion, templates int, thresholds []float32) (rs []CropResult) {
+	for j := templates; j < len(c.Subjects); j++ {
+		r := CropResult{Path: c.Subjects[j], Score: -1}
+		for i := range templates {
+			score := c.Matrix[i][j]
+			if score > r.Score {
+				r.Best, r.Score = c.Templates[i], score
+			}
+			if score >= thresholds[i] {
+				r.Matches = append(r.Matches, c.Templates[i])
+			}
+		}
+		rs = append(rs, r)
+	}
+	return
+}
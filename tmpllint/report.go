@@ -0,0 +1,77 @@
+package tmpllint
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Markdown 汇总模板数与默认阈值，列出单个模板的问题与超过阈值的易混模板对。
+func (r *Report) Markdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Template lint: %s\n\n", r.Dir)
+	fmt.Fprintf(&sb, "- templates: %d, issues: %d, confusable pairs: %d\n", r.Templates, len(r.Issues), len(r.Pairs))
+	if r.Threshold > 0 {
+		fmt.Fprintf(&sb, "- default threshold: %.2f", r.Threshold)
+	} else {
+		sb.WriteString("- default threshold: per strategy")
+	}
+	if r.Roi.X > 0 {
+		fmt.Fprintf(&sb, ", ROI: %dx%d", r.Roi.X, r.Roi.Y)
+	}
+	sb.WriteString("\n")
+
+	if len(r.Issues) > 0 {
+		sb.WriteString("\n## Issues\n\n")
+		sb.WriteString("| kind | file | detail |\n")
+		sb.WriteString("|---|---|---|\n")
+		for _, i := range r.Issues {
+			fmt.Fprintf(&sb, "| %s | %s | %s |\n", i.Kind, mdEscape(i.Path), mdEscape(i.Detail))
+		}
+	}
+
+	if len(r.Pairs) > 0 {
+		sb.WriteString("\n## Pairs over threshold\n\n")
+		sb.WriteString("| template | matches | score |\n")
+		sb.WriteString("|---|---|---:|\n")
+		for _, p := range r.Pairs {
+			fmt.Fprintf(&sb, "| %s | %s | %.4f |\n", mdEscape(p.Template), mdEscape(p.Subject), p.Score)
+		}
+	}
+
+	if len(r.Crops) > 0 {
+		sb.WriteString("\n## ROI crops\n\n")
+		sb.WriteString("| crop | best | score | over threshold |\n")
+		sb.WriteString("|---|---|---:|---|\n")
+		for _, c := range r.Crops {
+			matches := strings.Join(c.Matches, ", ")
+			if len(c.Matches) > 1 {
+				matches = "**" + matches + "**"
+			}
+			fmt.Fprintf(&sb, "| %s | %s | %.4f | %s |\n", mdEscape(c.Path), mdEscape(c.Best), c.Score, mdEscape(matches))
+		}
+	}
+
+	c := r.Confusion
+	if len(c.Templates) > 0 {
+		sb.WriteString("\n## Confusion matrix (rows: template, columns: matched image)\n\n")
+		sb.WriteString("| |")
+		for _, s := range c.Subjects {
+			fmt.Fprintf(&sb, " %s |", mdEscape(s))
+		}
+		sb.WriteString("\n|---|")
+		sb.WriteString(strings.Repeat("---:|", len(c.Subjects)))
+		sb.WriteString("\n")
+		for i, row := range c.Matrix {
+			fmt.Fprintf(&sb, "| **%s** |", mdEscape(c.Templates[i]))
+			for _, v := range row {
+				fmt.Fprintf(&sb, " %.2f |", v)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+func mdEscape(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,116 @@
+package tmpllint
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Miuzarte/GoCVStreamer/template"
+	"github.com/Miuzarte/GoCVStreamer/weapon"
+	"github.com/Miuzarte/GoCVStreamer/weapons"
+	"gocv.io/x/gocv"
+)
+
+func TestDuplicates(t *testing.T) {
+	ws := weapons.Weapons{
+		{Path: "a.png", Name: "R4-C", Aliases: []string{"R4"}},
+		{Path: "b.png", Name: "R4"},
+		{Path: "c.png", Name: "552"},
+	}
+	issues := duplicates(ws)
+	if len(issues) != 1 || issues[0].Path != "b.png" || issues[0].Kind != ISSUE_DUPLICATE {
+		t.Fatalf("issues = %+v", issues)
+	}
+}
+
+func TestCheckTemplate(t *testing.T) {
+	cfg := Config{Roi: image.Pt(88, 104), MaxScale: 1.15}
+	kinds := func(w *weapon.Weapon) (ks []string) {
+		for _, i := range checkTemplate(cfg, w) {
+			ks = append(ks, i.Kind)
+		}
+		return
+	}
+
+	if ks := kinds(&weapon.Weapon{Path: "ok.png", Template: template.Template{Width: 70, Height: 20}}); len(ks) != 0 {
+		t.Fatalf("ok template: %v", ks)
+	}
+	// 80 × 1.15 > 88
+	if ks := kinds(&weapon.Weapon{Path: "wide.png", Template: template.Template{Width: 80, Height: 20}}); len(ks) != 1 || ks[0] != ISSUE_SIZE {
+		t.Fatalf("wide template: %v", ks)
+	}
+	if ks := kinds(&weapon.Weapon{Path: "empty.png"}); len(ks) != 1 || ks[0] != ISSUE_EMPTY {
+		t.Fatalf("empty template: %v", ks)
+	}
+	cfg.CreateMask = true
+	if ks := kinds(&weapon.Weapon{Path: "nomask.png", Template: template.Template{Width: 10, Height: 10, Channels: 1}}); len(ks) != 1 || ks[0] != ISSUE_MASK {
+		t.Fatalf("missing mask: %v", ks)
+	}
+}
+
+func TestPairs(t *testing.T) {
+	c := Confusion{
+		Templates: []string{"A", "B", "C"},
+		Subjects:  []string{"A", "B", "C", "crop1.png", "crop2.png"},
+		Matrix: [][]float32{
+			{1, 0.95, 0.2, 0.97, 0.1},
+			{0.85, 1, 0.3, 0.93, 0.2},
+			{0.1, 0.2, 1, 0.4, 0.5},
+		},
+	}
+	thresholds := []float32{0.9, 0.8, 0.9}
+
+	ps := pairs(c, 3, thresholds)
+	if len(ps) != 2 || ps[0] != (Pair{"A", "B", 0.95}) || ps[1] != (Pair{"B", "A", 0.85}) {
+		t.Fatalf("pairs = %+v", ps)
+	}
+
+	rs := cropResults(c, 3, thresholds)
+	if len(rs) != 2 {
+		t.Fatalf("crops = %+v", rs)
+	}
+	if rs[0].Best != "A" || len(rs[0].Matches) != 2 {
+		t.Fatalf("ambiguous crop = %+v", rs[0])
+	}
+	if rs[1].Best != "C" || len(rs[1].Matches) != 0 {
+		t.Fatalf("unmatched crop = %+v", rs[1])
+	}
+
+	md := (&Report{Dir: "templates", Pairs: ps, Crops: rs, Confusion: c}).Markdown()
+	if !strings.Contains(md, "| A | B | 0.9500 |") || !strings.Contains(md, "**A, B**") {
+		t.Fatalf("markdown:\n%s", md)
+	}
+}
+
+// TestRunParseFailure 单个模板解析失败记为问题，不中断其余模板。
+func TestRunParseFailure(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"{AR_10_--} R4-C.png", "{XYZ_10_--} Bad.png", "noparams.png"} {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		png.Encode(f, image.NewGray(image.Rect(0, 0, 8, 4)))
+		f.Close()
+	}
+
+	r, err := Run(Config{Dir: dir, Depth: 1, Suffix: ".png", Flag: gocv.IMReadGrayScale, Threshold: 0.9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Templates != 1 {
+		t.Fatalf("templates = %d", r.Templates)
+	}
+	var parse int
+	for _, i := range r.Issues {
+		if i.Kind == ISSUE_PARSE {
+			parse++
+		}
+	}
+	if parse != 2 {
+		t.Fatalf("issues = %+v", r.Issues)
+	}
+}
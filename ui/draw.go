@@ -43,6 +43,19 @@ func (s DScale) Pos(pos image.Point) image.Point {
 	return image.Pt(ox+int(float64(pos.X)*r), oy+int(float64(pos.Y)*r))
 }
 
+// Unpos Pos 的逆变换：窗口坐标 -> 原始画面坐标。
+func (s DScale) Unpos(pos image.Point) image.Point {
+	if s.Orig.X <= 0 || s.Orig.Y <= 0 || s.Curr.X <= 0 || s.Curr.Y <= 0 {
+		return image.Point{}
+	}
+	r := s.Ratio()
+	displayW := int(float64(s.Orig.X) * r)
+	displayH := int(float64(s.Orig.Y) * r)
+	ox := (s.Curr.X - displayW) / 2
+	oy := (s.Curr.Y - displayH) / 2
+	return image.Pt(int(float64(pos.X-ox)/r), int(float64(pos.Y-oy)/r))
+}
+
 func (s DScale) Size(size image.Point) image.Point {
 	r := s.Ratio()
 	return image.Pt(int(float64(size.X)*r), int(float64(size.Y)*r))
@@ -7,8 +7,11 @@ import (
 
 	"gioui.org/app"
 	"gioui.org/f32"
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
 	"gioui.org/layout"
 	"gioui.org/op"
+	"gioui.org/op/clip"
 	"gioui.org/op/paint"
 	"gioui.org/unit"
 
@@ -33,6 +36,12 @@ type Window struct {
 	bounds      image.Point
 	drawEnabled bool
 	drawScale   DScale
+
+	// onSelect 鼠标左键拖出的矩形（原始画面坐标），nil 时不处理鼠标
+	onSelect  func(image.Rectangle)
+	selecting bool
+	selFrom   image.Point // 窗口坐标
+	selTo     image.Point
 }
 
 func NewWindow(cfg Config) *Window {
@@ -78,6 +87,11 @@ func (w *Window) SetShortcuts(s widgets.Shortcuts) {
 	w.cfg.Shortcuts = s
 }
 
+// SetOnSelect 设置鼠标拖选的回调，参数为原始画面坐标下的矩形；nil 关闭拖选。
+func (w *Window) SetOnSelect(f func(image.Rectangle)) {
+	w.onSelect = f
+}
+
 func (w *Window) DrawEnabled() bool {
 	return w.drawEnabled
 }
@@ -129,6 +143,10 @@ func (w *Window) Run(ctx context.Context) {
 				}
 			}
 
+			if w.onSelect != nil {
+				w.handleSelect(gtx)
+			}
+
 			e.Frame(gtx.Ops)
 
 		case app.ConfigEvent:
@@ -141,6 +159,57 @@ func (w *Window) Run(ctx context.Context) {
 	}
 }
 
+// handleSelect 处理鼠标拖选并绘制拖动中的矩形。
+func (w *Window) handleSelect(gtx layout.Context) {
+	area := clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops)
+	event.Op(gtx.Ops, &w.selFrom)
+	area.Pop()
+
+	for {
+		ev, ok := gtx.Event(pointer.Filter{
+			Target: &w.selFrom,
+			Kinds:  pointer.Press | pointer.Drag | pointer.Release | pointer.Cancel,
+		})
+		if !ok {
+			break
+		}
+		e, ok := ev.(pointer.Event)
+		if !ok {
+			continue
+		}
+		pos := e.Position.Round()
+		switch e.Kind {
+		case pointer.Press:
+			if e.Buttons == pointer.ButtonPrimary {
+				w.selecting = true
+				w.selFrom, w.selTo = pos, pos
+			}
+		case pointer.Drag:
+			if w.selecting {
+				w.selTo = pos
+			}
+		case pointer.Release:
+			if w.selecting {
+				w.selecting = false
+				w.selTo = pos
+				r := image.Rectangle{
+					Min: w.drawScale.Unpos(w.selFrom),
+					Max: w.drawScale.Unpos(w.selTo),
+				}.Canon()
+				if !r.Empty() {
+					w.onSelect(r)
+				}
+			}
+		case pointer.Cancel:
+			w.selecting = false
+		}
+	}
+
+	if w.selecting {
+		DrawBorder(gtx, ColorCyan.NRGBA(), image.Rectangle{Min: w.selFrom, Max: w.selTo}.Canon())
+	}
+}
+
 func (w *Window) drawScreen(gtx layout.Context) {
 	gtxBounds := gtx.Constraints.Max
 	gtxW, gtxH := gtxBounds.X, gtxBounds.Y
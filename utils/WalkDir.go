@@ -29,7 +29,7 @@ func WalkDir(dir string, depth int, suffix string, withoutPrefix string) (paths
 			return filepath.SkipDir
 		}
 
-		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), suffix) && !strings.HasPrefix(filepath.Base(path), withoutPrefix) {
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), suffix) && (withoutPrefix == "" || !strings.HasPrefix(filepath.Base(path), withoutPrefix)) {
 			if relDepth <= depth {
 				paths = append(paths, path)
 			}
@@ -0,0 +1,191 @@
+package weapon
+
+import (
+	jsonv2 "encoding/json/v2"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// CATALOG_BUILTIN 内置（R6S）类别表的名称，未加载数据文件时生效
+const CATALOG_BUILTIN = "builtin"
+
+// ClassSpec 数据文件中的一个类别。
+type ClassSpec struct {
+	Short string `json:"short"`
+	Full  string `json:"full"`
+	// Slot primary、secondary、mix，空为未定义
+	Slot string `json:"slot,omitzero"`
+	// Type full_auto、semi_auto、mix，空为未定义
+	Type   string `json:"type,omitzero"`
+	Offset int    `json:"offset,omitzero"`
+	// Attributes 供脚本等使用的任意属性
+	Attributes map[string]string `json:"attributes,omitzero"`
+}
+
+// CatalogFile 单个游戏的类别表文件，Class 取值为 Classes 的下标 +1。
+type CatalogFile struct {
+	Game    string      `json:"game"`
+	Classes []ClassSpec `json:"classes"`
+}
+
+// Catalog 解析后的类别表，下标即 Class（0 为未定义）。
+type Catalog struct {
+	Game    string
+	details []ClassDetail
+	attrs   map[Class]map[string]string
+}
+
+var activeCatalog atomic.Pointer[Catalog]
+
+func init() {
+	activeCatalog.Store(&Catalog{Game: CATALOG_BUILTIN, details: classDetails[:]})
+}
+
+// ActiveCatalog 当前生效的类别表。
+func ActiveCatalog() *Catalog {
+	return activeCatalog.Load()
+}
+
+// SetActiveCatalog 切换类别表；应在加载模板前调用，已加载模板的 Class 不会重新解析。
+func SetActiveCatalog(c *Catalog) {
+	activeCatalog.Store(c)
+}
+
+// LoadCatalog 读取并校验类别表文件；未知字段、重名与非法槽位/类型均视为错误。
+func LoadCatalog(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f CatalogFile
+	if err := jsonv2.Unmarshal(data, &f, jsonv2.RejectUnknownMembers(true)); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	c, err := NewCatalog(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// NewCatalog 由文件内容构建类别表。
+func NewCatalog(f CatalogFile) (*Catalog, error) {
+	c := &Catalog{
+		Game:    f.Game,
+		details: make([]ClassDetail, 1, len(f.Classes)+1),
+	}
+	names := make(map[string]bool, 2*len(f.Classes))
+	for i, spec := range f.Classes {
+		if spec.Short == "" || spec.Full == "" {
+			return nil, fmt.Errorf("class %d: short and full names are required", i)
+		}
+		for _, name := range []string{spec.Short, spec.Full} {
+			if names[name] {
+				return nil, fmt.Errorf("class %d: duplicate name %q", i, name)
+			}
+			names[name] = true
+		}
+		slot, err := ParseSlot(spec.Slot)
+		if err != nil {
+			return nil, fmt.Errorf("class %s: %w", spec.Short, err)
+		}
+		typ, err := ParseType(spec.Type)
+		if err != nil {
+			return nil, fmt.Errorf("class %s: %w", spec.Short, err)
+		}
+		class := Class(i + 1)
+		c.details = append(c.details, ClassDetail{
+			Slot:      slot,
+			Type:      typ,
+			Class:     class,
+			ShortName: spec.Short,
+			FullName:  spec.Full,
+			Offset:    spec.Offset,
+		})
+		if len(spec.Attributes) > 0 {
+			if c.attrs == nil {
+				c.attrs = make(map[Class]map[string]string)
+			}
+			c.attrs[class] = spec.Attributes
+		}
+	}
+	return c, nil
+}
+
+// Detail 越界（含其他类别表解析出的 Class）时返回未定义类别。
+func (c *Catalog) Detail(class Class) *ClassDetail {
+	if class <= 0 || int(class) >= len(c.details) {
+		return &c.details[0]
+	}
+	return &c.details[class]
+}
+
+// Attributes 类别在数据文件中的附加属性，没有时为 nil。
+func (c *Catalog) Attributes(class Class) map[string]string {
+	return c.attrs[class]
+}
+
+// Classes 全部已定义类别（不含 0）。
+func (c *Catalog) Classes() []ClassDetail {
+	return c.details[1:]
+}
+
+// Parse 按短名、再按全名查找，找不到时返回 0。
+func (c *Catalog) Parse(name string) Class {
+	// 先判断短名字, 一般用这个
+	for _, d := range c.details[1:] {
+		if name == d.ShortName {
+			return d.Class
+		}
+	}
+	for _, d := range c.details[1:] {
+		if name == d.FullName {
+			return d.Class
+		}
+	}
+	return 0
+}
+
+// Lookup 与 Parse 相同，找不到时返回列出可用短名的错误。
+func (c *Catalog) Lookup(name string) (Class, error) {
+	if class := c.Parse(name); class != 0 {
+		return class, nil
+	}
+	short := make([]string, 0, len(c.details)-1)
+	for _, d := range c.details[1:] {
+		short = append(short, d.ShortName)
+	}
+	return 0, fmt.Errorf("unknown class %q in %s catalogue (known: %s)", name, c.Game, strings.Join(short, ", "))
+}
+
+// ParseSlot 数据文件中的槽位名。
+func ParseSlot(s string) (Slot, error) {
+	switch s {
+	case "":
+		return SLOT_UNDEFINED, nil
+	case "primary":
+		return SLOT_PRIMARY, nil
+	case "secondary":
+		return SLOT_SECONDARY, nil
+	case "mix":
+		return SLOT_MIX, nil
+	}
+	return SLOT_UNDEFINED, fmt.Errorf("unknown slot %q", s)
+}
+
+// ParseType 数据文件中的射击类型名。
+func ParseType(s string) (Type, error) {
+	switch s {
+	case "":
+		return TYPE_UNDEFINED, nil
+	case "full_auto":
+		return TYPE_FULL_AUTO, nil
+	case "semi_auto":
+		return TYPE_SEMI_AUTO, nil
+	case "mix":
+		return TYPE_MIX, nil
+	}
+	return TYPE_UNDEFINED, fmt.Errorf("unknown type %q", s)
+}
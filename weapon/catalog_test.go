@@ -0,0 +1,74 @@
+package weapon
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestCatalogFiles 仓库自带的类别表可加载，r6s.json 与内置表一致。
+func TestCatalogFiles(t *testing.T) {
+	r6s, err := LoadCatalog(filepath.Join("..", "catalogs", "r6s.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r6s.Classes(), classDetails[1:]) {
+		t.Fatalf("r6s.json differs from the built-in table:\n%+v", r6s.Classes())
+	}
+
+	cs2, err := LoadCatalog(filepath.Join("..", "catalogs", "cs2.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rifle := cs2.Detail(cs2.Parse("RFL"))
+	if rifle.FullName != "Rifle" || rifle.Slot != SLOT_PRIMARY || !rifle.Type.Has(TYPE_FULL_AUTO) {
+		t.Fatalf("RFL = %+v", rifle)
+	}
+	if cs2.Attributes(cs2.Parse("Knife"))["recoil"] != "none" {
+		t.Fatal("attributes not loaded")
+	}
+}
+
+func TestCatalogValidation(t *testing.T) {
+	for name, f := range map[string]CatalogFile{
+		"duplicate": {Classes: []ClassSpec{{Short: "AR", Full: "A"}, {Short: "AR", Full: "B"}}},
+		"slot":      {Classes: []ClassSpec{{Short: "AR", Full: "A", Slot: "tertiary"}}},
+		"type":      {Classes: []ClassSpec{{Short: "AR", Full: "A", Type: "burst"}}},
+		"names":     {Classes: []ClassSpec{{Short: "AR"}}},
+	} {
+		if _, err := NewCatalog(f); err == nil {
+			t.Errorf("%s: invalid catalogue accepted", name)
+		}
+	}
+}
+
+// TestActiveCatalog 模板按当前类别表解析，未知类别给出可用类别而不是静默为 0。
+func TestActiveCatalog(t *testing.T) {
+	prev := ActiveCatalog()
+	defer SetActiveCatalog(prev)
+
+	c, err := NewCatalog(CatalogFile{Game: "test", Classes: []ClassSpec{
+		{Short: "PST", Full: "Pistol", Slot: "secondary", Type: "semi_auto"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetActiveCatalog(c)
+
+	if ParseClass("PST") != 1 || Class(1).Detail().Slot != SLOT_SECONDARY {
+		t.Fatal("class not resolved against the active catalogue")
+	}
+	if Class(5).Detail().ShortName != "" {
+		t.Fatal("out of range class should resolve to the undefined class")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "{AR_10_--} R4-C.png")
+	os.WriteFile(path, nil, 0o644)
+	err = new(Weapon).DecodeFrom(path, false, 0)
+	if err == nil || !strings.Contains(err.Error(), `unknown class "AR"`) || !strings.Contains(err.Error(), "PST") {
+		t.Fatalf("err = %v", err)
+	}
+}
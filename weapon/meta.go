@@ -0,0 +1,201 @@
+package weapon
+
+import (
+	"encoding/json/jsontext"
+	jsonv2 "encoding/json/v2"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Miuzarte/GoCVStreamer/template"
+)
+
+const (
+	// META_SUFFIX 单个模板的附属元数据：与图片同名，扩展名换成 .json
+	META_SUFFIX = ".json"
+	// MANIFEST_NAME 目录清单：文件名 -> 元数据，单个附属文件优先
+	MANIFEST_NAME = "templates.json"
+)
+
+// ErrNoMeta 模板没有附属元数据，也不在目录清单中。
+var ErrNoMeta = errors.New("no template metadata")
+
+// Meta 模板元数据，取代文件名中的 {CLASS_SPEED_SPEED} 参数。
+type Meta struct {
+	Name      string  `json:"name"`
+	Class     string  `json:"class"` // 短名或全名，如 SMG / Submachine Gun
+	SpeedMain float64 `json:"speed_main,omitzero"`
+	// SpeedAlt 数值，或 "--"（主速度 ×0.7，默认）、"=="（同主速度）
+	SpeedAlt string `json:"speed_alt,omitzero"`
+
+	Aliases []string `json:"aliases,omitzero"`
+	// Threshold 该模板的命中阈值，0 使用匹配器默认值
+	Threshold float32 `json:"threshold,omitzero"`
+	// Method 匹配策略（template.STRATEGY_*），空为沿用 ROI 的设置
+	Method string `json:"method,omitzero"`
+	// Mask 是否用 alpha 通道生成掩码，未设置时沿用全局设置
+	Mask     *bool    `json:"mask,omitzero"`
+	RoiGroup string   `json:"roi_group,omitzero"`
+	Tags     []string `json:"tags,omitzero"`
+}
+
+// Validate 检查类别（按当前生效的类别表）、速度、阈值与匹配方法。
+func (m Meta) Validate() error {
+	if m.Name == "" {
+		return errors.New("name is required")
+	}
+	if _, err := ActiveCatalog().Lookup(m.Class); err != nil {
+		return err
+	}
+	if _, err := parseSpeedAlt(m.SpeedMain, m.SpeedAlt); err != nil {
+		return err
+	}
+	if m.Threshold < 0 || m.Threshold > 1 {
+		return fmt.Errorf("threshold must be in [0, 1], got %v", m.Threshold)
+	}
+	if _, err := template.ParseStrategy(m.Method); err != nil {
+		return err
+	}
+	return nil
+}
+
+func parseSpeedAlt(speedMain float64, speedAlt string) (float64, error) {
+	switch speedAlt {
+	case "", SPEED_SIGN_AUTO:
+		return speedMain * SPEED_ALTERNATIVE_RATIO, nil
+	case SPEED_SIGN_COPY:
+		return speedMain, nil
+	}
+	v, err := strconv.ParseFloat(speedAlt, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid speed_alt %q: %w", speedAlt, err)
+	}
+	return v, nil
+}
+
+// MetaPath 模板图片对应的附属元数据路径。
+func MetaPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + META_SUFFIX
+}
+
+// LoadMeta 读取模板的元数据：附属文件优先，其次所在目录的清单；都没有时返回 ErrNoMeta。
+// 未知字段视为错误以便发现拼写问题。
+func LoadMeta(path string) (Meta, error) {
+	metaPath := MetaPath(path)
+	data, err := os.ReadFile(metaPath)
+	switch {
+	case err == nil:
+		var m Meta
+		if err := jsonv2.Unmarshal(data, &m, jsonv2.RejectUnknownMembers(true)); err != nil {
+			return Meta{}, fmt.Errorf("%s: %w", metaPath, err)
+		}
+		if err := m.Validate(); err != nil {
+			return Meta{}, fmt.Errorf("%s: %w", metaPath, err)
+		}
+		return m, nil
+	case !errors.Is(err, os.ErrNotExist):
+		return Meta{}, err
+	}
+
+	manifest, err := LoadManifest(filepath.Dir(path))
+	if err != nil {
+		return Meta{}, err
+	}
+	m, ok := manifest[filepath.Base(path)]
+	if !ok {
+		return Meta{}, ErrNoMeta
+	}
+	if err := m.Validate(); err != nil {
+		return Meta{}, fmt.Errorf("%s: %s: %w", MANIFEST_NAME, filepath.Base(path), err)
+	}
+	return m, nil
+}
+
+// LoadManifest 读取目录清单（图片文件名 -> 元数据），不存在时返回空清单。
+func LoadManifest(dir string) (map[string]Meta, error) {
+	path := filepath.Join(dir, MANIFEST_NAME)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest map[string]Meta
+	if err := jsonv2.Unmarshal(data, &manifest, jsonv2.RejectUnknownMembers(true)); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return manifest, nil
+}
+
+// MetaFromFileName 由文件名参数（旧格式）生成元数据。
+func MetaFromFileName(path string) (Meta, error) {
+	name, params, err := ParseFileName(path)
+	if err != nil {
+		return Meta{}, err
+	}
+	speedMain, err := strconv.ParseFloat(params[PARAM_SPEED_MAIN], 64)
+	if err != nil {
+		return Meta{}, err
+	}
+	m := Meta{
+		Name:      name,
+		Class:     params[PARAM_CLASS],
+		SpeedMain: speedMain,
+		SpeedAlt:  params[PARAM_SPEED_ALT],
+	}
+	if m.SpeedAlt == SPEED_SIGN_AUTO {
+		m.SpeedAlt = ""
+	}
+	return m, nil
+}
+
+// ResolveMeta 依次尝试附属文件、目录清单与文件名参数。
+func ResolveMeta(path string) (Meta, error) {
+	m, err := LoadMeta(path)
+	if errors.Is(err, ErrNoMeta) {
+		return MetaFromFileName(path)
+	}
+	return m, err
+}
+
+// WriteMeta 把元数据写为 path 对应的附属文件。
+func WriteMeta(path string, m Meta) error {
+	data, err := jsonv2.Marshal(m, jsontext.WithIndent("  "))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(MetaPath(path), append(data, '\n'), 0o644)
+}
+
+// UpdateMeta 改写 path 现有的元数据：有附属文件写附属文件，在目录清单中则改写清单，否则新建附属文件。
+func UpdateMeta(path string, m Meta) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(MetaPath(path)); err == nil {
+		return WriteMeta(path, m)
+	}
+	dir := filepath.Dir(path)
+	manifest, err := LoadManifest(dir)
+	if err != nil {
+		return err
+	}
+	if _, ok := manifest[filepath.Base(path)]; !ok {
+		return WriteMeta(path, m)
+	}
+	manifest[filepath.Base(path)] = m
+	return WriteManifest(dir, manifest)
+}
+
+// WriteManifest 把整个目录的元数据写为清单。
+func WriteManifest(dir string, manifest map[string]Meta) error {
+	data, err := jsonv2.Marshal(manifest, jsontext.WithIndent("  "), jsonv2.Deterministic(true))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, MANIFEST_NAME), append(data, '\n'), 0o644)
+}
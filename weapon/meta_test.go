@@ -0,0 +1,112 @@
+package weapon
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveMeta(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 仅文件名参数：回退解析
+	legacy := filepath.Join(dir, "{SMG_9_==} 9x19VSN.png")
+	m, err := ResolveMeta(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "9x19VSN" || m.Class != "SMG" || m.SpeedMain != 9 || m.SpeedAlt != SPEED_SIGN_COPY {
+		t.Fatalf("filename meta = %+v", m)
+	}
+
+	// 附属文件优先于文件名
+	write("{SMG_9_==} 9x19VSN.json", `{
+		"name": "9x19VSN",
+		"class": "Submachine Gun",
+		"speed_main": 9.5,
+		"aliases": ["VSN"],
+		"threshold": 0.85,
+		"method": "ccorr_normed",
+		"mask": true,
+		"roi_group": "primary",
+		"tags": ["r6s"]
+	}`)
+	m, err = ResolveMeta(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.SpeedMain != 9.5 || m.Threshold != 0.85 || m.RoiGroup != "primary" || m.Mask == nil || !*m.Mask {
+		t.Fatalf("sidecar meta = %+v", m)
+	}
+
+	// 目录清单
+	plain := filepath.Join(dir, "p90.png")
+	if _, err := LoadMeta(plain); !errors.Is(err, ErrNoMeta) {
+		t.Fatalf("err = %v, want ErrNoMeta", err)
+	}
+	write(MANIFEST_NAME, `{"p90.png": {"name": "P90", "class": "SMG", "speed_main": 8, "speed_alt": "6.5"}}`)
+	m, err = ResolveMeta(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alt, _ := parseSpeedAlt(m.SpeedMain, m.SpeedAlt); m.Name != "P90" || alt != 6.5 {
+		t.Fatalf("manifest meta = %+v", m)
+	}
+
+	// 拼写错误与非法取值
+	write("typo.json", `{"name": "X", "class": "AR", "treshold": 0.9}`)
+	if _, err := LoadMeta(filepath.Join(dir, "typo.png")); err == nil {
+		t.Fatal("unknown field accepted")
+	}
+	write("bad.json", `{"name": "X", "class": "XYZ"}`)
+	if _, err := LoadMeta(filepath.Join(dir, "bad.png")); err == nil {
+		t.Fatal("unknown class accepted")
+	}
+}
+
+func TestWriteMetaRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "{AR_12.5_--} R4-C.png")
+	m, err := MetaFromFileName(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteMeta(path, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadMeta(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "R4-C" || got.Class != "AR" || got.SpeedMain != 12.5 || got.SpeedAlt != "" {
+		t.Fatalf("round trip = %+v", got)
+	}
+}
+
+// TestUpdateMeta 元数据写回其来源：清单中的条目改写清单，不新建附属文件。
+func TestUpdateMeta(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "p90.png")
+	if err := WriteManifest(dir, map[string]Meta{"p90.png": {Name: "P90", Class: "SMG", SpeedMain: 8}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateMeta(path, Meta{Name: "P90", Class: "SMG", SpeedMain: 9}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(MetaPath(path)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("sidecar created: %v", err)
+	}
+	if m, err := LoadMeta(path); err != nil || m.SpeedMain != 9 {
+		t.Fatalf("manifest meta = %+v, %v", m, err)
+	}
+	if err := UpdateMeta(path, Meta{Name: "P90", Class: "XYZ"}); err == nil {
+		t.Fatal("invalid meta written")
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"io"
 	"math"
 	"path/filepath"
-	"strconv"
+	"slices"
 	"strings"
 
 	"github.com/Miuzarte/GoCVStreamer/template"
@@ -159,6 +159,7 @@ type ClassDetail struct {
 	Offset int
 }
 
+// classDetails 内置（R6S）类别表，未加载 -game 对应的数据文件时使用
 var classDetails = [...]ClassDetail{
 	0: {},
 
@@ -295,9 +296,9 @@ var classDetails = [...]ClassDetail{
 	},
 }
 
+// Detail 在当前生效的类别表中解析。
 func (c Class) Detail() *ClassDetail {
-	// let it panic if oob
-	return &classDetails[c]
+	return ActiveCatalog().Detail(c)
 }
 
 func (c Class) ToString(short bool) string {
@@ -312,19 +313,9 @@ func (c Class) String() string {
 	return c.ToString(false)
 }
 
+// ParseClass 在当前生效的类别表中查找，找不到时返回 0；需要错误信息时用 Catalog.Lookup。
 func ParseClass(className string) Class {
-	// 先判断短名字, 一般用这个
-	for _, wcd := range classDetails {
-		if className == wcd.ShortName {
-			return wcd.Class
-		}
-	}
-	for _, wcd := range classDetails {
-		if className == wcd.FullName {
-			return wcd.Class
-		}
-	}
-	return 0
+	return ActiveCatalog().Parse(className)
 }
 
 const (
@@ -340,7 +331,17 @@ const (
 	SPEED_SIGN_COPY         = "==" // *1
 )
 
+// ID 模板在进程内的稳定标识，由 weapons.Registry 分配，重命名/重载时保持不变
+type ID uint64
+
+// ID_NONE 无模板
+const ID_NONE ID = 0
+
 type Weapon struct {
+	ID ID
+	// Gen 最近一次加载该模板时注册表的代数
+	Gen uint64
+
 	Path  string
 	Name  string
 	Class Class
@@ -352,6 +353,14 @@ type Weapon struct {
 	SpeedAltInt   int
 	SpeedAltFrac  uint
 
+	Aliases []string
+	// Threshold 命中阈值，0 沿用 ROI 或策略的默认值
+	Threshold float32
+	// Strategy 匹配策略，nil 沿用 ROI 的设置
+	Strategy template.Strategy
+	RoiGroup string
+	Tags     []string
+
 	template.Template
 }
 
@@ -375,51 +384,57 @@ func New(path string, createMask bool, flag gocv.IMReadFlag) (*Weapon, error) {
 	return w, nil
 }
 
+// DecodeFrom 读取模板图片与元数据（附属文件、目录清单，最后是文件名参数）。
 func (w *Weapon) DecodeFrom(path string, createMask bool, flag gocv.IMReadFlag) error {
-	name, params, err := ParseFileName(path)
+	meta, err := ResolveMeta(path)
 	if err != nil {
 		return err
 	}
 
 	if w.Name != "" {
 		// overwriting
-		if name != w.Name {
+		if meta.Name != w.Name {
 			// wried
 			log.Warn().
 				Str("oldName", w.Name).
-				Str("newName", name).
+				Str("newName", meta.Name).
 				Msg("weapon name changed")
 		}
 	}
 
 	w.Path = path
-	w.Name = name
-	// [PARAM_CLASS]
-	w.Class = ParseClass(params[PARAM_CLASS])
+	w.Name = meta.Name
+	w.Class, err = ActiveCatalog().Lookup(meta.Class)
+	if err != nil {
+		return fmt.Errorf("template %s: %w", filepath.Base(path), err)
+	}
 	// w.Type = w.Class.Detail().Type
 
-	// [PARAM_SPEED_MAIN]
-	w.SpeedMain, err = strconv.ParseFloat(params[PARAM_SPEED_MAIN], 64)
+	w.SpeedMain = meta.SpeedMain
+	integer, fraction := math.Modf(w.SpeedMain)
+	w.SpeedMainInt, w.SpeedMainFrac = int(integer), uint(math.Round(fraction*10))
+
+	w.SpeedAlt, err = parseSpeedAlt(meta.SpeedMain, meta.SpeedAlt)
 	if err != nil {
 		return err
 	}
-	integer, fraction := math.Modf(w.SpeedMain)
-	w.SpeedMainInt, w.SpeedMainFrac = int(integer), uint(math.Round(fraction*10))
+	integer, fraction = math.Modf(w.SpeedAlt)
+	w.SpeedAltInt, w.SpeedAltFrac = int(integer), uint(math.Round(fraction*10))
 
-	// [PARAM_SPEED_ALT]
-	switch params[PARAM_SPEED_ALT] {
-	case SPEED_SIGN_AUTO:
-		w.SpeedAlt = w.SpeedMain * SPEED_ALTERNATIVE_RATIO
-	case SPEED_SIGN_COPY:
-		w.SpeedAlt = w.SpeedMain
-	default:
-		w.SpeedAlt, err = strconv.ParseFloat(params[PARAM_SPEED_ALT], 64)
+	w.Aliases = meta.Aliases
+	w.Threshold = meta.Threshold
+	w.Strategy = nil
+	if meta.Method != "" {
+		w.Strategy, err = template.ParseStrategy(meta.Method)
 		if err != nil {
 			return err
 		}
 	}
-	integer, fraction = math.Modf(w.SpeedAlt)
-	w.SpeedAltInt, w.SpeedAltFrac = int(integer), uint(math.Round(fraction*10))
+	w.RoiGroup = meta.RoiGroup
+	w.Tags = meta.Tags
+	if meta.Mask != nil {
+		createMask = *meta.Mask
+	}
 
 	err = w.Template.IMReadFrom(path, createMask, flag)
 	if err != nil {
@@ -428,6 +443,11 @@ func (w *Weapon) DecodeFrom(path string, createMask bool, flag gocv.IMReadFlag)
 	return nil
 }
 
+// HasName 名称或别名之一等于 name。
+func (w *Weapon) HasName(name string) bool {
+	return w.Name == name || slices.Contains(w.Aliases, name)
+}
+
 func (w *Weapon) SpeedMainWOffset() (int, uint) {
 	if w.SpeedMain == 0 {
 		return 0, 0
@@ -0,0 +1,130 @@
+package weapon
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// TEMPLATE_EXT 新建模板的图片格式
+const TEMPLATE_EXT = ".png"
+
+// ErrTemplateExists 目标目录已有同名模板。
+var ErrTemplateExists = errors.New("template already exists")
+
+// FileName 按旧格式生成模板文件名，如 "{SMG_9_==} 9x19VSN.png"；
+// 附属元数据优先，文件名参数只作无附属文件时的备用。
+func FileName(m Meta) (string, error) {
+	if err := m.Validate(); err != nil {
+		return "", err
+	}
+	if strings.ContainsAny(m.Name, `{}<>:"/\|?*`) || strings.TrimSpace(m.Name) != m.Name ||
+		strings.HasPrefix(m.Name, "__") {
+		return "", fmt.Errorf("invalid template name %q", m.Name)
+	}
+	c, _ := ActiveCatalog().Lookup(m.Class)
+	return fmt.Sprintf("{%s_%s_%s} %s%s",
+		c.ToString(true),
+		strconv.FormatFloat(m.SpeedMain, 'f', -1, 64),
+		cmp.Or(m.SpeedAlt, SPEED_SIGN_AUTO),
+		m.Name, TEMPLATE_EXT,
+	), nil
+}
+
+// WriteTemplate 把 img 写为 dir 下的新模板并返回图片路径。
+// 先写附属元数据，再把图片经临时文件改名到位，热重载看到图片时元数据已就绪。
+func WriteTemplate(dir string, img image.Image, m Meta) (string, error) {
+	name, err := FileName(m)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("%w: %s", ErrTemplateExists, path)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	if err := WriteMeta(path, m); err != nil {
+		return "", err
+	}
+
+	// 临时文件以 "__" 开头，热重载不理会
+	f, err := os.CreateTemp(dir, "__capture*.tmp")
+	if err != nil {
+		os.Remove(MetaPath(path))
+		return "", err
+	}
+	err = png.Encode(f, img)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		os.Remove(MetaPath(path))
+		return "", err
+	}
+	return path, nil
+}
+
+// RemoveTemplate 删除模板图片与其附属元数据（不存在的附属文件忽略）。
+func RemoveTemplate(path string) error {
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	if err := os.Remove(MetaPath(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// MoveTemplate 把模板图片连同附属元数据（或目录清单中的条目）移到 newPath；
+// newPath 已是另一个文件时返回 ErrTemplateExists。
+func MoveTemplate(oldPath, newPath string) error {
+	if oldPath == newPath {
+		return nil
+	}
+	if info, err := os.Stat(newPath); err == nil {
+		// 大小写不敏感的文件系统上只改大小写时是同一个文件
+		if old, err := os.Stat(oldPath); err != nil || !os.SameFile(info, old) {
+			return fmt.Errorf("%w: %s", ErrTemplateExists, newPath)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	if err := os.Rename(MetaPath(oldPath), MetaPath(newPath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	dir := filepath.Dir(oldPath)
+	manifest, err := LoadManifest(dir)
+	if err != nil {
+		return err
+	}
+	m, ok := manifest[filepath.Base(oldPath)]
+	if !ok {
+		return nil
+	}
+	delete(manifest, filepath.Base(oldPath))
+	if filepath.Dir(newPath) == dir {
+		manifest[filepath.Base(newPath)] = m
+		return WriteManifest(dir, manifest)
+	}
+	if err := WriteManifest(dir, manifest); err != nil {
+		return err
+	}
+	return WriteMeta(newPath, m)
+}
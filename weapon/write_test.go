@@ -0,0 +1,127 @@
+package weapon
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteTemplate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "templates")
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+
+	m := Meta{Name: "R4-C", Class: "Assault Rifle", SpeedMain: 12.5}
+	path, err := WriteTemplate(dir, img, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "{AR_12.5_--} R4-C.png"); path != want {
+		t.Fatalf("path = %q, want %q", path, want)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Bounds() != img.Bounds() {
+		t.Fatalf("bounds = %v", decoded.Bounds())
+	}
+	if r, _, _, _ := decoded.At(1, 1).RGBA(); r != 0xffff {
+		t.Fatal("pixel not preserved")
+	}
+
+	// 附属元数据优先于文件名
+	got, err := ResolveMeta(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != m.Name || got.Class != m.Class || got.SpeedMain != m.SpeedMain {
+		t.Fatalf("meta = %+v", got)
+	}
+
+	if _, err := WriteTemplate(dir, img, m); !errors.Is(err, ErrTemplateExists) {
+		t.Fatalf("err = %v, want ErrTemplateExists", err)
+	}
+	for _, name := range []string{"", "a/b", "__hidden", " padded", "{x}"} {
+		if _, err := WriteTemplate(dir, img, Meta{Name: name, Class: "AR"}); err == nil {
+			t.Fatalf("name %q accepted", name)
+		}
+	}
+
+	// 不留下临时文件
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries = %v", entries)
+	}
+
+	if err := RemoveTemplate(path); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("entries after remove = %v", entries)
+	}
+}
+
+func TestMoveTemplate(t *testing.T) {
+	dir := t.TempDir()
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	a, err := WriteTemplate(dir, img, Meta{Name: "A", Class: "AR", SpeedMain: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := WriteTemplate(dir, img, Meta{Name: "B", Class: "SMG", SpeedMain: 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 附属文件随图片移动
+	a2 := filepath.Join(dir, "renamed.png")
+	if err := MoveTemplate(a, a2); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := LoadMeta(a2); err != nil || m.Name != "A" {
+		t.Fatalf("meta = %+v, %v", m, err)
+	}
+	if _, err := os.Stat(MetaPath(a)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("old sidecar left behind")
+	}
+	if err := MoveTemplate(a2, b); !errors.Is(err, ErrTemplateExists) {
+		t.Fatalf("err = %v, want ErrTemplateExists", err)
+	}
+
+	// 目录清单中的条目改键；移出目录时改为附属文件
+	os.Remove(MetaPath(b))
+	if err := WriteManifest(dir, map[string]Meta{filepath.Base(b): {Name: "B", Class: "SMG", SpeedMain: 8}}); err != nil {
+		t.Fatal(err)
+	}
+	b2 := filepath.Join(dir, "b.png")
+	if err := MoveTemplate(b, b2); err != nil {
+		t.Fatal(err)
+	}
+	if manifest, _ := LoadManifest(dir); len(manifest) != 1 || manifest["b.png"].SpeedMain != 8 {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+	b3 := filepath.Join(dir, "sub", "b.png")
+	if err := MoveTemplate(b2, b3); err != nil {
+		t.Fatal(err)
+	}
+	if manifest, _ := LoadManifest(dir); len(manifest) != 0 {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+	if m, err := LoadMeta(b3); err != nil || m.SpeedMain != 8 {
+		t.Fatalf("meta = %+v, %v", m, err)
+	}
+}
@@ -0,0 +1,155 @@
+package weapons
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Miuzarte/GoCVStreamer/weapon"
+)
+
+// ErrTemplateNotFound 模板不在注册表中（可能已被删除或尚未加载）。
+var ErrTemplateNotFound = errors.New("template not found")
+
+// Library 模板目录的管理操作：上传、重命名、改类别、删除与重新对账。
+// 变更逐个执行，文件改完后立即经 Watcher.Sync 同步到 Registry，不等文件事件；
+// 稍后到达的文件事件再对账一次，结果不变。
+type Library struct {
+	mu      sync.Mutex
+	watcher *Watcher
+}
+
+func NewLibrary(watcher *Watcher) *Library {
+	return &Library{watcher: watcher}
+}
+
+// Dir 模板目录。
+func (l *Library) Dir() string {
+	return l.watcher.cfg.Dir
+}
+
+func (l *Library) Registry() *Registry {
+	return l.watcher.reg
+}
+
+// Failed 当前加载失败的文件。
+func (l *Library) Failed() map[string]error {
+	return l.watcher.Failed()
+}
+
+// Add 把 img 写为目录下的新模板并加载，返回图片路径。
+func (l *Library) Add(img image.Image, m weapon.Meta) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	path, err := weapon.WriteTemplate(l.Dir(), img, m)
+	if err != nil {
+		return "", err
+	}
+	if r := l.watcher.Sync([]string{path}); r.Failed[path] != nil {
+		return path, r.Failed[path]
+	}
+	return path, nil
+}
+
+// Rename 修改模板名称，文件名随之更新，ID 不变。
+func (l *Library) Rename(id weapon.ID, name string) (string, error) {
+	return l.Update(id, name, "")
+}
+
+// SetClass 修改模板类别，文件名随之更新，ID 不变。
+func (l *Library) SetClass(id weapon.ID, class string) (string, error) {
+	return l.Update(id, "", class)
+}
+
+// Update 修改模板名称与类别（空串不修改），文件名随之更新，ID 不变；
+// 与当前值相同时不移动文件，返回当前路径。
+func (l *Library) Update(id weapon.ID, name, class string) (string, error) {
+	return l.update(id, func(wp *weapon.Weapon, m *weapon.Meta) (changed bool) {
+		if name != "" && name != wp.Name {
+			m.Name, changed = name, true
+		}
+		if class != "" && weapon.ParseClass(class) != wp.Class {
+			m.Class, changed = class, true
+		}
+		return
+	})
+}
+
+// update 读出元数据，经 fn 修改后按新文件名移动并写回，返回新路径；fn 返回 false 时不做修改。
+// 模板的查找与比较都在 l.mu 内进行。
+func (l *Library) update(id weapon.ID, fn func(wp *weapon.Weapon, m *weapon.Meta) bool) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	wp, ok := l.Registry().Get(id)
+	if !ok {
+		return "", ErrTemplateNotFound
+	}
+	oldPath := wp.Path
+	m, err := weapon.ResolveMeta(oldPath)
+	if err != nil {
+		return "", err
+	}
+	if !fn(wp, &m) {
+		return oldPath, nil
+	}
+	name, err := weapon.FileName(m)
+	if err != nil {
+		return "", err
+	}
+	newPath := filepath.Join(filepath.Dir(oldPath), name)
+	if err := weapon.MoveTemplate(oldPath, newPath); err != nil {
+		return "", err
+	}
+	if err := weapon.UpdateMeta(newPath, m); err != nil {
+		return newPath, err
+	}
+	if r := l.watcher.Move(oldPath, newPath); r.Failed[newPath] != nil {
+		return newPath, r.Failed[newPath]
+	}
+	log.Info().
+		Str("from", oldPath).
+		Str("to", newPath).
+		Msg("template updated")
+	return newPath, nil
+}
+
+// Remove 给模板文件名加上忽略前缀（不真正删除），返回移动后的路径；
+// 同名的已删除模板存在时再加上时间。
+func (l *Library) Remove(id weapon.ID) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prefix := l.watcher.cfg.IgnorePrefix
+	if prefix == "" {
+		return "", errors.New("no ignore prefix configured")
+	}
+	wp, ok := l.Registry().Get(id)
+	if !ok {
+		return "", ErrTemplateNotFound
+	}
+	oldPath := wp.Path
+	dir, base := filepath.Split(oldPath)
+	newPath := filepath.Join(dir, prefix+base)
+	if _, err := os.Stat(newPath); err == nil {
+		newPath = filepath.Join(dir, fmt.Sprintf("%s%s_%s", prefix, time.Now().Format("20060102-150405"), base))
+	}
+	if err := weapon.MoveTemplate(oldPath, newPath); err != nil {
+		return "", err
+	}
+	l.watcher.Sync([]string{oldPath})
+	log.Info().
+		Str("from", oldPath).
+		Str("to", newPath).
+		Msg("template removed")
+	return newPath, nil
+}
+
+// Reload 对账整个目录与注册表。
+func (l *Library) Reload() SyncReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.watcher.Rescan()
+}
@@ -0,0 +1,89 @@
+package weapons
+
+import (
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Miuzarte/GoCVStreamer/weapon"
+)
+
+func TestLibrary(t *testing.T) {
+	dir := t.TempDir()
+	reg, w := newTestWatcher(t, dir, nil)
+	lib := NewLibrary(w)
+
+	img := image.NewGray(image.Rect(0, 0, 8, 4))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	path, err := lib.Add(img, weapon.Meta{Name: "A", Class: "AR", SpeedMain: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := reg.IDByPath(path)
+	if id == weapon.ID_NONE {
+		t.Fatal("added template not loaded")
+	}
+	if _, err := lib.Add(img, weapon.Meta{Name: "A", Class: "AR", SpeedMain: 10}); !errors.Is(err, weapon.ErrTemplateExists) {
+		t.Fatalf("err = %v, want ErrTemplateExists", err)
+	}
+
+	// 改类别：文件名更新，ID 不变
+	path, err = lib.SetClass(id, "SMG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != "{SMG_10_--} A.png" || reg.IDByPath(path) != id {
+		t.Fatalf("path = %q, id = %d", path, reg.IDByPath(path))
+	}
+	if wp, _ := reg.Get(id); wp.Class.ToString(true) != "SMG" {
+		t.Fatalf("class = %v", wp.Class)
+	}
+	if _, err := lib.SetClass(id, "XYZ"); err == nil {
+		t.Fatal("unknown class accepted")
+	}
+
+	// 重命名：ID 不变，旧文件与附属元数据不再存在
+	path, err = lib.Rename(id, "B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reg.Len() != 1 || reg.IDByName("B") != id || reg.IDByPath(path) != id || reg.IDByName("A") != weapon.ID_NONE {
+		t.Fatalf("id = %d, templates = %v", reg.IDByName("B"), reg.Paths())
+	}
+	// 与当前值相同时不移动文件
+	if same, err := lib.Update(id, "B", "SMG"); err != nil || same != path {
+		t.Fatalf("no-op update = %q, %v; want %q", same, err, path)
+	}
+
+	// 删除：加上忽略前缀，可重复删除同名模板
+	removed, err := lib.Remove(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(removed) != "__"+filepath.Base(path) || reg.Len() != 0 {
+		t.Fatalf("removed = %q, templates = %v", removed, reg.Paths())
+	}
+	if _, err := os.Stat(weapon.MetaPath(removed)); err != nil {
+		t.Fatal("sidecar not moved with the template")
+	}
+	if _, err := lib.Remove(id); !errors.Is(err, ErrTemplateNotFound) {
+		t.Fatalf("err = %v, want ErrTemplateNotFound", err)
+	}
+	path, err = lib.Add(img, weapon.Meta{Name: "B", Class: "SMG", SpeedMain: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := lib.Remove(reg.IDByPath(path)); err != nil || again == removed {
+		t.Fatalf("second remove = %q, %v", again, err)
+	}
+
+	// 外部新增的文件经 Reload 加载
+	writeTemplate(t, dir, "{AR_8_--} C.png")
+	if r := lib.Reload(); len(r.Added) != 1 || reg.Len() != 1 {
+		t.Fatalf("reload = %+v", r)
+	}
+}
@@ -0,0 +1,331 @@
+package weapons
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/Miuzarte/GoCVStreamer/template"
+	"github.com/Miuzarte/GoCVStreamer/utils"
+	"github.com/Miuzarte/GoCVStreamer/weapon"
+	"gocv.io/x/gocv"
+)
+
+// Registry 以稳定 ID 管理已加载的模板：增删改不影响其他模板的 ID，
+// 持有旧 ID 的一方可通过 Get 得知模板是否仍然存在。
+// 每次变更使代数加一，读取方可据此判断缓存是否过期。
+type Registry struct {
+	mu         sync.RWMutex
+	byId       map[weapon.ID]*weapon.Weapon
+	order      Weapons // 加载顺序，即匹配顺序
+	nextId     weapon.ID
+	generation uint64
+	// preprocess 加载时应用于每个模板的预处理链
+	preprocess template.Preprocess
+}
+
+func NewRegistry() *Registry {
+	return &Registry{byId: make(map[weapon.ID]*weapon.Weapon)}
+}
+
+// Generation 当前代数，每次增删改后递增。
+func (r *Registry) Generation() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.generation
+}
+
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.order)
+}
+
+// Read 在读锁内以加载顺序访问全部模板；fn 不得保留 ws，也不得调用 Registry 的写方法。
+func (r *Registry) Read(fn func(ws Weapons)) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fn(r.order)
+}
+
+// List 按加载顺序返回模板列表的拷贝。
+func (r *Registry) List() Weapons {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.order)
+}
+
+// Get 按 ID 查找；模板已被删除时返回 false。
+func (r *Registry) Get(id weapon.ID) (*weapon.Weapon, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	w, ok := r.byId[id]
+	return w, ok
+}
+
+// IDByName 按名称（其次别名）查找，找不到时返回 ID_NONE。
+func (r *Registry) IDByName(name string) weapon.ID {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if i := r.order.IndexByName(name); i >= 0 {
+		return r.order[i].ID
+	}
+	return weapon.ID_NONE
+}
+
+// IDByPath 找不到时返回 ID_NONE。
+func (r *Registry) IDByPath(path string) weapon.ID {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if i := r.order.IndexByPath(path); i >= 0 {
+		return r.order[i].ID
+	}
+	return weapon.ID_NONE
+}
+
+// SetPreprocess 设置模板的预处理链并重新处理已加载的模板，之后加载的模板同样应用。
+func (r *Registry) SetPreprocess(p template.Preprocess) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if slices.Equal(p, r.preprocess) {
+		return nil
+	}
+	r.preprocess = slices.Clone(p)
+	r.generation++
+	var errs []error
+	for _, w := range r.order {
+		w.Gen = r.generation
+		if err := w.Template.SetPreprocess(p); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", w.Path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Preprocess 当前的模板预处理链。
+func (r *Registry) Preprocess() template.Preprocess {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.preprocess
+}
+
+// Add 加载模板并分配新 ID。
+func (r *Registry) Add(path string, createMask bool, flag gocv.IMReadFlag) (weapon.ID, error) {
+	w, err := weapon.New(path, createMask, flag)
+	if err != nil {
+		return weapon.ID_NONE, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := w.Template.SetPreprocess(r.preprocess); err != nil {
+		w.Close()
+		return weapon.ID_NONE, fmt.Errorf("%s: %w", path, err)
+	}
+	r.nextId++
+	r.generation++
+	w.ID, w.Gen = r.nextId, r.generation
+	r.byId[w.ID] = w
+	r.order = append(r.order, w)
+	return w.ID, nil
+}
+
+// Reload 从 path 重新读取 id 对应的模板（如重命名后），ID 不变；失败时保留原模板。
+func (r *Registry) Reload(id weapon.ID, path string, createMask bool, flag gocv.IMReadFlag) error {
+	r.mu.RLock()
+	_, ok := r.byId[id]
+	r.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("template %d not found", id)
+	}
+
+	w, err := weapon.New(path, createMask, flag)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old, ok := r.byId[id]
+	if !ok {
+		// 读取期间被删除
+		w.Close()
+		return fmt.Errorf("template %d not found", id)
+	}
+	if err := w.Template.SetPreprocess(r.preprocess); err != nil {
+		w.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	r.generation++
+	w.ID, w.Gen = id, r.generation
+	r.byId[id] = w
+	r.order[slices.Index(r.order, old)] = w
+	return old.Close()
+}
+
+// Remove 删除并释放模板。
+func (r *Registry) Remove(id weapon.ID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w, ok := r.byId[id]
+	if !ok {
+		return nil
+	}
+	r.generation++
+	delete(r.byId, id)
+	r.order = slices.DeleteFunc(r.order, func(x *weapon.Weapon) bool { return x == w })
+	return w.Close()
+}
+
+// RemoveByPath 删除 path 对应的全部模板，返回删除数量。
+func (r *Registry) RemoveByPath(path string) (deleted int, err error) {
+	for {
+		id := r.IDByPath(path)
+		if id == weapon.ID_NONE {
+			return
+		}
+		if err = r.Remove(id); err != nil {
+			return
+		}
+		deleted++
+	}
+}
+
+// ReadFrom 加载目录下的全部模板，参数同 Weapons.ReadFrom。
+// 单个文件加载失败时记录日志并跳过，其余照常加载；返回合并后的全部错误。
+func (r *Registry) ReadFrom(dir string, depth int, suffix string, withoutPrefix string, createMask bool, flag gocv.IMReadFlag) error {
+	depth = max(depth, 1)
+	paths, err := utils.WalkDir(dir, depth, suffix, withoutPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to walk %s: %w", suffix, err)
+	}
+	var errs []error
+	for _, path := range paths {
+		if _, err := r.Add(path, createMask, flag); err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("template failed to load, skipped")
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(path), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Change 批量更新中的一项：Weapon 为 nil 表示移除 Path 对应的模板。
+type Change struct {
+	Path   string
+	Weapon *weapon.Weapon
+	// From 非空时 Path 由该路径移动而来（同批应移除 From），沿用其 ID 与顺序，不按名称猜测
+	From string
+}
+
+// SwapResult 批量更新的结果，均为模板路径。
+type SwapResult struct {
+	Added    []string
+	Reloaded []string
+	// Renamed 新路径 -> 旧路径
+	Renamed map[string]string
+	Removed []string
+}
+
+func (r SwapResult) Empty() bool {
+	return len(r.Added) == 0 && len(r.Reloaded) == 0 && len(r.Renamed) == 0 && len(r.Removed) == 0
+}
+
+// Swap 在一次写锁内应用整批变更，代数只加一：
+// 路径已存在的原地替换并保留 ID；新路径指明了来源（From），或与同批被移除的模板同名时视为重命名，
+// 沿用其 ID 与顺序。
+// 被替换、移除的模板在返回前释放；新模板按当前预处理链处理，失败的按原图加入并记录日志。
+func (r *Registry) Swap(changes []Change) (res SwapResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var orphans Weapons
+	loads := 0
+	for _, c := range changes {
+		if c.Weapon != nil {
+			loads++
+			continue
+		}
+		if i := r.order.IndexByPath(c.Path); i >= 0 && !slices.Contains(orphans, r.order[i]) {
+			orphans = append(orphans, r.order[i])
+		}
+	}
+	if loads == 0 && len(orphans) == 0 {
+		return
+	}
+
+	r.generation++
+	replace := func(old, w *weapon.Weapon) {
+		w.ID, w.Gen = old.ID, r.generation
+		r.byId[w.ID] = w
+		r.order[slices.Index(r.order, old)] = w
+		old.Close()
+	}
+	for _, c := range changes {
+		w := c.Weapon
+		if w == nil {
+			continue
+		}
+		if err := w.Template.SetPreprocess(r.preprocess); err != nil {
+			log.Warn().
+				Err(err).
+				Str("path", c.Path).
+				Msg("failed to preprocess template")
+		}
+		if i := r.order.IndexByPath(c.Path); i >= 0 {
+			replace(r.order[i], w)
+			res.Reloaded = append(res.Reloaded, c.Path)
+			continue
+		}
+		i := -1
+		if c.From != "" {
+			i = orphans.IndexByPath(c.From)
+		}
+		if i < 0 {
+			i = orphans.IndexByName(w.Name)
+		}
+		if i >= 0 {
+			old := orphans[i]
+			orphans = slices.Delete(orphans, i, i+1)
+			if res.Renamed == nil {
+				res.Renamed = make(map[string]string)
+			}
+			res.Renamed[c.Path] = old.Path
+			replace(old, w)
+			continue
+		}
+		r.nextId++
+		w.ID, w.Gen = r.nextId, r.generation
+		r.byId[w.ID] = w
+		r.order = append(r.order, w)
+		res.Added = append(res.Added, c.Path)
+	}
+	for _, old := range orphans {
+		delete(r.byId, old.ID)
+		r.order = slices.DeleteFunc(r.order, func(x *weapon.Weapon) bool { return x == old })
+		old.Close()
+		res.Removed = append(res.Removed, old.Path)
+	}
+	return
+}
+
+// Paths 全部已加载模板的路径。
+func (r *Registry) Paths() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	paths := make([]string, len(r.order))
+	for i, w := range r.order {
+		paths[i] = w.Path
+	}
+	return paths
+}
+
+// Close 释放并移除全部模板；ID 继续递增，不会与之前的重复。
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generation++
+	clear(r.byId)
+	return r.order.Close()
+}
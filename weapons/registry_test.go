@@ -0,0 +1,168 @@
+package weapons
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Miuzarte/GoCVStreamer/weapon"
+	"gocv.io/x/gocv"
+)
+
+// writeTemplate 写一张小的纯色 PNG 作为模板。
+func writeTemplate(t *testing.T, dir, name string) string {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 8, 4))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	img.Set(0, 0, color.Gray{Y: 255})
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRegistryStableIDs(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRegistry()
+	defer r.Close()
+
+	a, err := r.Add(writeTemplate(t, dir, "{AR_10_--} A.png"), false, gocv.IMReadGrayScale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := r.Add(writeTemplate(t, dir, "{SMG_9_==} B.png"), false, gocv.IMReadGrayScale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == weapon.ID_NONE || a == b {
+		t.Fatalf("ids = %d, %d", a, b)
+	}
+
+	// 删除前面的模板不影响后面的 ID
+	gen := r.Generation()
+	if err := r.Remove(a); err != nil {
+		t.Fatal(err)
+	}
+	if r.Generation() == gen {
+		t.Fatal("generation not bumped on remove")
+	}
+	if _, ok := r.Get(a); ok {
+		t.Fatal("removed template still found")
+	}
+	if wp, ok := r.Get(b); !ok || wp.Name != "B" {
+		t.Fatalf("Get(b) = %v, %v", wp, ok)
+	}
+
+	// 重命名后重新读取，ID 不变
+	renamed := filepath.Join(dir, "{SMG_8_==} B.png")
+	if err := os.Rename(filepath.Join(dir, "{SMG_9_==} B.png"), renamed); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(b, renamed, false, gocv.IMReadGrayScale); err != nil {
+		t.Fatal(err)
+	}
+	if wp, ok := r.Get(b); !ok || wp.SpeedMain != 8 || wp.ID != b {
+		t.Fatalf("reloaded = %+v", wp)
+	}
+	if r.IDByPath(renamed) != b || r.IDByName("B") != b {
+		t.Fatal("lookup by path/name after reload")
+	}
+	if err := r.Reload(a, renamed, false, gocv.IMReadGrayScale); err == nil {
+		t.Fatal("reload of removed id succeeded")
+	}
+
+	// 全部重载后 ID 不复用
+	r.Close()
+	c, err := r.Add(renamed, false, gocv.IMReadGrayScale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c == a || c == b {
+		t.Fatalf("id %d reused", c)
+	}
+}
+
+// TestRegistryConcurrent 读取方持有的 ID 在并发增删改下要么有效、要么明确不存在。
+func TestRegistryConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRegistry()
+	defer r.Close()
+
+	stable := writeTemplate(t, dir, "{AR_10_--} Stable.png")
+	id, err := r.Add(stable, false, gocv.IMReadGrayScale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	churn := writeTemplate(t, dir, "{SMG_9_==} Churn.png")
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for range 50 {
+			cid, err := r.Add(churn, false, gocv.IMReadGrayScale)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if err := r.Reload(id, stable, false, gocv.IMReadGrayScale); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := r.Remove(cid); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	})
+	for range 4 {
+		wg.Go(func() {
+			for range 200 {
+				wp, ok := r.Get(id)
+				if !ok || wp.ID != id || wp.Name != "Stable" {
+					t.Errorf("Get(%d) = %v, %v", id, wp, ok)
+					return
+				}
+				r.Read(func(ws Weapons) {
+					if ws.IndexByID(id) < 0 {
+						t.Errorf("id %d missing from %d templates", id, len(ws))
+					}
+				})
+			}
+		})
+	}
+	wg.Wait()
+
+	if n := r.Len(); n != 1 {
+		t.Fatalf("len = %d, want 1", n)
+	}
+}
+
+// TestRegistryReadFromSkipsFailed 单个模板加载失败不影响其余模板，错误合并返回。
+func TestRegistryReadFromSkipsFailed(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRegistry()
+	defer r.Close()
+
+	writeTemplate(t, dir, "{AR_10_--} A.png")
+	writeTemplate(t, dir, "{AAA_10_--} Unknown.png")
+	writeTemplate(t, dir, "{SMG_9_==} B.png")
+
+	err := r.ReadFrom(dir, 1, ".png", "__", false, gocv.IMReadGrayScale)
+	if err == nil {
+		t.Fatal("unknown class accepted")
+	}
+	if r.Len() != 2 || r.IDByName("A") == weapon.ID_NONE || r.IDByName("B") == weapon.ID_NONE {
+		t.Fatalf("templates = %v", r.Paths())
+	}
+}
@@ -0,0 +1,301 @@
+package weapons
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Miuzarte/GoCVStreamer/utils"
+	"github.com/Miuzarte/GoCVStreamer/weapon"
+	"github.com/fsnotify/fsnotify"
+	"gocv.io/x/gocv"
+)
+
+// DEFAULT_DEBOUNCE 图片编辑器保存时往往连续触发多次写入/重命名，静默这么久后才处理
+const DEFAULT_DEBOUNCE = 300 * time.Millisecond
+
+type WatcherConfig struct {
+	Dir string
+	// Depth 同 ReadFrom，1 为仅 Dir 下的文件
+	Depth        int
+	Suffix       string
+	IgnorePrefix string
+	CreateMask   bool
+	Flag         gocv.IMReadFlag
+
+	// Debounce 0 为 DEFAULT_DEBOUNCE
+	Debounce time.Duration
+	// OnSync 每批变更处理完后调用（含仅有加载失败的批次）
+	OnSync func(SyncReport)
+}
+
+// SyncReport 一批文件事件的处理结果。
+type SyncReport struct {
+	SwapResult
+	// Failed 加载失败的文件；已加载的旧版本保留不动
+	Failed map[string]error
+}
+
+// Watcher 监视模板目录（含子目录），把增删改与附属元数据的变更同步到 Registry。
+// 一批事件先在锁外加载为影子集合，再经 Registry.Swap 一次性替换。
+type Watcher struct {
+	cfg WatcherConfig
+	reg *Registry
+	fs  *fsnotify.Watcher
+
+	mu     sync.Mutex
+	failed map[string]error
+	// syncMu 文件事件与 Library 的变更各自调用 Sync，逐批执行
+	syncMu sync.Mutex
+}
+
+func NewWatcher(reg *Registry, cfg WatcherConfig) (*Watcher, error) {
+	cfg.Dir = filepath.Clean(cfg.Dir)
+	cfg.Depth = max(cfg.Depth, 1)
+	if !strings.HasPrefix(cfg.Suffix, ".") {
+		cfg.Suffix = "." + cfg.Suffix
+	}
+	if cfg.Debounce <= 0 {
+		cfg.Debounce = DEFAULT_DEBOUNCE
+	}
+
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{
+		cfg:    cfg,
+		reg:    reg,
+		fs:     fw,
+		failed: make(map[string]error),
+	}
+	if err := w.watchTree(cfg.Dir); err != nil {
+		fw.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Watcher) Close() error {
+	return w.fs.Close()
+}
+
+// Failed 当前加载失败的文件，文件修好或删除后移除。
+func (w *Watcher) Failed() map[string]error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return maps.Clone(w.failed)
+}
+
+// Run 处理文件事件直到 ctx 结束。
+func (w *Watcher) Run(ctx context.Context) {
+	pending := make(map[string]struct{})
+	timer := time.NewTimer(w.cfg.Debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			log.Debug().Str("path", event.Name).Str("op", event.Op.String()).Msg("template fs event")
+			pending[event.Name] = struct{}{}
+			timer.Reset(w.cfg.Debounce)
+
+		case <-timer.C:
+			w.Sync(slices.Collect(maps.Keys(pending)))
+			clear(pending)
+
+		case err, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				// 事件丢失，全量对账
+				log.Warn().Err(err).Msg("template watcher overflowed, rescanning")
+				w.Rescan()
+				continue
+			}
+			log.Warn().Err(err).Msg("template watcher error")
+		}
+	}
+}
+
+// Rescan 对账整个目录与注册表。
+func (w *Watcher) Rescan() SyncReport {
+	return w.Sync(append(w.reg.Paths(), w.cfg.Dir))
+}
+
+// Sync 处理一批发生变化的路径（文件、附属元数据、目录清单或目录）。
+func (w *Watcher) Sync(changed []string) SyncReport {
+	return w.sync(changed, nil)
+}
+
+// Move 同步把模板图片从 from 移到 to 的变更，新路径沿用原模板的 ID（即使名称也变了）。
+func (w *Watcher) Move(from, to string) SyncReport {
+	from, to = filepath.Clean(from), filepath.Clean(to)
+	return w.sync([]string{from, to}, map[string]string{to: from})
+}
+
+// sync moved 为新路径 -> 旧路径。
+func (w *Watcher) sync(changed []string, moved map[string]string) SyncReport {
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+	var changes []Change
+	report := SyncReport{Failed: make(map[string]error)}
+	for _, path := range w.expand(changed) {
+		if !w.wanted(path) {
+			changes = append(changes, Change{Path: path})
+			w.setFailed(path, nil)
+			continue
+		}
+		wp, err := weapon.New(path, w.cfg.CreateMask, w.cfg.Flag)
+		if err != nil {
+			report.Failed[path] = err
+			w.setFailed(path, err)
+			continue
+		}
+		changes = append(changes, Change{Path: path, Weapon: wp, From: moved[path]})
+		w.setFailed(path, nil)
+	}
+	report.SwapResult = w.reg.Swap(changes)
+
+	if report.Empty() && len(report.Failed) == 0 {
+		return report
+	}
+	for path, err := range report.Failed {
+		log.Warn().Err(err).Str("path", path).Msg("template failed to load, keeping previous version")
+	}
+	log.Info().
+		Strs("added", report.Added).
+		Strs("reloaded", report.Reloaded).
+		Any("renamed", report.Renamed).
+		Strs("removed", report.Removed).
+		Int("failed", len(report.Failed)).
+		Int("templates", w.reg.Len()).
+		Msg("templates synced")
+	if w.cfg.OnSync != nil {
+		w.cfg.OnSync(report)
+	}
+	return report
+}
+
+func (w *Watcher) setFailed(path string, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err == nil {
+		delete(w.failed, path)
+	} else {
+		w.failed[path] = err
+	}
+}
+
+// expand 把变化的路径展开为需要重新对账的模板图片路径（去重）。
+func (w *Watcher) expand(changed []string) []string {
+	set := make(map[string]struct{})
+	add := func(path string) {
+		if strings.EqualFold(filepath.Ext(path), w.cfg.Suffix) {
+			set[filepath.Clean(path)] = struct{}{}
+		}
+	}
+	loadedUnder := func(dir string) {
+		prefix := dir + string(filepath.Separator)
+		for _, p := range w.reg.Paths() {
+			if strings.HasPrefix(p, prefix) {
+				add(p)
+			}
+		}
+	}
+
+	for _, path := range changed {
+		path = filepath.Clean(path)
+		info, err := os.Stat(path)
+		switch {
+		case err == nil && info.IsDir():
+			// 新建或移入的目录：补上监视，里面的文件可能早于监视就已存在
+			if err := w.watchTree(path); err != nil {
+				log.Warn().Err(err).Str("dir", path).Msg("failed to watch template directory")
+			}
+			if files, err := utils.WalkDir(path, w.cfg.Depth, w.cfg.Suffix, w.cfg.IgnorePrefix); err == nil {
+				for _, f := range files {
+					add(f)
+				}
+			}
+			loadedUnder(path)
+
+		case filepath.Base(path) == weapon.MANIFEST_NAME:
+			dir := filepath.Dir(path)
+			if entries, err := os.ReadDir(dir); err == nil {
+				for _, e := range entries {
+					add(filepath.Join(dir, e.Name()))
+				}
+			}
+			loadedUnder(dir)
+
+		case strings.EqualFold(filepath.Ext(path), weapon.META_SUFFIX):
+			add(strings.TrimSuffix(path, filepath.Ext(path)) + w.cfg.Suffix)
+
+		default:
+			add(path)
+			// 被删除或移走的目录
+			if err != nil {
+				loadedUnder(path)
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(set))
+}
+
+// wanted 文件存在、不被忽略且在深度内。
+func (w *Watcher) wanted(path string) bool {
+	if w.cfg.IgnorePrefix != "" && strings.HasPrefix(filepath.Base(path), w.cfg.IgnorePrefix) {
+		return false
+	}
+	if d := w.depthOf(path); d < 1 || d > w.cfg.Depth {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// depthOf 相对 Dir 的层数，Dir 下的文件为 1，不在 Dir 内为 -1。
+func (w *Watcher) depthOf(path string) int {
+	rel, err := filepath.Rel(w.cfg.Dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return -1
+	}
+	if rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// watchTree 监视 dir 及其下深度内可能含有模板的子目录。
+func (w *Watcher) watchTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if w.depthOf(path) >= w.cfg.Depth {
+			return filepath.SkipDir
+		}
+		return w.fs.Add(path)
+	})
+}
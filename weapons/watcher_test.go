@@ -0,0 +1,137 @@
+package weapons
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+func newTestWatcher(t *testing.T, dir string, onSync func(SyncReport)) (*Registry, *Watcher) {
+	t.Helper()
+	reg := NewRegistry()
+	t.Cleanup(func() { reg.Close() })
+	w, err := NewWatcher(reg, WatcherConfig{
+		Dir:          dir,
+		Depth:        2,
+		Suffix:       ".png",
+		IgnorePrefix: "__",
+		Flag:         gocv.IMReadGrayScale,
+		Debounce:     20 * time.Millisecond,
+		OnSync:       onSync,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return reg, w
+}
+
+func TestWatcherSync(t *testing.T) {
+	dir := t.TempDir()
+	reg, w := newTestWatcher(t, dir, nil)
+
+	a := writeTemplate(t, dir, "{AR_10_--} A.png")
+	os.Mkdir(filepath.Join(dir, "smg"), 0o755)
+	b := writeTemplate(t, filepath.Join(dir, "smg"), "{SMG_9_==} B.png")
+	r := w.Rescan()
+	if len(r.Added) != 2 || reg.Len() != 2 {
+		t.Fatalf("rescan = %+v", r)
+	}
+	idA, idB := reg.IDByPath(a), reg.IDByPath(b)
+
+	// 原地修改：ID 不变
+	writeTemplate(t, dir, "{AR_10_--} A.png")
+	if r := w.Sync([]string{a}); len(r.Reloaded) != 1 || reg.IDByPath(a) != idA {
+		t.Fatalf("write = %+v", r)
+	}
+
+	// 重命名（删除 + 新建的同名模板）：ID 不变
+	a2 := filepath.Join(dir, "{AR_12_--} A.png")
+	os.Rename(a, a2)
+	r = w.Sync([]string{a, a2})
+	if r.Renamed[a2] != a || reg.IDByPath(a2) != idA {
+		t.Fatalf("rename = %+v", r)
+	}
+	if wp, _ := reg.Get(idA); wp.SpeedMain != 12 {
+		t.Fatalf("speed = %v", wp.SpeedMain)
+	}
+
+	// 附属元数据变化重新读取对应图片
+	os.WriteFile(filepath.Join(dir, "{AR_12_--} A.json"), []byte(`{"name": "A", "class": "AR", "speed_main": 7}`), 0o644)
+	w.Sync([]string{filepath.Join(dir, "{AR_12_--} A.json")})
+	if wp, _ := reg.Get(idA); wp.SpeedMain != 7 {
+		t.Fatalf("sidecar speed = %v", wp.SpeedMain)
+	}
+
+	// 加载失败：保留旧版本并记录错误
+	os.WriteFile(filepath.Join(dir, "{AR_12_--} A.json"), []byte(`{"name": "A", "class": "XYZ"}`), 0o644)
+	r = w.Sync([]string{filepath.Join(dir, "{AR_12_--} A.json")})
+	if r.Failed[a2] == nil || w.Failed()[a2] == nil {
+		t.Fatalf("failure not reported: %+v", r)
+	}
+	if wp, ok := reg.Get(idA); !ok || wp.SpeedMain != 7 {
+		t.Fatal("previous version dropped")
+	}
+	os.Remove(filepath.Join(dir, "{AR_12_--} A.json"))
+	w.Sync([]string{filepath.Join(dir, "{AR_12_--} A.json")})
+	if len(w.Failed()) != 0 {
+		t.Fatalf("failed = %v", w.Failed())
+	}
+
+	// 忽略前缀与删除目录
+	os.Rename(a2, filepath.Join(dir, "__A.png"))
+	os.RemoveAll(filepath.Join(dir, "smg"))
+	r = w.Sync([]string{a2, filepath.Join(dir, "__A.png"), filepath.Join(dir, "smg")})
+	if len(r.Removed) != 2 || reg.Len() != 0 {
+		t.Fatalf("remove = %+v", r)
+	}
+	if _, ok := reg.Get(idB); ok {
+		t.Fatal("template in removed directory still loaded")
+	}
+}
+
+func TestWatcherRun(t *testing.T) {
+	dir := t.TempDir()
+	synced := make(chan SyncReport, 8)
+	reg, w := newTestWatcher(t, dir, func(r SyncReport) { synced <- r })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	wait := func() SyncReport {
+		t.Helper()
+		select {
+		case r := <-synced:
+			return r
+		case <-time.After(5 * time.Second):
+			t.Fatal("no sync")
+			return SyncReport{}
+		}
+	}
+
+	// 新建子目录后立即写入的文件也能被发现；连续写入合并为一批
+	sub := filepath.Join(dir, "sub")
+	os.Mkdir(sub, 0o755)
+	for range 3 {
+		writeTemplate(t, sub, "{AR_10_--} A.png")
+	}
+	for reg.Len() == 0 {
+		wait()
+	}
+	id := reg.IDByName("A")
+
+	writeTemplate(t, sub, "{AR_10_--} A.png")
+	if r := wait(); len(r.Reloaded) != 1 || reg.IDByName("A") != id {
+		t.Fatalf("write = %+v", r)
+	}
+
+	os.Remove(filepath.Join(sub, "{AR_10_--} A.png"))
+	if r := wait(); len(r.Removed) != 1 || reg.Len() != 0 {
+		t.Fatalf("remove = %+v", r)
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"slices"
 
+	"github.com/Miuzarte/GoCVStreamer/logger"
 	"github.com/Miuzarte/GoCVStreamer/utils"
 	"github.com/Miuzarte/GoCVStreamer/weapon"
 	"gocv.io/x/gocv"
 )
 
+var log = logger.New("Weapons")
+
 type Weapons []*weapon.Weapon
 
 func (ws *Weapons) Append(path string, createMask bool, flag gocv.IMReadFlag) (err error) {
@@ -65,13 +68,30 @@ func (ws *Weapons) IndexByPath(path string) int {
 	})
 }
 
-func (ws *Weapons) IndexByName(name string) int {
+func (ws *Weapons) IndexByID(id weapon.ID) int {
 	return slices.IndexFunc(*ws, func(w *weapon.Weapon) bool {
+		return id == w.ID
+	})
+}
+
+// IndexByName 按名称查找，名称都不匹配时再按别名查找。
+func (ws *Weapons) IndexByName(name string) int {
+	if i := slices.IndexFunc(*ws, func(w *weapon.Weapon) bool {
 		return name == w.Name
+	}); i >= 0 {
+		return i
+	}
+	return slices.IndexFunc(*ws, func(w *weapon.Weapon) bool {
+		return w.HasName(name)
 	})
 }
 
 func (ws *Weapons) ReadFrom(dir string, depth int, suffix string, withoutPrefix string, createMask bool, flag gocv.IMReadFlag) error {
+	return ws.ReadFromEach(dir, depth, suffix, withoutPrefix, createMask, flag, nil)
+}
+
+// ReadFromEach 同 ReadFrom；onErr 非 nil 时单个文件加载失败交给 onErr 并继续，否则立即返回该错误。
+func (ws *Weapons) ReadFromEach(dir string, depth int, suffix string, withoutPrefix string, createMask bool, flag gocv.IMReadFlag, onErr func(path string, err error)) error {
 	depth = max(depth, 1)
 	paths, err := utils.WalkDir(dir, depth, suffix, withoutPrefix)
 	if err != nil {
@@ -86,26 +106,16 @@ func (ws *Weapons) ReadFrom(dir string, depth int, suffix string, withoutPrefix
 	for _, path := range paths {
 		err = ws.Append(path, createMask, flag)
 		if err != nil {
-			return err
+			if onErr == nil {
+				return err
+			}
+			onErr(path, err)
 		}
 	}
 
 	return nil
 }
 
-func (ws *Weapons) MinMaxIndex() (min, max int) {
-	var maxMinVal, maxMaxVal float32
-	for i, w := range *ws {
-		if w.Template.MinVal > maxMinVal {
-			min = i
-		}
-		if w.Template.MaxVal > maxMaxVal {
-			max = i
-		}
-	}
-	return
-}
-
 func (ws *Weapons) Close() (err error) {
 	for i := len(*ws) - 1; i >= 0; i-- {
 		err = (*ws)[i].Template.Close()